@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+	"github.com/hrand1005/training-notebook/tracing"
+)
+
+// Query limits for reading the audit log
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// New returns the handler for the audit resource. userDB is used to check
+// that the logged in user is an administrator.
+func New(db data.AuditDB, userDB data.UserDB) (*audit, error) {
+	return &audit{db: db, users: userDB}, nil
+}
+
+func (a *audit) RegisterHandlers(g *gin.RouterGroup) {
+	// the audit log spans every user, so only administrators may read it
+	adminGroup := g.Group("/admin")
+	adminGroup.Use(users.RequireAuthorization(), users.RequireAdmin(a.users), tracing.HandlerSpan())
+	adminGroup.GET("/audit", a.ReadAll)
+}
+
+// auditQuery defines the query parameters accepted when reading the audit log
+// swagger:parameters readAuditEvents
+type auditQuery struct {
+	// in: query
+	ActorID models.UserID `form:"actor-id" binding:"gte=0"`
+	// in: query
+	OwnerID models.UserID `form:"owner-id" binding:"gte=0"`
+	// in: query
+	Action string `form:"action" binding:"omitempty,oneof=create update delete"`
+	// in: query
+	TargetType string `form:"target-type" binding:"omitempty,oneof=set user"`
+	// in: query
+	TargetID int `form:"target-id" binding:"gte=0"`
+	// RFC 3339 timestamp
+	// in: query
+	Since time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	// RFC 3339 timestamp
+	// in: query
+	Until time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	// in: query
+	Limit int `form:"limit" binding:"gte=0,lte=1000"`
+	// in: query
+	Offset int `form:"offset" binding:"gte=0"`
+}
+
+// filter converts the query parameters to a filter on the audit log
+func (q *auditQuery) filter() models.AuditFilter {
+	limit := q.Limit
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+
+	return models.AuditFilter{
+		ActorID:    q.ActorID,
+		OwnerID:    q.OwnerID,
+		Action:     models.AuditAction(q.Action),
+		TargetType: models.AuditTarget(q.TargetType),
+		TargetID:   q.TargetID,
+		Since:      q.Since,
+		Until:      q.Until,
+		Limit:      limit,
+		Offset:     q.Offset,
+	}
+}
@@ -0,0 +1,40 @@
+// Package classification of Audit API
+//
+// Documentation for Audit API
+//
+//  Schemes: http
+//  BasePath: /api/v1
+//  Version: 1.0.0
+//
+//  Produces:
+//  - application/json
+//  - application/problem+json
+// swagger:meta
+package audit
+
+import (
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+type audit struct {
+	db    data.AuditDB
+	users data.UserDB
+}
+
+// returns audit events in the response
+// swagger:response auditEventsResponse
+type auditEventsResponse struct {
+	// A list of audit events, oldest first
+	// in: body
+	Body []models.AuditEvent
+}
+
+// returns an RFC 7807 problem describing the error
+// swagger:response errorResponse
+type errorResponse struct {
+	// Description of the error, with a machine-readable code
+	// in: body
+	Body problem.Problem
+}
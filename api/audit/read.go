@@ -0,0 +1,36 @@
+package audit
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/problem"
+)
+
+// swagger:route GET /admin/audit audit readAuditEvents
+// Query the audit log of every user. Requires an administrator.
+// responses:
+//  200: auditEventsResponse
+//  400: errorResponse
+//  401: errorResponse
+//  403: errorResponse
+//  500: errorResponse
+//  default: errorResponse
+
+// ReadAll is the handler for read requests on the audit resource. Events are
+// filtered by the query parameters, and paginated with limit and offset.
+func (a *audit) ReadAll(c *gin.Context) {
+	var q auditQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		problem.Abort(c, problem.FromBindingError(err))
+		return
+	}
+
+	events, err := a.db.Events(q.filter())
+	if err != nil {
+		problem.Internal(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, events)
+}
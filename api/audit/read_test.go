@@ -0,0 +1,215 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// TestReadAllAuditEvents tests the API layer's ReadAll method for the Audit resource.
+// The test suite mocks the AuditDB interface to test edge cases and error conditions.
+func TestReadAllAuditEvents(t *testing.T) {
+	timestamp := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		query      string
+		db         *data.MockAuditDB
+		wantFilter models.AuditFilter
+		wantCode   int
+		wantResp   bytes.Buffer
+	}{
+		{
+			name:  "Valid query returns StatusOK",
+			query: "?actor-id=2&action=update&target-type=set&since=2022-06-01T00:00:00Z&limit=10&offset=5",
+			db: &data.MockAuditDB{
+				EventsStub: func(f models.AuditFilter) ([]*models.AuditEvent, error) {
+					return []*models.AuditEvent{
+						{
+							ID:         7,
+							ActorID:    2,
+							OwnerID:    1,
+							Action:     models.AuditUpdate,
+							TargetType: models.AuditTargetSet,
+							TargetID:   3,
+							Before:     []byte(`{"set-id":3,"user-id":1,"movement":"Squat","volume":5,"intensity":80}`),
+							After:      []byte(`{"set-id":3,"user-id":1,"movement":"Squat","volume":5,"intensity":85}`),
+							Timestamp:  timestamp,
+						},
+					}, nil
+				},
+			},
+			wantFilter: models.AuditFilter{
+				ActorID:    2,
+				Action:     models.AuditUpdate,
+				TargetType: models.AuditTargetSet,
+				Since:      time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC),
+				Limit:      10,
+				Offset:     5,
+			},
+			wantCode: http.StatusOK,
+			wantResp: *bytes.NewBufferString(`[
+				{
+					"event-id": 7,
+					"actor-id": 2,
+					"owner-id": 1,
+					"action": "update",
+					"target-type": "set",
+					"target-id": 3,
+					"before": {
+						"set-id": 3,
+						"user-id": 1,
+						"movement": "Squat",
+						"volume": 5,
+						"intensity": 80
+					},
+					"after": {
+						"set-id": 3,
+						"user-id": 1,
+						"movement": "Squat",
+						"volume": 5,
+						"intensity": 85
+					},
+					"timestamp": "2022-06-01T12:00:00Z"
+				}
+			]`),
+		},
+		{
+			name:  "Empty query uses default limit",
+			query: "",
+			db: &data.MockAuditDB{
+				EventsStub: func(f models.AuditFilter) ([]*models.AuditEvent, error) {
+					return []*models.AuditEvent{}, nil
+				},
+			},
+			wantFilter: models.AuditFilter{
+				Limit: DefaultLimit,
+			},
+			wantCode: http.StatusOK,
+			wantResp: *bytes.NewBufferString(`[]`),
+		},
+		{
+			name:     "Invalid action returns StatusBadRequest",
+			query:    "?action=rename",
+			db:       &data.MockAuditDB{},
+			wantCode: http.StatusBadRequest,
+			wantResp: *bytes.NewBufferString(`{
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "'Action' field must be one of create update delete.",
+				"instance": "/admin/audit",
+				"code": "validation-failed",
+				"errors": [
+					{"field": "Action", "code": "oneof", "message": "'Action' field must be one of create update delete."}
+				]
+			}`),
+		},
+		{
+			name:     "Limit above maximum returns StatusBadRequest",
+			query:    "?limit=5000",
+			db:       &data.MockAuditDB{},
+			wantCode: http.StatusBadRequest,
+			wantResp: *bytes.NewBufferString(`{
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "'Limit' field must be no more than 1000.",
+				"instance": "/admin/audit",
+				"code": "validation-failed",
+				"errors": [
+					{"field": "Limit", "code": "lte", "message": "'Limit' field must be no more than 1000."}
+				]
+			}`),
+		},
+		{
+			name:  "Invalid db query returns InternalServerError",
+			query: "?target-id=3",
+			db: &data.MockAuditDB{
+				EventsStub: func(f models.AuditFilter) ([]*models.AuditEvent, error) {
+					return nil, fmt.Errorf("Expected error")
+				},
+			},
+			wantFilter: models.AuditFilter{
+				TargetID: 3,
+				Limit:    DefaultLimit,
+			},
+			wantCode: http.StatusInternalServerError,
+			wantResp: *bytes.NewBufferString(`{
+				"type": "urn:training-notebook:problem:internal-error",
+				"title": "Internal server error",
+				"status": 500,
+				"detail": "an internal error occurred, quote the request id when reporting it",
+				"instance": "/admin/audit",
+				"code": "internal-error"
+			}`),
+		},
+	}
+
+	for _, v := range tests {
+		// capture the filter used to query the audit db
+		var gotFilter models.AuditFilter
+		if v.db.EventsStub != nil {
+			stub := v.db.EventsStub
+			v.db.EventsStub = func(f models.AuditFilter) ([]*models.AuditEvent, error) {
+				gotFilter = f
+				return stub(f)
+			}
+		}
+
+		a, err := New(v.db, &data.MockUserDB{})
+		if err != nil {
+			t.Fail()
+		}
+
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/admin/audit"+v.query, nil)
+
+		// execute ReadAll on test context
+		a.ReadAll(c)
+
+		// check response code
+		if v.wantCode != w.Code {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v\n", v.name, v.wantCode, w.Code)
+		}
+
+		if !gotFilter.Since.Equal(v.wantFilter.Since) {
+			t.Fatalf("%s: Wanted since: %v\nGot since: %v\n", v.name, v.wantFilter.Since, gotFilter.Since)
+		}
+		gotFilter.Since, v.wantFilter.Since = time.Time{}, time.Time{}
+		if gotFilter != v.wantFilter {
+			t.Fatalf("%s: Wanted filter: %+v\nGot filter: %+v\n", v.name, v.wantFilter, gotFilter)
+		}
+
+		// check response body
+		if equal, _ := JSONBytesEqual(v.wantResp.Bytes(), w.Body.Bytes()); !equal {
+			t.Fatalf("%s: Wanted body: %v\nGot body: %v\n", v.name, v.wantResp.String(), w.Body.String())
+		}
+	}
+}
+
+// JSONBytesEqual compares the JSON in two byte slices.
+func JSONBytesEqual(a, b []byte) (bool, error) {
+	var j, j2 interface{}
+	if err := json.Unmarshal(a, &j); err != nil {
+		log.Printf("Problem unmarshalling json a: %v\nError: %v\n", a, err)
+		return false, err
+	}
+	if err := json.Unmarshal(b, &j2); err != nil {
+		log.Printf("Problem unmarshalling json b: %v\nError: %v\n", b, err)
+		return false, err
+	}
+	return reflect.DeepEqual(j2, j), nil
+}
@@ -0,0 +1,216 @@
+// Package cors lets browsers call the api from the origins it is configured
+// to allow, such as a frontend dev server on another port, by answering
+// preflight requests and setting the Access-Control-* response headers.
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/problem"
+)
+
+// AnyOrigin allows requests from every origin. It cannot be combined with
+// credentials.
+const AnyOrigin = "*"
+
+// ErrOriginNotAllowed is the detail of preflight responses to origins that
+// are not allowed
+const ErrOriginNotAllowed = "requests from origin %q are not allowed"
+
+// allowedMethods are the methods the api serves
+var allowedMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// allowedHeaders are the request headers read by the api, beyond those
+// browsers always allow
+var allowedHeaders = []string{
+	"Authorization",
+	"Content-Type",
+	"Idempotency-Key",
+	"If-Match",
+	"If-None-Match",
+	"X-CSRF-Token",
+	"X-Request-ID",
+}
+
+// exposedHeaders are the response headers scripts on allowed origins may read
+var exposedHeaders = []string{
+	"ETag",
+	"Idempotent-Replayed",
+	"Location",
+	"RateLimit-Limit",
+	"RateLimit-Policy",
+	"RateLimit-Remaining",
+	"RateLimit-Reset",
+	"Retry-After",
+	"X-CSRF-Token",
+	"X-Request-ID",
+}
+
+// Options configure which cross-origin requests are allowed
+type Options struct {
+	// AllowedOrigins are origins such as http://localhost:3000, or AnyOrigin
+	AllowedOrigins []string
+	// AllowCredentials lets browsers send cookies with cross-origin requests
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache preflight responses
+	MaxAge time.Duration
+}
+
+// Validate returns an error if the options cannot be applied.
+func (o Options) Validate() error {
+	for _, origin := range o.AllowedOrigins {
+		if origin == AnyOrigin {
+			if o.AllowCredentials {
+				return fmt.Errorf("credentials cannot be allowed from any origin")
+			}
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			return fmt.Errorf("origin must be a scheme and host, e.g. https://example.com, got %q", origin)
+		}
+	}
+	if o.MaxAge < 0 {
+		return fmt.Errorf("max age must not be negative")
+	}
+	return nil
+}
+
+// AllowsCredentials reports whether browsers may send cookies with requests
+// from origin, which requires AllowCredentials and origin to be listed in
+// AllowedOrigins.
+func (o Options) AllowsCredentials(origin string) bool {
+	if !o.AllowCredentials {
+		return false
+	}
+	for _, allowed := range o.AllowedOrigins {
+		if normalize(allowed) == normalize(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy applies Options to requests. The options may be replaced while the
+// server is running.
+type Policy struct {
+	current atomic.Pointer[compiled]
+}
+
+// compiled are options prepared for matching the origins of requests
+type compiled struct {
+	opts      Options
+	origins   map[string]bool
+	anyOrigin bool
+	maxAge    string
+}
+
+// New returns a policy applying opts.
+func New(opts Options) *Policy {
+	p := &Policy{}
+	p.SetOptions(opts)
+	return p
+}
+
+// SetOptions replaces the options of the policy. Requests already being
+// served keep the options they started with.
+func (p *Policy) SetOptions(opts Options) {
+	c := &compiled{
+		opts:    opts,
+		origins: make(map[string]bool, len(opts.AllowedOrigins)),
+		maxAge:  strconv.Itoa(int(opts.MaxAge.Seconds())),
+	}
+	for _, origin := range opts.AllowedOrigins {
+		if origin == AnyOrigin {
+			c.anyOrigin = true
+			continue
+		}
+		c.origins[normalize(origin)] = true
+	}
+	p.current.Store(c)
+}
+
+// AllowsCredentials reports whether the current options let browsers send
+// cookies with requests from origin.
+func (p *Policy) AllowsCredentials(origin string) bool {
+	return p.current.Load().opts.AllowsCredentials(origin)
+}
+
+// Middleware sets the Access-Control-* headers on responses to allowed
+// origins, and answers preflight requests, with StatusNoContent if the
+// origin is allowed and StatusForbidden otherwise. It must be added to the
+// router rather than a group, since preflight requests match no route.
+// Requests from origins that are not allowed are served without the headers,
+// so browsers withhold the response from the calling script. Requests are
+// served untouched while no origins are allowed.
+func (p *Policy) Middleware() gin.HandlerFunc {
+	methods := strings.Join(allowedMethods, ", ")
+	headers := strings.Join(allowedHeaders, ", ")
+	exposed := strings.Join(exposedHeaders, ", ")
+
+	return func(c *gin.Context) {
+		current := p.current.Load()
+		origin := c.GetHeader("Origin")
+		if origin == "" || len(current.opts.AllowedOrigins) == 0 {
+			c.Next()
+			return
+		}
+
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		c.Writer.Header().Add("Vary", "Origin")
+		if preflight {
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if !current.anyOrigin && !current.origins[normalize(origin)] {
+			if preflight {
+				problem.Abort(c, problem.Newf(problem.CodeOriginNotAllowed, ErrOriginNotAllowed, origin))
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if current.anyOrigin && !current.opts.AllowCredentials {
+			c.Header("Access-Control-Allow-Origin", AnyOrigin)
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		if current.opts.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			c.Header("Access-Control-Expose-Headers", exposed)
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Methods", methods)
+		c.Header("Access-Control-Allow-Headers", headers)
+		if current.opts.MaxAge > 0 {
+			c.Header("Access-Control-Max-Age", current.maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// normalize returns origin in the form browsers send it.
+func normalize(origin string) string {
+	return strings.ToLower(strings.TrimSuffix(origin, "/"))
+}
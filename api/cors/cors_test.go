@@ -0,0 +1,203 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestMiddleware checks that allowed origins receive CORS headers, and that
+// preflight requests are answered without reaching the api.
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        Options
+		method      string
+		origin      string
+		preflight   bool
+		wantCode    int
+		wantOrigin  string
+		wantHeaders map[string]string
+	}{
+		{
+			name:       "Allowed origin is reflected with credentials",
+			opts:       Options{AllowedOrigins: []string{"http://localhost:3000"}, AllowCredentials: true},
+			method:     http.MethodGet,
+			origin:     "http://localhost:3000",
+			wantCode:   http.StatusOK,
+			wantOrigin: "http://localhost:3000",
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Credentials": "true",
+				"Vary":                             "Origin",
+			},
+		},
+		{
+			name:     "Other origin receives no headers",
+			opts:     Options{AllowedOrigins: []string{"http://localhost:3000"}},
+			method:   http.MethodGet,
+			origin:   "https://evil.example",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Request without origin receives no headers",
+			opts:     Options{AllowedOrigins: []string{"http://localhost:3000"}},
+			method:   http.MethodGet,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:       "Any origin is allowed without credentials",
+			opts:       Options{AllowedOrigins: []string{AnyOrigin}},
+			method:     http.MethodGet,
+			origin:     "https://example.com",
+			wantCode:   http.StatusOK,
+			wantOrigin: AnyOrigin,
+		},
+		{
+			name:       "Preflight is answered with cache age",
+			opts:       Options{AllowedOrigins: []string{"http://localhost:3000/"}, MaxAge: 10 * time.Minute},
+			method:     http.MethodOptions,
+			origin:     "http://localhost:3000",
+			preflight:  true,
+			wantCode:   http.StatusNoContent,
+			wantOrigin: "http://localhost:3000",
+			wantHeaders: map[string]string{
+				"Access-Control-Max-Age": "600",
+			},
+		},
+		{
+			name:      "Preflight from other origin is forbidden",
+			opts:      Options{AllowedOrigins: []string{"http://localhost:3000"}},
+			method:    http.MethodOptions,
+			origin:    "https://evil.example",
+			preflight: true,
+			wantCode:  http.StatusForbidden,
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, v := range tests {
+		router := gin.New()
+		router.Use(New(v.opts).Middleware())
+		router.GET("/sets", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		req, _ := http.NewRequest(v.method, "/sets", nil)
+		if v.origin != "" {
+			req.Header.Set("Origin", v.origin)
+		}
+		if v.preflight {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != v.wantCode {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v", v.name, v.wantCode, w.Code)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != v.wantOrigin {
+			t.Fatalf("%s: Wanted allowed origin %q, got %q", v.name, v.wantOrigin, got)
+		}
+		for h, want := range v.wantHeaders {
+			if got := w.Header().Get(h); got != want {
+				t.Fatalf("%s: Wanted %v %q, got %q", v.name, h, want, got)
+			}
+		}
+		if v.preflight && v.wantCode == http.StatusNoContent && w.Header().Get("Access-Control-Allow-Headers") == "" {
+			t.Fatalf("%s: Wanted allowed headers in preflight response", v.name)
+		}
+	}
+}
+
+// TestSetOptions checks that replaced options apply to later requests, and
+// that requests are served untouched while no origins are allowed.
+func TestSetOptions(t *testing.T) {
+	const origin = "http://localhost:3000"
+
+	gin.SetMode(gin.TestMode)
+	p := New(Options{})
+	router := gin.New()
+	router.Use(p.Middleware())
+	router.GET("/sets", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name            string
+		opts            Options
+		wantOrigin      string
+		wantVary        bool
+		wantCredentials bool
+	}{
+		{name: "No origins leaves requests untouched", opts: Options{}},
+		{name: "Added origin is allowed", opts: Options{AllowedOrigins: []string{origin}, AllowCredentials: true}, wantOrigin: origin, wantVary: true, wantCredentials: true},
+		{name: "Removed origin is not allowed", opts: Options{AllowedOrigins: []string{"https://example.com"}, AllowCredentials: true}, wantVary: true},
+	}
+
+	for _, v := range tests {
+		p.SetOptions(v.opts)
+
+		req, _ := http.NewRequest(http.MethodGet, "/sets", nil)
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != v.wantOrigin {
+			t.Fatalf("%s: Wanted allowed origin %q, got %q", v.name, v.wantOrigin, got)
+		}
+		if got := w.Header().Get("Vary") != ""; got != v.wantVary {
+			t.Fatalf("%s: Wanted Vary header: %v\nGot Vary header: %v", v.name, v.wantVary, got)
+		}
+		if got := p.AllowsCredentials(origin); got != v.wantCredentials {
+			t.Fatalf("%s: Wanted allowed credentials: %v\nGot allowed credentials: %v", v.name, v.wantCredentials, got)
+		}
+	}
+}
+
+// TestOptionsValidate checks that origins are well formed, and that
+// credentials are never allowed from any origin.
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{name: "Origins are valid", opts: Options{AllowedOrigins: []string{"http://localhost:3000", "https://example.com"}, AllowCredentials: true}},
+		{name: "Any origin is valid", opts: Options{AllowedOrigins: []string{AnyOrigin}}},
+		{name: "Any origin with credentials is invalid", opts: Options{AllowedOrigins: []string{AnyOrigin}, AllowCredentials: true}, wantErr: true},
+		{name: "Origin without scheme is invalid", opts: Options{AllowedOrigins: []string{"localhost:3000"}}, wantErr: true},
+		{name: "Origin with path is invalid", opts: Options{AllowedOrigins: []string{"https://example.com/app"}}, wantErr: true},
+		{name: "Negative max age is invalid", opts: Options{MaxAge: -time.Second}, wantErr: true},
+	}
+
+	for _, v := range tests {
+		if err := v.opts.Validate(); (err != nil) != v.wantErr {
+			t.Fatalf("%s: Wanted error: %v, got %v", v.name, v.wantErr, err)
+		}
+	}
+}
+
+// TestAllowsCredentials checks that credentials are only allowed from listed
+// origins, and only if AllowCredentials is set.
+func TestAllowsCredentials(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Options
+		origin string
+		want   bool
+	}{
+		{name: "Listed origin is allowed", opts: Options{AllowedOrigins: []string{"http://localhost:3000/"}, AllowCredentials: true}, origin: "http://LOCALHOST:3000", want: true},
+		{name: "Other origin is not allowed", opts: Options{AllowedOrigins: []string{"http://localhost:3000"}, AllowCredentials: true}, origin: "https://example.com"},
+		{name: "Listed origin without credentials is not allowed", opts: Options{AllowedOrigins: []string{"http://localhost:3000"}}, origin: "http://localhost:3000"},
+		{name: "Any origin is not allowed", opts: Options{AllowedOrigins: []string{AnyOrigin}}, origin: "https://example.com"},
+	}
+
+	for _, v := range tests {
+		if got := v.opts.AllowsCredentials(v.origin); got != v.want {
+			t.Fatalf("%s: Wanted allowed: %v\nGot allowed: %v", v.name, v.want, got)
+		}
+	}
+}
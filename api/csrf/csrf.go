@@ -0,0 +1,102 @@
+// Package csrf protects cookie authenticated requests from cross-site request
+// forgery with double-submit tokens. Every client is issued a random token in
+// a cookie readable by scripts on the frontend's origin, and mutating
+// requests authenticated by the login cookie must echo the token in a header,
+// which other sites can neither read nor set.
+package csrf
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/api/users"
+)
+
+const (
+	// CookieName is the cookie carrying the client's token
+	CookieName = "csrf_token"
+	// Header is the HTTP header mutating requests echo the token in. It is
+	// also set on every response, for clients on other origins, which cannot
+	// read the cookie.
+	Header = "X-CSRF-Token"
+	// CookieMaxAge outlives the login cookie, so that the token does not
+	// expire during a login
+	CookieMaxAge = 24 * 3600
+	// tokenLength is the length of tokens in bytes, before hex encoding
+	tokenLength = 32
+)
+
+// ErrMissingToken is the detail of responses to requests without a valid token
+const ErrMissingToken = "this request requires the " + Header + " header to match the " + CookieName + " cookie"
+
+// Middleware issues a token to clients without one, and rejects POST, PUT,
+// PATCH and DELETE requests authenticated by the login cookie with
+// StatusForbidden unless they carry the token in the X-CSRF-Token header.
+// Requests with a bearer token are exempt, since browsers never send one
+// implicitly. The token cookie is marked secure if secure is set, and has the
+// same SameSite attribute as the login cookie.
+func Middleware(secure bool, sameSite http.SameSite) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, err := c.Cookie(CookieName)
+		if err != nil || !validToken(token) {
+			token = newToken()
+			c.SetSameSite(sameSite)
+			// scripts must read the token to echo it, so it is not http only
+			c.SetCookie(CookieName, token, CookieMaxAge, "/", "", secure, false)
+		}
+		c.Header(Header, token)
+
+		if !isMutating(c.Request.Method) || !cookieAuthenticated(c.Request) {
+			c.Next()
+			return
+		}
+
+		sent := c.GetHeader(Header)
+		if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+			problem.Abort(c, problem.New(problem.CodeCSRFTokenInvalid, ErrMissingToken))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// cookieAuthenticated reports whether the request would be authenticated by
+// the login cookie, rather than by a bearer token.
+func cookieAuthenticated(r *http.Request) bool {
+	if _, ok := users.BearerToken(r); ok {
+		return false
+	}
+	cookie, err := r.Cookie(users.LoginCookieName)
+	return err == nil && cookie.Value != ""
+}
+
+// isMutating reports whether requests with method may change state.
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// validToken reports whether token could have been issued by newToken, so
+// that arbitrary cookie values are never echoed in headers.
+func validToken(token string) bool {
+	b, err := hex.DecodeString(token)
+	return err == nil && len(b) == tokenLength
+}
+
+// newToken generates a random token.
+func newToken() string {
+	b := make([]byte, tokenLength)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
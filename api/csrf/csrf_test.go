@@ -0,0 +1,126 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/users"
+)
+
+// TestMiddleware checks that mutating requests authenticated by the login
+// cookie must echo the token, and that other requests are exempt.
+func TestMiddleware(t *testing.T) {
+	token := newToken()
+
+	tests := []struct {
+		name        string
+		method      string
+		loginCookie bool
+		tokenCookie string
+		header      string
+		bearer      bool
+		wantCode    int
+	}{
+		{
+			name:        "Matching token is allowed",
+			method:      http.MethodPost,
+			loginCookie: true,
+			tokenCookie: token,
+			header:      token,
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "Missing header is rejected",
+			method:      http.MethodDelete,
+			loginCookie: true,
+			tokenCookie: token,
+			wantCode:    http.StatusForbidden,
+		},
+		{
+			name:        "Mismatched header is rejected",
+			method:      http.MethodPatch,
+			loginCookie: true,
+			tokenCookie: token,
+			header:      newToken(),
+			wantCode:    http.StatusForbidden,
+		},
+		{
+			name:        "Missing token cookie is rejected",
+			method:      http.MethodPut,
+			loginCookie: true,
+			header:      token,
+			wantCode:    http.StatusForbidden,
+		},
+		{
+			name:        "Safe method is allowed",
+			method:      http.MethodGet,
+			loginCookie: true,
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:     "Request without login cookie is allowed",
+			method:   http.MethodPost,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:        "Bearer token is exempt",
+			method:      http.MethodPost,
+			loginCookie: true,
+			bearer:      true,
+			wantCode:    http.StatusOK,
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, v := range tests {
+		router := gin.New()
+		router.Use(Middleware(false, http.SameSiteLaxMode))
+		router.Any("/sets", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		req, _ := http.NewRequest(v.method, "/sets", nil)
+		if v.loginCookie {
+			req.AddCookie(&http.Cookie{Name: users.LoginCookieName, Value: "login"})
+		}
+		if v.tokenCookie != "" {
+			req.AddCookie(&http.Cookie{Name: CookieName, Value: v.tokenCookie})
+		}
+		if v.header != "" {
+			req.Header.Set(Header, v.header)
+		}
+		if v.bearer {
+			req.Header.Set("Authorization", users.BearerScheme+" login")
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != v.wantCode {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v\nBody: %v", v.name, v.wantCode, w.Code, w.Body.String())
+		}
+
+		// the token is issued to clients without one, and always echoed
+		issued := ""
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == CookieName {
+				issued = cookie.Value
+				if cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+					t.Fatalf("%s: Wanted script readable lax cookie, got %+v", v.name, cookie)
+				}
+			}
+		}
+		if (issued != "") != (v.tokenCookie == "") {
+			t.Fatalf("%s: Wanted token issued only without token cookie, got %q", v.name, issued)
+		}
+		want := v.tokenCookie
+		if want == "" {
+			want = issued
+		}
+		if got := w.Header().Get(Header); got != want {
+			t.Fatalf("%s: Wanted %v header %q, got %q", v.name, Header, want, got)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Package classification of Events API
+//
+// Documentation for Events API, streaming changes to sets as server-sent
+// events
+//
+//  Schemes: http
+//  BasePath: /api/v1
+//  Version: 1.0.0
+//
+//  Produces:
+//  - text/event-stream
+//  - application/problem+json
+// swagger:meta
+package events
+
+import (
+	"time"
+
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/data"
+)
+
+type events struct {
+	hub       *Hub
+	users     data.UserDB
+	heartbeat time.Duration
+}
+
+// streams changes to sets as server-sent events. Each event has an id, a
+// type of set.created, set.updated or set.deleted, and the set as its data.
+// A reset event tells the client that it missed events and should reload.
+// swagger:response eventStream
+type eventStream struct {
+	// in: body
+	Body string
+}
+
+// swagger:parameters streamEvents
+type lastEventIDParam struct {
+	// The id of the last event received, to resume after it
+	// in: header
+	// name: Last-Event-ID
+	LastEventID string
+}
+
+// returns an RFC 7807 problem describing the error
+// swagger:response errorResponse
+type errorResponse struct {
+	// Description of the error, with a machine-readable code
+	// in: body
+	Body problem.Problem
+}
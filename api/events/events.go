@@ -0,0 +1,48 @@
+package events
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/data"
+)
+
+// DefaultHeartbeat is how often a comment is sent on idle streams, so that
+// proxies and clients do not time them out.
+const DefaultHeartbeat = 15 * time.Second
+
+// New returns the handler streaming the events published to hub. userDB is
+// used to check whether the logged in user is an administrator, who may see
+// changes to the sets of every user.
+func New(hub *Hub, userDB data.UserDB) (*events, error) {
+	return &events{hub: hub, users: userDB, heartbeat: DefaultHeartbeat}, nil
+}
+
+func (e *events) RegisterHandlers(g *gin.RouterGroup) {
+	g.GET("/events", users.RequireAuthorization(), e.Stream)
+}
+
+// controllerKey is the context key of the response controller set by
+// WithResponseController
+type controllerKey struct{}
+
+// WithResponseController makes the response controller of each request
+// served by h available to the event stream, which needs it to extend the
+// server's write timeout while streaming. Gin's response writer does not
+// expose it.
+func WithResponseController(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), controllerKey{}, http.NewResponseController(w))
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// responseController returns the response controller set by
+// WithResponseController, if any.
+func responseController(ctx context.Context) (*http.ResponseController, bool) {
+	rc, ok := ctx.Value(controllerKey{}).(*http.ResponseController)
+	return rc, ok
+}
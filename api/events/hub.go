@@ -0,0 +1,214 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// Types of events published for changes to sets
+const (
+	SetCreated = "set.created"
+	SetUpdated = "set.updated"
+	SetDeleted = "set.deleted"
+)
+
+// Types of events published for changes to users
+const (
+	UserCreated = "user.created"
+	UserUpdated = "user.updated"
+	UserDeleted = "user.deleted"
+)
+
+// Sizes used when none are given
+const (
+	DefaultLogSize    = 1000
+	DefaultBufferSize = 64
+)
+
+// Event is a change to a set or a user. Set holds the state of the set
+// after the change, or before it for deletions. Changes to users hold the
+// user in User instead, without sensitive data, and are owned by the user.
+type Event struct {
+	ID      uint64
+	Type    string
+	OwnerID models.UserID
+	Set     models.Set
+	User    *models.User
+	Time    time.Time
+}
+
+// userEventTypes are the types of events for the audit actions on users
+var userEventTypes = map[models.AuditAction]string{
+	models.AuditCreate: UserCreated,
+	models.AuditUpdate: UserUpdated,
+	models.AuditDelete: UserDeleted,
+}
+
+// UserEvent returns the event for a change to u recorded as action, which
+// holds u without sensitive data.
+func UserEvent(action models.AuditAction, u *models.User) Event {
+	return Event{Type: userEventTypes[action], OwnerID: u.ID, User: u.Sanitized()}
+}
+
+// IsSet reports whether e is a change to a set, rather than to a user.
+func (e Event) IsSet() bool {
+	return e.User == nil
+}
+
+// Hub fans events out to subscribers, and keeps the latest events in a
+// bounded log so that subscribers can resume after reconnecting. A Hub is
+// safe for concurrent use.
+type Hub struct {
+	mu     sync.Mutex
+	log    []Event
+	next   int
+	full   bool
+	lastID uint64
+	subs   map[*Subscription]struct{}
+	buffer int
+	closed bool
+}
+
+// Subscription receives the events published to a hub that match its
+// filter. Events is closed when the subscription ends, either because the
+// hub was closed or because the subscriber fell too far behind.
+type Subscription struct {
+	// Start is the id of the last event published before the subscription
+	// started
+	Start  uint64
+	Events <-chan Event
+	events chan Event
+	filter func(Event) bool
+	// Dropped is set if the subscription ended because its buffer filled
+	// up. It may only be read once Events is closed.
+	Dropped bool
+}
+
+// NewHub returns a hub keeping the latest logSize events, and buffering up
+// to bufferSize events for each subscriber.
+func NewHub(logSize, bufferSize int) *Hub {
+	if logSize <= 0 {
+		logSize = DefaultLogSize
+	}
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+
+	return &Hub{
+		log: make([]Event, logSize),
+		// ids continue from the start time, so that ids handed out before a
+		// restart are older than anything in the new log, rather than
+		// colliding with it
+		lastID: uint64(time.Now().UnixMicro()),
+		subs:   make(map[*Subscription]struct{}),
+		buffer: bufferSize,
+	}
+}
+
+// Publish assigns e the next id, appends it to the log and delivers it to
+// every matching subscriber. Subscribers whose buffer is full are dropped
+// instead of blocking the publisher; they can resume from the log.
+func (h *Hub) Publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	h.lastID++
+	e.ID = h.lastID
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	h.log[h.next] = e
+	h.next = (h.next + 1) % len(h.log)
+	if h.next == 0 {
+		h.full = true
+	}
+
+	for sub := range h.subs {
+		if !sub.filter(e) {
+			continue
+		}
+		select {
+		case sub.events <- e:
+		default:
+			sub.Dropped = true
+			h.remove(sub)
+		}
+	}
+}
+
+// Subscribe starts a subscription to events matching filter. Events logged
+// after lastID are returned to be replayed before those received by the
+// subscription, with no gap between them. If lastID is zero nothing is
+// replayed. ok is false if events after lastID are no longer in the log, or
+// lastID was never handed out, in which case the subscriber has missed
+// events and should reload its state.
+func (h *Hub) Subscribe(lastID uint64, filter func(Event) bool) (sub *Subscription, missed []Event, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	events := make(chan Event, h.buffer)
+	sub = &Subscription{Start: h.lastID, Events: events, events: events, filter: filter}
+	if h.closed {
+		close(events)
+		return sub, nil, true
+	}
+	h.subs[sub] = struct{}{}
+
+	if lastID == 0 {
+		return sub, nil, true
+	}
+	// ids are consecutive, so the log covers everything after the id before
+	// its oldest event
+	logged := h.logged()
+	if lastID > h.lastID || lastID < h.lastID-uint64(len(logged)) {
+		return sub, nil, false
+	}
+	for _, e := range logged {
+		if e.ID > lastID && filter(e) {
+			missed = append(missed, e)
+		}
+	}
+	return sub, missed, true
+}
+
+// Unsubscribe ends sub, if it has not already ended.
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[sub]; ok {
+		h.remove(sub)
+	}
+}
+
+// Close ends every subscription, and discards events published afterwards.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for sub := range h.subs {
+		h.remove(sub)
+	}
+}
+
+// remove ends sub. The caller must hold h.mu.
+func (h *Hub) remove(sub *Subscription) {
+	delete(h.subs, sub)
+	close(sub.events)
+}
+
+// logged returns the events in the log, oldest first. The caller must hold
+// h.mu.
+func (h *Hub) logged() []Event {
+	if !h.full {
+		return h.log[:h.next]
+	}
+	return append(append([]Event{}, h.log[h.next:]...), h.log[:h.next]...)
+}
@@ -0,0 +1,159 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// TestSubscribe checks that subscribers resuming from an event are replayed
+// the matching events logged since, unless the log no longer covers them.
+func TestSubscribe(t *testing.T) {
+	tests := []struct {
+		name        string
+		logSize     int
+		published   int
+		resumeAfter int
+		unknownID   bool
+		owner       models.UserID
+		wantMissed  []models.SetID
+		wantOK      bool
+	}{
+		{
+			name:        "New subscriber is not replayed anything",
+			logSize:     5,
+			published:   3,
+			resumeAfter: -1,
+			wantOK:      true,
+		},
+		{
+			name:        "Events after the last one received are replayed",
+			logSize:     5,
+			published:   4,
+			resumeAfter: 2,
+			wantMissed:  []models.SetID{3, 4},
+			wantOK:      true,
+		},
+		{
+			name:        "Subscriber that received every event is replayed nothing",
+			logSize:     5,
+			published:   4,
+			resumeAfter: 4,
+			wantOK:      true,
+		},
+		{
+			name:        "Subscriber is only replayed events matching its filter",
+			logSize:     5,
+			published:   4,
+			resumeAfter: 1,
+			owner:       2,
+			wantMissed:  []models.SetID{2, 4},
+			wantOK:      true,
+		},
+		{
+			name:        "Subscriber is replayed the whole log if it covers the gap",
+			logSize:     3,
+			published:   4,
+			resumeAfter: 1,
+			wantMissed:  []models.SetID{2, 3, 4},
+			wantOK:      true,
+		},
+		{
+			name:        "Events evicted from the log cannot be replayed",
+			logSize:     3,
+			published:   5,
+			resumeAfter: 1,
+			wantOK:      false,
+		},
+		{
+			name:      "Event ids that were never handed out cannot be resumed from",
+			logSize:   3,
+			published: 2,
+			unknownID: true,
+			wantOK:    false,
+		},
+	}
+
+	for _, v := range tests {
+		hub := NewHub(v.logSize, 0)
+		var ids []uint64
+		for i := 1; i <= v.published; i++ {
+			// sets with even ids belong to user 2, and odd ones to user 1
+			hub.Publish(Event{Type: SetCreated, OwnerID: models.UserID(2 - i%2), Set: models.Set{ID: models.SetID(i)}})
+			ids = append(ids, hub.lastID)
+		}
+
+		var lastID uint64
+		switch {
+		case v.unknownID:
+			lastID = hub.lastID + 10
+		case v.resumeAfter > 0:
+			lastID = ids[v.resumeAfter-1]
+		}
+		sub, missed, ok := hub.Subscribe(lastID, func(e Event) bool {
+			return v.owner == 0 || e.OwnerID == v.owner
+		})
+
+		if ok != v.wantOK {
+			t.Fatalf("%s: Wanted ok: %v\nGot ok: %v", v.name, v.wantOK, ok)
+		}
+		var gotMissed []models.SetID
+		for _, e := range missed {
+			gotMissed = append(gotMissed, e.Set.ID)
+		}
+		if len(gotMissed) != len(v.wantMissed) {
+			t.Fatalf("%s: Wanted missed sets: %v\nGot missed sets: %v", v.name, v.wantMissed, gotMissed)
+		}
+		for i := range gotMissed {
+			if gotMissed[i] != v.wantMissed[i] {
+				t.Fatalf("%s: Wanted missed sets: %v\nGot missed sets: %v", v.name, v.wantMissed, gotMissed)
+			}
+		}
+		if sub.Start != hub.lastID {
+			t.Fatalf("%s: Wanted subscription to start at: %v\nGot start: %v", v.name, hub.lastID, sub.Start)
+		}
+	}
+}
+
+// TestPublish checks that events are delivered to matching subscribers, and
+// that subscribers which fall behind are dropped rather than blocking.
+func TestPublish(t *testing.T) {
+	hub := NewHub(10, 2)
+	own, _, _ := hub.Subscribe(0, func(e Event) bool { return e.OwnerID == 1 })
+	slow, _, _ := hub.Subscribe(0, func(e Event) bool { return true })
+
+	for i := 1; i <= 3; i++ {
+		hub.Publish(Event{Type: SetCreated, OwnerID: models.UserID(i), Set: models.Set{ID: models.SetID(i)}})
+	}
+
+	e := <-own.Events
+	if e.Set.ID != 1 || e.ID == 0 || e.Time.IsZero() {
+		t.Fatalf("Wanted event for set 1 with an id and time\nGot event: %+v", e)
+	}
+	if len(own.Events) != 0 {
+		t.Fatalf("Wanted only events for user 1\nGot %v more events", len(own.Events))
+	}
+
+	// the third event overflowed the buffer of the slow subscriber
+	var got int
+	for range slow.Events {
+		got++
+	}
+	if got != 2 || !slow.Dropped {
+		t.Fatalf("Wanted the slow subscriber to be dropped after 2 events\nGot %v events, dropped: %v", got, slow.Dropped)
+	}
+
+	hub.Close()
+	if _, open := <-own.Events; open {
+		t.Fatalf("Wanted subscriptions to end when the hub is closed")
+	}
+	if own.Dropped {
+		t.Fatalf("Wanted subscriptions ended by closing the hub not to be dropped")
+	}
+
+	// subscriptions to a closed hub end immediately
+	late, _, _ := hub.Subscribe(0, func(e Event) bool { return true })
+	if _, open := <-late.Events; open {
+		t.Fatalf("Wanted subscriptions to a closed hub to end")
+	}
+}
@@ -0,0 +1,168 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/api/requestid"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/api/version"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/tracing"
+)
+
+// ErrInvalidLastEventID is the detail of the problem returned for a
+// Last-Event-ID header that was not sent by this api.
+var ErrInvalidLastEventID = "Last-Event-ID must be the id of an event"
+
+// ResetEvent is the type of the event sent to clients that have missed
+// events, and should reload the sets they show.
+const ResetEvent = "reset"
+
+// writeWait bounds how long a single write to a stream may block, so that
+// streams to clients that stopped reading are closed.
+const writeWait = 10 * time.Second
+
+// swagger:route GET /events events streamEvents
+// Stream changes to the sets of the logged in user, or of every user for
+// administrators. Send Last-Event-ID to resume after the given event.
+// produces:
+//  - text/event-stream
+// responses:
+//  200: eventStream
+//  400: errorResponse
+//  401: errorResponse
+//  500: errorResponse
+//  default: errorResponse
+
+// Stream is the handler streaming changes to sets as server-sent events, in
+// the representation of the api version serving the request. Events missed
+// since Last-Event-ID are replayed first. The stream ends when the client
+// disconnects, falls too far behind, or the server shuts down; clients
+// reconnect with the id of the last event they received.
+func (e *events) Stream(c *gin.Context) {
+	userID, err := users.UserIDFromContext(c)
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeUnauthenticated, "you must be logged in to perform this action"))
+		return
+	}
+
+	var lastID uint64
+	if h := c.GetHeader("Last-Event-ID"); h != "" {
+		lastID, err = strconv.ParseUint(h, 10, 64)
+		if err != nil {
+			problem.Abort(c, problem.New(problem.CodeInvalidParameter, ErrInvalidLastEventID))
+			return
+		}
+	}
+
+	user, err := data.TraceUserDB(tracing.Context(c), e.users).UserByID(userID)
+	if err != nil {
+		if err == data.ErrNotFound {
+			problem.Abort(c, problem.New(problem.CodeUnauthenticated, "you must be logged in to perform this action"))
+			return
+		}
+		problem.Internal(c, err)
+		return
+	}
+
+	// administrators see every change, other users only changes to their
+	// own sets. Changes to users are only delivered to webhooks.
+	sub, missed, ok := e.hub.Subscribe(lastID, func(ev Event) bool {
+		return ev.IsSet() && (user.Admin || ev.OwnerID == userID)
+	})
+	defer e.hub.Unsubscribe(sub)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	// ask proxies not to buffer the stream
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	s := &stream{c: c}
+	if !ok {
+		s.write(sub.Start, ResetEvent, "{}")
+	}
+	for _, ev := range missed {
+		s.event(ev)
+	}
+	s.flush()
+
+	heartbeat := time.NewTicker(e.heartbeat)
+	defer heartbeat.Stop()
+	for s.err == nil {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case ev, open := <-sub.Events:
+			if !open {
+				if sub.Dropped {
+					slog.Warn("event stream fell behind", "request_id", requestid.FromContext(c), "user_id", userID)
+				}
+				return
+			}
+			s.event(ev)
+		case <-heartbeat.C:
+			s.comment("heartbeat")
+		}
+		s.flush()
+	}
+}
+
+// stream writes server-sent events to the response. Once a write fails,
+// later writes are skipped and the error is kept in err.
+type stream struct {
+	c   *gin.Context
+	err error
+}
+
+// event writes ev, with its set as data.
+func (s *stream) event(ev Event) {
+	var body interface{} = &ev.Set
+	if version.FromContext(s.c) != version.V1 {
+		body = ev.Set.V2()
+	}
+	doc, err := json.Marshal(body)
+	if err != nil {
+		s.err = err
+		return
+	}
+	s.write(ev.ID, ev.Type, string(doc))
+}
+
+// write writes an event with the given id, type and single line of data.
+func (s *stream) write(id uint64, typ, data string) {
+	s.send(fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", id, typ, data))
+}
+
+// comment writes a comment, which clients ignore.
+func (s *stream) comment(text string) {
+	s.send(": " + text + "\n\n")
+}
+
+func (s *stream) send(msg string) {
+	if s.err != nil {
+		return
+	}
+	// the server's write timeout covers the whole response, so each write
+	// gets its own deadline instead
+	if rc, ok := responseController(s.c.Request.Context()); ok {
+		if err := rc.SetWriteDeadline(time.Now().Add(writeWait)); err != nil && err != http.ErrNotSupported {
+			s.err = err
+			return
+		}
+	}
+	_, s.err = s.c.Writer.WriteString(msg)
+}
+
+func (s *stream) flush() {
+	if s.err == nil {
+		s.c.Writer.Flush()
+	}
+}
@@ -0,0 +1,276 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/api/version"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// sseEvent is an event read from a stream
+type sseEvent struct {
+	id    string
+	typ   string
+	data  string
+	setID models.SetID
+}
+
+// TestStream tests the API layer's Stream method for the Events resource.
+// Events are published to a hub before and after the stream is opened.
+func TestStream(t *testing.T) {
+	tests := []struct {
+		name        string
+		admin       bool
+		version     version.Version
+		before      []Event
+		lastEventID func(ids []uint64) string
+		after       []Event
+		wantCode    int
+		wantReset   bool
+		wantSets    []models.SetID
+	}{
+		{
+			name:     "Changes to the user's sets are streamed",
+			version:  version.V1,
+			after:    []Event{created(1, 3), created(2, 4), updated(1, 3)},
+			wantCode: http.StatusOK,
+			wantSets: []models.SetID{1, 1},
+		},
+		{
+			name:     "Administrators are streamed changes to every set",
+			admin:    true,
+			version:  version.V1,
+			after:    []Event{created(1, 3), created(2, 4), deleted(1, 3)},
+			wantCode: http.StatusOK,
+			wantSets: []models.SetID{1, 2, 1},
+		},
+		{
+			name:     "Changes to users are not streamed",
+			admin:    true,
+			version:  version.V1,
+			after:    []Event{UserEvent(models.AuditUpdate, &models.User{ID: 3}), created(1, 3)},
+			wantCode: http.StatusOK,
+			wantSets: []models.SetID{1},
+		},
+		{
+			name:    "Events since Last-Event-ID are replayed first",
+			version: version.V1,
+			before:  []Event{created(1, 3), created(2, 3), created(3, 4)},
+			lastEventID: func(ids []uint64) string {
+				return strconv.FormatUint(ids[0], 10)
+			},
+			after:    []Event{deleted(1, 3)},
+			wantCode: http.StatusOK,
+			wantSets: []models.SetID{2, 1},
+		},
+		{
+			name:    "Events evicted from the log are reset",
+			version: version.V1,
+			before:  []Event{created(1, 3), created(2, 3), created(3, 3), created(4, 3), created(5, 3), created(6, 3)},
+			lastEventID: func(ids []uint64) string {
+				return strconv.FormatUint(ids[0], 10)
+			},
+			after:     []Event{deleted(5, 3)},
+			wantCode:  http.StatusOK,
+			wantReset: true,
+			wantSets:  []models.SetID{5},
+		},
+		{
+			name:     "Sets are in the representation of the version",
+			version:  version.V2,
+			after:    []Event{created(1, 3)},
+			wantCode: http.StatusOK,
+			wantSets: []models.SetID{1},
+		},
+		{
+			name:    "Invalid Last-Event-ID returns StatusBadRequest",
+			version: version.V1,
+			lastEventID: func(ids []uint64) string {
+				return "latest"
+			},
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, v := range tests {
+		hub := NewHub(4, 0)
+		var ids []uint64
+		for _, e := range v.before {
+			hub.Publish(e)
+			ids = append(ids, hub.lastID)
+		}
+
+		server := testServer(t, hub, v.version, &models.User{ID: 3, Admin: v.admin})
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/events", nil)
+		if v.lastEventID != nil {
+			req.Header.Set("Last-Event-ID", v.lastEventID(ids))
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: failed to open stream: %v", v.name, err)
+		}
+		if resp.StatusCode != v.wantCode {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v", v.name, v.wantCode, resp.StatusCode)
+		}
+		if v.wantCode != http.StatusOK {
+			resp.Body.Close()
+			continue
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("%s: Wanted content type: text/event-stream\nGot content type: %v", v.name, ct)
+		}
+
+		r := bufio.NewReader(resp.Body)
+		if v.wantReset {
+			e := readEvent(t, r)
+			if e.typ != ResetEvent || e.id != strconv.FormatUint(hub.lastID, 10) {
+				t.Fatalf("%s: Wanted a reset to event %v\nGot event: %+v", v.name, hub.lastID, e)
+			}
+		}
+		// the stream is subscribed once its headers are sent
+		for _, e := range v.after {
+			hub.Publish(e)
+		}
+
+		for _, want := range v.wantSets {
+			e := readEvent(t, r)
+			if e.setID != want {
+				t.Fatalf("%s: Wanted event for set %v\nGot event: %+v", v.name, want, e)
+			}
+			wantField := `"set-id"`
+			if v.version == version.V2 {
+				wantField = `"id"`
+			}
+			if !strings.Contains(e.data, wantField) {
+				t.Fatalf("%s: Wanted data with %v\nGot data: %v", v.name, wantField, e.data)
+			}
+		}
+
+		// closing the hub ends the stream
+		hub.Close()
+		if rest, err := io.ReadAll(r); err != nil || len(rest) != 0 {
+			t.Fatalf("%s: Wanted the stream to end\nGot: %q, error: %v", v.name, rest, err)
+		}
+		resp.Body.Close()
+	}
+}
+
+// TestStreamHeartbeat checks that idle streams are sent heartbeat comments.
+func TestStreamHeartbeat(t *testing.T) {
+	hub := NewHub(0, 0)
+	server := testServer(t, hub, version.V1, &models.User{ID: 3}, func(e *events) {
+		e.heartbeat = 10 * time.Millisecond
+	})
+
+	resp, err := http.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || line != ": heartbeat\n" {
+		t.Fatalf("Wanted a heartbeat\nGot: %q, error: %v", line, err)
+	}
+}
+
+// testServer serves the event stream of hub to the given user, in the given
+// version, until the test ends.
+func testServer(t *testing.T, hub *Hub, v version.Version, user *models.User, opts ...func(*events)) *httptest.Server {
+	t.Helper()
+
+	e, err := New(hub, &data.MockUserDB{
+		UserByIDStub: func(id models.UserID) (*models.User, error) {
+			if id != user.ID {
+				return nil, data.ErrNotFound
+			}
+			return user, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create events resource: %v", err)
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	router := gin.New()
+	router.GET("/events", func(c *gin.Context) {
+		c.Set(users.UserIDFromContextKey, user.ID)
+		c.Set(version.ContextKey, v)
+		e.Stream(c)
+	})
+	server := httptest.NewServer(WithResponseController(router))
+	t.Cleanup(server.Close)
+	// streams only end once the hub is closed, which must happen before the
+	// server waits for them
+	t.Cleanup(hub.Close)
+	return server
+}
+
+// readEvent reads the next event from r, skipping comments.
+func readEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+
+	var e sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && e.typ != "":
+			if e.typ != ResetEvent {
+				var s struct {
+					V1 models.SetID `json:"set-id"`
+					V2 models.SetID `json:"id"`
+				}
+				if err := json.Unmarshal([]byte(e.data), &s); err != nil {
+					t.Fatalf("failed to decode event data %q: %v", e.data, err)
+				}
+				e.setID = s.V1 + s.V2
+			}
+			return e
+		case strings.HasPrefix(line, "id: "):
+			e.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			e.typ = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			e.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func created(id models.SetID, owner models.UserID) Event {
+	return setEvent(SetCreated, id, owner)
+}
+
+func updated(id models.SetID, owner models.UserID) Event {
+	return setEvent(SetUpdated, id, owner)
+}
+
+func deleted(id models.SetID, owner models.UserID) Event {
+	return setEvent(SetDeleted, id, owner)
+}
+
+func setEvent(typ string, id models.SetID, owner models.UserID) Event {
+	return Event{
+		Type:    typ,
+		OwnerID: owner,
+		Set:     models.Set{ID: id, UID: owner, Movement: fmt.Sprintf("Squat %v", id), Volume: 5, Intensity: 80},
+	}
+}
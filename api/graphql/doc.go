@@ -0,0 +1,51 @@
+// Package classification of GraphQL API
+//
+// Documentation for GraphQL API, serving users, their sets and stats
+// derived from the sets in a single query
+//
+//  Schemes: http
+//  BasePath: /api/v1
+//  Version: 1.0.0
+//
+//  Consumes:
+//  - application/json
+//
+//  Produces:
+//  - application/json
+//  - application/problem+json
+// swagger:meta
+package graphql
+
+import (
+	gql "github.com/graphql-go/graphql"
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/data"
+)
+
+type graphQL struct {
+	schema gql.Schema
+	sets   data.SetDB
+	users  data.UserDB
+}
+
+// returns the result of a GraphQL query
+// swagger:response graphqlResponse
+type graphqlResponse struct {
+	// in: body
+	Body Response
+}
+
+// returns an RFC 7807 problem describing the error
+// swagger:response errorResponse
+type errorResponse struct {
+	// Description of the error, with a machine-readable code
+	// in: body
+	Body problem.Problem
+}
+
+// swagger:parameters graphqlQuery
+type queryParameter struct {
+	// in: body
+	// required: true
+	Body Request
+}
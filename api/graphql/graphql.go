@@ -0,0 +1,155 @@
+package graphql
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	gql "github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/location"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/api/requestid"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/tracing"
+)
+
+// New returns the handler for the graphql resource, resolving queries from
+// the set and user dbs.
+func New(setDB data.SetDB, userDB data.UserDB) (*graphQL, error) {
+	schema, err := newSchema()
+	if err != nil {
+		return nil, err
+	}
+	return &graphQL{schema: schema, sets: setDB, users: userDB}, nil
+}
+
+func (g *graphQL) RegisterHandlers(rg *gin.RouterGroup) {
+	// queries are resolved for the logged in user
+	graphGroup := rg.Group("/graphql")
+	graphGroup.Use(users.RequireAuthorization(), tracing.HandlerSpan())
+	graphGroup.POST("", g.Query)
+}
+
+// Request is a GraphQL query, sent as the body of a POST request
+// swagger:model GraphQLRequest
+type Request struct {
+	// The GraphQL document
+	// required: true
+	Query string `json:"query" binding:"required"`
+	// The operation of the document to execute, if it holds more than one
+	OperationName string `json:"operationName,omitempty"`
+	// The values of the variables of the operation
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response is the result of a GraphQL query. Data is missing if the query
+// could not be executed, and partial if resolving some of its fields failed.
+// swagger:model GraphQLResponse
+type Response struct {
+	// The fields selected by the query
+	// x-nullable: true
+	Data interface{} `json:"data,omitempty"`
+	// The errors that occurred, each with a code in its extensions
+	Errors []gqlerrors.FormattedError `json:"errors,omitempty"`
+}
+
+// swagger:route POST /graphql graphql graphqlQuery
+// Query users, sets and their stats with GraphQL.
+// responses:
+//  200: graphqlResponse
+//  400: errorResponse
+//  401: errorResponse
+//  500: errorResponse
+//  default: errorResponse
+
+// Query is the handler for GraphQL queries. Queries that are malformed or
+// exceed the depth and complexity limits are answered with their errors,
+// without being executed. Errors resolving fields are reported alongside
+// the data that could be resolved, so every well formed request is answered
+// with StatusOK.
+func (g *graphQL) Query(c *gin.Context) {
+	userID, err := users.UserIDFromContext(c)
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeUnauthenticated, "you must be logged in to perform this action"))
+		return
+	}
+
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Abort(c, problem.FromBindingError(err))
+		return
+	}
+
+	ctx := tracing.Context(c)
+	s := newSession(userID, requestid.FromContext(c), data.TraceSetDB(ctx, g.sets), data.TraceUserDB(ctx, g.users))
+	c.IndentedJSON(http.StatusOK, g.execute(context.WithValue(ctx, sessionKey{}, s), &req))
+}
+
+// execute parses, checks and executes a query.
+func (g *graphQL) execute(ctx context.Context, req *Request) *Response {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &Response{Errors: withCode(gqlerrors.FormatErrors(err), problem.CodeMalformedRequest)}
+	}
+
+	if err := checkLimits(doc, req.OperationName, req.Variables); err != nil {
+		return &Response{Errors: []gqlerrors.FormattedError{{
+			Message:    err.Error(),
+			Locations:  []location.SourceLocation{},
+			Extensions: err.Extensions(),
+		}}}
+	}
+
+	if result := gql.ValidateDocument(&g.schema, doc, nil); !result.IsValid {
+		return &Response{Errors: withCode(result.Errors, problem.CodeValidationFailed)}
+	}
+
+	result := gql.Execute(gql.ExecuteParams{
+		Schema:        g.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	})
+	return &Response{Data: result.Data, Errors: withCode(result.Errors, problem.CodeInternal)}
+}
+
+// withCode sets the code in the extensions of errs, to the code of the
+// queryError causing each error, or to code if there is none. The executor
+// drops the extensions of errors returned by thunks, so they are recovered
+// from the cause.
+func withCode(errs []gqlerrors.FormattedError, code problem.Code) []gqlerrors.FormattedError {
+	for i := range errs {
+		if errs[i].Extensions != nil {
+			continue
+		}
+		errs[i].Extensions = map[string]interface{}{"code": code}
+		if qe := causingQueryError(errs[i]); qe != nil {
+			errs[i].Extensions = qe.Extensions()
+		}
+	}
+	return errs
+}
+
+// causingQueryError returns the queryError at the root of err, or nil.
+func causingQueryError(err error) *queryError {
+	for err != nil {
+		switch e := err.(type) {
+		case *queryError:
+			return e
+		case gqlerrors.FormattedError:
+			err = e.OriginalError()
+		case *gqlerrors.Error:
+			err = e.OriginalError
+		default:
+			return nil
+		}
+	}
+	return nil
+}
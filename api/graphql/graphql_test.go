@@ -0,0 +1,312 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+var testUsers = []*models.User{
+	{ID: 1, Name: "Hubie"},
+	{ID: 2, Name: "Dewey", Admin: true},
+	{ID: 3, Name: "Louie"},
+}
+
+var testSets = []*models.Set{
+	{ID: 1, UID: 1, Movement: "Squat", Volume: 5, Intensity: 80, Version: 1},
+	{ID: 2, UID: 1, Movement: "Bench", Volume: 8, Intensity: 70, Version: 2},
+	{ID: 3, UID: 1, Movement: "Squat", Volume: 3, Intensity: 90, Version: 1},
+	{ID: 4, UID: 3, Movement: "Curl", Volume: 12, Intensity: 50, Version: 1},
+}
+
+// testDBs returns mock dbs serving testUsers and testSets, counting the calls
+// made to them.
+func testDBs(setCalls, userCalls *int) (*data.MockSetDB, *data.MockUserDB) {
+	setDB := &data.MockSetDB{
+		SetsByUserIDsStub: func(ids []models.UserID) ([]*models.Set, error) {
+			*setCalls++
+			var sets []*models.Set
+			for _, s := range testSets {
+				for _, id := range ids {
+					if s.UID == id {
+						sets = append(sets, s)
+					}
+				}
+			}
+			return sets, nil
+		},
+	}
+	userDB := &data.MockUserDB{
+		UsersStub: func() ([]*models.User, error) {
+			*userCalls++
+			return testUsers, nil
+		},
+		UsersByIDsStub: func(ids []models.UserID) ([]*models.User, error) {
+			*userCalls++
+			var found []*models.User
+			for _, u := range testUsers {
+				for _, id := range ids {
+					if u.ID == id {
+						found = append(found, u)
+					}
+				}
+			}
+			return found, nil
+		},
+	}
+	return setDB, userDB
+}
+
+// TestQuery tests the API layer's Query method for the GraphQL resource,
+// checking the data and error codes of each query, and that the database
+// calls made to resolve it are batched.
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		name          string
+		viewer        models.UserID
+		body          string
+		setDBErr      error
+		wantCode      int
+		wantData      string
+		wantErrors    []string
+		wantSetCalls  int
+		wantUserCalls int
+	}{
+		{
+			name:     "Sets and their owners are loaded in one call each",
+			viewer:   1,
+			body:     `{"query": "{ sets(first: 2) { totalCount nodes { id movement owner { name } } pageInfo { endCursor hasNextPage } } }"}`,
+			wantCode: http.StatusOK,
+			wantData: fmt.Sprintf(`{"sets": {
+				"totalCount": 3,
+				"nodes": [
+					{"id": 1, "movement": "Squat", "owner": {"name": "Hubie"}},
+					{"id": 2, "movement": "Bench", "owner": {"name": "Hubie"}}
+				],
+				"pageInfo": {"endCursor": %q, "hasNextPage": true}
+			}}`, encodeCursor("set", 2)),
+			wantSetCalls:  1,
+			wantUserCalls: 1,
+		},
+		{
+			name:     "Sets are filtered and paginated after a cursor",
+			viewer:   1,
+			body:     fmt.Sprintf(`{"query": "query($after: String) { sets(filter: {movement: \"squat\", minIntensity: 75}, after: $after) { totalCount nodes { id intensity version } pageInfo { hasNextPage } } }", "variables": {"after": %q}}`, encodeCursor("set", 1)),
+			wantCode: http.StatusOK,
+			wantData: `{"sets": {
+				"totalCount": 2,
+				"nodes": [{"id": 3, "intensity": 90, "version": 1}],
+				"pageInfo": {"hasNextPage": false}
+			}}`,
+			wantSetCalls: 1,
+		},
+		{
+			name:     "Stats are derived from the sets of the logged in user",
+			viewer:   1,
+			body:     `{"query": "{ stats { setCount totalVolume averageIntensity maxIntensity movements { movement setCount maxIntensity } } }"}`,
+			wantCode: http.StatusOK,
+			wantData: `{"stats": {
+				"setCount": 3,
+				"totalVolume": 16,
+				"averageIntensity": 80,
+				"maxIntensity": 90,
+				"movements": [
+					{"movement": "Bench", "setCount": 1, "maxIntensity": 70},
+					{"movement": "Squat", "setCount": 2, "maxIntensity": 90}
+				]
+			}}`,
+			wantSetCalls: 1,
+		},
+		{
+			name:     "Sets and stats of every user are loaded in one call",
+			viewer:   2,
+			body:     `{"query": "{ users { totalCount nodes { name sets { totalCount } stats { setCount } } } }"}`,
+			wantCode: http.StatusOK,
+			wantData: `{"users": {
+				"totalCount": 3,
+				"nodes": [
+					{"name": "Hubie", "sets": {"totalCount": 3}, "stats": {"setCount": 3}},
+					{"name": "Dewey", "sets": {"totalCount": 0}, "stats": {"setCount": 0}},
+					{"name": "Louie", "sets": {"totalCount": 1}, "stats": {"setCount": 1}}
+				]
+			}}`,
+			wantSetCalls:  1,
+			wantUserCalls: 2,
+		},
+		{
+			name:     "Administrators may read other users",
+			viewer:   2,
+			body:     `{"query": "{ user(id: 3) { id name } missing: user(id: 9) { id } }"}`,
+			wantCode: http.StatusOK,
+			wantData: `{"user": {"id": 3, "name": "Louie"}, "missing": null}`,
+			// the viewer and both users are loaded together
+			wantUserCalls: 1,
+		},
+		{
+			name:          "Users may read themselves",
+			viewer:        1,
+			body:          `{"query": "{ me { id name admin } user(id: 1) { name } }"}`,
+			wantCode:      http.StatusOK,
+			wantData:      `{"me": {"id": 1, "name": "Hubie", "admin": false}, "user": {"name": "Hubie"}}`,
+			wantUserCalls: 1,
+		},
+		{
+			name:          "Users may not read other users",
+			viewer:        1,
+			body:          `{"query": "{ user(id: 3) { name } }"}`,
+			wantCode:      http.StatusOK,
+			wantData:      `{"user": null}`,
+			wantErrors:    []string{"forbidden"},
+			wantUserCalls: 1,
+		},
+		{
+			name:          "Users may not list users",
+			viewer:        1,
+			body:          `{"query": "{ users { nodes { name } } }"}`,
+			wantCode:      http.StatusOK,
+			wantErrors:    []string{"forbidden"},
+			wantUserCalls: 1,
+		},
+		{
+			name:       "Page size above maximum is rejected",
+			viewer:     1,
+			body:       `{"query": "{ sets(first: 500) { totalCount } }"}`,
+			wantCode:   http.StatusOK,
+			wantErrors: []string{"invalid-parameter"},
+			// the sets are loaded before the arguments are checked
+			wantSetCalls: 1,
+		},
+		{
+			name:       "Query deeper than the maximum depth is not executed",
+			viewer:     1,
+			body:       `{"query": "{ me { sets { nodes { owner { sets { nodes { owner { sets { nodes { owner { sets { nodes { id } } } } } } } } } } } } }"}`,
+			wantCode:   http.StatusOK,
+			wantErrors: []string{CodeTooDeep},
+		},
+		{
+			name:       "Query more complex than the maximum complexity is not executed",
+			viewer:     2,
+			body:       `{"query": "{ users(first: 100) { nodes { sets(first: 100) { nodes { id movement } } } } }"}`,
+			wantCode:   http.StatusOK,
+			wantErrors: []string{CodeTooComplex},
+		},
+		{
+			name:         "Database errors are hidden",
+			viewer:       1,
+			body:         `{"query": "{ sets { totalCount } }"}`,
+			setDBErr:     fmt.Errorf("Expected error"),
+			wantCode:     http.StatusOK,
+			wantErrors:   []string{"internal-error"},
+			wantSetCalls: 1,
+		},
+		{
+			name:       "Invalid query is not executed",
+			viewer:     1,
+			body:       `{"query": "{ sets { weight } }"}`,
+			wantCode:   http.StatusOK,
+			wantErrors: []string{"validation-failed"},
+		},
+		{
+			name:       "Malformed query is not executed",
+			viewer:     1,
+			body:       `{"query": "{ sets { "}`,
+			wantCode:   http.StatusOK,
+			wantErrors: []string{"malformed-request"},
+		},
+		{
+			name:     "Missing query returns StatusBadRequest",
+			viewer:   1,
+			body:     `{"variables": {}}`,
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, v := range tests {
+		var setCalls, userCalls int
+		setDB, userDB := testDBs(&setCalls, &userCalls)
+		if v.setDBErr != nil {
+			setDB.SetsByUserIDsStub = func([]models.UserID) ([]*models.Set, error) {
+				setCalls++
+				return nil, v.setDBErr
+			}
+		}
+
+		g, err := New(setDB, userDB)
+		if err != nil {
+			t.Fatalf("%s: failed to create resource: %v", v.name, err)
+		}
+
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/graphql", bytes.NewBufferString(v.body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Set(users.UserIDFromContextKey, v.viewer)
+
+		g.Query(c)
+
+		if v.wantCode != w.Code {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v\nBody: %v", v.name, v.wantCode, w.Code, w.Body.String())
+		}
+		if v.wantCode != http.StatusOK {
+			continue
+		}
+
+		var resp struct {
+			Data   json.RawMessage `json:"data"`
+			Errors []struct {
+				Message    string            `json:"message"`
+				Extensions map[string]string `json:"extensions"`
+			} `json:"errors"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", v.name, err)
+		}
+
+		if v.wantData == "" && len(resp.Data) > 0 && string(resp.Data) != "null" {
+			t.Fatalf("%s: Wanted no data\nGot data: %s", v.name, resp.Data)
+		}
+		if v.wantData != "" {
+			if equal, _ := JSONBytesEqual([]byte(v.wantData), resp.Data); !equal {
+				t.Fatalf("%s: Wanted data: %v\nGot data: %s", v.name, v.wantData, resp.Data)
+			}
+		}
+
+		gotErrors := make([]string, 0, len(resp.Errors))
+		for _, e := range resp.Errors {
+			gotErrors = append(gotErrors, e.Extensions["code"])
+		}
+		if len(gotErrors) != len(v.wantErrors) || (len(gotErrors) > 0 && !reflect.DeepEqual(gotErrors, v.wantErrors)) {
+			t.Fatalf("%s: Wanted error codes: %v\nGot errors: %+v", v.name, v.wantErrors, resp.Errors)
+		}
+
+		if setCalls != v.wantSetCalls || userCalls != v.wantUserCalls {
+			t.Fatalf("%s: Wanted %v set and %v user db calls\nGot %v set and %v user db calls",
+				v.name, v.wantSetCalls, v.wantUserCalls, setCalls, userCalls)
+		}
+	}
+}
+
+// JSONBytesEqual compares the JSON in two byte slices.
+func JSONBytesEqual(a, b []byte) (bool, error) {
+	var j, j2 interface{}
+	if err := json.Unmarshal(a, &j); err != nil {
+		log.Printf("Problem unmarshalling json a: %v\nError: %v\n", a, err)
+		return false, err
+	}
+	if err := json.Unmarshal(b, &j2); err != nil {
+		log.Printf("Problem unmarshalling json b: %v\nError: %v\n", b, err)
+		return false, err
+	}
+	return reflect.DeepEqual(j2, j), nil
+}
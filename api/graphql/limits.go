@@ -0,0 +1,139 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// Limits on the cost of a query, checked before it is executed
+const (
+	// MaxDepth is the deepest nesting of fields a query may select
+	MaxDepth = 10
+	// MaxComplexity is the highest complexity a query may have. Every field
+	// costs one, and the fields selected on a paginated connection cost as
+	// much as if they were selected on each item of a full page.
+	MaxComplexity = 2000
+)
+
+// Error codes reported in the extensions of query errors, in addition to the
+// problem codes
+const (
+	CodeTooDeep    = "query-too-deep"
+	CodeTooComplex = "query-too-complex"
+)
+
+// cost walks the selections of an operation, tracking the depth and
+// complexity of the fields selected.
+type cost struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	// visiting guards against fragments spreading themselves, which are
+	// rejected by validation but may be walked before it
+	visiting map[string]bool
+}
+
+// checkLimits returns an error if the operation exceeds MaxDepth or
+// MaxComplexity.
+func checkLimits(doc *ast.Document, operationName string, variables map[string]interface{}) *queryError {
+	depth, complexity := measure(doc, operationName, variables)
+	if depth > MaxDepth {
+		return &queryError{code: CodeTooDeep, message: fmt.Sprintf("query has depth %v, exceeding the maximum depth of %v", depth, MaxDepth)}
+	}
+	if complexity > MaxComplexity {
+		return &queryError{code: CodeTooComplex, message: fmt.Sprintf("query has complexity %v, exceeding the maximum complexity of %v", complexity, MaxComplexity)}
+	}
+	return nil
+}
+
+// measure returns the depth and complexity of the operation named
+// operationName, or of the first operation if none is named. Introspection
+// fields are not counted, since they are cheap to resolve and clients need
+// them to discover the schema.
+func measure(doc *ast.Document, operationName string, variables map[string]interface{}) (depth, complexity int) {
+	c := &cost{
+		fragments: make(map[string]*ast.FragmentDefinition),
+		variables: variables,
+		visiting:  make(map[string]bool),
+	}
+
+	var op *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.FragmentDefinition:
+			c.fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if op == nil || (def.Name != nil && def.Name.Value == operationName) {
+				op = def
+			}
+		}
+	}
+	if op == nil {
+		return 0, 0
+	}
+	return c.selections(op.SelectionSet)
+}
+
+// selections returns the depth and complexity of a selection set.
+func (c *cost) selections(set *ast.SelectionSet) (depth, complexity int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	for _, sel := range set.Selections {
+		var d, n int
+		switch sel := sel.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(sel.Name.Value, "__") {
+				continue
+			}
+			d, n = c.selections(sel.SelectionSet)
+			d, n = d+1, 1+c.multiplier(sel)*n
+		case *ast.InlineFragment:
+			d, n = c.selections(sel.SelectionSet)
+		case *ast.FragmentSpread:
+			name := sel.Name.Value
+			if c.visiting[name] || c.fragments[name] == nil {
+				continue
+			}
+			c.visiting[name] = true
+			d, n = c.selections(c.fragments[name].SelectionSet)
+			c.visiting[name] = false
+		}
+
+		if d > depth {
+			depth = d
+		}
+		complexity += n
+	}
+	return depth, complexity
+}
+
+// multiplier returns the number of items a field may return, which is the
+// page size of paginated connections, and one for other fields.
+func (c *cost) multiplier(f *ast.Field) int {
+	if !paginated[f.Name.Value] {
+		return 1
+	}
+
+	for _, arg := range f.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil && n >= 0 && n <= MaxPageSize {
+				return n
+			}
+		case *ast.Variable:
+			if n, ok := c.variables[v.Name.Value].(float64); ok && n >= 0 && n <= MaxPageSize {
+				return int(n)
+			}
+		}
+		// values that are invalid are rejected when the field is resolved
+		return MaxPageSize
+	}
+	return DefaultPageSize
+}
@@ -0,0 +1,113 @@
+package graphql
+
+import (
+	"testing"
+
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// TestCheckLimits checks the depth and complexity counted for queries, and
+// that queries exceeding the limits are rejected with their code.
+func TestCheckLimits(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		operationName  string
+		variables      map[string]interface{}
+		wantDepth      int
+		wantComplexity int
+		wantCode       string
+	}{
+		{
+			name:           "Fields cost one each",
+			query:          `{ me { id name } }`,
+			wantDepth:      2,
+			wantComplexity: 3,
+		},
+		{
+			name:           "Connections cost a default page of their selections",
+			query:          `{ sets { nodes { id } } }`,
+			wantDepth:      3,
+			wantComplexity: 1 + DefaultPageSize*2,
+		},
+		{
+			name:           "Connections cost the page size asked for",
+			query:          `{ sets(first: 5) { nodes { id } } }`,
+			wantDepth:      3,
+			wantComplexity: 1 + 5*2,
+		},
+		{
+			name:           "Page size is read from variables",
+			query:          `query($n: Int) { sets(first: $n) { nodes { id } } }`,
+			variables:      map[string]interface{}{"n": float64(3)},
+			wantDepth:      3,
+			wantComplexity: 1 + 3*2,
+		},
+		{
+			name:           "Invalid page size costs a full page",
+			query:          `{ sets(first: 5000) { totalCount } }`,
+			wantDepth:      2,
+			wantComplexity: 1 + MaxPageSize,
+		},
+		{
+			name:           "Fragments count as if inlined",
+			query:          `{ me { ...names ... on User { id } } } fragment names on User { name }`,
+			wantDepth:      2,
+			wantComplexity: 3,
+		},
+		{
+			name:           "Fragments spreading themselves are walked once",
+			query:          `{ me { ...loop } } fragment loop on User { name ...loop }`,
+			wantDepth:      2,
+			wantComplexity: 2,
+		},
+		{
+			name:           "Introspection is not counted",
+			query:          `{ __schema { types { name fields { name } } } me { id } }`,
+			wantDepth:      2,
+			wantComplexity: 2,
+		},
+		{
+			name:           "Only the named operation is counted",
+			query:          `query a { me { id } } query b { me { sets { nodes { id } } } }`,
+			operationName:  "a",
+			wantDepth:      2,
+			wantComplexity: 2,
+		},
+		{
+			name:           "Query exceeding the maximum depth is rejected",
+			query:          `{ me { sets { nodes { owner { sets { nodes { owner { sets { nodes { owner { id } } } } } } } } } } }`,
+			wantDepth:      11,
+			wantComplexity: 1 + (1 + DefaultPageSize*(1+(1+(1+DefaultPageSize*(1+(1+(1+DefaultPageSize*(1+2)))))))),
+			wantCode:       CodeTooDeep,
+		},
+		{
+			name:           "Query exceeding the maximum complexity is rejected",
+			query:          `{ users(first: 50) { nodes { sets(first: 50) { nodes { id } } } } }`,
+			wantDepth:      5,
+			wantComplexity: 1 + 50*(1+(1+50*2)),
+			wantCode:       CodeTooComplex,
+		},
+	}
+
+	for _, v := range tests {
+		doc, err := parser.Parse(parser.ParseParams{Source: v.query})
+		if err != nil {
+			t.Fatalf("%s: failed to parse query: %v", v.name, err)
+		}
+
+		depth, complexity := measure(doc, v.operationName, v.variables)
+		if depth != v.wantDepth || complexity != v.wantComplexity {
+			t.Fatalf("%s: Wanted depth %v and complexity %v\nGot depth %v and complexity %v",
+				v.name, v.wantDepth, v.wantComplexity, depth, complexity)
+		}
+
+		gotCode := ""
+		if qe := checkLimits(doc, v.operationName, v.variables); qe != nil {
+			gotCode = qe.code
+		}
+		if gotCode != v.wantCode {
+			t.Fatalf("%s: Wanted code: %q\nGot code: %q", v.name, v.wantCode, gotCode)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package graphql
+
+import "sync"
+
+// batchFunc loads the values of many keys at once. Keys missing from the
+// returned map have the zero value.
+type batchFunc[K comparable, V any] func(keys []K) (map[K]V, error)
+
+// loader batches the loads of a request, so that resolving a field of every
+// item in a list costs a single database call instead of one per item.
+// Load only queues a key, and the queued keys are loaded together when the
+// first of their thunks is called, after the executor has resolved the rest
+// of the list. Loaded values are cached for the rest of the request.
+type loader[K comparable, V any] struct {
+	mu      sync.Mutex
+	batch   batchFunc[K, V]
+	pending []K
+	values  map[K]V
+	errs    map[K]error
+}
+
+// newLoader returns a loader loading keys with batch.
+func newLoader[K comparable, V any](batch batchFunc[K, V]) *loader[K, V] {
+	return &loader[K, V]{
+		batch:  batch,
+		values: make(map[K]V),
+		errs:   make(map[K]error),
+	}
+}
+
+// Load queues key to be loaded with the next batch, and returns a thunk
+// returning its value.
+func (l *loader[K, V]) Load(key K) func() (V, error) {
+	l.mu.Lock()
+	if _, ok := l.values[key]; !ok && l.errs[key] == nil {
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() (V, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if _, ok := l.values[key]; !ok && l.errs[key] == nil {
+			l.dispatch()
+		}
+		return l.values[key], l.errs[key]
+	}
+}
+
+// Prime caches the value of key, for values already loaded by other means.
+func (l *loader[K, V]) Prime(key K, value V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.values[key]; !ok {
+		l.values[key] = value
+	}
+}
+
+// dispatch loads every pending key in one batch. Must be called with mu held.
+func (l *loader[K, V]) dispatch() {
+	seen := make(map[K]bool, len(l.pending))
+	keys := make([]K, 0, len(l.pending))
+	for _, k := range l.pending {
+		if _, ok := l.values[k]; !ok && !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	l.pending = nil
+
+	values, err := l.batch(keys)
+	for _, k := range keys {
+		if err != nil {
+			l.errs[k] = err
+			continue
+		}
+		l.values[k] = values[k]
+	}
+}
@@ -0,0 +1,369 @@
+package graphql
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	gql "github.com/graphql-go/graphql"
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// session is the state of a single query: the logged in user, and the
+// loaders batching the database calls made while resolving the query.
+type session struct {
+	viewer    models.UserID
+	requestID string
+	userDB    data.UserDB
+	users     *loader[models.UserID, *models.User]
+	sets      *loader[models.UserID, []*models.Set]
+}
+
+// sessionKey is used to retrieve the session from the context of a query
+type sessionKey struct{}
+
+// newSession returns the session of a query by viewer, loading users and sets
+// from the given dbs.
+func newSession(viewer models.UserID, requestID string, setDB data.SetDB, userDB data.UserDB) *session {
+	return &session{
+		viewer:    viewer,
+		requestID: requestID,
+		userDB:    userDB,
+		users: newLoader(func(ids []models.UserID) (map[models.UserID]*models.User, error) {
+			found, err := userDB.UsersByIDs(ids)
+			if err != nil {
+				return nil, err
+			}
+			users := make(map[models.UserID]*models.User, len(found))
+			for _, u := range found {
+				users[u.ID] = u
+			}
+			return users, nil
+		}),
+		sets: newLoader(func(ids []models.UserID) (map[models.UserID][]*models.Set, error) {
+			found, err := setDB.SetsByUserIDs(ids)
+			if err != nil {
+				return nil, err
+			}
+			sets := make(map[models.UserID][]*models.Set, len(ids))
+			for _, s := range found {
+				sets[s.UID] = append(sets[s.UID], s)
+			}
+			return sets, nil
+		}),
+	}
+}
+
+// sessionFrom returns the session of the query being resolved.
+func sessionFrom(ctx context.Context) *session {
+	return ctx.Value(sessionKey{}).(*session)
+}
+
+// queryError is an error resolving a query, reporting its code in the
+// extensions of the error
+type queryError struct {
+	code    string
+	message string
+}
+
+func (e *queryError) Error() string {
+	return e.message
+}
+
+// Extensions implements gqlerrors.ExtendedError.
+func (e *queryError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.code}
+}
+
+// Errors returned by resolvers
+var (
+	errUnauthenticated = &queryError{code: string(problem.CodeUnauthenticated), message: "your login is invalid or has expired, log in again"}
+	errForbidden       = &queryError{code: string(problem.CodeForbidden), message: "you must be an administrator to perform this action"}
+	errInternal        = &queryError{code: string(problem.CodeInternal), message: "the server encountered an unexpected error"}
+)
+
+// internal logs err, which is hidden from the client behind errInternal.
+func (s *session) internal(err error) error {
+	slog.Error("failed to resolve graphql query", "request_id", s.requestID, "error", err)
+	return errInternal
+}
+
+// user returns a thunk resolving to the user with id, or to null if there is
+// no such user.
+func (s *session) user(id models.UserID) func() (interface{}, error) {
+	load := s.users.Load(id)
+	return func() (interface{}, error) {
+		u, err := load()
+		if err != nil {
+			return nil, s.internal(err)
+		}
+		if u == nil {
+			return nil, nil
+		}
+		return u, nil
+	}
+}
+
+// requireAdmin returns a thunk resolving to the result of next if the viewer
+// is an administrator, and to errForbidden otherwise.
+func (s *session) requireAdmin(next func() (interface{}, error)) func() (interface{}, error) {
+	load := s.users.Load(s.viewer)
+	return func() (interface{}, error) {
+		viewer, err := load()
+		if err != nil {
+			return nil, s.internal(err)
+		}
+		if viewer == nil {
+			return nil, errUnauthenticated
+		}
+		if !viewer.Admin {
+			return nil, errForbidden
+		}
+		return next()
+	}
+}
+
+// setsOf returns a thunk resolving to the sets of a user, filtered and
+// passed to result.
+func (s *session) setsOf(id models.UserID, args map[string]interface{}, result func([]*models.Set, map[string]interface{}) (interface{}, error)) func() (interface{}, error) {
+	load := s.sets.Load(id)
+	return func() (interface{}, error) {
+		sets, err := load()
+		if err != nil {
+			return nil, s.internal(err)
+		}
+		return result(filterSets(sets, args["filter"]), args)
+	}
+}
+
+func resolveMe(p gql.ResolveParams) (interface{}, error) {
+	s := sessionFrom(p.Context)
+	me := s.user(s.viewer)
+	return func() (interface{}, error) {
+		u, err := me()
+		if err == nil && u == nil {
+			// the user was deleted since logging in
+			return nil, errUnauthenticated
+		}
+		return u, err
+	}, nil
+}
+
+func resolveUser(p gql.ResolveParams) (interface{}, error) {
+	s := sessionFrom(p.Context)
+	id := models.UserID(p.Args["id"].(int))
+	if id == s.viewer {
+		return s.user(id), nil
+	}
+	return s.requireAdmin(s.user(id)), nil
+}
+
+func resolveUsers(p gql.ResolveParams) (interface{}, error) {
+	s := sessionFrom(p.Context)
+	return s.requireAdmin(func() (interface{}, error) {
+		users, err := s.userDB.Users()
+		if err != nil {
+			return nil, s.internal(err)
+		}
+
+		sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+		for _, u := range users {
+			s.users.Prime(u.ID, u)
+		}
+		return paginate(users, p.Args, "user", func(u *models.User) int { return int(u.ID) })
+	}), nil
+}
+
+func resolveSets(p gql.ResolveParams) (interface{}, error) {
+	s := sessionFrom(p.Context)
+	return s.setsOf(s.viewer, p.Args, setConnection), nil
+}
+
+func resolveStats(p gql.ResolveParams) (interface{}, error) {
+	s := sessionFrom(p.Context)
+	return s.setsOf(s.viewer, p.Args, setStats), nil
+}
+
+func resolveUserID(p gql.ResolveParams) (interface{}, error) {
+	return int(p.Source.(*models.User).ID), nil
+}
+
+func resolveUserSets(p gql.ResolveParams) (interface{}, error) {
+	return sessionFrom(p.Context).setsOf(p.Source.(*models.User).ID, p.Args, setConnection), nil
+}
+
+func resolveUserStats(p gql.ResolveParams) (interface{}, error) {
+	return sessionFrom(p.Context).setsOf(p.Source.(*models.User).ID, p.Args, setStats), nil
+}
+
+func resolveSetID(p gql.ResolveParams) (interface{}, error) {
+	return int(p.Source.(*models.Set).ID), nil
+}
+
+func resolveSetOwner(p gql.ResolveParams) (interface{}, error) {
+	return sessionFrom(p.Context).user(p.Source.(*models.Set).UID), nil
+}
+
+// filterSets returns the sets matching filter, which are all sets if filter
+// is nil.
+func filterSets(sets []*models.Set, filter interface{}) []*models.Set {
+	f, ok := filter.(map[string]interface{})
+	if !ok {
+		return sets
+	}
+
+	matching := make([]*models.Set, 0, len(sets))
+	for _, s := range sets {
+		if movement, ok := f["movement"].(string); ok && !strings.EqualFold(s.Movement, movement) {
+			continue
+		}
+		if min, ok := f["minIntensity"].(float64); ok && s.Intensity < min {
+			continue
+		}
+		if max, ok := f["maxIntensity"].(float64); ok && s.Intensity > max {
+			continue
+		}
+		if min, ok := f["minVolume"].(float64); ok && s.Volume < min {
+			continue
+		}
+		if max, ok := f["maxVolume"].(float64); ok && s.Volume > max {
+			continue
+		}
+		matching = append(matching, s)
+	}
+	return matching
+}
+
+// connection is a page of a list of items ordered by id
+type connection struct {
+	Nodes      interface{}
+	TotalCount int
+	PageInfo   pageInfo
+}
+
+type pageInfo struct {
+	EndCursor   *string
+	HasNextPage bool
+}
+
+func setConnection(sets []*models.Set, args map[string]interface{}) (interface{}, error) {
+	return paginate(sets, args, "set", func(s *models.Set) int { return int(s.ID) })
+}
+
+// paginate returns the page of items, which are ordered by id, selected by
+// the first and after arguments. Cursors encode the kind and id of an item.
+func paginate[T any](items []T, args map[string]interface{}, kind string, id func(T) int) (interface{}, error) {
+	first, _ := args["first"].(int)
+	if first < 0 || first > MaxPageSize {
+		return nil, &queryError{code: string(problem.CodeInvalidParameter), message: fmt.Sprintf("first must be between 0 and %v", MaxPageSize)}
+	}
+
+	start := 0
+	if after, ok := args["after"].(string); ok {
+		afterID, err := decodeCursor(kind, after)
+		if err != nil {
+			return nil, &queryError{code: string(problem.CodeInvalidParameter), message: "after is not a valid cursor"}
+		}
+		start = sort.Search(len(items), func(i int) bool { return id(items[i]) > afterID })
+	}
+
+	end := start + first
+	if end > len(items) {
+		end = len(items)
+	}
+
+	page := connection{
+		Nodes:      items[start:end],
+		TotalCount: len(items),
+		PageInfo:   pageInfo{HasNextPage: end < len(items)},
+	}
+	if end > start {
+		cursor := encodeCursor(kind, id(items[end-1]))
+		page.PageInfo.EndCursor = &cursor
+	}
+	return page, nil
+}
+
+// encodeCursor returns the opaque cursor of the item of kind with id.
+func encodeCursor(kind string, id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(kind + ":" + strconv.Itoa(id)))
+}
+
+// decodeCursor returns the id of the item of kind identified by cursor.
+func decodeCursor(kind, cursor string) (int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	prefix, id, ok := strings.Cut(string(decoded), ":")
+	if !ok || prefix != kind {
+		return 0, fmt.Errorf("cursor %q is not a %s cursor", cursor, kind)
+	}
+	return strconv.Atoi(id)
+}
+
+// stats are derived from a list of sets
+type stats struct {
+	SetCount         int
+	TotalVolume      float64
+	AverageIntensity float64
+	MaxIntensity     float64
+	Movements        []*movementStats
+}
+
+type movementStats struct {
+	Movement         string
+	SetCount         int
+	TotalVolume      float64
+	AverageIntensity float64
+	MaxIntensity     float64
+}
+
+// add accumulates s into the stats, leaving AverageIntensity to be divided
+// by SetCount once every set is added.
+func (m *movementStats) add(s *models.Set) {
+	m.SetCount++
+	m.TotalVolume += s.Volume
+	m.AverageIntensity += s.Intensity
+	if s.Intensity > m.MaxIntensity {
+		m.MaxIntensity = s.Intensity
+	}
+}
+
+func setStats(sets []*models.Set, _ map[string]interface{}) (interface{}, error) {
+	var total movementStats
+	byMovement := make(map[string]*movementStats)
+	for _, s := range sets {
+		total.add(s)
+		m, ok := byMovement[s.Movement]
+		if !ok {
+			m = &movementStats{Movement: s.Movement}
+			byMovement[s.Movement] = m
+		}
+		m.add(s)
+	}
+
+	movements := make([]*movementStats, 0, len(byMovement))
+	for _, m := range byMovement {
+		m.AverageIntensity /= float64(m.SetCount)
+		movements = append(movements, m)
+	}
+	sort.Slice(movements, func(i, j int) bool { return movements[i].Movement < movements[j].Movement })
+
+	if total.SetCount > 0 {
+		total.AverageIntensity /= float64(total.SetCount)
+	}
+	return &stats{
+		SetCount:         total.SetCount,
+		TotalVolume:      total.TotalVolume,
+		AverageIntensity: total.AverageIntensity,
+		MaxIntensity:     total.MaxIntensity,
+		Movements:        movements,
+	}, nil
+}
@@ -0,0 +1,195 @@
+package graphql
+
+import (
+	gql "github.com/graphql-go/graphql"
+)
+
+// Page sizes of paginated connections
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// paginated names the fields returning connections, which accept the first
+// and after arguments
+var paginated = map[string]bool{"sets": true, "users": true}
+
+var pageInfoType = gql.NewObject(gql.ObjectConfig{
+	Name:        "PageInfo",
+	Description: "Where a page of a connection ends, and whether more items follow it.",
+	Fields: gql.Fields{
+		"endCursor": &gql.Field{
+			Type:        gql.String,
+			Description: "Cursor of the last item of the page, to pass as after to read the next page. Null if the page is empty.",
+		},
+		"hasNextPage": &gql.Field{Type: gql.NewNonNull(gql.Boolean)},
+	},
+})
+
+var setFilterType = gql.NewInputObject(gql.InputObjectConfig{
+	Name:        "SetFilter",
+	Description: "Selects the sets matching every given field.",
+	Fields: gql.InputObjectConfigFieldMap{
+		"movement":     &gql.InputObjectFieldConfig{Type: gql.String},
+		"minIntensity": &gql.InputObjectFieldConfig{Type: gql.Float},
+		"maxIntensity": &gql.InputObjectFieldConfig{Type: gql.Float},
+		"minVolume":    &gql.InputObjectFieldConfig{Type: gql.Float},
+		"maxVolume":    &gql.InputObjectFieldConfig{Type: gql.Float},
+	},
+})
+
+// pageArgs are the arguments of paginated connections
+var pageArgs = gql.FieldConfigArgument{
+	"first": &gql.ArgumentConfig{
+		Type:         gql.Int,
+		DefaultValue: DefaultPageSize,
+		Description:  "Number of items in the page, at most 100.",
+	},
+	"after": &gql.ArgumentConfig{
+		Type:        gql.String,
+		Description: "Cursor of the item after which the page starts.",
+	},
+}
+
+// setArgs are the arguments of fields returning sets
+var setArgs = gql.FieldConfigArgument{
+	"filter": &gql.ArgumentConfig{Type: setFilterType},
+	"first":  pageArgs["first"],
+	"after":  pageArgs["after"],
+}
+
+// statsArgs are the arguments of fields returning stats
+var statsArgs = gql.FieldConfigArgument{
+	"filter": &gql.ArgumentConfig{Type: setFilterType},
+}
+
+var movementStatsType = gql.NewObject(gql.ObjectConfig{
+	Name:        "MovementStats",
+	Description: "Stats of the sets of a single movement.",
+	Fields: gql.Fields{
+		"movement":         &gql.Field{Type: gql.NewNonNull(gql.String)},
+		"setCount":         &gql.Field{Type: gql.NewNonNull(gql.Int)},
+		"totalVolume":      &gql.Field{Type: gql.NewNonNull(gql.Float)},
+		"averageIntensity": &gql.Field{Type: gql.NewNonNull(gql.Float)},
+		"maxIntensity":     &gql.Field{Type: gql.NewNonNull(gql.Float)},
+	},
+})
+
+var statsType = gql.NewObject(gql.ObjectConfig{
+	Name:        "Stats",
+	Description: "Stats derived from sets, overall and per movement.",
+	Fields: gql.Fields{
+		"setCount":         &gql.Field{Type: gql.NewNonNull(gql.Int)},
+		"totalVolume":      &gql.Field{Type: gql.NewNonNull(gql.Float)},
+		"averageIntensity": &gql.Field{Type: gql.NewNonNull(gql.Float)},
+		"maxIntensity":     &gql.Field{Type: gql.NewNonNull(gql.Float)},
+		"movements": &gql.Field{
+			Type:        gql.NewNonNull(gql.NewList(gql.NewNonNull(movementStatsType))),
+			Description: "Stats of each movement, ordered by movement.",
+		},
+	},
+})
+
+// newSchema returns the schema served by the graphql resource. Types that
+// refer to each other are built here rather than as package variables, which
+// may not refer to themselves.
+func newSchema() (gql.Schema, error) {
+	// users and sets refer to each other, so their fields are thunks evaluated
+	// once both types exist
+	var setConnectionType *gql.Object
+	userType := gql.NewObject(gql.ObjectConfig{
+		Name: "User",
+		Fields: gql.FieldsThunk(func() gql.Fields {
+			return gql.Fields{
+				"id":    &gql.Field{Type: gql.NewNonNull(gql.Int), Resolve: resolveUserID},
+				"name":  &gql.Field{Type: gql.NewNonNull(gql.String)},
+				"admin": &gql.Field{Type: gql.NewNonNull(gql.Boolean)},
+				"sets": &gql.Field{
+					Type:        gql.NewNonNull(setConnectionType),
+					Description: "Sets owned by the user.",
+					Args:        setArgs,
+					Resolve:     resolveUserSets,
+				},
+				"stats": &gql.Field{
+					Type:        gql.NewNonNull(statsType),
+					Description: "Stats of the sets owned by the user.",
+					Args:        statsArgs,
+					Resolve:     resolveUserStats,
+				},
+			}
+		}),
+	})
+
+	setType := gql.NewObject(gql.ObjectConfig{
+		Name: "Set",
+		Fields: gql.FieldsThunk(func() gql.Fields {
+			return gql.Fields{
+				"id":        &gql.Field{Type: gql.NewNonNull(gql.Int), Resolve: resolveSetID},
+				"owner":     &gql.Field{Type: gql.NewNonNull(userType), Resolve: resolveSetOwner},
+				"movement":  &gql.Field{Type: gql.NewNonNull(gql.String)},
+				"volume":    &gql.Field{Type: gql.NewNonNull(gql.Float)},
+				"intensity": &gql.Field{Type: gql.NewNonNull(gql.Float)},
+				"version":   &gql.Field{Type: gql.NewNonNull(gql.Int), Description: "Incremented on every update, as the ETag of the set."},
+			}
+		}),
+	})
+
+	setConnectionType = gql.NewObject(gql.ObjectConfig{
+		Name:        "SetConnection",
+		Description: "A page of sets, ordered by id.",
+		Fields: gql.Fields{
+			"nodes":      &gql.Field{Type: gql.NewNonNull(gql.NewList(gql.NewNonNull(setType)))},
+			"totalCount": &gql.Field{Type: gql.NewNonNull(gql.Int), Description: "Number of sets in every page."},
+			"pageInfo":   &gql.Field{Type: gql.NewNonNull(pageInfoType)},
+		},
+	})
+
+	userConnectionType := gql.NewObject(gql.ObjectConfig{
+		Name:        "UserConnection",
+		Description: "A page of users, ordered by id.",
+		Fields: gql.Fields{
+			"nodes":      &gql.Field{Type: gql.NewNonNull(gql.NewList(gql.NewNonNull(userType)))},
+			"totalCount": &gql.Field{Type: gql.NewNonNull(gql.Int), Description: "Number of users in every page."},
+			"pageInfo":   &gql.Field{Type: gql.NewNonNull(pageInfoType)},
+		},
+	})
+
+	queryType := gql.NewObject(gql.ObjectConfig{
+		Name: "Query",
+		Fields: gql.Fields{
+			"me": &gql.Field{
+				Type:        gql.NewNonNull(userType),
+				Description: "The logged in user.",
+				Resolve:     resolveMe,
+			},
+			"user": &gql.Field{
+				Type:        userType,
+				Description: "A user by id. Only administrators may read other users.",
+				Args: gql.FieldConfigArgument{
+					"id": &gql.ArgumentConfig{Type: gql.NewNonNull(gql.Int)},
+				},
+				Resolve: resolveUser,
+			},
+			"users": &gql.Field{
+				Type:        gql.NewNonNull(userConnectionType),
+				Description: "Every user. Requires an administrator.",
+				Args:        pageArgs,
+				Resolve:     resolveUsers,
+			},
+			"sets": &gql.Field{
+				Type:        gql.NewNonNull(setConnectionType),
+				Description: "Sets owned by the logged in user.",
+				Args:        setArgs,
+				Resolve:     resolveSets,
+			},
+			"stats": &gql.Field{
+				Type:        gql.NewNonNull(statsType),
+				Description: "Stats of the sets owned by the logged in user.",
+				Args:        statsArgs,
+				Resolve:     resolveStats,
+			},
+		},
+	})
+
+	return gql.NewSchema(gql.SchemaConfig{Query: queryType})
+}
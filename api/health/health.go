@@ -0,0 +1,120 @@
+// Package health serves the probes used by orchestrators to decide whether
+// the server is alive and whether it should receive traffic, and reports the
+// build of the running server.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/requestid"
+	"github.com/hrand1005/training-notebook/buildinfo"
+	"github.com/hrand1005/training-notebook/data"
+)
+
+// PingTimeout bounds how long a readiness check waits for the database
+const PingTimeout = 2 * time.Second
+
+// Status values reported by the probes
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// Failure descriptions reported by the readiness probe, which is
+// unauthenticated and so never reports the underlying errors
+const (
+	failDatabase   = "database unavailable"
+	failMigrations = "migrations not applied"
+	failShutdown   = "server is shutting down"
+)
+
+// Check names reported by the readiness probe
+const (
+	CheckDatabase   = "database"
+	CheckMigrations = "migrations"
+	CheckShutdown   = "shutdown"
+)
+
+// Checker tracks whether the server is ready to receive traffic.
+type Checker struct {
+	db           *sql.DB
+	shuttingDown atomic.Bool
+}
+
+// New returns the health status of a server backed by db.
+func New(db *sql.DB) *Checker {
+	return &Checker{db: db}
+}
+
+// RegisterHandlers registers the probes and the version endpoint on g.
+func (h *Checker) RegisterHandlers(g gin.IRoutes) {
+	g.GET("/healthz", h.Live)
+	g.GET("/readyz", h.Ready)
+	g.GET("/version", Version)
+}
+
+// ShuttingDown marks the server as no longer ready, so that orchestrators
+// stop routing traffic to it while outstanding requests are drained.
+func (h *Checker) ShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// response is the body of the liveness and readiness probes
+type response struct {
+	Status string `json:"status"`
+	// Checks maps each readiness check to "ok" or a fixed description of its
+	// failure
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Live reports that the server process is running and handling requests.
+func (h *Checker) Live(c *gin.Context) {
+	c.IndentedJSON(http.StatusOK, response{Status: StatusOK})
+}
+
+// Ready reports whether the server can serve traffic: the database must be
+// reachable with every migration applied, and the server must not be shutting
+// down. Responds StatusServiceUnavailable if any check fails.
+func (h *Checker) Ready(c *gin.Context) {
+	checks := map[string]string{
+		CheckDatabase:   StatusOK,
+		CheckMigrations: StatusOK,
+		CheckShutdown:   StatusOK,
+	}
+	ready := true
+
+	if h.shuttingDown.Load() {
+		checks[CheckShutdown] = failShutdown
+		ready = false
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), PingTimeout)
+	defer cancel()
+	if err := h.db.PingContext(ctx); err != nil {
+		slog.Error("readiness check failed to reach the database", "request_id", requestid.FromContext(c), "error", err)
+		checks[CheckDatabase] = failDatabase
+		checks[CheckMigrations] = failDatabase
+		ready = false
+	} else if err := data.CheckSchema(h.db); err != nil {
+		slog.Error("readiness check found the database not migrated", "request_id", requestid.FromContext(c), "error", err)
+		checks[CheckMigrations] = failMigrations
+		ready = false
+	}
+
+	if !ready {
+		c.IndentedJSON(http.StatusServiceUnavailable, response{Status: StatusUnavailable, Checks: checks})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, response{Status: StatusOK, Checks: checks})
+}
+
+// Version responds with the commit, build time and Go version of the server.
+func Version(c *gin.Context) {
+	c.IndentedJSON(http.StatusOK, buildinfo.Get())
+}
@@ -0,0 +1,178 @@
+package health
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/buildinfo"
+	"github.com/hrand1005/training-notebook/data"
+)
+
+const testHealthDB = "testHealthDB.sqlite"
+
+// TestReady checks that the readiness probe fails if the database is
+// unreachable or not migrated, or once shutdown has begun, without reporting
+// the underlying errors.
+func TestReady(t *testing.T) {
+	tests := []struct {
+		name         string
+		migrate      bool
+		closeDB      bool
+		shuttingDown bool
+		wantCode     int
+		wantFailing  []string
+	}{
+		{
+			name:     "Migrated database is ready",
+			migrate:  true,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:        "Unmigrated database is not ready",
+			wantCode:    http.StatusServiceUnavailable,
+			wantFailing: []string{CheckMigrations},
+		},
+		{
+			name:        "Closed database is not ready",
+			migrate:     true,
+			closeDB:     true,
+			wantCode:    http.StatusServiceUnavailable,
+			wantFailing: []string{CheckDatabase, CheckMigrations},
+		},
+		{
+			name:         "Shutting down is not ready",
+			migrate:      true,
+			shuttingDown: true,
+			wantCode:     http.StatusServiceUnavailable,
+			wantFailing:  []string{CheckShutdown},
+		},
+	}
+
+	for _, v := range tests {
+		db := setupTestDB(t, v.migrate)
+		if v.closeDB {
+			db.Close()
+		}
+
+		h := New(db)
+		if v.shuttingDown {
+			h.ShuttingDown()
+		}
+
+		w := serve(h, "/readyz")
+
+		db.Close()
+		os.Remove(testHealthDB)
+
+		if w.Code != v.wantCode {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v\nBody: %v", v.name, v.wantCode, w.Code, w.Body.String())
+		}
+
+		var got response
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: failed to decode body: %v", v.name, err)
+		}
+		failing := 0
+		for _, status := range got.Checks {
+			if status != StatusOK {
+				failing++
+			}
+		}
+		if failing != len(v.wantFailing) {
+			t.Errorf("%s: wanted failing checks %v, got %v", v.name, v.wantFailing, got.Checks)
+		}
+		for _, check := range v.wantFailing {
+			if got.Checks[check] == StatusOK {
+				t.Errorf("%s: wanted check %q to fail, got %v", v.name, check, got.Checks)
+			}
+		}
+		for check, status := range got.Checks {
+			if status != StatusOK && status != failDatabase && status != failMigrations && status != failShutdown {
+				t.Errorf("%s: wanted check %q to report a fixed failure, got %q", v.name, check, status)
+			}
+		}
+	}
+}
+
+// TestLive checks that the liveness probe succeeds even while shutting down.
+func TestLive(t *testing.T) {
+	db := setupTestDB(t, false)
+	defer os.Remove(testHealthDB)
+	defer db.Close()
+
+	h := New(db)
+	h.ShuttingDown()
+
+	if w := serve(h, "/healthz"); w.Code != http.StatusOK {
+		t.Fatalf("Wanted code: %v\nGot code: %v", http.StatusOK, w.Code)
+	}
+}
+
+// TestVersion checks that the version endpoint reports the Go version and
+// any commit set at link time.
+func TestVersion(t *testing.T) {
+	buildinfo.Commit = "abc123"
+	defer func() { buildinfo.Commit = "" }()
+
+	w := serve(New(nil), "/version")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Wanted code: %v\nGot code: %v", http.StatusOK, w.Code)
+	}
+
+	var got buildinfo.Info
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if got.Commit != "abc123" || got.GoVersion != runtime.Version() {
+		t.Fatalf("Unexpected build info: %+v", got)
+	}
+}
+
+// serve sends a GET request for path to the probes registered by h.
+func serve(h *Checker, path string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	h.RegisterHandlers(router)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	router.ServeHTTP(w, req)
+
+	return w
+}
+
+// setupTestDB opens the test database, creating every table the server
+// requires if migrate is set.
+func setupTestDB(t *testing.T, migrate bool) *sql.DB {
+	db, err := data.SqliteDB(testHealthDB)
+	if err != nil {
+		t.Fatalf("Failed to setup test db: %v", err)
+	}
+	if !migrate {
+		return db
+	}
+
+	if _, err := data.NewSetDB(db); err != nil {
+		t.Fatalf("Failed to setup test db: %v", err)
+	}
+	if _, err := data.NewUserDB(db); err != nil {
+		t.Fatalf("Failed to setup test db: %v", err)
+	}
+	if _, err := data.NewAuditDB(db); err != nil {
+		t.Fatalf("Failed to setup test db: %v", err)
+	}
+	if _, err := data.NewIdempotencyDB(db); err != nil {
+		t.Fatalf("Failed to setup test db: %v", err)
+	}
+	if _, err := data.NewWebhookDB(db); err != nil {
+		t.Fatalf("Failed to setup test db: %v", err)
+	}
+
+	return db
+}
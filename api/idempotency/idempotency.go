@@ -0,0 +1,210 @@
+// Package idempotency lets clients safely retry mutating requests. A request
+// carrying an Idempotency-Key header is executed at most once per key, and
+// retries within the configured window are answered with the original response.
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/api/requestid"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+const (
+	// Header is the HTTP header carrying the client supplied idempotency key
+	Header = "Idempotency-Key"
+	// ReplayedHeader is set on responses replayed from a previous request
+	ReplayedHeader = "Idempotent-Replayed"
+	// DefaultWindow is how long responses are kept when no window is configured
+	DefaultWindow = 24 * time.Hour
+	// MaxKeyLength is the maximum accepted length of an idempotency key
+	MaxKeyLength = 255
+)
+
+const (
+	ErrInvalidKey = "Idempotency-Key must be between 1 and 255 printable characters"
+	ErrInProgress = "a request with this Idempotency-Key is still in progress"
+	ErrKeyReused  = "Idempotency-Key has already been used for a different request"
+)
+
+// storedHeaders are the response headers replayed alongside the stored body.
+// Cookies are deliberately never stored.
+var storedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// Middleware applies idempotency keys to POST, PUT, PATCH and DELETE requests.
+// Keys are scoped to the caller's login token, or to the client address for
+// anonymous requests. The first request with a key is executed and its response
+// is stored for the duration returned by window, which is called for each
+// request so that it may change while the server runs. Retries with the same
+// method, path and body receive the stored response, while reusing a key for a
+// different request returns StatusUnprocessableEntity. Server errors are not
+// stored, so they may be retried.
+func Middleware(db data.IdempotencyDB, window func() time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, ok := c.Request.Header[Header]
+		if !ok || !isMutating(c.Request.Method) {
+			c.Next()
+			return
+		}
+		if len(key) != 1 || !validKey(key[0]) {
+			problem.Abort(c, problem.New(problem.CodeInvalidIdempotencyKey, ErrInvalidKey))
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			problem.Abort(c, problem.New(problem.CodeMalformedRequest, "failed to read request body"))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope := callerScope(c)
+		fingerprint := requestFingerprint(c.Request, body)
+
+		expiry := window()
+		if expiry <= 0 {
+			expiry = DefaultWindow
+		}
+		rec, reserved, err := db.Reserve(scope, key[0], fingerprint, time.Now().Add(-expiry))
+		if err != nil {
+			problem.Internal(c, err)
+			return
+		}
+
+		if !reserved {
+			switch {
+			case rec.Fingerprint != fingerprint:
+				problem.Abort(c, problem.New(problem.CodeIdempotencyKeyReused, ErrKeyReused))
+			case rec.Status == 0:
+				problem.Abort(c, problem.New(problem.CodeRequestInProgress, ErrInProgress))
+			default:
+				replay(c, rec)
+			}
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		// a panicking handler releases the key so that the request may be
+		// retried, and the panic is passed on to the recovery middleware
+		defer func() {
+			if r := recover(); r != nil {
+				release(c, db, scope, key[0])
+				panic(r)
+			}
+		}()
+
+		c.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			release(c, db, scope, key[0])
+			return
+		}
+
+		rec.Status = status
+		rec.Header = make(map[string]string)
+		for _, h := range storedHeaders {
+			if v := recorder.Header().Get(h); v != "" {
+				rec.Header[h] = v
+			}
+		}
+		rec.Body = recorder.body.Bytes()
+
+		if err := db.Complete(rec); err != nil {
+			slog.Error("failed to store response for idempotency key", "request_id", requestid.FromContext(c), "error", err)
+		}
+	}
+}
+
+// release frees a reserved key without storing a response, so that the
+// request may be executed again.
+func release(c *gin.Context, db data.IdempotencyDB, scope, key string) {
+	if err := db.Release(scope, key); err != nil {
+		slog.Error("failed to release idempotency key", "request_id", requestid.FromContext(c), "error", err)
+	}
+}
+
+// replay writes the stored response to the client and aborts the chain.
+func replay(c *gin.Context, rec *models.IdempotencyRecord) {
+	for h, v := range rec.Header {
+		c.Header(h, v)
+	}
+	c.Header(ReplayedHeader, "true")
+	c.Status(rec.Status)
+	if len(rec.Body) > 0 {
+		c.Writer.Write(rec.Body)
+	} else {
+		c.Writer.WriteHeaderNow()
+	}
+	c.Abort()
+}
+
+// callerScope identifies who made the request, so that keys chosen by
+// different callers never collide.
+func callerScope(c *gin.Context) string {
+	if token, ok := users.LoginToken(c); ok {
+		sum := sha256.Sum256([]byte(token))
+		return "token:" + hex.EncodeToString(sum[:])
+	}
+	return "client:" + c.ClientIP()
+}
+
+// requestFingerprint hashes the parts of the request that must match for a
+// retry to be considered the same request.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method)
+	h.Write([]byte{0})
+	io.WriteString(h, r.URL.RequestURI())
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func validKey(key string) bool {
+	if len(key) == 0 || len(key) > MaxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// responseRecorder copies the response body as it is written to the client.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
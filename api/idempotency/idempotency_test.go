@@ -0,0 +1,240 @@
+package idempotency
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// TestMiddleware sends sequences of requests through the idempotency middleware
+// and checks which are executed by the handler and which are answered by the middleware.
+// The test suite mocks the IdempotencyDB interface with an in memory store.
+func TestMiddleware(t *testing.T) {
+	type request struct {
+		method      string
+		path        string
+		key         string
+		body        string
+		wantCode    int
+		wantBody    string
+		wantProblem problem.Code
+		replayed    bool
+	}
+
+	tests := []struct {
+		name      string
+		status    int
+		inFlight  bool
+		requests  []request
+		wantCalls int
+	}{
+		{
+			name:   "Retry returns the original response",
+			status: http.StatusCreated,
+			requests: []request{
+				{method: http.MethodPost, path: "/sets", key: "a", body: `{"volume":5}`, wantCode: http.StatusCreated, wantBody: `call 1`},
+				{method: http.MethodPost, path: "/sets", key: "a", body: `{"volume":5}`, wantCode: http.StatusCreated, wantBody: `call 1`, replayed: true},
+			},
+			wantCalls: 1,
+		},
+		{
+			name:   "Reusing a key for a different body returns StatusUnprocessableEntity",
+			status: http.StatusCreated,
+			requests: []request{
+				{method: http.MethodPost, path: "/sets", key: "a", body: `{"volume":5}`, wantCode: http.StatusCreated, wantBody: `call 1`},
+				{method: http.MethodPost, path: "/sets", key: "a", body: `{"volume":6}`, wantCode: http.StatusUnprocessableEntity, wantProblem: problem.CodeIdempotencyKeyReused},
+			},
+			wantCalls: 1,
+		},
+		{
+			name:   "Reusing a key for a different route returns StatusUnprocessableEntity",
+			status: http.StatusOK,
+			requests: []request{
+				{method: http.MethodPut, path: "/sets/1", key: "a", body: `{}`, wantCode: http.StatusOK, wantBody: `call 1`},
+				{method: http.MethodPut, path: "/sets/2", key: "a", body: `{}`, wantCode: http.StatusUnprocessableEntity, wantProblem: problem.CodeIdempotencyKeyReused},
+			},
+			wantCalls: 1,
+		},
+		{
+			name:   "Distinct keys are executed separately",
+			status: http.StatusCreated,
+			requests: []request{
+				{method: http.MethodPost, path: "/sets", key: "a", body: `{}`, wantCode: http.StatusCreated, wantBody: `call 1`},
+				{method: http.MethodPost, path: "/sets", key: "b", body: `{}`, wantCode: http.StatusCreated, wantBody: `call 2`},
+			},
+			wantCalls: 2,
+		},
+		{
+			name:   "Server errors are not stored",
+			status: http.StatusInternalServerError,
+			requests: []request{
+				{method: http.MethodDelete, path: "/sets/1", key: "a", wantCode: http.StatusInternalServerError, wantBody: `call 1`},
+				{method: http.MethodDelete, path: "/sets/1", key: "a", wantCode: http.StatusInternalServerError, wantBody: `call 2`},
+			},
+			wantCalls: 2,
+		},
+		{
+			name:     "Request in progress returns StatusConflict",
+			status:   http.StatusCreated,
+			inFlight: true,
+			requests: []request{
+				{method: http.MethodPost, path: "/sets", key: "a", body: `{}`, wantCode: http.StatusConflict, wantProblem: problem.CodeRequestInProgress},
+			},
+		},
+		{
+			name:   "Requests without a key are always executed",
+			status: http.StatusCreated,
+			requests: []request{
+				{method: http.MethodPost, path: "/sets", body: `{}`, wantCode: http.StatusCreated, wantBody: `call 1`},
+				{method: http.MethodPost, path: "/sets", body: `{}`, wantCode: http.StatusCreated, wantBody: `call 2`},
+			},
+			wantCalls: 2,
+		},
+		{
+			name:   "Safe methods ignore the key",
+			status: http.StatusOK,
+			requests: []request{
+				{method: http.MethodGet, path: "/sets", key: "a", wantCode: http.StatusOK, wantBody: `call 1`},
+				{method: http.MethodGet, path: "/sets", key: "a", wantCode: http.StatusOK, wantBody: `call 2`},
+			},
+			wantCalls: 2,
+		},
+		{
+			name:   "Invalid key returns StatusBadRequest",
+			status: http.StatusCreated,
+			requests: []request{
+				{method: http.MethodPost, path: "/sets", key: strings.Repeat("a", MaxKeyLength+1), wantCode: http.StatusBadRequest, wantProblem: problem.CodeInvalidIdempotencyKey},
+			},
+		},
+	}
+
+	for _, v := range tests {
+		db := newMemoryDB()
+		if v.inFlight {
+			req, _ := http.NewRequest(v.requests[0].method, v.requests[0].path, nil)
+			db.records["client:192.0.2.1 a"] = &models.IdempotencyRecord{
+				Scope:       "client:192.0.2.1",
+				Key:         "a",
+				Fingerprint: requestFingerprint(req, []byte(v.requests[0].body)),
+			}
+		}
+
+		calls := 0
+		handler := func(c *gin.Context) {
+			calls++
+			c.String(v.status, "call %d", calls)
+		}
+
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.Use(Middleware(db.mock(), func() time.Duration { return time.Hour }))
+		router.Any("/sets", handler)
+		router.Any("/sets/:id", handler)
+
+		for i, r := range v.requests {
+			req, _ := http.NewRequest(r.method, r.path, strings.NewReader(r.body))
+			req.RemoteAddr = "192.0.2.1:1234"
+			if r.key != "" {
+				req.Header.Set(Header, r.key)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if r.wantCode != w.Code {
+				t.Fatalf("%s: request %d: Wanted code: %v\nGot code: %v", v.name, i, r.wantCode, w.Code)
+			}
+			if r.wantProblem != "" {
+				var p problem.Problem
+				if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil || p.Code != r.wantProblem {
+					t.Fatalf("%s: request %d: Wanted problem: %v\nGot body: %v", v.name, i, r.wantProblem, w.Body.String())
+				}
+			} else if r.wantBody != w.Body.String() {
+				t.Fatalf("%s: request %d: Wanted body: %v\nGot body: %v", v.name, i, r.wantBody, w.Body.String())
+			}
+			if replayed := w.Header().Get(ReplayedHeader) == "true"; replayed != r.replayed {
+				t.Fatalf("%s: request %d: Wanted replayed: %v\nGot replayed: %v", v.name, i, r.replayed, replayed)
+			}
+		}
+
+		if v.wantCalls != calls {
+			t.Fatalf("%s: Wanted %v handler calls, got %v", v.name, v.wantCalls, calls)
+		}
+	}
+}
+
+// TestPanic checks that the key of a request whose handler panics is released,
+// so that a retry is executed rather than rejected as in progress.
+func TestPanic(t *testing.T) {
+	db := newMemoryDB()
+	calls := 0
+	handler := func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("handler failed")
+		}
+		c.String(http.StatusCreated, "call %d", calls)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(gin.CustomRecovery(func(c *gin.Context, err interface{}) {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}))
+	router.Use(Middleware(db.mock(), func() time.Duration { return time.Hour }))
+	router.POST("/sets", handler)
+
+	for i, wantCode := range []int{http.StatusInternalServerError, http.StatusCreated} {
+		req, _ := http.NewRequest(http.MethodPost, "/sets", strings.NewReader(`{"volume":5}`))
+		req.Header.Set(Header, "a")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != wantCode {
+			t.Fatalf("request %d: Wanted code: %v\nGot code: %v\nBody: %v", i, wantCode, w.Code, w.Body.String())
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("Wanted 2 handler calls, got %v", calls)
+	}
+}
+
+// memoryDB backs a MockIdempotencyDB with a map
+type memoryDB struct {
+	records map[string]*models.IdempotencyRecord
+}
+
+func newMemoryDB() *memoryDB {
+	return &memoryDB{records: make(map[string]*models.IdempotencyRecord)}
+}
+
+func (m *memoryDB) mock() *data.MockIdempotencyDB {
+	return &data.MockIdempotencyDB{
+		ReserveStub: func(scope, key, fingerprint string, expiresBefore time.Time) (*models.IdempotencyRecord, bool, error) {
+			if rec, ok := m.records[scope+" "+key]; ok {
+				copied := *rec
+				return &copied, false, nil
+			}
+			rec := &models.IdempotencyRecord{Scope: scope, Key: key, Fingerprint: fingerprint}
+			copied := *rec
+			m.records[scope+" "+key] = &copied
+			return rec, true, nil
+		},
+		CompleteStub: func(rec *models.IdempotencyRecord) error {
+			copied := *rec
+			m.records[rec.Scope+" "+rec.Key] = &copied
+			return nil
+		},
+		ReleaseStub: func(scope, key string) error {
+			delete(m.records, scope+" "+key)
+			return nil
+		},
+	}
+}
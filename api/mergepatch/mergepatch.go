@@ -0,0 +1,97 @@
+// Package mergepatch implements JSON Merge Patch, as described in RFC 7396.
+// Resources are patched by applying the patch to their JSON representation,
+// and decoding and validating the result like a full update.
+package mergepatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+)
+
+// ContentType is the media type of JSON merge patch documents
+const ContentType = "application/merge-patch+json"
+
+// ErrNotObject is returned when a patch is not a JSON object. Patches that are
+// not objects would replace the whole resource, which is not a valid partial update.
+var ErrNotObject = errors.New("merge patch must be a JSON object")
+
+// AcceptsContentType reports whether a request with the given Content-Type
+// header carries a merge patch. Plain JSON is accepted for convenience.
+func AcceptsContentType(header string) bool {
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return false
+	}
+	return mediaType == ContentType || mediaType == "application/json"
+}
+
+// Fields decodes an object patch, returning its top level members. Members set
+// to null are present in the result with a nil value.
+func Fields(patch []byte) (map[string]interface{}, error) {
+	v, err := decode(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, ErrNotObject
+	}
+	return fields, nil
+}
+
+// Apply applies the merge patch to the JSON document doc, returning the
+// patched document.
+func Apply(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := decode(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(merge(target, p))
+}
+
+// merge implements the MergePatch function defined in section 2 of RFC 7396
+func merge(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+			continue
+		}
+		t[name] = merge(t[name], value)
+	}
+
+	return t
+}
+
+// decode unmarshals JSON without losing the precision of numbers
+func decode(b []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	if d.More() {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return v, nil
+}
@@ -0,0 +1,82 @@
+package mergepatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// TestApply checks Apply against the examples given in appendix A of RFC 7396.
+func TestApply(t *testing.T) {
+	tests := []struct {
+		doc   string
+		patch string
+		want  string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		// numbers keep their precision through a patch
+		{`{"volume":9007199254740993}`, `{"intensity":80.5}`, `{"volume":9007199254740993,"intensity":80.5}`},
+	}
+
+	for _, v := range tests {
+		got, err := Apply([]byte(v.doc), []byte(v.patch))
+		if err != nil {
+			t.Fatalf("Apply(%s, %s) returned unexpected error: %v", v.doc, v.patch, err)
+		}
+
+		var gotValue, wantValue interface{}
+		json.Unmarshal(got, &gotValue)
+		json.Unmarshal([]byte(v.want), &wantValue)
+		if !reflect.DeepEqual(gotValue, wantValue) {
+			t.Fatalf("Apply(%s, %s)\nWanted: %s\nGot: %s", v.doc, v.patch, v.want, got)
+		}
+	}
+}
+
+// TestFields checks that only object patches are accepted as partial updates.
+func TestFields(t *testing.T) {
+	fields, err := Fields([]byte(`{"name":"Herb","password":null}`))
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if _, ok := fields["password"]; !ok || len(fields) != 2 {
+		t.Fatalf("Unexpected fields: %v", fields)
+	}
+
+	for _, patch := range []string{`["a"]`, `null`, `"a"`, `{"a":1} {"b":2}`, `{`} {
+		if _, err := Fields([]byte(patch)); err == nil {
+			t.Fatalf("Expected error for patch %s", patch)
+		}
+	}
+}
+
+// TestAcceptsContentType checks the media types accepted for merge patches.
+func TestAcceptsContentType(t *testing.T) {
+	accepted := []string{"application/merge-patch+json", "application/json; charset=utf-8"}
+	for _, h := range accepted {
+		if !AcceptsContentType(h) {
+			t.Fatalf("Wanted %q to be accepted", h)
+		}
+	}
+
+	rejected := []string{"", "text/plain", "application/json-patch+json"}
+	for _, h := range rejected {
+		if AcceptsContentType(h) {
+			t.Fatalf("Wanted %q to be rejected", h)
+		}
+	}
+}
@@ -1,22 +1,75 @@
 package api
 
 import (
-	"log"
+	"log/slog"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/requestid"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/metrics"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// LatencyLogger records the latency of set request handled
-func LatencyLogger(l *log.Logger) gin.HandlerFunc {
+// RequestLogger logs a structured record of every request handled, including
+// its request id, trace id, the logged in user, the matched route template,
+// the status, latency and response size. Server errors are logged at error
+// level and client errors at warn level.
+func RequestLogger(l *slog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// before request
-		t := time.Now()
+		start := time.Now()
 
 		c.Next()
 
 		// after request
-		latency := time.Since(t)
-		l.Printf("%v request on %q returned status %v in %v", c.Request.Method, c.Request.URL, c.Writer.Status(), latency)
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("request_id", requestid.FromContext(c)),
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if userID, err := users.UserIDFromContext(c); err == nil {
+			attrs = append(attrs, slog.Int("user_id", int(userID)))
+		}
+		if sc := trace.SpanContextFromContext(c.Request.Context()); sc.IsValid() {
+			attrs = append(attrs, slog.String("trace_id", sc.TraceID().String()))
+		}
+
+		l.LogAttrs(c.Request.Context(), level, "request handled", attrs...)
+	}
+}
+
+// Metrics records the count and latency of every request handled, labelled
+// by method, matched route template and status.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			// unmatched paths are grouped to keep label cardinality bounded
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
 	}
 }
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/requestid"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/metrics"
+	"github.com/hrand1005/training-notebook/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// TestRequestLogger checks that a structured record is logged for each
+// request, at a level determined by the response status.
+func TestRequestLogger(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		userID    models.UserID
+		wantLevel string
+	}{
+		{
+			name:      "Successful request is logged at info level",
+			status:    http.StatusOK,
+			userID:    3,
+			wantLevel: "INFO",
+		},
+		{
+			name:      "Client error is logged at warn level",
+			status:    http.StatusNotFound,
+			wantLevel: "WARN",
+		},
+		{
+			name:      "Server error is logged at error level",
+			status:    http.StatusInternalServerError,
+			wantLevel: "ERROR",
+		},
+	}
+
+	for _, v := range tests {
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.Use(requestid.Middleware(), RequestLogger(logger))
+		router.GET("/sets/:id", func(c *gin.Context) {
+			if v.userID != 0 {
+				c.Set(users.UserIDFromContextKey, v.userID)
+			}
+			c.String(v.status, "hello")
+		})
+
+		req, _ := http.NewRequest(http.MethodGet, "/sets/7", nil)
+		req.Header.Set(requestid.Header, "abc")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		var record map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+			t.Fatalf("%s: Encountered unexpected error decoding log record %q: %v", v.name, buf.String(), err)
+		}
+
+		want := map[string]interface{}{
+			"level":      v.wantLevel,
+			"request_id": "abc",
+			"method":     http.MethodGet,
+			"route":      "/sets/:id",
+			"path":       "/sets/7",
+			"status":     float64(v.status),
+			"bytes":      float64(len("hello")),
+		}
+		for key, val := range want {
+			if record[key] != val {
+				t.Fatalf("%s: Wanted %s: %v\nGot record: %v", v.name, key, val, record)
+			}
+		}
+		if _, ok := record["latency_ms"]; !ok {
+			t.Fatalf("%s: Wanted latency_ms in record: %v", v.name, record)
+		}
+		if got, ok := record["user_id"]; (v.userID != 0) != ok || (ok && got != float64(v.userID)) {
+			t.Fatalf("%s: Wanted user_id: %v\nGot record: %v", v.name, v.userID, record)
+		}
+	}
+}
+
+// TestMetrics checks that requests are counted by method, route template and status.
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Metrics())
+	router.GET("/metrics-test/:id", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	paths := []string{"/metrics-test/1", "/metrics-test/2", "/metrics-test-missing"}
+	for _, path := range paths {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	tests := []struct {
+		route  string
+		status string
+		want   float64
+	}{
+		{route: "/metrics-test/:id", status: "204", want: 2},
+		{route: "unmatched", status: "404", want: 1},
+	}
+	for _, v := range tests {
+		got := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(http.MethodGet, v.route, v.status))
+		if got != v.want {
+			t.Fatalf("Wanted %v requests on %s with status %s, got %v", v.want, v.route, v.status, got)
+		}
+	}
+}
@@ -0,0 +1,400 @@
+// Package openapi validates api traffic against the swagger spec served at
+// /api/swagger.yaml, so that the spec cannot silently drift from the
+// handlers. Requests that do not match the spec are rejected before they
+// reach a handler, and responses may be checked in test mode.
+package openapi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi2"
+	"github.com/getkin/kin-openapi/openapi2conv"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/api/requestid"
+	"github.com/hrand1005/training-notebook/models"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ErrRequestMismatch  = "request does not match the api spec: %v"
+	ErrResponseMismatch = "response does not match the api spec"
+)
+
+// eventStream is the media type of server-sent events
+const eventStream = "text/event-stream"
+
+func init() {
+	// merge patches are json documents, validated against the schema of the
+	// resource they patch
+	openapi3filter.RegisterBodyDecoder("application/merge-patch+json", openapi3filter.JSONBodyDecoder)
+}
+
+// Spec is a loaded swagger spec, whose operations are looked up by the gin
+// route that serves them
+type Spec struct {
+	doc *openapi3.T
+	// basePath prefixes every path in the spec, e.g. /api
+	basePath string
+	options  *openapi3filter.Options
+	// streams holds the operations producing event streams, by method and
+	// path template
+	streams map[string]bool
+}
+
+// Load parses and validates a swagger 2.0 spec in yaml or json.
+func Load(spec []byte) (*Spec, error) {
+	var doc2 openapi2.T
+	// decode through yaml, since swagger documents are json compatible but
+	// kin-openapi only unmarshals json
+	var raw interface{}
+	if err := yaml.Unmarshal(spec, &raw); err != nil {
+		return nil, fmt.Errorf("decoding api spec: %v", err)
+	}
+	asJSON, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("decoding api spec: %v", err)
+	}
+	if err := doc2.UnmarshalJSON(asJSON); err != nil {
+		return nil, fmt.Errorf("decoding api spec: %v", err)
+	}
+
+	doc, err := openapi2conv.ToV3(&doc2)
+	if err != nil {
+		return nil, fmt.Errorf("converting api spec: %v", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("validating api spec: %v", err)
+	}
+
+	// the produces of an operation is only applied to its inline responses
+	// by the conversion, so event streams are found in the swagger document
+	streams := make(map[string]bool)
+	for path, item := range doc2.Paths {
+		for method, op := range item.Operations() {
+			for _, mime := range op.Produces {
+				if mime == eventStream {
+					streams[method+" "+path] = true
+				}
+			}
+		}
+	}
+
+	return &Spec{
+		doc:      doc,
+		basePath: strings.TrimSuffix(doc2.BasePath, "/"),
+		options: &openapi3filter.Options{
+			// authentication is enforced by the handlers' middleware
+			AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+			IncludeResponseStatus: true,
+		},
+		streams: streams,
+	}, nil
+}
+
+// Route is a method and path template documented by the spec or served by
+// gin, with parameters in gin's :name form and the base path included.
+type Route struct {
+	Method string
+	Path   string
+}
+
+func (r Route) String() string {
+	return r.Method + " " + r.Path
+}
+
+// Routes returns every operation documented by the spec, sorted by path and
+// method.
+func (s *Spec) Routes() []Route {
+	var routes []Route
+	for path, item := range s.doc.Paths.Map() {
+		for method := range item.Operations() {
+			routes = append(routes, Route{Method: method, Path: s.basePath + ginPath(path)})
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// Documents reports whether the spec documents the route, ignoring the names
+// of path parameters.
+func (s *Spec) Documents(r Route) bool {
+	_, ok := s.route(r.Method, r.Path)
+	return ok
+}
+
+// route returns the operation serving a gin route, and false if the spec does
+// not document it.
+func (s *Spec) route(method, ginRoute string) (*routers.Route, bool) {
+	if !strings.HasPrefix(ginRoute, s.basePath+"/") {
+		return nil, false
+	}
+	path := specPath(strings.TrimPrefix(ginRoute, s.basePath))
+	item := s.doc.Paths.Find(path)
+	if item == nil {
+		return nil, false
+	}
+	op := item.GetOperation(method)
+	if op == nil {
+		return nil, false
+	}
+	// the spec may name path parameters differently
+	for template, candidate := range s.doc.Paths.Map() {
+		if candidate == item {
+			path = template
+			break
+		}
+	}
+
+	return &routers.Route{
+		Spec:      s.doc,
+		Path:      path,
+		PathItem:  item,
+		Method:    method,
+		Operation: op,
+	}, true
+}
+
+// Middleware rejects requests to documented routes that do not match the
+// spec, with StatusBadRequest, or StatusUnsupportedMediaType if the body's
+// content type is not accepted. Bodies without a content type are validated
+// as json, which the handlers assume. If validateResponses is set, responses
+// are buffered and checked before they are sent, and responses that do not
+// match are replaced by an internal error, so that tests catch drift. Event
+// streams are never buffered, since they do not end. Routes missing from the
+// spec are served unvalidated.
+func (s *Spec) Middleware(validateResponses bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, ok := s.route(c.Request.Method, c.FullPath())
+		if !ok {
+			c.Next()
+			return
+		}
+
+		input, err := s.requestInput(c, route)
+		if err != nil {
+			problem.Internal(c, err)
+			return
+		}
+		if err := openapi3filter.ValidateRequest(c.Request.Context(), input); err != nil {
+			problem.Abort(c, requestProblem(err))
+			return
+		}
+
+		if !validateResponses || s.streamed(route) {
+			c.Next()
+			return
+		}
+
+		w := &bufferedWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+		if w.hijacked {
+			return
+		}
+
+		err = openapi3filter.ValidateResponse(c.Request.Context(), &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 w.status,
+			Header:                 w.Header(),
+			Body:                   io.NopCloser(bytes.NewReader(w.body.Bytes())),
+			Options:                s.options,
+		})
+		if err != nil {
+			slog.Error(ErrResponseMismatch, "request_id", requestid.FromContext(c), "route", route.Method+" "+c.FullPath(), "status", w.status, "error", err)
+			w.Header().Del("Content-Length")
+			w.Header().Del("ETag")
+			problem.Abort(c, problem.New(problem.CodeInternal, problem.InternalDetail))
+			return
+		}
+
+		c.Writer.WriteHeader(w.status)
+		if w.body.Len() > 0 {
+			c.Writer.Write(w.body.Bytes())
+		} else {
+			c.Writer.WriteHeaderNow()
+		}
+	}
+}
+
+// requestInput returns the input validating the request against route. The
+// request body is read, and replaced so that handlers can read it again.
+func (s *Spec) requestInput(c *gin.Context, route *routers.Route) (*openapi3filter.RequestValidationInput, error) {
+	req := c.Request.Clone(c.Request.Context())
+	if c.Request.Body != nil && c.Request.Body != http.NoBody {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return nil, fmt.Errorf("reading request body: %v", err)
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		if len(body) > 0 && req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	}
+
+	// path parameters are named differently by gin and the spec, but are in
+	// the same positions
+	params := make(map[string]string)
+	specSegments := strings.Split(route.Path, "/")
+	ginSegments := strings.Split(strings.TrimPrefix(c.FullPath(), s.basePath), "/")
+	for i, segment := range specSegments {
+		if i < len(ginSegments) && strings.HasPrefix(segment, "{") && strings.HasPrefix(ginSegments[i], ":") {
+			params[strings.Trim(segment, "{}")] = c.Param(ginSegments[i][1:])
+		}
+	}
+
+	return &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: params,
+		Route:      route,
+		Options:    s.options,
+	}, nil
+}
+
+// streamed reports whether the operation of route produces an event stream.
+func (s *Spec) streamed(route *routers.Route) bool {
+	return s.streams[route.Method+" "+route.Path]
+}
+
+// requestProblem describes why a request does not match the spec.
+func requestProblem(err error) *problem.Problem {
+	var reqErr *openapi3filter.RequestError
+	if !errors.As(err, &reqErr) {
+		return problem.Newf(problem.CodeValidationFailed, ErrRequestMismatch, err)
+	}
+
+	if reqErr.Parameter != nil {
+		return problem.Newf(problem.CodeInvalidParameter, ErrRequestMismatch,
+			fmt.Sprintf("%v parameter %q: %v", reqErr.Parameter.In, reqErr.Parameter.Name, reason(reqErr)))
+	}
+	if strings.HasPrefix(reqErr.Reason, "header Content-Type has unexpected value") {
+		return problem.Newf(problem.CodeUnsupportedMediaType, ErrRequestMismatch, reqErr.Reason)
+	}
+
+	p := problem.Newf(problem.CodeValidationFailed, ErrRequestMismatch, "body: "+reason(reqErr))
+	var schemaErr *openapi3.SchemaError
+	if errors.As(reqErr.Err, &schemaErr) {
+		p.Errors = []models.FieldError{{
+			Field:   strings.Join(schemaErr.JSONPointer(), "."),
+			Tag:     schemaErr.SchemaField,
+			Message: schemaErr.Reason,
+		}}
+	}
+	return p
+}
+
+// reason returns the cause of a request error, without repeating the request.
+func reason(err *openapi3filter.RequestError) string {
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err.Err, &schemaErr) {
+		if pointer := schemaErr.JSONPointer(); len(pointer) > 0 {
+			return fmt.Sprintf("field %q: %v", strings.Join(pointer, "."), schemaErr.Reason)
+		}
+		return schemaErr.Reason
+	}
+	if err.Err != nil {
+		return err.Err.Error()
+	}
+	return err.Reason
+}
+
+// ginPath converts a path template from the spec's {name} form to gin's
+// :name form.
+func ginPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			segments[i] = ":" + strings.Trim(segment, "{}")
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// specPath converts a path template from gin's :name form to the spec's
+// {name} form.
+func specPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// bufferedWriter holds a response until it has been validated
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status   int
+	written  bool
+	hijacked bool
+	body     bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if code > 0 && !w.written {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	w.written = true
+	return w.body.Write(b)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.written = true
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.written
+}
+
+// Flush is a no-op, since the response is sent once it has been validated.
+func (w *bufferedWriter) Flush() {}
+
+// Hijack hands the connection over to the handler, e.g. to upgrade it to a
+// WebSocket, after which the response is no longer validated.
+func (w *bufferedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	// the status is still recorded for logging
+	w.ResponseWriter.WriteHeader(w.status)
+	return w.ResponseWriter.Hijack()
+}
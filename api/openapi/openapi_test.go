@@ -0,0 +1,253 @@
+package openapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/problem"
+)
+
+const testSpec = `
+swagger: "2.0"
+basePath: /api
+info:
+  title: test
+  version: 1.0.0
+consumes:
+  - application/json
+produces:
+  - application/json
+definitions:
+  Thing:
+    type: object
+    required: [name]
+    properties:
+      name:
+        type: string
+        minLength: 1
+paths:
+  /things/{id}:
+    put:
+      operationId: updateThing
+      parameters:
+        - name: id
+          in: path
+          required: true
+          type: integer
+          minimum: 1
+        - name: body
+          in: body
+          required: true
+          schema:
+            $ref: "#/definitions/Thing"
+      responses:
+        "200":
+          description: the updated thing
+          schema:
+            $ref: "#/definitions/Thing"
+  /events:
+    get:
+      operationId: streamEvents
+      produces:
+        - text/event-stream
+      responses:
+        "200":
+          description: a stream of events
+          schema:
+            type: string
+`
+
+// TestMiddleware checks that requests not matching the spec are rejected, that
+// responses not matching it are replaced if they are validated, and that
+// routes missing from the spec are not validated.
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name              string
+		path              string
+		contentType       string
+		body              string
+		response          string
+		validateResponses bool
+		wantCode          int
+		wantProblem       problem.Code
+	}{
+		{
+			name:     "Matching request is allowed",
+			path:     "/api/things/1",
+			body:     `{"name": "squat"}`,
+			response: `{"name": "squat"}`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:        "Invalid path parameter is rejected",
+			path:        "/api/things/0",
+			body:        `{"name": "squat"}`,
+			wantCode:    http.StatusBadRequest,
+			wantProblem: problem.CodeInvalidParameter,
+		},
+		{
+			name:        "Body missing a required field is rejected",
+			path:        "/api/things/1",
+			body:        `{}`,
+			wantCode:    http.StatusBadRequest,
+			wantProblem: problem.CodeValidationFailed,
+		},
+		{
+			name:        "Unsupported content type is rejected",
+			path:        "/api/things/1",
+			contentType: "text/plain",
+			body:        `name=squat`,
+			wantCode:    http.StatusUnsupportedMediaType,
+			wantProblem: problem.CodeUnsupportedMediaType,
+		},
+		{
+			name:              "Matching response is sent",
+			path:              "/api/things/1",
+			body:              `{"name": "squat"}`,
+			response:          `{"name": "squat"}`,
+			validateResponses: true,
+			wantCode:          http.StatusOK,
+		},
+		{
+			name:              "Response not matching the spec is replaced",
+			path:              "/api/things/1",
+			body:              `{"name": "squat"}`,
+			response:          `{"name": ""}`,
+			validateResponses: true,
+			wantCode:          http.StatusInternalServerError,
+			wantProblem:       problem.CodeInternal,
+		},
+		{
+			name:              "Undocumented response is sent unless validated",
+			path:              "/api/things/1",
+			body:              `{"name": "squat"}`,
+			response:          `{"name": ""}`,
+			validateResponses: false,
+			wantCode:          http.StatusOK,
+		},
+		{
+			name:     "Route missing from the spec is not validated",
+			path:     "/api/other/0",
+			body:     `{}`,
+			response: `{}`,
+			wantCode: http.StatusOK,
+		},
+	}
+
+	spec, err := Load([]byte(testSpec))
+	if err != nil {
+		t.Fatalf("failed to load spec: %v", err)
+	}
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.New()
+			g := router.Group("/api")
+			g.Use(spec.Middleware(tc.validateResponses))
+			handler := func(c *gin.Context) {
+				// handlers must still be able to read the body
+				var body map[string]interface{}
+				if err := c.ShouldBindJSON(&body); err != nil {
+					t.Errorf("handler failed to read body: %v", err)
+				}
+				c.Data(http.StatusOK, "application/json", []byte(tc.response))
+			}
+			g.PUT("/things/:thingID", handler)
+			g.PUT("/other/:id", handler)
+
+			req := httptest.NewRequest(http.MethodPut, tc.path, strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.wantCode {
+				t.Fatalf("got status %v, want %v: %s", w.Code, tc.wantCode, w.Body)
+			}
+			if tc.wantProblem == "" {
+				if w.Body.String() != tc.response {
+					t.Errorf("got body %s, want %s", w.Body, tc.response)
+				}
+				return
+			}
+			var p problem.Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+				t.Fatalf("failed to decode problem: %v", err)
+			}
+			if p.Code != tc.wantProblem {
+				t.Errorf("got problem %v, want %v: %v", p.Code, tc.wantProblem, p.Detail)
+			}
+		})
+	}
+}
+
+// TestMiddlewareStreams checks that event streams are sent as they are
+// written, even when responses are validated.
+func TestMiddlewareStreams(t *testing.T) {
+	spec, err := Load([]byte(testSpec))
+	if err != nil {
+		t.Fatalf("failed to load spec: %v", err)
+	}
+	gin.SetMode(gin.TestMode)
+
+	done := make(chan struct{})
+	router := gin.New()
+	g := router.Group("/api")
+	g.Use(spec.Middleware(true))
+	g.GET("/events", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		c.String(http.StatusOK, "data: first\n\n")
+		c.Writer.Flush()
+		// the stream stays open until the client has read the first event
+		<-done
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+	defer close(done)
+
+	resp, err := http.Get(server.URL + "/api/events")
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to read stream: %v", err)
+	}
+	if line != "data: first\n" {
+		t.Errorf("got line %q, want %q", line, "data: first\n")
+	}
+}
+
+// TestLoad checks that specs which are not valid swagger are rejected.
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{
+			name: "Malformed yaml",
+			spec: "swagger: [",
+		},
+		{
+			name: "Dangling reference",
+			spec: strings.Replace(testSpec, "#/definitions/Thing", "#/definitions/Missing", 1),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Load([]byte(tc.spec)); err == nil {
+				t.Errorf("got no error loading spec")
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+// Package problem reports API errors as RFC 7807 problem details. Every error
+// response has content type application/problem+json and carries a stable,
+// machine-readable code alongside the human-readable detail.
+package problem
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/requestid"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// ContentType is the media type of problem detail responses
+const ContentType = "application/problem+json"
+
+// TypePrefix is prepended to a problem's code to form its type URI
+const TypePrefix = "urn:training-notebook:problem:"
+
+// InternalDetail is returned in place of the cause of internal errors, which
+// are only logged server side
+const InternalDetail = "an internal error occurred, quote the request id when reporting it"
+
+// Code identifies a kind of problem. Codes are stable, so clients may rely on
+// them instead of parsing the detail message.
+type Code string
+
+const (
+	CodeMalformedRequest      Code = "malformed-request"
+	CodeValidationFailed      Code = "validation-failed"
+	CodeInvalidParameter      Code = "invalid-parameter"
+	CodeInvalidIdempotencyKey Code = "invalid-idempotency-key"
+	CodeUnauthenticated       Code = "unauthenticated"
+	CodeInvalidCredentials    Code = "invalid-credentials"
+	CodeForbidden             Code = "forbidden"
+	CodeCSRFTokenInvalid      Code = "csrf-token-invalid"
+	CodeOriginNotAllowed      Code = "origin-not-allowed"
+	CodeNotFound              Code = "not-found"
+	CodeRequestInProgress     Code = "request-in-progress"
+	CodePreconditionFailed    Code = "precondition-failed"
+	CodeUnsupportedMediaType  Code = "unsupported-media-type"
+	CodeUnsupportedVersion    Code = "unsupported-api-version"
+	CodeIdempotencyKeyReused  Code = "idempotency-key-reused"
+	CodeRateLimited           Code = "rate-limited"
+	CodeInternal              Code = "internal-error"
+)
+
+// definition holds the status and title shared by all problems with a code
+type definition struct {
+	status int
+	title  string
+}
+
+var definitions = map[Code]definition{
+	CodeMalformedRequest:      {http.StatusBadRequest, "Malformed request"},
+	CodeValidationFailed:      {http.StatusBadRequest, "Validation failed"},
+	CodeInvalidParameter:      {http.StatusBadRequest, "Invalid parameter"},
+	CodeInvalidIdempotencyKey: {http.StatusBadRequest, "Invalid idempotency key"},
+	CodeUnauthenticated:       {http.StatusUnauthorized, "Authentication required"},
+	CodeInvalidCredentials:    {http.StatusUnauthorized, "Invalid credentials"},
+	CodeForbidden:             {http.StatusForbidden, "Forbidden"},
+	CodeCSRFTokenInvalid:      {http.StatusForbidden, "Invalid CSRF token"},
+	CodeOriginNotAllowed:      {http.StatusForbidden, "Origin not allowed"},
+	CodeNotFound:              {http.StatusNotFound, "Resource not found"},
+	CodeRequestInProgress:     {http.StatusConflict, "Request in progress"},
+	CodePreconditionFailed:    {http.StatusPreconditionFailed, "Precondition failed"},
+	CodeUnsupportedMediaType:  {http.StatusUnsupportedMediaType, "Unsupported media type"},
+	CodeUnsupportedVersion:    {http.StatusNotAcceptable, "Unsupported API version"},
+	CodeIdempotencyKeyReused:  {http.StatusUnprocessableEntity, "Idempotency key reused"},
+	CodeRateLimited:           {http.StatusTooManyRequests, "Too many requests"},
+	CodeInternal:              {http.StatusInternalServerError, "Internal server error"},
+}
+
+// Problem is an RFC 7807 problem details object, extended with the problem's
+// code, the request id, and details of the fields at fault. Handlers may add
+// further details of their own problems as extension members.
+type Problem struct {
+	Type      string              `json:"type"`
+	Title     string              `json:"title"`
+	Status    int                 `json:"status"`
+	Detail    string              `json:"detail,omitempty"`
+	Instance  string              `json:"instance,omitempty"`
+	Code      Code                `json:"code"`
+	RequestID string              `json:"request-id,omitempty"`
+	Errors    []models.FieldError `json:"errors,omitempty"`
+	// Extensions are written as members of the problem after the members
+	// above, which they must not share a name with.
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// New returns a problem with the given code and detail. The status and title
+// are determined by the code.
+func New(code Code, detail string) *Problem {
+	def, ok := definitions[code]
+	if !ok {
+		def = definitions[CodeInternal]
+	}
+
+	return &Problem{
+		Type:   TypePrefix + string(code),
+		Title:  def.title,
+		Status: def.status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Newf returns a problem with the given code and a formatted detail.
+func Newf(code Code, format string, args ...interface{}) *Problem {
+	return New(code, fmt.Sprintf(format, args...))
+}
+
+// FromBindingError returns a validation problem listing each invalid field of
+// a binding error, or a malformed request problem if the body could not be decoded.
+func FromBindingError(err error) *Problem {
+	fieldErrors := models.BindingErrorToFieldErrors(err)
+	if len(fieldErrors) == 0 {
+		return New(CodeMalformedRequest, models.BindingErrorToMessage(err))
+	}
+
+	p := New(CodeValidationFailed, models.BindingErrorToMessage(err))
+	p.Errors = fieldErrors
+	return p
+}
+
+// MarshalJSON implements json.Marshaler, writing the extensions of the problem
+// as members alongside its standard members.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	// the alias has no MarshalJSON method, so encoding it does not recurse
+	type standard Problem
+	b, err := json.Marshal(standard(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return b, err
+	}
+
+	ext, err := json.Marshal(p.Extensions)
+	if err != nil {
+		return nil, err
+	}
+
+	// join the two objects into one
+	return append(append(b[:len(b)-1], ','), ext[1:]...), nil
+}
+
+// Error implements the error interface
+func (p *Problem) Error() string {
+	return fmt.Sprintf("%s: %s", p.Code, p.Detail)
+}
+
+// Abort writes p to the client and aborts the handler chain. The instance and
+// request id are taken from the request being handled.
+func Abort(c *gin.Context, p *Problem) {
+	if c.Request != nil && c.Request.URL != nil {
+		p.Instance = c.Request.URL.Path
+	}
+	p.RequestID = requestid.FromContext(c)
+
+	c.Header("Content-Type", ContentType)
+	c.IndentedJSON(p.Status, p)
+	c.Abort()
+}
+
+// Internal logs err server side and aborts with a generic internal error, so
+// that details of the failure are never exposed to the client.
+func Internal(c *gin.Context, err error) {
+	slog.Error("internal error", "request_id", requestid.FromContext(c), "route", c.FullPath(), "error", err)
+	Abort(c, New(CodeInternal, InternalDetail))
+}
@@ -0,0 +1,163 @@
+package problem
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/hrand1005/training-notebook/api/requestid"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// TestAbort checks that problems are written as problem+json with the
+// instance and request id of the request being handled.
+func TestAbort(t *testing.T) {
+	type payload struct {
+		Name   string `json:"name" binding:"required"`
+		Volume int    `json:"volume" binding:"gt=0"`
+	}
+
+	tests := []struct {
+		name        string
+		respond     func(c *gin.Context)
+		wantCode    int
+		wantProblem Problem
+	}{
+		{
+			name: "Problem is written with its status and code",
+			respond: func(c *gin.Context) {
+				Abort(c, Newf(CodeNotFound, "no such set with id %v", 4))
+			},
+			wantCode: http.StatusNotFound,
+			wantProblem: Problem{
+				Type:      TypePrefix + "not-found",
+				Title:     "Resource not found",
+				Status:    http.StatusNotFound,
+				Detail:    "no such set with id 4",
+				Instance:  "/api/sets/4",
+				Code:      CodeNotFound,
+				RequestID: "abc",
+			},
+		},
+		{
+			name: "Internal error hides its cause",
+			respond: func(c *gin.Context) {
+				Internal(c, fmt.Errorf("no such table: sets"))
+			},
+			wantCode: http.StatusInternalServerError,
+			wantProblem: Problem{
+				Type:      TypePrefix + "internal-error",
+				Title:     "Internal server error",
+				Status:    http.StatusInternalServerError,
+				Detail:    InternalDetail,
+				Instance:  "/api/sets/4",
+				Code:      CodeInternal,
+				RequestID: "abc",
+			},
+		},
+		{
+			name: "Binding error lists invalid fields",
+			respond: func(c *gin.Context) {
+				var p payload
+				err := binding.JSON.BindBody([]byte(`{"volume": 0}`), &p)
+				Abort(c, FromBindingError(err))
+			},
+			wantCode: http.StatusBadRequest,
+			wantProblem: Problem{
+				Type:      TypePrefix + "validation-failed",
+				Title:     "Validation failed",
+				Status:    http.StatusBadRequest,
+				Detail:    "'Name' field is required.'Volume' field must be greater than 0.",
+				Instance:  "/api/sets/4",
+				Code:      CodeValidationFailed,
+				RequestID: "abc",
+				Errors: []models.FieldError{
+					{Field: "Name", Tag: "required", Message: "'Name' field is required."},
+					{Field: "Volume", Tag: "gt", Message: "'Volume' field must be greater than 0."},
+				},
+			},
+		},
+		{
+			name: "Malformed body is not a validation error",
+			respond: func(c *gin.Context) {
+				var p payload
+				err := binding.JSON.BindBody([]byte(`{"volume": `), &p)
+				Abort(c, FromBindingError(err))
+			},
+			wantCode: http.StatusBadRequest,
+			wantProblem: Problem{
+				Type:      TypePrefix + "malformed-request",
+				Title:     "Malformed request",
+				Status:    http.StatusBadRequest,
+				Detail:    "Malformed JSON",
+				Instance:  "/api/sets/4",
+				Code:      CodeMalformedRequest,
+				RequestID: "abc",
+			},
+		},
+	}
+
+	for _, v := range tests {
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/api/sets/4", bytes.NewBufferString(""))
+		c.Set(requestid.ContextKey, "abc")
+
+		v.respond(c)
+
+		if v.wantCode != w.Code {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v", v.name, v.wantCode, w.Code)
+		}
+		if !c.IsAborted() {
+			t.Fatalf("%s: Wanted handler chain to be aborted", v.name)
+		}
+		if got := w.Header().Get("Content-Type"); got != ContentType {
+			t.Fatalf("%s: Wanted content type: %v\nGot content type: %v", v.name, ContentType, got)
+		}
+
+		var got Problem
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: Encountered unexpected error decoding body: %v", v.name, err)
+		}
+		if !reflect.DeepEqual(v.wantProblem, got) {
+			t.Fatalf("%s: Wanted problem: %+v\nGot problem: %+v", v.name, v.wantProblem, got)
+		}
+	}
+}
+
+// TestExtensions checks that extension members are written alongside the
+// standard members of a problem.
+func TestExtensions(t *testing.T) {
+	p := New(CodeValidationFailed, "batch contains invalid operations")
+	p.Extensions = map[string]interface{}{
+		"operations": []map[string]interface{}{{"index": 1, "message": "invalid movement"}},
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("Encountered unexpected error decoding problem: %v", err)
+	}
+	want := map[string]interface{}{
+		"type":       TypePrefix + "validation-failed",
+		"title":      "Validation failed",
+		"status":     float64(http.StatusBadRequest),
+		"detail":     "batch contains invalid operations",
+		"code":       "validation-failed",
+		"operations": []interface{}{map[string]interface{}{"index": float64(1), "message": "invalid movement"}},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("Wanted problem: %v\nGot problem: %s", want, b)
+	}
+}
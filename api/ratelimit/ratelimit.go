@@ -0,0 +1,218 @@
+// Package ratelimit limits how often each client may call the api. Requests
+// are counted in token buckets kept per route policy and per client, where
+// clients are identified by their logged in user, or by their address if
+// they are not logged in.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/api/requestid"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/api/version"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// Response headers describing the limit applied to a request
+const (
+	LimitHeader      = "RateLimit-Limit"
+	RemainingHeader  = "RateLimit-Remaining"
+	ResetHeader      = "RateLimit-Reset"
+	PolicyHeader     = "RateLimit-Policy"
+	RetryAfterHeader = "Retry-After"
+)
+
+// AnyRoute is the route of a policy that applies to every route without a
+// policy of its own
+const AnyRoute = "*"
+
+// ErrRateLimited is the detail of responses to limited requests
+const ErrRateLimited = "rate limit exceeded, retry after %v seconds"
+
+// Policy limits requests to a route to Requests per Per, allowing bursts of
+// up to Burst requests
+type Policy struct {
+	// Route is the route template the policy applies to, e.g.
+	// /api/sets/:paramSetID, or AnyRoute. Routes are unversioned, so that
+	// every version of the api shares the allowance for a route.
+	Route string
+	// Method restricts the policy to one HTTP method, if set
+	Method   string
+	Requests int
+	Per      time.Duration
+	// Burst defaults to Requests if it is not set
+	Burst int
+}
+
+// limit returns the token bucket described by the policy.
+func (p Policy) limit() Limit {
+	burst := p.Burst
+	if burst <= 0 {
+		burst = p.Requests
+	}
+	return Limit{Rate: float64(p.Requests) / p.Per.Seconds(), Burst: burst}
+}
+
+// Validate returns an error if the policy cannot limit requests.
+func (p Policy) Validate() error {
+	if p.Route == "" {
+		return fmt.Errorf("route is required")
+	}
+	if p.Requests <= 0 {
+		return fmt.Errorf("requests must be greater than 0")
+	}
+	if p.Per <= 0 {
+		return fmt.Errorf("per must be greater than 0")
+	}
+	if p.Burst < 0 {
+		return fmt.Errorf("burst must not be negative")
+	}
+	return nil
+}
+
+// Limiter applies rate limit policies to requests. Policies may be replaced
+// while the server is running.
+type Limiter struct {
+	store    Store
+	policies atomic.Pointer[[]Policy]
+}
+
+// New returns a limiter counting requests in store.
+func New(store Store, policies []Policy) *Limiter {
+	l := &Limiter{store: store}
+	l.SetPolicies(policies)
+	return l
+}
+
+// SetPolicies replaces the limiter's policies. Buckets of changed policies
+// start full.
+func (l *Limiter) SetPolicies(policies []Policy) {
+	l.policies.Store(&policies)
+}
+
+// policy returns the policy applying to the given route and method, and
+// false if the route is not limited. Policies for the route take precedence
+// over policies for any route, and policies for the method over policies for
+// every method.
+func (l *Limiter) policy(route, method string) (Policy, bool) {
+	var best Policy
+	bestScore := 0
+	for _, p := range *l.policies.Load() {
+		score := 0
+		switch version.Unversioned(p.Route) {
+		case route:
+			score += 2
+		case AnyRoute:
+		default:
+			continue
+		}
+		switch {
+		case p.Method == "":
+		case strings.EqualFold(p.Method, method):
+			score++
+		default:
+			continue
+		}
+		// every matching policy scores at least 1, to tell it from no match
+		score++
+		if score > bestScore {
+			best, bestScore = p, score
+		}
+	}
+	return best, bestScore > 0
+}
+
+// ClientAddress identifies a client that is not logged in by its address.
+func ClientAddress(ip string) string {
+	return "ip:" + ip
+}
+
+// ClientUser identifies a client by its logged in user.
+func ClientUser(userID models.UserID) string {
+	return "user:" + strconv.Itoa(int(userID))
+}
+
+// take counts a request by client to route and method against the policy for
+// the route, where client is identified by ClientAddress or ClientUser.
+// Returns false if the route is not limited.
+func (l *Limiter) take(ctx context.Context, route, method, client string) (Policy, Result, bool, error) {
+	p, ok := l.policy(route, method)
+	if !ok {
+		return Policy{}, Result{}, false, nil
+	}
+
+	key := strings.Join([]string{p.Route, p.Method, client}, "|")
+	result, err := l.store.Take(ctx, key, p.limit())
+	return p, result, true, err
+}
+
+// Allow counts a request by client to an unversioned route and method, e.g.
+// /api/login and POST, against the same allowance as requests served by
+// Middleware, so that other transports share the limits of the REST api.
+// Returns false and how long until the request can be retried if the client
+// has used its allowance. Requests are allowed if the store fails.
+func (l *Limiter) Allow(ctx context.Context, route, method, client string) (bool, time.Duration) {
+	_, result, ok, err := l.take(ctx, route, method, client)
+	if !ok {
+		return true, 0
+	}
+	if err != nil {
+		slog.Warn("failed to apply rate limit, allowing request", "route", route, "error", err)
+		return true, 0
+	}
+	return result.Allowed, result.RetryAfter
+}
+
+// Middleware limits requests according to the policy for their route,
+// responding StatusTooManyRequests once a client has used its allowance.
+// Clients are identified by the user set by users.Authenticate, or by client
+// address, which is only read from proxy headers sent by trusted proxies.
+// Requests are allowed if the store fails.
+func (l *Limiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client := ClientAddress(c.ClientIP())
+		if userID, err := users.UserIDFromContext(c); err == nil {
+			client = ClientUser(userID)
+		}
+
+		p, result, ok, err := l.take(c.Request.Context(), version.Unversioned(c.FullPath()), c.Request.Method, client)
+		if !ok {
+			c.Next()
+			return
+		}
+		if err != nil {
+			slog.Warn("failed to apply rate limit, allowing request", "request_id", requestid.FromContext(c), "error", err)
+			c.Next()
+			return
+		}
+
+		limit := p.limit()
+		c.Header(LimitHeader, strconv.Itoa(limit.Burst))
+		c.Header(RemainingHeader, strconv.Itoa(result.Remaining))
+		c.Header(ResetHeader, strconv.Itoa(CeilSeconds(result.Reset)))
+		c.Header(PolicyHeader, fmt.Sprintf("%d;w=%d", limit.Burst, CeilSeconds(p.Per)))
+
+		if !result.Allowed {
+			retryAfter := CeilSeconds(result.RetryAfter)
+			c.Header(RetryAfterHeader, strconv.Itoa(retryAfter))
+			problem.Abort(c, problem.Newf(problem.CodeRateLimited, ErrRateLimited, retryAfter))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// CeilSeconds rounds a duration up to whole seconds, as reported to clients.
+func CeilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
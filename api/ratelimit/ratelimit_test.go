@@ -0,0 +1,180 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// failingStore is a Store that always fails
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Limit) (Result, error) {
+	return Result{}, fmt.Errorf("expected error")
+}
+
+// TestMiddleware checks that requests are limited by the policy for their
+// route and method, per user or per client address.
+func TestMiddleware(t *testing.T) {
+	policies := []Policy{
+		{Route: "/signup", Method: http.MethodPost, Requests: 1, Per: time.Minute},
+		{Route: "/sets/:id", Requests: 2, Per: time.Minute},
+		{Route: "/api/login", Requests: 1, Per: time.Minute},
+		{Route: AnyRoute, Requests: 100, Per: time.Minute},
+	}
+
+	type request struct {
+		method string
+		path   string
+		userID models.UserID
+		ip     string
+	}
+	tests := []struct {
+		name        string
+		store       Store
+		requests    []request
+		wantCode    int
+		wantLimit   string
+		wantHeaders bool
+	}{
+		{
+			name: "Requests within the limit are allowed",
+			requests: []request{
+				{method: http.MethodPost, path: "/signup", ip: "10.0.0.1"},
+			},
+			wantCode:    http.StatusOK,
+			wantLimit:   "1",
+			wantHeaders: true,
+		},
+		{
+			name: "Requests over the limit are rejected",
+			requests: []request{
+				{method: http.MethodPost, path: "/signup", ip: "10.0.0.1"},
+				{method: http.MethodPost, path: "/signup", ip: "10.0.0.1"},
+			},
+			wantCode:    http.StatusTooManyRequests,
+			wantLimit:   "1",
+			wantHeaders: true,
+		},
+		{
+			name: "Clients are limited separately",
+			requests: []request{
+				{method: http.MethodPost, path: "/signup", ip: "10.0.0.1"},
+				{method: http.MethodPost, path: "/signup", ip: "10.0.0.2"},
+			},
+			wantCode:    http.StatusOK,
+			wantLimit:   "1",
+			wantHeaders: true,
+		},
+		{
+			name: "Users are limited across addresses",
+			requests: []request{
+				{method: http.MethodGet, path: "/sets/1", userID: 3, ip: "10.0.0.1"},
+				{method: http.MethodGet, path: "/sets/2", userID: 3, ip: "10.0.0.2"},
+				{method: http.MethodGet, path: "/sets/1", userID: 3, ip: "10.0.0.3"},
+			},
+			wantCode:    http.StatusTooManyRequests,
+			wantLimit:   "2",
+			wantHeaders: true,
+		},
+		{
+			name: "Routes without a policy use the policy for any route",
+			requests: []request{
+				{method: http.MethodGet, path: "/signup", ip: "10.0.0.1"},
+				{method: http.MethodGet, path: "/signup", ip: "10.0.0.1"},
+			},
+			wantCode:    http.StatusOK,
+			wantLimit:   "100",
+			wantHeaders: true,
+		},
+		{
+			name: "Versions of a route share its policy",
+			requests: []request{
+				{method: http.MethodPost, path: "/api/v1/login", ip: "10.0.0.1"},
+				{method: http.MethodPost, path: "/api/v2/login", ip: "10.0.0.1"},
+			},
+			wantCode:    http.StatusTooManyRequests,
+			wantLimit:   "1",
+			wantHeaders: true,
+		},
+		{
+			name:  "Failing store allows requests",
+			store: failingStore{},
+			requests: []request{
+				{method: http.MethodPost, path: "/signup", ip: "10.0.0.1"},
+			},
+			wantCode: http.StatusOK,
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, v := range tests {
+		store := v.store
+		if store == nil {
+			store = NewMemoryStore()
+		}
+		limiter := New(store, policies)
+
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			if id := c.GetHeader("X-Test-User"); id != "" {
+				var userID models.UserID
+				fmt.Sscan(id, &userID)
+				c.Set(users.UserIDFromContextKey, userID)
+			}
+		}, limiter.Middleware())
+		ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+		router.Any("/signup", ok)
+		router.GET("/sets/:id", ok)
+		router.POST("/api/v1/login", ok)
+		router.POST("/api/v2/login", ok)
+
+		var w *httptest.ResponseRecorder
+		for _, r := range v.requests {
+			w = httptest.NewRecorder()
+			req, _ := http.NewRequest(r.method, r.path, nil)
+			req.RemoteAddr = r.ip + ":1234"
+			if r.userID != 0 {
+				req.Header.Set("X-Test-User", fmt.Sprint(r.userID))
+			}
+			router.ServeHTTP(w, req)
+		}
+
+		if w.Code != v.wantCode {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v\nBody: %v", v.name, v.wantCode, w.Code, w.Body.String())
+		}
+		if !v.wantHeaders {
+			if w.Header().Get(LimitHeader) != "" {
+				t.Fatalf("%s: Wanted no rate limit headers, got %v", v.name, w.Header())
+			}
+			continue
+		}
+		if got := w.Header().Get(LimitHeader); got != v.wantLimit {
+			t.Fatalf("%s: Wanted %v %q, got %q", v.name, LimitHeader, v.wantLimit, got)
+		}
+		for _, h := range []string{RemainingHeader, ResetHeader, PolicyHeader} {
+			if w.Header().Get(h) == "" {
+				t.Fatalf("%s: Wanted %v header, got %v", v.name, h, w.Header())
+			}
+		}
+
+		if v.wantCode == http.StatusTooManyRequests {
+			if w.Header().Get(RetryAfterHeader) == "" {
+				t.Fatalf("%s: Wanted %v header", v.name, RetryAfterHeader)
+			}
+			var p problem.Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil || p.Code != problem.CodeRateLimited {
+				t.Fatalf("%s: Wanted %v problem, got %v", v.name, problem.CodeRateLimited, w.Body.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the memory store drops buckets that have
+// refilled, and so no longer need to be kept
+const sweepInterval = time.Minute
+
+// Limit describes a token bucket: it holds at most Burst tokens, and refills
+// at Rate tokens per second
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the state of a bucket after a token is taken from it
+type Result struct {
+	// Allowed is set if a token was available
+	Allowed bool
+	// Remaining is the number of whole tokens left in the bucket
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until a token is available, if none was
+	RetryAfter time.Duration
+}
+
+// Store holds token buckets. Implementations may share buckets between
+// servers, so that clients are limited across every replica.
+type Store interface {
+	// Take removes a token from the bucket identified by key, creating a full
+	// bucket described by limit if none exists.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// bucket is a token bucket held in memory
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+// refill adds the tokens accrued since the bucket was last used.
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+	b.last = now
+}
+
+// memoryStore holds token buckets in memory, so they are not shared between
+// servers and are lost on restart
+type memoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore returns a Store holding token buckets in memory.
+func NewMemoryStore() Store {
+	return newMemoryStore(time.Now)
+}
+
+func newMemoryStore(now func() time.Time) *memoryStore {
+	return &memoryStore{
+		buckets:   make(map[string]*bucket),
+		lastSweep: now(),
+		now:       now,
+	}
+}
+
+// Take implements the Store interface method for taking a token.
+func (m *memoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok || b.limit != limit {
+		// buckets are recreated when their policy changes
+		b = &bucket{tokens: float64(limit.Burst), last: now, limit: limit}
+		m.buckets[key] = b
+	}
+	b.refill(now)
+
+	var result Result
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((float64(limit.Burst) - b.tokens) / limit.Rate)
+
+	return result, nil
+}
+
+// sweep drops buckets that have refilled, at most once per sweepInterval.
+func (m *memoryStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+
+	for key, b := range m.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+// seconds converts a number of seconds to a duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
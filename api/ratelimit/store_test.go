@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestMemoryStoreTake checks that tokens are taken until the bucket is
+// empty, and refill at the bucket's rate.
+func TestMemoryStoreTake(t *testing.T) {
+	now := time.Unix(0, 0)
+	store := newMemoryStore(func() time.Time { return now })
+	limit := Limit{Rate: 1, Burst: 2}
+
+	steps := []struct {
+		name          string
+		advance       time.Duration
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+	}{
+		{name: "First request is allowed", wantAllowed: true, wantRemaining: 1},
+		{name: "Burst is allowed", wantAllowed: true, wantRemaining: 0},
+		{name: "Empty bucket is denied", wantAllowed: false, wantRemaining: 0, wantRetry: time.Second},
+		{name: "Partially refilled bucket is denied", advance: 500 * time.Millisecond, wantAllowed: false, wantRetry: 500 * time.Millisecond},
+		{name: "Refilled token is allowed", advance: 500 * time.Millisecond, wantAllowed: true, wantRemaining: 0},
+		{name: "Bucket does not overfill", advance: time.Hour, wantAllowed: true, wantRemaining: 1},
+	}
+
+	for _, v := range steps {
+		now = now.Add(v.advance)
+		got, err := store.Take(context.Background(), "key", limit)
+		if err != nil {
+			t.Fatalf("%s: Encountered unexpected error: %v", v.name, err)
+		}
+		if got.Allowed != v.wantAllowed || got.Remaining != v.wantRemaining || got.RetryAfter != v.wantRetry {
+			t.Fatalf("%s: Unexpected result: %+v", v.name, got)
+		}
+	}
+
+	// other keys have their own buckets
+	if got, _ := store.Take(context.Background(), "other", limit); got.Remaining != 1 {
+		t.Fatalf("Wanted separate bucket for other key, got %+v", got)
+	}
+}
+
+// TestMemoryStoreSweep checks that refilled buckets are dropped.
+func TestMemoryStoreSweep(t *testing.T) {
+	now := time.Unix(0, 0)
+	store := newMemoryStore(func() time.Time { return now })
+	limit := Limit{Rate: 1, Burst: 1}
+
+	store.Take(context.Background(), "idle", limit)
+	now = now.Add(sweepInterval)
+	store.Take(context.Background(), "active", limit)
+
+	if _, ok := store.buckets["idle"]; ok {
+		t.Fatalf("Wanted refilled bucket to be dropped")
+	}
+	if _, ok := store.buckets["active"]; !ok {
+		t.Fatalf("Wanted bucket in use to be kept")
+	}
+}
@@ -4,33 +4,95 @@ import (
 	"database/sql"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/audit"
+	"github.com/hrand1005/training-notebook/api/events"
+	"github.com/hrand1005/training-notebook/api/graphql"
+	"github.com/hrand1005/training-notebook/api/rooms"
 	"github.com/hrand1005/training-notebook/api/sets"
 	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/api/version"
+	"github.com/hrand1005/training-notebook/api/webhooks"
 	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
 )
 
-// RegisterAll uses registers all api endpoints on the given RouterGroup.
-// Additionally, the provided db handle will be used for all resources.
-func RegisterAll(db *sql.DB, g *gin.RouterGroup) error {
+// RegisterAll registers all api endpoints on the given RouterGroup, once for
+// each supported version under the version's path, e.g. /v2/sets/.
+// Additionally, the provided db handle will be used for all resources, and
+// changes to sets and users are published to hub for the event stream,
+// workout rooms and webhooks. The returned channel is closed once background
+// work, such as delivering webhooks, has stopped after hub is closed.
+func RegisterAll(db *sql.DB, g *gin.RouterGroup, hub *events.Hub) (<-chan struct{}, error) {
+	auditDB, err := data.NewAuditDB(db)
+	if err != nil {
+		return nil, err
+	}
+
 	setDB, err := data.NewSetDB(db)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	setResource, err := sets.New(setDB)
+	setResource, err := sets.New(setDB, auditDB, hub)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	setResource.RegisterHandlers(g)
 
 	userDB, err := data.NewUserDB(db)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	userResource, err := users.New(userDB, auditDB)
+	if err != nil {
+		return nil, err
+	}
+
+	// changes are published here, since events depends on the users package
+	userResource.OnChange(func(action models.AuditAction, changed *models.User) {
+		hub.Publish(events.UserEvent(action, changed))
+	})
+
+	auditResource, err := audit.New(auditDB, userDB)
+	if err != nil {
+		return nil, err
 	}
-	userResource, err := users.New(userDB)
+
+	graphqlResource, err := graphql.New(setDB, userDB)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	eventResource, err := events.New(hub, userDB)
+	if err != nil {
+		return nil, err
+	}
+
+	roomResource, err := rooms.New(hub, userDB)
+	if err != nil {
+		return nil, err
+	}
+
+	webhookDB, err := data.NewWebhookDB(db)
+	if err != nil {
+		return nil, err
+	}
+	webhookResource, err := webhooks.New(webhookDB, userDB, hub)
+	if err != nil {
+		return nil, err
+	}
+
+	// resources handle every version, in the representation of the version
+	// serving the request
+	for _, v := range version.Supported {
+		vg := g.Group(v.Path())
+		vg.Use(version.Middleware(v))
+		setResource.RegisterHandlers(vg)
+		userResource.RegisterHandlers(vg)
+		auditResource.RegisterHandlers(vg)
+		graphqlResource.RegisterHandlers(vg)
+		eventResource.RegisterHandlers(vg)
+		roomResource.RegisterHandlers(vg)
+		webhookResource.RegisterHandlers(vg)
 	}
-	userResource.RegisterHandlers(g)
 
-	return nil
+	return webhookResource.Stopped(), nil
 }
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/events"
+	"github.com/hrand1005/training-notebook/api/openapi"
+	"github.com/hrand1005/training-notebook/api/version"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/docs"
+)
+
+// paramName matches the name of a path parameter, which may differ between
+// the spec and the gin route
+var paramName = regexp.MustCompile(`:[^/]+`)
+
+// TestRoutesMatchSpec checks that every registered endpoint is documented by
+// the swagger spec of its version, and that every documented operation is
+// registered.
+func TestRoutesMatchSpec(t *testing.T) {
+	var specs []*openapi.Spec
+	for _, v := range version.Supported {
+		raw, err := fs.ReadFile(docs.FS, docs.SwaggerFileFor(int(v)))
+		if err != nil {
+			t.Fatalf("failed to read %v spec: %v", v, err)
+		}
+		spec, err := openapi.Load(raw)
+		if err != nil {
+			t.Fatalf("failed to load %v spec: %v", v, err)
+		}
+		specs = append(specs, spec)
+	}
+
+	db, err := data.SqliteDB(filepath.Join(t.TempDir(), "test.sqlite"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer db.Close()
+
+	router := gin.New()
+	if _, err := RegisterAll(db, router.Group("/api"), events.NewHub(0, 0)); err != nil {
+		t.Fatalf("failed to register endpoints: %v", err)
+	}
+
+	registered := make(map[openapi.Route]bool)
+	for _, r := range router.Routes() {
+		route := openapi.Route{Method: r.Method, Path: r.Path}
+		registered[normalize(route)] = true
+		if !documented(specs, route) {
+			t.Errorf("%v is registered but missing from the spec", route)
+		}
+	}
+
+	for _, spec := range specs {
+		for _, route := range spec.Routes() {
+			if !registered[normalize(route)] {
+				t.Errorf("%v is in the spec but not registered", route)
+			}
+		}
+	}
+}
+
+// documented reports whether any of specs documents r.
+func documented(specs []*openapi.Spec, r openapi.Route) bool {
+	for _, spec := range specs {
+		if spec.Documents(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize strips the names of path parameters from r.
+func normalize(r openapi.Route) openapi.Route {
+	r.Path = paramName.ReplaceAllString(r.Path, ":")
+	return r
+}
@@ -0,0 +1,61 @@
+// Package requestid assigns every request an identifier that can be used to
+// correlate logs, audit events and error responses.
+package requestid
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// Header is the HTTP header carrying the request id in requests and responses
+	Header = "X-Request-ID"
+	// ContextKey is used to retrieve the request id from gin.Context
+	ContextKey = "requestID"
+)
+
+// validID restricts client supplied ids to a reasonable length and charset,
+// so they can be safely written to logs and headers
+var validID = regexp.MustCompile(`^[A-Za-z0-9._\-]{1,64}$`)
+
+// Middleware reuses the request id supplied by the client in the X-Request-ID
+// header if it is valid, and otherwise generates a new one. The id is set in
+// the gin context and echoed in the response headers.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(Header)
+		if !Valid(id) {
+			id = New()
+		}
+
+		c.Set(ContextKey, id)
+		c.Header(Header, id)
+
+		c.Next()
+	}
+}
+
+// Valid reports whether id may be reused as a request id, rather than being
+// replaced by a generated one.
+func Valid(id string) bool {
+	return validID.MatchString(id)
+}
+
+// FromContext returns the request id set by Middleware, or an empty string if
+// the request has none.
+func FromContext(c *gin.Context) string {
+	return c.GetString(ContextKey)
+}
+
+// New generates a random request id.
+func New() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
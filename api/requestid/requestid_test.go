@@ -0,0 +1,88 @@
+package requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestMiddleware checks that valid request ids supplied by clients are kept,
+// that other requests are assigned a generated id, and that the id is echoed
+// in the response and returned by FromContext.
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		wantKept bool
+	}{
+		{
+			name:     "Incoming request id is kept",
+			header:   "client-id_1.2",
+			wantKept: true,
+		},
+		{
+			name:     "Missing request id is generated",
+			header:   "",
+			wantKept: false,
+		},
+		{
+			name:     "Invalid request id is replaced",
+			header:   "id with spaces",
+			wantKept: false,
+		},
+		{
+			name:     "Overlong request id is replaced",
+			header:   strings.Repeat("a", 65),
+			wantKept: false,
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, v := range tests {
+		var fromContext string
+		r := gin.New()
+		r.Use(Middleware())
+		r.GET("/", func(c *gin.Context) {
+			fromContext = FromContext(c)
+			c.Status(http.StatusOK)
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		if v.header != "" {
+			req.Header.Set(Header, v.header)
+		}
+		r.ServeHTTP(w, req)
+
+		got := w.Header().Get(Header)
+		if v.wantKept && got != v.header {
+			t.Fatalf("%s: Wanted request id: %q\nGot request id: %q", v.name, v.header, got)
+		}
+		if !v.wantKept && (got == v.header || !Valid(got)) {
+			t.Fatalf("%s: Wanted a generated request id\nGot request id: %q", v.name, got)
+		}
+		if fromContext != got {
+			t.Fatalf("%s: Wanted FromContext: %q\nGot FromContext: %q", v.name, got, fromContext)
+		}
+	}
+}
+
+// TestFromContext checks that requests that did not pass through the
+// middleware have no request id.
+func TestFromContext(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if got := FromContext(c); got != "" {
+		t.Fatalf("Wanted no request id\nGot request id: %q", got)
+	}
+}
+
+// TestNew checks that generated request ids are valid and distinct.
+func TestNew(t *testing.T) {
+	a, b := New(), New()
+	if !Valid(a) || !Valid(b) || a == b {
+		t.Fatalf("Wanted two distinct valid request ids\nGot request ids: %q, %q", a, b)
+	}
+}
@@ -0,0 +1,114 @@
+package rooms
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hrand1005/training-notebook/api/version"
+	"github.com/hrand1005/training-notebook/models"
+	"github.com/hrand1005/training-notebook/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// writeWait is how long a message may take to write
+	writeWait = 10 * time.Second
+	// pongWait is how long a participant may take to answer a ping
+	pongWait = 60 * time.Second
+	// pingPeriod is how often participants are pinged, which must be less
+	// than pongWait
+	pingPeriod = pongWait * 9 / 10
+	// maxMessageSize is the largest message accepted from a participant
+	maxMessageSize = 4096
+)
+
+// client is a participant's connection to a room
+type client struct {
+	conn    *websocket.Conn
+	userID  models.UserID
+	role    string
+	version version.Version
+	// joined is the span of the request that opened the connection, which
+	// the spans of messages link to
+	joined trace.SpanContext
+
+	// send queues the messages for the participant. It is closed by the room
+	// when it disconnects them, after setting the close code and reason.
+	send        chan []byte
+	closeCode   int
+	closeReason string
+}
+
+// readPump hands the messages read from the connection to rm, until the
+// connection fails or is closed.
+func (c *client) readPump(rm *room) {
+	defer func() {
+		rm.exit(c)
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		typ, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var msg inbound
+		if typ != websocket.TextMessage {
+			err = errNotText
+		} else {
+			err = json.Unmarshal(data, &msg)
+		}
+
+		// each message starts a trace of its own, since the connection may
+		// last for hours
+		_, span := tracing.Tracer().Start(context.Background(), "rooms.message",
+			trace.WithLinks(trace.Link{SpanContext: c.joined}),
+			trace.WithAttributes(
+				attribute.String("room.id", rm.ID),
+				attribute.String("message.type", msg.Type),
+				attribute.Int("user.id", int(c.userID)),
+			),
+		)
+		rm.receive(c, msg, err)
+		tracing.End(span, err)
+	}
+}
+
+// writePump writes the messages queued for the participant, and pings them
+// while they are idle. The connection is closed once the room disconnects
+// them.
+func (c *client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case msg, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, c.closeReason))
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
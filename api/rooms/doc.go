@@ -0,0 +1,82 @@
+// Package classification of Rooms API
+//
+// Documentation for Rooms API, sharing a live workout between an athlete and
+// their coaches over a WebSocket
+//
+//  Schemes: http
+//  BasePath: /api/v1
+//  Version: 1.0.0
+//
+//  Consumes:
+//  - application/json
+//
+//  Produces:
+//  - application/json
+//  - application/problem+json
+// swagger:meta
+package rooms
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hrand1005/training-notebook/api/events"
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/data"
+)
+
+type rooms struct {
+	hub   *events.Hub
+	users data.UserDB
+
+	mu    sync.Mutex
+	rooms map[string]*room
+
+	// restTick is how often rest timers report the time remaining
+	restTick time.Duration
+	// idleTimeout is how long a room stays open without participants
+	idleTimeout time.Duration
+	// sendBuffer is how many messages are queued for a participant before
+	// they are disconnected for falling behind
+	sendBuffer int
+}
+
+// swagger:parameters joinRoom
+// swagger:parameters closeRoom
+type roomIDParameter struct {
+	// The id of the room
+	// in: path
+	// required: true
+	ID string `json:"id"`
+}
+
+// swagger:parameters openRoom
+type roomParameter struct {
+	// The coaches invited to the room
+	// in: body
+	// required: true
+	Body RoomRequest
+}
+
+// returns a workout room in the response
+// swagger:response roomResponse
+type roomResponse struct {
+	// in: body
+	Body Room
+}
+
+// the connection was upgraded to a WebSocket carrying the room's messages
+// swagger:response switchingProtocols
+type switchingProtocols struct{}
+
+// returns an RFC 7807 problem describing the error
+// swagger:response errorResponse
+type errorResponse struct {
+	// Description of the error, with a machine-readable code
+	// in: body
+	Body problem.Problem
+}
+
+// the request succeeded without a response body
+// swagger:response noContent
+type noContent struct{}
@@ -0,0 +1,125 @@
+package rooms
+
+import (
+	"time"
+
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/api/version"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// Roles of the participants of a room
+const (
+	RoleAthlete = "athlete"
+	RoleCoach   = "coach"
+)
+
+// Types of the messages sent by the server. Changes to the athlete's sets
+// are sent with the types of the events package, e.g. set.created.
+const (
+	TypeState       = "state"
+	TypeJoined      = "joined"
+	TypeLeft        = "left"
+	TypeComment     = "comment"
+	TypeRestStarted = "rest.started"
+	TypeRestTick    = "rest.tick"
+	TypeRestDone    = "rest.done"
+	TypeRestStopped = "rest.stopped"
+	TypeError       = "error"
+)
+
+// Types of the messages sent by participants
+const (
+	TypeRestStart = "rest.start"
+	TypeRestStop  = "rest.stop"
+)
+
+// Limits on the messages sent by participants
+const (
+	MaxCommentLength = 1000
+	MaxRestSeconds   = 3600
+)
+
+// RoomRequest is the body of a request to open a workout room
+type RoomRequest struct {
+	// the users invited to coach the session
+	CoachIDs []models.UserID `json:"coach-ids" binding:"max=10,dive,gte=0"`
+}
+
+// Room is a live workout session of an athlete, shared with the coaches they
+// invited
+type Room struct {
+	// the id of the room, assigned by the server
+	ID        string          `json:"room-id"`
+	AthleteID models.UserID   `json:"athlete-id"`
+	CoachIDs  []models.UserID `json:"coach-ids"`
+}
+
+// inbound is a message sent by a participant
+type inbound struct {
+	Type    string `json:"type"`
+	Text    string `json:"text"`
+	Seconds int    `json:"seconds"`
+}
+
+// message is a message sent to participants. Seq increases with every
+// message broadcast to the room, so clients can tell where a snapshot of
+// the state fits among them.
+type message struct {
+	Type string    `json:"type"`
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+	// the participant who caused the message
+	UserID models.UserID `json:"user-id,omitempty"`
+	Role   string        `json:"role,omitempty"`
+	Text   string        `json:"text,omitempty"`
+	// seconds left on the rest timer
+	Remaining int              `json:"remaining,omitempty"`
+	Set       interface{}      `json:"set,omitempty"`
+	State     *state           `json:"state,omitempty"`
+	Error     *problem.Problem `json:"error,omitempty"`
+
+	// set is rendered into Set in the representation of each participant's
+	// api version
+	set *models.Set
+}
+
+// state is a snapshot of a room, sent to participants when they join so that
+// clients reconnecting after missing messages can resync.
+type state struct {
+	Room
+	// the participants currently connected
+	Online []models.UserID `json:"online"`
+	// the athlete's sets logged or changed during the session, in the order
+	// they were first seen, without those since deleted
+	Entries  []interface{} `json:"entries"`
+	Comments []*message    `json:"comments"`
+	// seconds left on the rest timer, or zero if it is not running
+	Rest int `json:"rest"`
+
+	entries []*models.Set
+}
+
+// rendered returns m with its sets in the representation of v.
+func (m message) rendered(v version.Version) message {
+	if m.set != nil {
+		m.Set = setBody(v, m.set)
+	}
+	if m.State != nil {
+		st := *m.State
+		st.Entries = make([]interface{}, 0, len(st.entries))
+		for _, s := range st.entries {
+			st.Entries = append(st.Entries, setBody(v, s))
+		}
+		m.State = &st
+	}
+	return m
+}
+
+// setBody returns the representation of s in v.
+func setBody(v version.Version, s *models.Set) interface{} {
+	if v == version.V1 {
+		return s
+	}
+	return s.V2()
+}
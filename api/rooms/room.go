@@ -0,0 +1,403 @@
+package rooms
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hrand1005/training-notebook/api/events"
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/api/version"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// maxComments bounds the comments kept for the snapshot sent to joining
+// participants
+const maxComments = 100
+
+// Reasons given to participants when they are disconnected by the server
+const (
+	reasonClosed       = "room closed"
+	reasonIdle         = "room closed after being empty"
+	reasonShuttingDown = "server shutting down"
+	reasonFellBehind   = "fell behind, reconnect to resync"
+)
+
+// received is a message read from a participant's connection
+type received struct {
+	client *client
+	msg    inbound
+	err    error
+}
+
+// room runs a workout session. Its state is owned by the goroutine running
+// run, and every change reaches it through a channel, so that messages are
+// broadcast in the order the changes were made.
+type room struct {
+	Room
+
+	hub         *events.Hub
+	sub         *events.Subscription
+	lastEventID uint64
+
+	join     chan *client
+	leave    chan *client
+	received chan received
+	close    chan struct{}
+	// done is closed once the room has ended
+	done chan struct{}
+
+	clients    map[*client]struct{}
+	seq        uint64
+	entries    []*models.Set
+	comments   []*message
+	restLeft   int
+	restTicker *time.Ticker
+
+	restTick    time.Duration
+	idleTimeout time.Duration
+	onEnd       func()
+}
+
+// newRoom starts a room following the sets of r's athlete published to hub.
+// onEnd is called once the room has ended.
+func newRoom(r Room, hub *events.Hub, restTick, idleTimeout time.Duration, onEnd func()) *room {
+	rm := &room{
+		Room:        r,
+		hub:         hub,
+		join:        make(chan *client),
+		leave:       make(chan *client),
+		received:    make(chan received),
+		close:       make(chan struct{}),
+		done:        make(chan struct{}),
+		clients:     make(map[*client]struct{}),
+		restTick:    restTick,
+		idleTimeout: idleTimeout,
+		onEnd:       onEnd,
+	}
+	rm.sub, _, _ = hub.Subscribe(0, rm.follows)
+	rm.lastEventID = rm.sub.Start
+
+	go rm.run()
+	return rm
+}
+
+// role returns the role of the user in the room, and false if they are not
+// a participant.
+func (r *room) role(userID models.UserID) (string, bool) {
+	if userID == r.AthleteID {
+		return RoleAthlete, true
+	}
+	for _, id := range r.CoachIDs {
+		if id == userID {
+			return RoleCoach, true
+		}
+	}
+	return "", false
+}
+
+// follows reports whether e is a change to a set of the room's athlete.
+func (r *room) follows(e events.Event) bool {
+	return e.IsSet() && e.OwnerID == r.AthleteID
+}
+
+// enter adds c to the room, and returns false if the room has ended.
+func (r *room) enter(c *client) bool {
+	select {
+	case r.join <- c:
+		return true
+	case <-r.done:
+		return false
+	}
+}
+
+// exit removes c from the room, if it has not ended.
+func (r *room) exit(c *client) {
+	select {
+	case r.leave <- c:
+	case <-r.done:
+	}
+}
+
+// receive hands a message read from c to the room, if it has not ended.
+func (r *room) receive(c *client, msg inbound, err error) {
+	select {
+	case r.received <- received{client: c, msg: msg, err: err}:
+	case <-r.done:
+	}
+}
+
+// end closes the room, if it has not already ended.
+func (r *room) end() {
+	select {
+	case r.close <- struct{}{}:
+	case <-r.done:
+	}
+}
+
+func (r *room) run() {
+	// rooms nobody joins are closed too
+	idle := time.NewTimer(r.idleTimeout)
+	defer idle.Stop()
+
+	for {
+		var restTick <-chan time.Time
+		if r.restTicker != nil {
+			restTick = r.restTicker.C
+		}
+
+		select {
+		case c := <-r.join:
+			wasOnline := r.online(c.userID)
+			r.clients[c] = struct{}{}
+			idle.Stop()
+			if !wasOnline {
+				r.broadcast(&message{Type: TypeJoined, UserID: c.userID, Role: c.role}, c)
+			}
+			r.send(c, r.snapshot())
+
+		case c := <-r.leave:
+			if _, ok := r.clients[c]; ok {
+				r.drop(c, websocket.CloseNormalClosure, "")
+			}
+			if !r.online(c.userID) {
+				r.broadcast(&message{Type: TypeLeft, UserID: c.userID, Role: c.role}, nil)
+			}
+			if len(r.clients) == 0 {
+				idle.Reset(r.idleTimeout)
+			}
+
+		case in := <-r.received:
+			if _, ok := r.clients[in.client]; ok {
+				r.handle(in)
+			}
+
+		case e, ok := <-r.sub.Events:
+			if !ok {
+				if r.sub.Dropped {
+					r.resubscribe()
+					continue
+				}
+				r.shutdown(websocket.CloseGoingAway, reasonShuttingDown)
+				return
+			}
+			r.apply(e)
+
+		case <-restTick:
+			r.restLeft--
+			if r.restLeft > 0 {
+				r.broadcast(&message{Type: TypeRestTick, Remaining: r.restLeft}, nil)
+				continue
+			}
+			r.stopRest()
+			r.broadcast(&message{Type: TypeRestDone}, nil)
+
+		case <-idle.C:
+			r.shutdown(websocket.CloseNormalClosure, reasonIdle)
+			return
+
+		case <-r.close:
+			r.shutdown(websocket.CloseNormalClosure, reasonClosed)
+			return
+		}
+	}
+}
+
+// handle acts on a message sent by a participant.
+func (r *room) handle(in received) {
+	c := in.client
+	if in.err != nil {
+		r.sendError(c, problem.New(problem.CodeMalformedRequest, "messages must be json objects"))
+		return
+	}
+
+	switch in.msg.Type {
+	case TypeComment:
+		text := strings.TrimSpace(in.msg.Text)
+		if text == "" || len(text) > MaxCommentLength {
+			r.sendError(c, problem.Newf(problem.CodeValidationFailed, "comments must have 1 to %v characters", MaxCommentLength))
+			return
+		}
+		m := &message{Type: TypeComment, UserID: c.userID, Role: c.role, Text: text}
+		r.broadcast(m, nil)
+		r.comments = append(r.comments, m)
+		if len(r.comments) > maxComments {
+			r.comments = r.comments[len(r.comments)-maxComments:]
+		}
+
+	case TypeRestStart:
+		if in.msg.Seconds < 1 || in.msg.Seconds > MaxRestSeconds {
+			r.sendError(c, problem.Newf(problem.CodeValidationFailed, "rest must last 1 to %v seconds", MaxRestSeconds))
+			return
+		}
+		// starting a timer replaces any running one
+		r.stopRest()
+		r.restLeft = in.msg.Seconds
+		r.restTicker = time.NewTicker(r.restTick)
+		r.broadcast(&message{Type: TypeRestStarted, UserID: c.userID, Role: c.role, Remaining: r.restLeft}, nil)
+
+	case TypeRestStop:
+		if r.restTicker == nil {
+			return
+		}
+		r.stopRest()
+		r.broadcast(&message{Type: TypeRestStopped, UserID: c.userID, Role: c.role}, nil)
+
+	default:
+		r.sendError(c, problem.Newf(problem.CodeValidationFailed, "unknown message type %q", in.msg.Type))
+	}
+}
+
+// apply records a change to one of the athlete's sets, and broadcasts it.
+func (r *room) apply(e events.Event) {
+	r.lastEventID = e.ID
+
+	s := e.Set
+	i := 0
+	for i < len(r.entries) && r.entries[i].ID != s.ID {
+		i++
+	}
+	switch {
+	case e.Type == events.SetDeleted && i < len(r.entries):
+		r.entries = append(r.entries[:i], r.entries[i+1:]...)
+	case e.Type == events.SetDeleted:
+	case i < len(r.entries):
+		r.entries[i] = &s
+	default:
+		r.entries = append(r.entries, &s)
+	}
+
+	r.broadcast(&message{Type: e.Type, UserID: r.AthleteID, Role: RoleAthlete, set: &s}, nil)
+}
+
+// resubscribe follows the athlete's sets again after the room fell behind
+// the hub, catching up on the events it missed if they are still logged.
+func (r *room) resubscribe() {
+	sub, missed, ok := r.hub.Subscribe(r.lastEventID, r.follows)
+	if !ok {
+		slog.Warn("workout room missed set events", "room_id", r.ID)
+	}
+	r.sub = sub
+	for _, e := range missed {
+		r.apply(e)
+	}
+}
+
+// snapshot returns the state of the room.
+func (r *room) snapshot() *message {
+	st := &state{
+		Room:     r.Room,
+		Online:   []models.UserID{},
+		Comments: r.comments,
+		Rest:     r.restLeft,
+		entries:  r.entries,
+	}
+	if st.Comments == nil {
+		st.Comments = []*message{}
+	}
+	for c := range r.clients {
+		if !containsUser(st.Online, c.userID) {
+			st.Online = append(st.Online, c.userID)
+		}
+	}
+	return &message{Type: TypeState, Seq: r.seq, Time: time.Now().UTC(), State: st}
+}
+
+// broadcast numbers m and sends it to every participant but except.
+func (r *room) broadcast(m *message, except *client) {
+	r.seq++
+	m.Seq = r.seq
+	m.Time = time.Now().UTC()
+
+	// each version is only encoded once
+	encoded := make(map[version.Version][]byte)
+	for c := range r.clients {
+		if c == except {
+			continue
+		}
+		doc, ok := encoded[c.version]
+		if !ok {
+			var err error
+			if doc, err = json.Marshal(m.rendered(c.version)); err != nil {
+				slog.Error("failed to encode workout room message", "room_id", r.ID, "error", err)
+				return
+			}
+			encoded[c.version] = doc
+		}
+		r.deliver(c, doc)
+	}
+}
+
+// send sends m to c alone.
+func (r *room) send(c *client, m *message) {
+	doc, err := json.Marshal(m.rendered(c.version))
+	if err != nil {
+		slog.Error("failed to encode workout room message", "room_id", r.ID, "error", err)
+		return
+	}
+	r.deliver(c, doc)
+}
+
+// sendError tells c that its last message was rejected.
+func (r *room) sendError(c *client, p *problem.Problem) {
+	r.send(c, &message{Type: TypeError, Seq: r.seq, Time: time.Now().UTC(), Error: p})
+}
+
+// deliver queues doc for c. Participants whose queue is full are
+// disconnected rather than holding up the room, and resync when they
+// reconnect.
+func (r *room) deliver(c *client, doc []byte) {
+	select {
+	case c.send <- doc:
+	default:
+		r.drop(c, websocket.CloseTryAgainLater, reasonFellBehind)
+	}
+}
+
+// drop disconnects c with the given close code and reason.
+func (r *room) drop(c *client, code int, reason string) {
+	delete(r.clients, c)
+	c.closeCode = code
+	c.closeReason = reason
+	close(c.send)
+}
+
+// shutdown disconnects every participant and ends the room.
+func (r *room) shutdown(code int, reason string) {
+	r.stopRest()
+	r.hub.Unsubscribe(r.sub)
+	for c := range r.clients {
+		r.drop(c, code, reason)
+	}
+	close(r.done)
+	r.onEnd()
+}
+
+func (r *room) stopRest() {
+	if r.restTicker != nil {
+		r.restTicker.Stop()
+		r.restTicker = nil
+	}
+	r.restLeft = 0
+}
+
+// online reports whether userID has a connection to the room.
+func (r *room) online(userID models.UserID) bool {
+	for c := range r.clients {
+		if c.userID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func containsUser(ids []models.UserID, id models.UserID) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,301 @@
+package rooms
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/hrand1005/training-notebook/api/cors"
+	"github.com/hrand1005/training-notebook/api/events"
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/api/version"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+	"github.com/hrand1005/training-notebook/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Keys used to retrieve values from gin.Context
+const (
+	RoomIDFromParamsKey = "paramRoomID"
+)
+
+// Defaults for the rooms resource
+const (
+	// DefaultRestTick makes rest timers count down in seconds
+	DefaultRestTick = time.Second
+	// DefaultIdleTimeout is how long a room stays open without participants
+	DefaultIdleTimeout = 10 * time.Minute
+	// DefaultSendBuffer is how many messages are queued for a participant
+	// before they are disconnected for falling behind
+	DefaultSendBuffer = 64
+)
+
+// ErrRoomNotFound is the detail of the problem returned for rooms that do
+// not exist, or that the logged in user was not invited to.
+var ErrRoomNotFound = "no such room"
+
+// errNotText is reported to participants sending binary messages
+var errNotText = errors.New("messages must be text")
+
+// New returns the handler for workout rooms. Rooms follow the changes to the
+// athlete's sets published to hub, and userDB is used to check that invited
+// coaches exist.
+func New(hub *events.Hub, userDB data.UserDB) (*rooms, error) {
+	return &rooms{
+		hub:         hub,
+		users:       userDB,
+		rooms:       make(map[string]*room),
+		restTick:    DefaultRestTick,
+		idleTimeout: DefaultIdleTimeout,
+		sendBuffer:  DefaultSendBuffer,
+	}, nil
+}
+
+// allowedOrigins is the CORS policy of the api, whose origins allowed
+// credentials may open WebSockets from other origins
+var allowedOrigins = cors.New(cors.Options{})
+
+// SetAllowedOrigins sets the CORS policy of the api. Browsers send login
+// cookies with every WebSocket, so only origins allowed credentials may open
+// them from another origin. It must be called before the server starts, and
+// changes to the policy's options apply to WebSockets opened afterwards.
+func SetAllowedOrigins(policy *cors.Policy) {
+	allowedOrigins = policy
+}
+
+func (r *rooms) RegisterHandlers(g *gin.RouterGroup) {
+	roomGroup := g.Group("/rooms")
+	roomGroup.Use(users.RequireAuthorization())
+	roomGroup.POST("/", tracing.HandlerSpan(), r.Create)
+	roomGroup.DELETE("/:"+RoomIDFromParamsKey, tracing.HandlerSpan(), r.Delete)
+	// connections outlive any handler span, so each message is traced instead
+	roomGroup.GET("/:"+RoomIDFromParamsKey+"/ws", r.Join)
+}
+
+// swagger:route POST /rooms/ rooms openRoom
+// Open a workout room for the logged in athlete, inviting the given coaches.
+// responses:
+//  201: roomResponse
+//  400: errorResponse
+//  401: errorResponse
+//  500: errorResponse
+//  default: errorResponse
+
+// Create is the handler opening a workout room for the logged in user. The
+// room stays open until the athlete closes it, or nobody has been connected
+// to it for the idle timeout.
+func (r *rooms) Create(c *gin.Context) {
+	userID, err := users.UserIDFromContext(c)
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeUnauthenticated, "you must be logged in to perform this action"))
+		return
+	}
+
+	var req RoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Abort(c, problem.FromBindingError(err))
+		return
+	}
+
+	// athletes cannot coach themselves, nor be invited twice
+	coachIDs := []models.UserID{}
+	for _, id := range req.CoachIDs {
+		if id != userID && !containsUser(coachIDs, id) {
+			coachIDs = append(coachIDs, id)
+		}
+	}
+
+	userDB := data.TraceUserDB(tracing.Context(c), r.users)
+	for _, id := range coachIDs {
+		if _, err := userDB.UserByID(id); err != nil {
+			if err == data.ErrNotFound {
+				p := problem.New(problem.CodeValidationFailed, "coaches must be existing users")
+				p.Errors = []models.FieldError{{
+					Field:   "CoachIDs",
+					Tag:     "exists",
+					Message: fmt.Sprintf("no user with id %v", id),
+				}}
+				problem.Abort(c, p)
+				return
+			}
+			problem.Internal(c, err)
+			return
+		}
+	}
+
+	id, err := newRoomID()
+	if err != nil {
+		problem.Internal(c, err)
+		return
+	}
+
+	var rm *room
+	r.mu.Lock()
+	rm = newRoom(Room{ID: id, AthleteID: userID, CoachIDs: coachIDs}, r.hub, r.restTick, r.idleTimeout, func() {
+		r.remove(rm)
+	})
+	r.rooms[id] = rm
+	r.mu.Unlock()
+
+	c.IndentedJSON(http.StatusCreated, rm.Room)
+}
+
+// swagger:route DELETE /rooms/{id} rooms closeRoom
+// Close a workout room, disconnecting its participants. Only the athlete may
+// close their room.
+// responses:
+//  204: noContent
+//  401: errorResponse
+//  403: errorResponse
+//  404: errorResponse
+//  default: errorResponse
+
+// Delete is the handler closing a workout room.
+func (r *rooms) Delete(c *gin.Context) {
+	userID, err := users.UserIDFromContext(c)
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeUnauthenticated, "you must be logged in to perform this action"))
+		return
+	}
+
+	rm, ok := r.participating(c.Param(RoomIDFromParamsKey), userID)
+	if !ok {
+		problem.Abort(c, problem.New(problem.CodeNotFound, ErrRoomNotFound))
+		return
+	}
+	if rm.AthleteID != userID {
+		problem.Abort(c, problem.New(problem.CodeForbidden, "only the athlete may close their room"))
+		return
+	}
+
+	r.remove(rm)
+	rm.end()
+	c.Status(http.StatusNoContent)
+}
+
+// swagger:route GET /rooms/{id}/ws rooms joinRoom
+// Join a workout room over a WebSocket. The first message is the state of the
+// room; clients that reconnect after missing messages are resynced by it.
+// responses:
+//  101: switchingProtocols
+//  400: errorResponse
+//  401: errorResponse
+//  403: errorResponse
+//  404: errorResponse
+//  default: errorResponse
+
+// Join is the handler upgrading the connection of a participant to a
+// WebSocket, over which they receive the room's messages and send their
+// comments and rest timers.
+func (r *rooms) Join(c *gin.Context) {
+	userID, err := users.UserIDFromContext(c)
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeUnauthenticated, "you must be logged in to perform this action"))
+		return
+	}
+
+	rm, ok := r.participating(c.Param(RoomIDFromParamsKey), userID)
+	if !ok {
+		problem.Abort(c, problem.New(problem.CodeNotFound, ErrRoomNotFound))
+		return
+	}
+	role, _ := rm.role(userID)
+
+	// recorded for the request log, unless the upgrade fails
+	c.Status(http.StatusSwitchingProtocols)
+	conn, err := upgrader(c).Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// the upgrader has replied with a problem
+		return
+	}
+
+	cl := &client{
+		conn:    conn,
+		userID:  userID,
+		role:    role,
+		version: version.FromContext(c),
+		joined:  trace.SpanContextFromContext(tracing.Context(c)),
+		send:    make(chan []byte, r.sendBuffer),
+	}
+	if !rm.enter(cl) {
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, reasonClosed), time.Now().Add(writeWait))
+		conn.Close()
+		return
+	}
+	go cl.writePump()
+	cl.readPump(rm)
+}
+
+// participating returns the room with the given id, and false if it does not
+// exist or userID was not invited to it, so that rooms cannot be discovered
+// by others.
+func (r *rooms) participating(id string, userID models.UserID) (*room, bool) {
+	r.mu.Lock()
+	rm, ok := r.rooms[id]
+	r.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	if _, ok := rm.role(userID); !ok {
+		return nil, false
+	}
+	return rm, true
+}
+
+// remove forgets rm, if it is still registered.
+func (r *rooms) remove(rm *room) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.rooms[rm.ID] == rm {
+		delete(r.rooms, rm.ID)
+	}
+}
+
+// upgrader returns the upgrader for the connection of c, which only accepts
+// WebSockets allowed by checkOrigin, and replies to failed upgrades with a
+// problem.
+func upgrader(c *gin.Context) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		HandshakeTimeout: writeWait,
+		CheckOrigin:      checkOrigin,
+		Error: func(w http.ResponseWriter, req *http.Request, status int, reason error) {
+			code := problem.CodeMalformedRequest
+			if status == http.StatusForbidden {
+				code = problem.CodeOriginNotAllowed
+			}
+			problem.Abort(c, problem.New(code, reason.Error()))
+		},
+	}
+}
+
+// checkOrigin accepts WebSockets opened without an Origin header, such as by
+// native clients, from the api's own origin, or from origins allowed
+// credentials by the CORS options.
+func checkOrigin(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, req.Host) {
+		return true
+	}
+	return allowedOrigins.AllowsCredentials(origin)
+}
+
+// newRoomID returns a random, unguessable room id.
+func newRoomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
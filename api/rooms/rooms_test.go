@@ -0,0 +1,462 @@
+package rooms
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/hrand1005/training-notebook/api/cors"
+	"github.com/hrand1005/training-notebook/api/events"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/api/version"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// testUserHeader names the logged in user of requests to the test server
+const testUserHeader = "Test-User"
+
+// TestCreate tests the API layer's Create method for the Rooms resource.
+func TestCreate(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantCode    int
+		wantCoaches []models.UserID
+	}{
+		{
+			name:        "Room is opened with the invited coaches",
+			body:        `{"coach-ids": [2, 3]}`,
+			wantCode:    http.StatusCreated,
+			wantCoaches: []models.UserID{2, 3},
+		},
+		{
+			name:        "Duplicate coaches and the athlete are not invited",
+			body:        `{"coach-ids": [2, 1, 2]}`,
+			wantCode:    http.StatusCreated,
+			wantCoaches: []models.UserID{2},
+		},
+		{
+			name:        "Room is opened without coaches",
+			body:        `{}`,
+			wantCode:    http.StatusCreated,
+			wantCoaches: []models.UserID{},
+		},
+		{
+			name:     "Unknown coach returns StatusBadRequest",
+			body:     `{"coach-ids": [2, 9]}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Negative coach id returns StatusBadRequest",
+			body:     `{"coach-ids": [-1]}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Malformed body returns StatusBadRequest",
+			body:     `{"coach-ids": "2"}`,
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, v := range tests {
+		r, server := testServer(t)
+		resp := do(t, server, http.MethodPost, "/rooms/", 1, v.body)
+		resp.Body.Close()
+		if resp.StatusCode != v.wantCode {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v", v.name, v.wantCode, resp.StatusCode)
+		}
+		open := registered(r)
+		if v.wantCode != http.StatusCreated {
+			if len(open) != 0 {
+				t.Fatalf("%s: Wanted no rooms\nGot rooms: %+v", v.name, open)
+			}
+			continue
+		}
+
+		var got Room
+		for _, rm := range open {
+			got = rm
+		}
+		if got.AthleteID != 1 || !equalUsers(got.CoachIDs, v.wantCoaches) {
+			t.Fatalf("%s: Wanted a room of athlete 1 with coaches %v\nGot room: %+v", v.name, v.wantCoaches, got)
+		}
+	}
+}
+
+// TestDelete tests the API layer's Delete method for the Rooms resource.
+// Room 1 has athlete 1 and coach 2.
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   models.UserID
+		roomID   string
+		wantCode int
+	}{
+		{
+			name:     "Athlete closes their room",
+			userID:   1,
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "Coach returns StatusForbidden",
+			userID:   2,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Uninvited user returns StatusNotFound",
+			userID:   3,
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Unknown room returns StatusNotFound",
+			userID:   1,
+			roomID:   "unknown",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, v := range tests {
+		r, server := testServer(t)
+		id := openRoom(t, server, 1, 2)
+		if v.roomID != "" {
+			id = v.roomID
+		}
+
+		resp := do(t, server, http.MethodDelete, "/rooms/"+id, v.userID, "")
+		resp.Body.Close()
+		if resp.StatusCode != v.wantCode {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v", v.name, v.wantCode, resp.StatusCode)
+		}
+		wantRooms := 1
+		if v.wantCode == http.StatusNoContent {
+			wantRooms = 0
+		}
+		if open := registered(r); len(open) != wantRooms {
+			t.Fatalf("%s: Wanted %v rooms\nGot rooms: %+v", v.name, wantRooms, open)
+		}
+	}
+}
+
+// TestJoin tests the API layer's Join method for the Rooms resource, which
+// only upgrades participants opening a WebSocket.
+func TestJoin(t *testing.T) {
+	_, server := testServer(t)
+	id := openRoom(t, server, 1, 2)
+
+	resp := do(t, server, http.MethodGet, "/rooms/"+id+"/ws", 1, "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Wanted code for a plain request: %v\nGot code: %v", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	_, resp, err := dial(server, id, 3)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Wanted code for an uninvited user: %v\nGot response: %v, error: %v", http.StatusNotFound, resp, err)
+	}
+}
+
+// TestJoinOrigin checks that WebSockets are only opened from the api's own
+// origin, or from origins allowed credentials by the CORS options.
+func TestJoinOrigin(t *testing.T) {
+	SetAllowedOrigins(cors.New(cors.Options{AllowedOrigins: []string{"http://localhost:3000"}, AllowCredentials: true}))
+	defer SetAllowedOrigins(cors.New(cors.Options{}))
+
+	_, server := testServer(t)
+	id := openRoom(t, server, 1, 2)
+
+	tests := []struct {
+		name     string
+		origin   string
+		wantCode int
+	}{
+		{name: "Native client without an origin is accepted", wantCode: http.StatusSwitchingProtocols},
+		{name: "Same origin is accepted", origin: server.URL, wantCode: http.StatusSwitchingProtocols},
+		{name: "Allowed origin is accepted", origin: "http://localhost:3000", wantCode: http.StatusSwitchingProtocols},
+		{name: "Other origin is forbidden", origin: "https://evil.example", wantCode: http.StatusForbidden},
+	}
+
+	for _, v := range tests {
+		header := http.Header{testUserHeader: {"1"}}
+		if v.origin != "" {
+			header.Set("Origin", v.origin)
+		}
+		url := "ws" + strings.TrimPrefix(server.URL, "http") + "/rooms/" + id + "/ws"
+		conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+		if resp == nil || resp.StatusCode != v.wantCode {
+			t.Fatalf("%s: Wanted code: %v\nGot response: %v, error: %v", v.name, v.wantCode, resp, err)
+		}
+		if conn != nil {
+			conn.Close()
+		}
+	}
+}
+
+// TestRoom checks that participants receive the athlete's sets, each other's
+// comments and the rest timer, and that reconnecting participants are resynced.
+func TestRoom(t *testing.T) {
+	r, server := testServer(t)
+	id := openRoom(t, server, 1, 2)
+
+	athlete := join(t, server, id, 1)
+	st := readMessage(t, athlete, TypeState)
+	if st.State.AthleteID != 1 || len(st.State.Online) != 1 {
+		t.Fatalf("Wanted a state with the athlete online\nGot state: %+v", st.State)
+	}
+
+	coach := join(t, server, id, 2)
+	readMessage(t, coach, TypeState)
+	if m := readMessage(t, athlete, TypeJoined); m.UserID != 2 || m.Role != RoleCoach {
+		t.Fatalf("Wanted coach 2 to have joined\nGot message: %+v", m)
+	}
+
+	// only changes to the athlete's sets are sent
+	r.hub.Publish(events.Event{Type: events.SetCreated, OwnerID: 3, Set: models.Set{ID: 7, UID: 3}})
+	r.hub.Publish(events.Event{Type: events.SetCreated, OwnerID: 1, Set: models.Set{ID: 8, UID: 1, Movement: "Squat"}})
+	for _, conn := range []*websocket.Conn{athlete, coach} {
+		if m := readMessage(t, conn, events.SetCreated); !strings.Contains(string(m.Set), `"set-id":8`) {
+			t.Fatalf("Wanted set 8\nGot set: %s", m.Set)
+		}
+	}
+
+	send(t, coach, `{"type": "comment", "text": "  keep your back straight "}`)
+	for _, conn := range []*websocket.Conn{athlete, coach} {
+		if m := readMessage(t, conn, TypeComment); m.Text != "keep your back straight" || m.UserID != 2 {
+			t.Fatalf("Wanted the coach's comment\nGot message: %+v", m)
+		}
+	}
+
+	send(t, athlete, `{"type": "rest.start", "seconds": 2}`)
+	for _, conn := range []*websocket.Conn{athlete, coach} {
+		if m := readMessage(t, conn, TypeRestStarted); m.Remaining != 2 {
+			t.Fatalf("Wanted a rest of 2\nGot message: %+v", m)
+		}
+		if m := readMessage(t, conn, TypeRestTick); m.Remaining != 1 {
+			t.Fatalf("Wanted 1 remaining\nGot message: %+v", m)
+		}
+		readMessage(t, conn, TypeRestDone)
+	}
+
+	send(t, athlete, `{"type": "comment", "text": ""}`)
+	if m := readMessage(t, athlete, TypeError); m.Error == nil || m.Error.Status != http.StatusBadRequest {
+		t.Fatalf("Wanted an error for an empty comment\nGot message: %+v", m)
+	}
+
+	// the coach reconnects, and is resynced
+	coach.Close()
+	if m := readMessage(t, athlete, TypeLeft); m.UserID != 2 {
+		t.Fatalf("Wanted coach 2 to have left\nGot message: %+v", m)
+	}
+	coach = join(t, server, id, 2)
+	st = readMessage(t, coach, TypeState)
+	if len(st.State.Entries) != 1 || len(st.State.Comments) != 1 || len(st.State.Online) != 2 {
+		t.Fatalf("Wanted a state with 1 entry, 1 comment and 2 online\nGot state: %+v", st.State)
+	}
+
+	// closing the room disconnects its participants
+	resp := do(t, server, http.MethodDelete, "/rooms/"+id, 1, "")
+	resp.Body.Close()
+	for _, conn := range []*websocket.Conn{athlete, coach} {
+		if code := closeCode(t, conn); code != websocket.CloseNormalClosure {
+			t.Fatalf("Wanted close code: %v\nGot close code: %v", websocket.CloseNormalClosure, code)
+		}
+	}
+}
+
+// TestFallingBehind checks that participants who do not keep up with the
+// room are disconnected, without holding up the others.
+func TestFallingBehind(t *testing.T) {
+	hub := events.NewHub(0, 0)
+	defer hub.Close()
+	rm := newRoom(Room{ID: "room", AthleteID: 1}, hub, time.Millisecond, time.Minute, func() {})
+
+	slow := &client{userID: 1, role: RoleAthlete, version: version.V1, send: make(chan []byte, 2)}
+	fast := &client{userID: 1, role: RoleAthlete, version: version.V1, send: make(chan []byte, 100)}
+	rm.enter(slow)
+	rm.enter(fast)
+	for i := 0; i < 5; i++ {
+		rm.receive(fast, inbound{Type: TypeComment, Text: "comment " + strconv.Itoa(i)}, nil)
+	}
+	rm.end()
+
+	n := 0
+	for range slow.send {
+		n++
+	}
+	if n != 2 || slow.closeCode != websocket.CloseTryAgainLater {
+		t.Fatalf("Wanted the slow participant to be sent 2 messages before closing with %v\nGot %v messages, close code: %v", websocket.CloseTryAgainLater, n, slow.closeCode)
+	}
+	n = 0
+	for range fast.send {
+		n++
+	}
+	// the state, and the comments
+	if n != 6 || fast.closeCode != websocket.CloseNormalClosure {
+		t.Fatalf("Wanted the fast participant to be sent 6 messages before closing with %v\nGot %v messages, close code: %v", websocket.CloseNormalClosure, n, fast.closeCode)
+	}
+}
+
+// testServer serves the rooms resource, with users 1 to 3 and fast rest
+// timers, until the test ends. Requests are made as the user named by the
+// testUserHeader.
+func testServer(t *testing.T) (*rooms, *httptest.Server) {
+	t.Helper()
+
+	hub := events.NewHub(0, 0)
+	r, err := New(hub, &data.MockUserDB{
+		UserByIDStub: func(id models.UserID) (*models.User, error) {
+			if id < 1 || id > 3 {
+				return nil, data.ErrNotFound
+			}
+			return &models.User{ID: id}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create rooms resource: %v", err)
+	}
+	r.restTick = 5 * time.Millisecond
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	g := router.Group("/rooms", func(c *gin.Context) {
+		id, _ := strconv.Atoi(c.GetHeader(testUserHeader))
+		c.Set(users.UserIDFromContextKey, models.UserID(id))
+		c.Set(version.ContextKey, version.V1)
+	})
+	g.POST("/", r.Create)
+	g.DELETE("/:"+RoomIDFromParamsKey, r.Delete)
+	g.GET("/:"+RoomIDFromParamsKey+"/ws", r.Join)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	// rooms end once the hub is closed, disconnecting their participants
+	// before the server waits for them
+	t.Cleanup(hub.Close)
+	return r, server
+}
+
+func do(t *testing.T, server *httptest.Server, method, path string, userID models.UserID, body string) *http.Response {
+	t.Helper()
+
+	req, _ := http.NewRequest(method, server.URL+path, bytes.NewBufferString(body))
+	req.Header.Set(testUserHeader, strconv.Itoa(int(userID)))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to %v %v: %v", method, path, err)
+	}
+	return resp
+}
+
+// openRoom opens a room for athleteID, and returns its id.
+func openRoom(t *testing.T, server *httptest.Server, athleteID models.UserID, coachIDs ...models.UserID) string {
+	t.Helper()
+
+	body, _ := json.Marshal(RoomRequest{CoachIDs: coachIDs})
+	resp := do(t, server, http.MethodPost, "/rooms/", athleteID, string(body))
+	defer resp.Body.Close()
+	var room Room
+	if err := json.NewDecoder(resp.Body).Decode(&room); err != nil || resp.StatusCode != http.StatusCreated {
+		t.Fatalf("failed to open room: %v, code: %v", err, resp.StatusCode)
+	}
+	return room.ID
+}
+
+func dial(server *httptest.Server, roomID string, userID models.UserID) (*websocket.Conn, *http.Response, error) {
+	header := http.Header{testUserHeader: {strconv.Itoa(int(userID))}}
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/rooms/" + roomID + "/ws"
+	return websocket.DefaultDialer.Dial(url, header)
+}
+
+// join connects userID to the room until the test ends.
+func join(t *testing.T, server *httptest.Server, roomID string, userID models.UserID) *websocket.Conn {
+	t.Helper()
+
+	conn, _, err := dial(server, roomID, userID)
+	if err != nil {
+		t.Fatalf("failed to join room: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func send(t *testing.T, conn *websocket.Conn, msg string) {
+	t.Helper()
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+		t.Fatalf("failed to send message: %v", err)
+	}
+}
+
+// receivedMessage is a message read by a participant
+type receivedMessage struct {
+	message
+	Set   json.RawMessage `json:"set"`
+	State *struct {
+		Room
+		Online   []models.UserID   `json:"online"`
+		Entries  []json.RawMessage `json:"entries"`
+		Comments []json.RawMessage `json:"comments"`
+	} `json:"state"`
+}
+
+// readMessage reads the next message from conn, which must be of type typ.
+func readMessage(t *testing.T, conn *websocket.Conn, typ string) receivedMessage {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var m receivedMessage
+	if err := conn.ReadJSON(&m); err != nil {
+		t.Fatalf("failed to read %v message: %v", typ, err)
+	}
+	if m.Type != typ {
+		t.Fatalf("Wanted message of type: %v\nGot message: %+v", typ, m)
+	}
+	return m
+}
+
+// closeCode reads from conn until it is closed, and returns the close code.
+func closeCode(t *testing.T, conn *websocket.Conn) int {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, _, err := conn.ReadMessage()
+		if ce, ok := err.(*websocket.CloseError); ok {
+			return ce.Code
+		}
+		if err != nil {
+			t.Fatalf("Wanted a close frame\nGot error: %v", err)
+		}
+	}
+}
+
+// registered returns the rooms open in r.
+func registered(r *rooms) map[string]Room {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	open := make(map[string]Room)
+	for id, rm := range r.rooms {
+		open[id] = rm.Room
+	}
+	return open
+}
+
+func equalUsers(a, b []models.UserID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
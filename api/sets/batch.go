@@ -0,0 +1,164 @@
+package sets
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/hrand1005/training-notebook/api/events"
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// MaxBatchSize is the largest number of operations accepted in a single batch
+const MaxBatchSize = 100
+
+// swagger:route POST /sets/batch sets batchSets
+// Create, update and delete several sets in a single transaction.
+// responses:
+//  200: batchResponse
+//  400: batchErrorResponse
+// 	401: errorResponse
+//  404: batchErrorResponse
+//  412: batchErrorResponse
+//  500: errorResponse
+//  default: errorResponse
+
+// Batch is the handler for batch requests on the set resource. The request is
+// an array of operations, each of which is validated like the equivalent
+// single request. Operations are applied in order in a single transaction, so
+// if any operation fails none are applied. Results and errors identify each
+// operation by its index in the request.
+func (s *set) Batch(c *gin.Context) {
+	userID, err := users.UserIDFromContext(c)
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeUnauthenticated, "you must be logged in to perform this action"))
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeMalformedRequest, "Malformed JSON"))
+		return
+	}
+
+	ops, err := decodeOperations(c, body)
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeMalformedRequest, "Malformed JSON"))
+		return
+	}
+	if len(ops) == 0 || len(ops) > MaxBatchSize {
+		problem.Abort(c, problem.Newf(problem.CodeValidationFailed, "batch must contain between 1 and %v operations", MaxBatchSize))
+		return
+	}
+
+	// validate every operation before applying any, so that all problems with
+	// the batch are reported at once
+	var invalid []models.SetOperationResult
+	for i, op := range ops {
+		if msg := validateSetOperation(op, idField(c)); msg != "" {
+			invalid = append(invalid, models.SetOperationResult{
+				Index:   i,
+				Op:      op.Op,
+				Status:  http.StatusBadRequest,
+				Message: msg,
+			})
+		}
+	}
+	if len(invalid) > 0 {
+		p := problem.New(problem.CodeValidationFailed, "batch contains invalid operations, no operations were applied")
+		p.Extensions = map[string]interface{}{"operations": invalid}
+		problem.Abort(c, p)
+		return
+	}
+
+	if err := s.dbFor(c).BatchForUser(userID, ops); err != nil {
+		var batchErr *data.BatchError
+		if !errors.As(err, &batchErr) {
+			problem.Internal(c, err)
+			return
+		}
+
+		failed := ops[batchErr.Index]
+		result := models.SetOperationResult{Index: batchErr.Index, Op: failed.Op}
+		var code problem.Code
+		switch batchErr.Err {
+		case data.ErrNotFound:
+			code = problem.CodeNotFound
+			result.Message = fmt.Sprintf("no such set with id %v", failed.SetID)
+		case data.ErrVersionMismatch:
+			code = problem.CodePreconditionFailed
+			result.Message = ErrSetModified
+		default:
+			problem.Internal(c, err)
+			return
+		}
+
+		p := problem.Newf(code, "operation %v failed, no operations were applied", batchErr.Index)
+		result.Status = p.Status
+		p.Extensions = map[string]interface{}{"operations": []models.SetOperationResult{result}}
+		problem.Abort(c, p)
+		return
+	}
+
+	results := make([]models.SetOperationResult, 0, len(ops))
+	for i, op := range ops {
+		result := models.SetOperationResult{Index: i, Op: op.Op}
+		switch op.Op {
+		case models.SetOpCreate:
+			result.Status = http.StatusCreated
+			result.Set = op.Set
+			s.publish(events.SetCreated, userID, op.Set)
+		case models.SetOpUpdate:
+			result.Status = http.StatusOK
+			result.Set = op.Set
+			s.publish(events.SetUpdated, userID, op.Set)
+		case models.SetOpDelete:
+			result.Status = http.StatusNoContent
+			s.publish(events.SetDeleted, userID, op.Previous)
+		}
+		results = append(results, result)
+	}
+
+	c.IndentedJSON(http.StatusOK, resultsBody(c, results))
+}
+
+// validateSetOperation checks that an operation has the fields required by
+// its type, and that any set it carries passes the same validation as a
+// single create or update. Returns a description of the problem, or an empty
+// string if the operation is valid. idField is the name of the field holding
+// the operation's SetID.
+func validateSetOperation(op *models.SetOperation, idField string) string {
+	switch op.Op {
+	case models.SetOpCreate:
+		if op.SetID != 0 || op.Version != 0 {
+			return fmt.Sprintf("'%s' and 'version' fields must not be set on create.", idField)
+		}
+	case models.SetOpUpdate, models.SetOpDelete:
+		if op.SetID <= 0 {
+			return ErrInvalidSetID
+		}
+		if op.Version < 0 {
+			return "'version' field must be at least 0."
+		}
+	default:
+		return "'op' field must be one of create update delete."
+	}
+
+	if op.Op == models.SetOpDelete {
+		return ""
+	}
+
+	if op.Set == nil {
+		return "'set' field is required."
+	}
+	if err := binding.Validator.ValidateStruct(op.Set); err != nil {
+		return models.BindingErrorToMessage(err)
+	}
+
+	return ""
+}
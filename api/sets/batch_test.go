@@ -0,0 +1,180 @@
+package sets
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// TestBatchSets tests the API layer's Batch method for the Sets resource.
+// The test suite mocks the SetDB interface to test edge cases and error conditions.
+func TestBatchSets(t *testing.T) {
+	tests := []struct {
+		name        string
+		db          *data.MockSetDB
+		requestBody string
+		wantCode    int
+		wantResp    string
+	}{
+		{
+			name: "Valid batch returns StatusOK with a result per operation",
+			db: &data.MockSetDB{
+				BatchForUserStub: func(userID models.UserID, ops []*models.SetOperation) error {
+					ops[0].SetID = 7
+					ops[0].Set.ID = 7
+					ops[0].Set.UID = userID
+					ops[1].Set.ID = ops[1].SetID
+					ops[1].Set.UID = userID
+					return nil
+				},
+			},
+			requestBody: `[
+				{"op": "create", "set": {"movement": "Squat", "volume": 5, "intensity": 80}},
+				{"op": "update", "set-id": 3, "set": {"movement": "Press", "volume": 5, "intensity": 70}},
+				{"op": "delete", "set-id": 4}
+			]`,
+			wantCode: http.StatusOK,
+			wantResp: `[
+				{
+					"index": 0,
+					"op": "create",
+					"status": 201,
+					"set": {"set-id": 7, "user-id": 1, "movement": "Squat", "volume": 5, "intensity": 80}
+				},
+				{
+					"index": 1,
+					"op": "update",
+					"status": 200,
+					"set": {"set-id": 3, "user-id": 1, "movement": "Press", "volume": 5, "intensity": 70}
+				},
+				{
+					"index": 2,
+					"op": "delete",
+					"status": 204
+				}
+			]`,
+		},
+		{
+			name: "Invalid operations return StatusBadRequest with their indices",
+			db:   &data.MockSetDB{},
+			requestBody: `[
+				{"op": "create", "set": {"movement": "Squat", "volume": 5, "intensity": 80}},
+				{"op": "create", "set": {"movement": "Squat", "volume": 0, "intensity": 80}},
+				{"op": "update", "set": {"movement": "Squat", "volume": 5, "intensity": 80}},
+				{"op": "rename", "set-id": 2}
+			]`,
+			wantCode: http.StatusBadRequest,
+			wantResp: fmt.Sprintf(`{
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "batch contains invalid operations, no operations were applied",
+				"code": "validation-failed",
+				"operations": [
+					{"index": 1, "op": "create", "status": 400, "message": "'Volume' field must be greater than 0."},
+					{"index": 2, "op": "update", "status": 400, "message": %q},
+					{"index": 3, "op": "rename", "status": 400, "message": "'op' field must be one of create update delete."}
+				]
+			}`, ErrInvalidSetID),
+		},
+		{
+			name: "Failed operation returns its status and index",
+			db: &data.MockSetDB{
+				BatchForUserStub: func(userID models.UserID, ops []*models.SetOperation) error {
+					return &data.BatchError{Index: 1, Err: data.ErrNotFound}
+				},
+			},
+			requestBody: `[
+				{"op": "delete", "set-id": 3},
+				{"op": "delete", "set-id": 4}
+			]`,
+			wantCode: http.StatusNotFound,
+			wantResp: `{
+				"type": "urn:training-notebook:problem:not-found",
+				"title": "Resource not found",
+				"status": 404,
+				"detail": "operation 1 failed, no operations were applied",
+				"code": "not-found",
+				"operations": [
+					{"index": 1, "op": "delete", "status": 404, "message": "no such set with id 4"}
+				]
+			}`,
+		},
+		{
+			name:        "Empty batch returns StatusBadRequest",
+			db:          &data.MockSetDB{},
+			requestBody: `[]`,
+			wantCode:    http.StatusBadRequest,
+			wantResp: fmt.Sprintf(`{
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "batch must contain between 1 and %v operations",
+				"code": "validation-failed"
+			}`, MaxBatchSize),
+		},
+		{
+			name:        "Malformed body returns StatusBadRequest",
+			db:          &data.MockSetDB{},
+			requestBody: `{"op": "create"}`,
+			wantCode:    http.StatusBadRequest,
+			wantResp: `{
+				"type": "urn:training-notebook:problem:malformed-request",
+				"title": "Malformed request",
+				"status": 400,
+				"detail": "Malformed JSON",
+				"code": "malformed-request"
+			}`,
+		},
+		{
+			name: "Invalid db call returns InternalServerError",
+			db: &data.MockSetDB{
+				BatchForUserStub: func(userID models.UserID, ops []*models.SetOperation) error {
+					return fmt.Errorf("Expected error")
+				},
+			},
+			requestBody: `[{"op": "delete", "set-id": 3}]`,
+			wantCode:    http.StatusInternalServerError,
+			wantResp: `{
+				"type": "urn:training-notebook:problem:internal-error",
+				"title": "Internal server error",
+				"status": 500,
+				"detail": "an internal error occurred, quote the request id when reporting it",
+				"code": "internal-error"
+			}`,
+		},
+	}
+
+	for _, v := range tests {
+		ts, err := New(v.db, nil, nil)
+		if err != nil {
+			t.Fail()
+		}
+
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "", bytes.NewBufferString(v.requestBody))
+		c.Set(users.UserIDFromContextKey, models.UserID(1))
+
+		// execute batch with the test context
+		ts.Batch(c)
+
+		// check response code
+		if v.wantCode != w.Code {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v\nBody: %v", v.name, v.wantCode, w.Code, w.Body.String())
+		}
+
+		// check response body
+		if equal, _ := JSONBytesEqual([]byte(v.wantResp), w.Body.Bytes()); !equal {
+			t.Fatalf("%s: Wanted body: %v\nGot body: %v\n", v.name, v.wantResp, w.Body.String())
+		}
+	}
+}
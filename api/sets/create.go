@@ -4,30 +4,33 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/events"
+	"github.com/hrand1005/training-notebook/api/problem"
 	"github.com/hrand1005/training-notebook/api/users"
 	"github.com/hrand1005/training-notebook/models"
 )
 
-// swagger:route POST /sets sets createSet
+// swagger:route POST /sets/ sets createSet
 // Creates a set.
 // responses:
 //  201: setResponse
 //  400: errorResponse
 // 	401: errorResponse
 //  500: errorResponse
+//  default: errorResponse
 
 // Create is the handler for create requests on the set resource.
 func (s *set) Create(c *gin.Context) {
 	userID, err := users.UserIDFromContext(c)
 	if err != nil {
-		c.IndentedJSON(http.StatusUnauthorized, gin.H{"message": "you must be logged in with valid user to perform this action"})
+		problem.Abort(c, problem.New(problem.CodeUnauthenticated, "you must be logged in with valid user to perform this action"))
+		return
 	}
 
 	var newSet models.Set
 
-	if err := c.BindJSON(&newSet); err != nil {
-		msg := models.BindingErrorToMessage(err)
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": msg})
+	if err := bindSet(c, &newSet); err != nil {
+		problem.Abort(c, problem.FromBindingError(err))
 		return
 	}
 
@@ -35,12 +38,14 @@ func (s *set) Create(c *gin.Context) {
 	newSet.UID = userID
 
 	// assigns ID to newSet upon entry
-	id, err := s.db.AddSet(&newSet)
+	id, err := s.dbFor(c).AddSet(&newSet)
 	if err != nil {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		problem.Internal(c, err)
 		return
 	}
 
 	newSet.ID = id
-	c.IndentedJSON(http.StatusCreated, newSet)
+	s.publish(events.SetCreated, userID, &newSet)
+	c.Header("ETag", setETag(&newSet))
+	c.IndentedJSON(http.StatusCreated, setBody(c, &newSet))
 }
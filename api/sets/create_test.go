@@ -60,7 +60,11 @@ func TestCreateSet(t *testing.T) {
 			},
 			wantCode: http.StatusInternalServerError,
 			wantResp: *bytes.NewBufferString(` {
-				"message": "Expected Error"
+				"type": "urn:training-notebook:problem:internal-error",
+				"title": "Internal server error",
+				"status": 500,
+				"detail": "an internal error occurred, quote the request id when reporting it",
+				"code": "internal-error"
 			} `),
 		},
 		{
@@ -71,7 +75,14 @@ func TestCreateSet(t *testing.T) {
 			}`),
 			wantCode: http.StatusBadRequest,
 			wantResp: *bytes.NewBufferString(` {
-				"message": "'Movement' field must use unicode characters."
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "'Movement' field must use unicode characters.",
+				"code": "validation-failed",
+				"errors": [
+					{"field": "Movement", "code": "movement", "message": "'Movement' field must use unicode characters."}
+				]
 			} `),
 		},
 		{
@@ -82,7 +93,14 @@ func TestCreateSet(t *testing.T) {
 			}`),
 			wantCode: http.StatusBadRequest,
 			wantResp: *bytes.NewBufferString(` {
-				"message": "'Volume' field must be greater than 0."
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "'Volume' field must be greater than 0.",
+				"code": "validation-failed",
+				"errors": [
+					{"field": "Volume", "code": "gt", "message": "'Volume' field must be greater than 0."}
+				]
 			} `),
 		},
 		{
@@ -94,7 +112,14 @@ func TestCreateSet(t *testing.T) {
 			}`),
 			wantCode: http.StatusBadRequest,
 			wantResp: *bytes.NewBufferString(` {
-				"message": "'Volume' field must be greater than 0."
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "'Volume' field must be greater than 0.",
+				"code": "validation-failed",
+				"errors": [
+					{"field": "Volume", "code": "gt", "message": "'Volume' field must be greater than 0."}
+				]
 			} `),
 		},
 		{
@@ -105,7 +130,14 @@ func TestCreateSet(t *testing.T) {
 			}`),
 			wantCode: http.StatusBadRequest,
 			wantResp: *bytes.NewBufferString(` {
-				"message": "'Intensity' field must be greater than 0."
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "'Intensity' field must be greater than 0.",
+				"code": "validation-failed",
+				"errors": [
+					{"field": "Intensity", "code": "gt", "message": "'Intensity' field must be greater than 0."}
+				]
 			} `),
 		},
 		{
@@ -117,7 +149,14 @@ func TestCreateSet(t *testing.T) {
 			}`),
 			wantCode: http.StatusBadRequest,
 			wantResp: *bytes.NewBufferString(` {
-				"message": "'Intensity' field must be greater than 0."
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "'Intensity' field must be greater than 0.",
+				"code": "validation-failed",
+				"errors": [
+					{"field": "Intensity", "code": "gt", "message": "'Intensity' field must be greater than 0."}
+				]
 			} `),
 		},
 		{
@@ -129,13 +168,20 @@ func TestCreateSet(t *testing.T) {
 			}`),
 			wantCode: http.StatusBadRequest,
 			wantResp: *bytes.NewBufferString(` {
-				"message": "'Intensity' field must be no more than 100."
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "'Intensity' field must be no more than 100.",
+				"code": "validation-failed",
+				"errors": [
+					{"field": "Intensity", "code": "lte", "message": "'Intensity' field must be no more than 100."}
+				]
 			} `),
 		},
 	}
 	for _, v := range tests {
 		// configure test case with data and test context
-		ts, err := New(v.db)
+		ts, err := New(v.db, nil, nil)
 		if err != nil {
 			t.Fail()
 		}
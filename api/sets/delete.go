@@ -5,8 +5,11 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/events"
+	"github.com/hrand1005/training-notebook/api/problem"
 	"github.com/hrand1005/training-notebook/api/users"
 	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
 )
 
 // swagger:route DELETE /sets/{id} sets deleteSet
@@ -15,32 +18,68 @@ import (
 //  204: noContent
 // 	401: errorResponse
 //  404: errorResponse
+//  412: errorResponse
 //  500: errorResponse
+//  default: errorResponse
 
 // Delete is the handler for delete requests on the set resource. An id must be
-// specified.
+// specified. If the If-Match header is set, the delete only applies if it
+// matches the ETag of the stored set.
 func (s *set) Delete(c *gin.Context) {
 	userID, err := users.UserIDFromContext(c)
 	if err != nil {
-		c.IndentedJSON(http.StatusUnauthorized, gin.H{"message": "you must be logged in to perform this action"})
+		problem.Abort(c, problem.New(problem.CodeUnauthenticated, "you must be logged in to perform this action"))
 		return
 	}
 
 	setID, err := SetIDFromParams(c)
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": ErrInvalidSetID})
+		problem.Abort(c, problem.New(problem.CodeInvalidParameter, ErrInvalidSetID))
 		return
 	}
 
-	if err := s.db.DeleteSetForUser(setID, userID); err != nil {
+	// the current state of the set is needed to check preconditions, and for
+	// live events
+	ifMatch := c.GetHeader("If-Match")
+	var current *models.Set
+	if ifMatch != "" || s.events != nil {
+		current, err = s.dbFor(c).SetByIDForUser(setID, userID)
+		if err != nil {
+			if err == data.ErrNotFound {
+				msg := fmt.Sprintf("no such set with id %v", setID)
+				problem.Abort(c, problem.New(problem.CodeNotFound, msg))
+				return
+			}
+			problem.Internal(c, err)
+			return
+		}
+	}
+
+	if ifMatch != "" {
+		if !etagMatches(ifMatch, setETag(current), false) {
+			problem.Abort(c, problem.New(problem.CodePreconditionFailed, ErrSetModified))
+			return
+		}
+		// guard against concurrent changes between the read and the delete
+		err = s.dbFor(c).DeleteSetForUserAtVersion(setID, userID, current.Version)
+	} else {
+		err = s.dbFor(c).DeleteSetForUser(setID, userID)
+	}
+
+	if err != nil {
 		if err == data.ErrNotFound {
 			msg := fmt.Sprintf("no such set with id %v", setID)
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": msg})
+			problem.Abort(c, problem.New(problem.CodeNotFound, msg))
+			return
+		}
+		if err == data.ErrVersionMismatch {
+			problem.Abort(c, problem.New(problem.CodePreconditionFailed, ErrSetModified))
 			return
 		}
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		problem.Internal(c, err)
 		return
 	}
 
+	s.publish(events.SetDeleted, userID, current)
 	c.IndentedJSON(http.StatusNoContent, gin.H{})
 }
@@ -46,7 +46,11 @@ func TestDeleteSet(t *testing.T) {
 			userID:   1,
 			wantCode: http.StatusNotFound,
 			wantResp: *bytes.NewBufferString(`{
-				"message": "no such set with id 4"
+				"type": "urn:training-notebook:problem:not-found",
+				"title": "Resource not found",
+				"status": 404,
+				"detail": "no such set with id 4",
+				"code": "not-found"
 			}`),
 		},
 		{
@@ -60,7 +64,11 @@ func TestDeleteSet(t *testing.T) {
 			userID:   1,
 			wantCode: http.StatusInternalServerError,
 			wantResp: *bytes.NewBufferString(`{
-				"message": "Expected error"
+				"type": "urn:training-notebook:problem:internal-error",
+				"title": "Internal server error",
+				"status": 500,
+				"detail": "an internal error occurred, quote the request id when reporting it",
+				"code": "internal-error"
 			}`),
 		},
 		{
@@ -69,14 +77,18 @@ func TestDeleteSet(t *testing.T) {
 			userID:   1,
 			wantCode: http.StatusBadRequest,
 			wantResp: *bytes.NewBufferString(fmt.Sprintf(`{
-				"message": %q
+				"type": "urn:training-notebook:problem:invalid-parameter",
+				"title": "Invalid parameter",
+				"status": 400,
+				"detail": %q,
+				"code": "invalid-parameter"
 			}`, ErrInvalidSetID)),
 		},
 	}
 
 	for _, v := range tests {
 		// configure test case with data and test context
-		ts, err := New(v.db)
+		ts, err := New(v.db, nil, nil)
 		if err != nil {
 			t.Fail()
 		}
@@ -84,6 +96,7 @@ func TestDeleteSet(t *testing.T) {
 		gin.SetMode(gin.TestMode)
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodDelete, "", nil)
 		c.AddParam(SetIDFromParamsKey, v.setID)
 		c.Set(users.UserIDFromContextKey, v.userID)
 
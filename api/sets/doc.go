@@ -3,7 +3,7 @@
 // Documentation for Set API
 //
 //  Schemes: http
-//  BasePath: /
+//  BasePath: /api/v1
 //  Version: 1.0.0
 //
 //  Consumes:
@@ -11,17 +11,21 @@
 //
 //  Produces:
 //  - application/json
+//  - application/problem+json
 // swagger:meta
 package sets
 
 import (
-	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/events"
+	"github.com/hrand1005/training-notebook/api/problem"
 	"github.com/hrand1005/training-notebook/data"
 	"github.com/hrand1005/training-notebook/models"
 )
 
 type set struct {
-	db data.SetDB
+	db     data.SetDB
+	audit  data.AuditDB
+	events *events.Hub
 }
 
 // returns a set in the response
@@ -40,20 +44,104 @@ type setsResponse struct {
 	Body []models.Set
 }
 
-// returns generic error message as string
+// returns the audit events recorded for a set
+// swagger:response historyResponse
+type historyResponse struct {
+	// A list of audit events, oldest first
+	// in: body
+	Body []models.AuditEvent
+}
+
+// returns the result of each operation in a batch
+// swagger:response batchResponse
+type batchResponse struct {
+	// A result for each operation, in request order
+	// in: body
+	Body []models.SetOperationResult
+}
+
+// BatchProblem is the problem reported for a rejected batch, with the
+// operations that caused it to be rejected.
+// swagger:model BatchProblem
+type batchProblem struct {
+	problem.Problem
+	// The operations that caused the batch to be rejected
+	Operations []models.SetOperationResult `json:"operations"`
+}
+
+// returns the operations that caused a batch to be rejected
+// swagger:response batchErrorResponse
+type batchErrorResponse struct {
+	// Description of the error, listing the failed operations
+	// in: body
+	Body batchProblem
+}
+
+// swagger:parameters batchSets
+type batchParameter struct {
+	// The operations to apply, in order
+	// in: body
+	// required: true
+	Body []models.SetOperation
+}
+
+// returns the changes since the previous sync, and the discarded changes of
+// the client
+// swagger:response syncResponse
+type syncResponse struct {
+	// in: body
+	Body SyncResponse
+}
+
+// swagger:parameters syncSets
+type syncParameter struct {
+	// The changes made on the client, and the token of the previous sync
+	// in: body
+	// required: true
+	Body SyncRequest
+}
+
+// returns an RFC 7807 problem describing the error
 // swagger:response errorResponse
 type errorResponse struct {
-	// Description of the error
+	// Description of the error, with a machine-readable code
 	// in: body
-	Body gin.H
+	Body problem.Problem
 }
 
+// the request succeeded without a response body
+// swagger:response noContent
+type noContent struct{}
+
+// the resource has not changed since the version named by If-None-Match
+// swagger:response notModified
+type notModified struct{}
+
 // swagger:parameters readSet
 // swagger:parameters updateSet
+// swagger:parameters patchSet
 // swagger:parameters deleteSet
+// swagger:parameters setHistory
 type setIDParameter struct {
 	// The id of the set
-	// in: required: true
+	// in: path
 	// required: true
-	ID int `json:"set-id"`
+	ID int `json:"id"`
+}
+
+// swagger:parameters createSet
+// swagger:parameters updateSet
+type setParameter struct {
+	// The set to create, or to replace the set with
+	// in: body
+	// required: true
+	Body models.Set
+}
+
+// swagger:parameters patchSet
+type setPatchParameter struct {
+	// The fields to change
+	// in: body
+	// required: true
+	Body models.Set
 }
@@ -0,0 +1,52 @@
+package sets
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// ErrSetModified is the message returned when an If-Match precondition fails
+var ErrSetModified = "set has been modified, read the latest version and retry"
+
+// setETag returns the entity tag of a single set, derived from its version
+func setETag(s *models.Set) string {
+	return fmt.Sprintf(`"%d"`, s.Version)
+}
+
+// setsETag returns the entity tag of a list of sets, derived from the id and
+// version of each set in the list
+func setsETag(sets []*models.Set) string {
+	h := sha256.New()
+	for _, s := range sets {
+		fmt.Fprintf(h, "%d:%d;", s.ID, s.Version)
+	}
+	return fmt.Sprintf(`"%x"`, h.Sum(nil)[:16])
+}
+
+// etagMatches reports whether the If-Match or If-None-Match header value
+// matches etag. If-Match uses strong comparison, so weak tags never match it,
+// while If-None-Match uses weak comparison and ignores the W/ prefix.
+func etagMatches(header, etag string, weak bool) bool {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return true
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,158 @@
+package sets
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// TestConditionalRequests tests that the handlers of the Sets resource honor
+// the If-Match and If-None-Match preconditions. The test suite mocks the SetDB
+// interface, storing a single set at version 3.
+func TestConditionalRequests(t *testing.T) {
+	storedSet := func() *models.Set {
+		return &models.Set{ID: 1, UID: 1, Movement: "Squat", Volume: 5, Intensity: 80, Version: 3}
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		header   string
+		value    string
+		body     string
+		wantCode int
+		wantETag string
+	}{
+		{
+			name:     "Read with matching If-None-Match returns StatusNotModified",
+			method:   http.MethodGet,
+			header:   "If-None-Match",
+			value:    `W/"3"`,
+			wantCode: http.StatusNotModified,
+			wantETag: `"3"`,
+		},
+		{
+			name:     "Read with stale If-None-Match returns StatusOK",
+			method:   http.MethodGet,
+			header:   "If-None-Match",
+			value:    `"2"`,
+			wantCode: http.StatusOK,
+			wantETag: `"3"`,
+		},
+		{
+			name:     "Update with matching If-Match returns StatusOK",
+			method:   http.MethodPut,
+			header:   "If-Match",
+			value:    `"1", "3"`,
+			body:     `{"movement": "Squat", "volume": 5, "intensity": 85}`,
+			wantCode: http.StatusOK,
+			wantETag: `"4"`,
+		},
+		{
+			name:     "Update with stale If-Match returns StatusPreconditionFailed",
+			method:   http.MethodPut,
+			header:   "If-Match",
+			value:    `"2"`,
+			body:     `{"movement": "Squat", "volume": 5, "intensity": 85}`,
+			wantCode: http.StatusPreconditionFailed,
+		},
+		{
+			name:     "Update with weak If-Match returns StatusPreconditionFailed",
+			method:   http.MethodPut,
+			header:   "If-Match",
+			value:    `W/"3"`,
+			body:     `{"movement": "Squat", "volume": 5, "intensity": 85}`,
+			wantCode: http.StatusPreconditionFailed,
+		},
+		{
+			name:     "Delete with wildcard If-Match returns StatusNoContent",
+			method:   http.MethodDelete,
+			header:   "If-Match",
+			value:    `*`,
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "Delete with stale If-Match returns StatusPreconditionFailed",
+			method:   http.MethodDelete,
+			header:   "If-Match",
+			value:    `"2"`,
+			wantCode: http.StatusPreconditionFailed,
+		},
+	}
+
+	for _, v := range tests {
+		db := &data.MockSetDB{
+			SetByIDForUserStub: func(setID models.SetID, userID models.UserID) (*models.Set, error) {
+				return storedSet(), nil
+			},
+			UpdateSetForUserStub: func(setID models.SetID, userID models.UserID, s *models.Set) error {
+				if s.Version != 3 {
+					t.Fatalf("%s: Wanted versioned update at version 3 but got %v", v.name, s.Version)
+				}
+				s.Version++
+				return nil
+			},
+			DeleteSetForUserAtVersionStub: func(setID models.SetID, userID models.UserID, version int) error {
+				if version != 3 {
+					t.Fatalf("%s: Wanted versioned delete at version 3 but got %v", v.name, version)
+				}
+				return nil
+			},
+		}
+
+		ts, err := New(db, nil, nil)
+		if err != nil {
+			t.Fail()
+		}
+
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(v.method, "", bytes.NewBufferString(v.body))
+		c.Request.Header.Set(v.header, v.value)
+		c.AddParam(SetIDFromParamsKey, "1")
+		c.Set(users.UserIDFromContextKey, models.UserID(1))
+
+		switch v.method {
+		case http.MethodGet:
+			ts.Read(c)
+		case http.MethodPut:
+			ts.Update(c)
+		case http.MethodDelete:
+			ts.Delete(c)
+		}
+
+		// gin only flushes the status of bodiless responses when writing headers
+		c.Writer.WriteHeaderNow()
+
+		if v.wantCode != w.Code {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v\n", v.name, v.wantCode, w.Code)
+		}
+
+		if got := w.Header().Get("ETag"); v.wantETag != "" && v.wantETag != got {
+			t.Fatalf("%s: Wanted ETag: %v\nGot ETag: %v\n", v.name, v.wantETag, got)
+		}
+	}
+}
+
+// TestSetsETag checks that the ETag of a list of sets changes when any set in
+// the list changes.
+func TestSetsETag(t *testing.T) {
+	sets := []*models.Set{{ID: 1, Version: 1}, {ID: 2, Version: 4}}
+	before := setsETag(sets)
+
+	sets[1].Version++
+	if after := setsETag(sets); after == before {
+		t.Fatalf("Wanted ETag to change after update, but got %v for both", before)
+	}
+
+	if empty := setsETag(nil); empty == before {
+		t.Fatalf("Wanted ETag of empty list to differ, but got %v", empty)
+	}
+}
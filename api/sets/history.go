@@ -0,0 +1,54 @@
+package sets
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// swagger:route GET /sets/{id}/history sets setHistory
+// Read the change history of a set.
+// responses:
+//  200: historyResponse
+//  400: errorResponse
+// 	401: errorResponse
+//  404: errorResponse
+//  500: errorResponse
+//  default: errorResponse
+
+// History is the handler for reading the audit trail of a set owned by the
+// logged in user. The history of deleted sets remains readable.
+func (s *set) History(c *gin.Context) {
+	userID, err := users.UserIDFromContext(c)
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeUnauthenticated, "you must be logged in to perform this action"))
+		return
+	}
+
+	setID, err := SetIDFromParams(c)
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeInvalidParameter, ErrInvalidSetID))
+		return
+	}
+
+	events, err := s.audit.Events(models.AuditFilter{
+		OwnerID:    userID,
+		TargetType: models.AuditTargetSet,
+		TargetID:   int(setID),
+	})
+	if err != nil {
+		problem.Internal(c, err)
+		return
+	}
+	if len(events) == 0 {
+		msg := fmt.Sprintf("no history for set with id %v for logged in user", setID)
+		problem.Abort(c, problem.New(problem.CodeNotFound, msg))
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, events)
+}
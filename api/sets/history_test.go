@@ -0,0 +1,179 @@
+package sets
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// TestSetHistory tests the API layer's History method for the Sets resource.
+// The test suite mocks the AuditDB interface to test edge cases and error conditions.
+func TestSetHistory(t *testing.T) {
+	timestamp := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		audit      *data.MockAuditDB
+		setID      string
+		userID     models.UserID
+		wantFilter models.AuditFilter
+		wantCode   int
+		wantResp   bytes.Buffer
+	}{
+		{
+			name: "Set with history returns StatusOK",
+			audit: &data.MockAuditDB{
+				EventsStub: func(f models.AuditFilter) ([]*models.AuditEvent, error) {
+					return []*models.AuditEvent{
+						{
+							ID:         1,
+							ActorID:    1,
+							OwnerID:    1,
+							Action:     models.AuditCreate,
+							TargetType: models.AuditTargetSet,
+							TargetID:   3,
+							After:      []byte(`{"set-id":3,"user-id":1,"movement":"Squat","volume":5,"intensity":80}`),
+							RequestID:  "abc",
+							Timestamp:  timestamp,
+						},
+					}, nil
+				},
+			},
+			setID:  "3",
+			userID: 1,
+			wantFilter: models.AuditFilter{
+				OwnerID:    1,
+				TargetType: models.AuditTargetSet,
+				TargetID:   3,
+			},
+			wantCode: http.StatusOK,
+			wantResp: *bytes.NewBufferString(`[
+				{
+					"event-id": 1,
+					"actor-id": 1,
+					"owner-id": 1,
+					"action": "create",
+					"target-type": "set",
+					"target-id": 3,
+					"after": {
+						"set-id": 3,
+						"user-id": 1,
+						"movement": "Squat",
+						"volume": 5,
+						"intensity": 80
+					},
+					"request-id": "abc",
+					"timestamp": "2022-06-01T12:00:00Z"
+				}
+			]`),
+		},
+		{
+			name: "Set without history returns StatusNotFound",
+			audit: &data.MockAuditDB{
+				EventsStub: func(f models.AuditFilter) ([]*models.AuditEvent, error) {
+					return []*models.AuditEvent{}, nil
+				},
+			},
+			setID:  "4",
+			userID: 1,
+			wantFilter: models.AuditFilter{
+				OwnerID:    1,
+				TargetType: models.AuditTargetSet,
+				TargetID:   4,
+			},
+			wantCode: http.StatusNotFound,
+			wantResp: *bytes.NewBufferString(`{
+				"type": "urn:training-notebook:problem:not-found",
+				"title": "Resource not found",
+				"status": 404,
+				"detail": "no history for set with id 4 for logged in user",
+				"code": "not-found"
+			}`),
+		},
+		{
+			name: "Invalid db query returns InternalServerError",
+			audit: &data.MockAuditDB{
+				EventsStub: func(f models.AuditFilter) ([]*models.AuditEvent, error) {
+					return nil, fmt.Errorf("Expected error")
+				},
+			},
+			setID:  "4",
+			userID: 1,
+			wantFilter: models.AuditFilter{
+				OwnerID:    1,
+				TargetType: models.AuditTargetSet,
+				TargetID:   4,
+			},
+			wantCode: http.StatusInternalServerError,
+			wantResp: *bytes.NewBufferString(`{
+				"type": "urn:training-notebook:problem:internal-error",
+				"title": "Internal server error",
+				"status": 500,
+				"detail": "an internal error occurred, quote the request id when reporting it",
+				"code": "internal-error"
+			}`),
+		},
+		{
+			name:     "Invalid params returns StatusBadRequest",
+			audit:    &data.MockAuditDB{},
+			setID:    "-1",
+			userID:   1,
+			wantCode: http.StatusBadRequest,
+			wantResp: *bytes.NewBufferString(fmt.Sprintf(`{
+				"type": "urn:training-notebook:problem:invalid-parameter",
+				"title": "Invalid parameter",
+				"status": 400,
+				"detail": %q,
+				"code": "invalid-parameter"
+			}`, ErrInvalidSetID)),
+		},
+	}
+
+	for _, v := range tests {
+		// capture the filter used to query the audit db
+		var gotFilter models.AuditFilter
+		if v.audit.EventsStub != nil {
+			stub := v.audit.EventsStub
+			v.audit.EventsStub = func(f models.AuditFilter) ([]*models.AuditEvent, error) {
+				gotFilter = f
+				return stub(f)
+			}
+		}
+
+		ts, err := New(&data.MockSetDB{}, v.audit, nil)
+		if err != nil {
+			t.Fail()
+		}
+
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.AddParam(SetIDFromParamsKey, v.setID)
+		c.Set(users.UserIDFromContextKey, v.userID)
+
+		// execute History on test context
+		ts.History(c)
+
+		// check response code
+		if v.wantCode != w.Code {
+			t.Fatalf("Wanted code: %v\nGot code: %v\n", v.wantCode, w.Code)
+		}
+
+		if gotFilter != v.wantFilter {
+			t.Fatalf("Wanted filter: %+v\nGot filter: %+v\n", v.wantFilter, gotFilter)
+		}
+
+		// check response body
+		if equal, _ := JSONBytesEqual(v.wantResp.Bytes(), w.Body.Bytes()); !equal {
+			t.Fatalf("Wanted body: %v\nGot body: %v\n", v.wantResp.String(), w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package sets
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/hrand1005/training-notebook/api/events"
+	"github.com/hrand1005/training-notebook/api/mergepatch"
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// swagger:route PATCH /sets/{id} sets patchSet
+// Partially update a set with a JSON merge patch.
+// consumes:
+//  - application/merge-patch+json
+// responses:
+//  200: setResponse
+//  400: errorResponse
+// 	401: errorResponse
+//  404: errorResponse
+//  412: errorResponse
+//  415: errorResponse
+//  500: errorResponse
+//  default: errorResponse
+
+// Patch is the handler for merge patch requests on the set resource. An id must
+// be specified. Fields omitted from the patch keep their stored values, and the
+// patched set is validated like a full update. If the If-Match header is set,
+// the patch only applies if it matches the ETag of the stored set.
+func (s *set) Patch(c *gin.Context) {
+	userID, err := users.UserIDFromContext(c)
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeUnauthenticated, "you must be logged in to perform this action"))
+		return
+	}
+
+	setID, err := SetIDFromParams(c)
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeInvalidParameter, ErrInvalidSetID))
+		return
+	}
+
+	if !mergepatch.AcceptsContentType(c.GetHeader("Content-Type")) {
+		msg := fmt.Sprintf("patch must have content type %s", mergepatch.ContentType)
+		problem.Abort(c, problem.New(problem.CodeUnsupportedMediaType, msg))
+		return
+	}
+
+	patch, err := c.GetRawData()
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeMalformedRequest, "Malformed JSON"))
+		return
+	}
+
+	fields, err := mergepatch.Fields(patch)
+	if err != nil {
+		if err == mergepatch.ErrNotObject {
+			problem.Abort(c, problem.New(problem.CodeMalformedRequest, err.Error()))
+			return
+		}
+		problem.Abort(c, problem.New(problem.CodeMalformedRequest, "Malformed JSON"))
+		return
+	}
+	for _, name := range serverFields(c) {
+		if _, ok := fields[name]; ok {
+			msg := fmt.Sprintf("'%s' field cannot be changed.", name)
+			problem.Abort(c, problem.New(problem.CodeValidationFailed, msg))
+			return
+		}
+	}
+
+	current, err := s.dbFor(c).SetByIDForUser(setID, userID)
+	if err != nil {
+		if err == data.ErrNotFound {
+			msg := fmt.Sprintf("no such set with id %v", setID)
+			problem.Abort(c, problem.New(problem.CodeNotFound, msg))
+			return
+		}
+		problem.Internal(c, err)
+		return
+	}
+
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" && !etagMatches(ifMatch, setETag(current), false) {
+		problem.Abort(c, problem.New(problem.CodePreconditionFailed, ErrSetModified))
+		return
+	}
+
+	doc, err := json.Marshal(setBody(c, current))
+	if err != nil {
+		problem.Internal(c, err)
+		return
+	}
+	patchedDoc, err := mergepatch.Apply(doc, patch)
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeMalformedRequest, "Malformed JSON"))
+		return
+	}
+
+	var patched models.Set
+	if err := decodeSet(c, patchedDoc, &patched); err != nil {
+		problem.Abort(c, problem.FromBindingError(err))
+		return
+	}
+	if err := binding.Validator.ValidateStruct(&patched); err != nil {
+		problem.Abort(c, problem.FromBindingError(err))
+		return
+	}
+
+	// the patch was computed from the current version, so it must not apply
+	// over a concurrent change
+	patched.Version = current.Version
+	if err := s.dbFor(c).UpdateSetForUser(setID, userID, &patched); err != nil {
+		if err == data.ErrNotFound {
+			msg := fmt.Sprintf("no such set with id %v", setID)
+			problem.Abort(c, problem.New(problem.CodeNotFound, msg))
+			return
+		}
+		if err == data.ErrVersionMismatch {
+			problem.Abort(c, problem.New(problem.CodePreconditionFailed, ErrSetModified))
+			return
+		}
+		problem.Internal(c, err)
+		return
+	}
+
+	patched.ID = setID
+	patched.UID = userID
+	s.publish(events.SetUpdated, userID, &patched)
+	c.Header("ETag", setETag(&patched))
+	c.IndentedJSON(http.StatusOK, setBody(c, &patched))
+}
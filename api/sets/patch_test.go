@@ -0,0 +1,233 @@
+package sets
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/mergepatch"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// TestPatchSet tests the API layer's Patch method for the Sets resource.
+// The test suite mocks the SetDB interface to test edge cases and error conditions.
+func TestPatchSet(t *testing.T) {
+	storedSet := func(setID models.SetID, userID models.UserID) (*models.Set, error) {
+		return &models.Set{ID: setID, UID: userID, Movement: "Squat", Volume: 5, Intensity: 80, Version: 2}, nil
+	}
+	updateSet := func(setID models.SetID, userID models.UserID, s *models.Set) error {
+		s.Version++
+		return nil
+	}
+
+	tests := []struct {
+		name        string
+		db          *data.MockSetDB
+		setID       string
+		contentType string
+		requestBody string
+		wantCode    int
+		wantResp    string
+	}{
+		{
+			name: "Partial patch keeps omitted fields and returns StatusOK",
+			db: &data.MockSetDB{
+				SetByIDForUserStub: storedSet,
+				UpdateSetForUserStub: func(setID models.SetID, userID models.UserID, s *models.Set) error {
+					if s.Movement != "Squat" || s.Volume != 5 || s.Intensity != 85 || s.Version != 2 {
+						return fmt.Errorf("unexpected update: %+v", s)
+					}
+					return updateSet(setID, userID, s)
+				},
+			},
+			setID:       "1",
+			contentType: mergepatch.ContentType,
+			requestBody: `{"intensity": 85}`,
+			wantCode:    http.StatusOK,
+			wantResp: `{
+				"set-id": 1,
+				"user-id": 1,
+				"movement": "Squat",
+				"volume": 5,
+				"intensity": 85
+			}`,
+		},
+		{
+			name: "Null member fails validation and returns StatusBadRequest",
+			db: &data.MockSetDB{
+				SetByIDForUserStub: storedSet,
+			},
+			setID:       "1",
+			contentType: mergepatch.ContentType,
+			requestBody: `{"volume": null}`,
+			wantCode:    http.StatusBadRequest,
+			wantResp: `{
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "'Volume' field must be greater than 0.",
+				"code": "validation-failed",
+				"errors": [
+					{"field": "Volume", "code": "gt", "message": "'Volume' field must be greater than 0."}
+				]
+			}`,
+		},
+		{
+			name: "Invalid field value returns StatusBadRequest",
+			db: &data.MockSetDB{
+				SetByIDForUserStub: storedSet,
+			},
+			setID:       "1",
+			contentType: mergepatch.ContentType,
+			requestBody: `{"intensity": 101}`,
+			wantCode:    http.StatusBadRequest,
+			wantResp: `{
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "'Intensity' field must be no more than 100.",
+				"code": "validation-failed",
+				"errors": [
+					{"field": "Intensity", "code": "lte", "message": "'Intensity' field must be no more than 100."}
+				]
+			}`,
+		},
+		{
+			name:        "Changing the owner returns StatusBadRequest",
+			db:          &data.MockSetDB{},
+			setID:       "1",
+			contentType: mergepatch.ContentType,
+			requestBody: `{"user-id": 2}`,
+			wantCode:    http.StatusBadRequest,
+			wantResp: `{
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "'user-id' field cannot be changed.",
+				"code": "validation-failed"
+			}`,
+		},
+		{
+			name:        "Non-object patch returns StatusBadRequest",
+			db:          &data.MockSetDB{},
+			setID:       "1",
+			contentType: mergepatch.ContentType,
+			requestBody: `[{"volume": 3}]`,
+			wantCode:    http.StatusBadRequest,
+			wantResp: fmt.Sprintf(`{
+				"type": "urn:training-notebook:problem:malformed-request",
+				"title": "Malformed request",
+				"status": 400,
+				"detail": %q,
+				"code": "malformed-request"
+			}`, mergepatch.ErrNotObject),
+		},
+		{
+			name:        "Unsupported content type returns StatusUnsupportedMediaType",
+			db:          &data.MockSetDB{},
+			setID:       "1",
+			contentType: "text/plain",
+			requestBody: `{"volume": 3}`,
+			wantCode:    http.StatusUnsupportedMediaType,
+			wantResp: `{
+				"type": "urn:training-notebook:problem:unsupported-media-type",
+				"title": "Unsupported media type",
+				"status": 415,
+				"detail": "patch must have content type application/merge-patch+json",
+				"code": "unsupported-media-type"
+			}`,
+		},
+		{
+			name: "Set not found returns StatusNotFound",
+			db: &data.MockSetDB{
+				SetByIDForUserStub: func(setID models.SetID, userID models.UserID) (*models.Set, error) {
+					return nil, data.ErrNotFound
+				},
+			},
+			setID:       "4",
+			contentType: mergepatch.ContentType,
+			requestBody: `{"volume": 3}`,
+			wantCode:    http.StatusNotFound,
+			wantResp: `{
+				"type": "urn:training-notebook:problem:not-found",
+				"title": "Resource not found",
+				"status": 404,
+				"detail": "no such set with id 4",
+				"code": "not-found"
+			}`,
+		},
+		{
+			name: "Concurrent change returns StatusPreconditionFailed",
+			db: &data.MockSetDB{
+				SetByIDForUserStub: storedSet,
+				UpdateSetForUserStub: func(setID models.SetID, userID models.UserID, s *models.Set) error {
+					return data.ErrVersionMismatch
+				},
+			},
+			setID:       "1",
+			contentType: mergepatch.ContentType,
+			requestBody: `{"volume": 3}`,
+			wantCode:    http.StatusPreconditionFailed,
+			wantResp: fmt.Sprintf(`{
+				"type": "urn:training-notebook:problem:precondition-failed",
+				"title": "Precondition failed",
+				"status": 412,
+				"detail": %q,
+				"code": "precondition-failed"
+			}`, ErrSetModified),
+		},
+		{
+			name: "Invalid db call returns InternalServerError",
+			db: &data.MockSetDB{
+				SetByIDForUserStub: storedSet,
+				UpdateSetForUserStub: func(setID models.SetID, userID models.UserID, s *models.Set) error {
+					return fmt.Errorf("Expected error")
+				},
+			},
+			setID:       "1",
+			contentType: mergepatch.ContentType,
+			requestBody: `{"volume": 3}`,
+			wantCode:    http.StatusInternalServerError,
+			wantResp: `{
+				"type": "urn:training-notebook:problem:internal-error",
+				"title": "Internal server error",
+				"status": 500,
+				"detail": "an internal error occurred, quote the request id when reporting it",
+				"code": "internal-error"
+			}`,
+		},
+	}
+
+	for _, v := range tests {
+		ts, err := New(v.db, nil, nil)
+		if err != nil {
+			t.Fail()
+		}
+
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPatch, "", bytes.NewBufferString(v.requestBody))
+		c.Request.Header.Set("Content-Type", v.contentType)
+		c.AddParam(SetIDFromParamsKey, v.setID)
+		c.Set(users.UserIDFromContextKey, models.UserID(1))
+
+		// execute patch with the test context
+		ts.Patch(c)
+
+		// check response code
+		if v.wantCode != w.Code {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v\nBody: %v", v.name, v.wantCode, w.Code, w.Body.String())
+		}
+
+		// check response body
+		if equal, _ := JSONBytesEqual([]byte(v.wantResp), w.Body.Bytes()); !equal {
+			t.Fatalf("%s: Wanted body: %v\nGot body: %v\n", v.name, v.wantResp, w.Body.String())
+		}
+	}
+}
@@ -5,74 +5,91 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/problem"
 	"github.com/hrand1005/training-notebook/api/users"
 	"github.com/hrand1005/training-notebook/data"
-	"github.com/hrand1005/training-notebook/models"
 )
 
-// swagger:route GET /sets sets readAllSets
+// swagger:route GET /sets/ sets readAllSets
 // Read all sets.
 // responses:
 //  200: setsResponse
+//  304: notModified
 // 	401: errorResponse
 //  500: errorResponse
+//  default: errorResponse
 
 // ReadAll is the handler for read requests on the set resource where no id is
-// specified. Returns all sets on this resource's data source.
+// specified. Returns all sets on this resource's data source. Responds with
+// StatusNotModified if If-None-Match matches the ETag of the sets.
 func (s *set) ReadAll(c *gin.Context) {
 	userID, err := users.UserIDFromContext(c)
 	if err != nil {
-		c.IndentedJSON(http.StatusUnauthorized, gin.H{"message": "you must be logged in to perform this action"})
+		problem.Abort(c, problem.New(problem.CodeUnauthenticated, "you must be logged in to perform this action"))
 		return
 	}
 
-	sets, err := s.db.SetsByUserID(userID)
+	sets, err := s.dbFor(c).SetsByUserID(userID)
 	if err != nil {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		problem.Internal(c, err)
 		return
 	}
-	if len(sets) == 0 {
-		// if no sets are found, return an empty slice
-		c.IndentedJSON(http.StatusOK, []*models.Set{})
+
+	etag := setsETag(sets)
+	c.Header("ETag", etag)
+	if inm := c.GetHeader("If-None-Match"); inm != "" && etagMatches(inm, etag, true) {
+		c.Status(http.StatusNotModified)
 		return
 	}
-	c.IndentedJSON(http.StatusOK, sets)
+
+	// if no sets are found, an empty slice is returned
+	c.IndentedJSON(http.StatusOK, setsBody(c, sets))
 }
 
 // swagger:route GET /sets/{id} sets readSet
 // Read a set.
 // responses:
 //  200: setResponse
+//  304: notModified
 //  400: errorResponse
 // 	401: errorResponse
 //  404: errorResponse
 //  500: errorResponse
+//  default: errorResponse
 
 // Read is the handler for read requests on the set resource where an id is
-// specified.
+// specified. Responds with StatusNotModified if If-None-Match matches the ETag
+// of the set.
 func (s *set) Read(c *gin.Context) {
 	userID, err := users.UserIDFromContext(c)
 	if err != nil {
-		c.IndentedJSON(http.StatusUnauthorized, gin.H{"message": "you must be logged in to perform this action"})
+		problem.Abort(c, problem.New(problem.CodeUnauthenticated, "you must be logged in to perform this action"))
 		return
 	}
 
 	setID, err := SetIDFromParams(c)
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": ErrInvalidSetID})
+		problem.Abort(c, problem.New(problem.CodeInvalidParameter, ErrInvalidSetID))
 		return
 	}
 
-	resultSet, err := s.db.SetByIDForUser(setID, userID)
+	resultSet, err := s.dbFor(c).SetByIDForUser(setID, userID)
 	if err != nil {
 		if err == data.ErrNotFound {
 			msg := fmt.Sprintf("no such set with id %v for logged in user", setID)
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": msg})
+			problem.Abort(c, problem.New(problem.CodeNotFound, msg))
 			return
 		}
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		problem.Internal(c, err)
+		return
+	}
+
+	etag := setETag(resultSet)
+	c.Header("ETag", etag)
+	if inm := c.GetHeader("If-None-Match"); inm != "" && etagMatches(inm, etag, true) {
+		c.Status(http.StatusNotModified)
 		return
 	}
 
-	c.IndentedJSON(http.StatusOK, resultSet)
+	c.IndentedJSON(http.StatusOK, setBody(c, resultSet))
 }
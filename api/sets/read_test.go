@@ -64,7 +64,11 @@ func TestReadSingleSet(t *testing.T) {
 			userID:   1,
 			wantCode: http.StatusNotFound,
 			wantResp: *bytes.NewBufferString(`{
-				"message": "no such set with id 4 for logged in user"
+				"type": "urn:training-notebook:problem:not-found",
+				"title": "Resource not found",
+				"status": 404,
+				"detail": "no such set with id 4 for logged in user",
+				"code": "not-found"
 			}`),
 		},
 		{
@@ -78,7 +82,11 @@ func TestReadSingleSet(t *testing.T) {
 			userID:   1,
 			wantCode: http.StatusInternalServerError,
 			wantResp: *bytes.NewBufferString(`{
-				"message": "Expected error"
+				"type": "urn:training-notebook:problem:internal-error",
+				"title": "Internal server error",
+				"status": 500,
+				"detail": "an internal error occurred, quote the request id when reporting it",
+				"code": "internal-error"
 			}`),
 		},
 		{
@@ -86,14 +94,18 @@ func TestReadSingleSet(t *testing.T) {
 			id:       "-1",
 			wantCode: http.StatusBadRequest,
 			wantResp: *bytes.NewBufferString(fmt.Sprintf(`{
-				"message": %q
+				"type": "urn:training-notebook:problem:invalid-parameter",
+				"title": "Invalid parameter",
+				"status": 400,
+				"detail": %q,
+				"code": "invalid-parameter"
 			}`, ErrInvalidSetID)),
 		},
 	}
 
 	for _, v := range tests {
 		// configure test case with data and test context
-		ts, err := New(v.db)
+		ts, err := New(v.db, nil, nil)
 		if err != nil {
 			t.Fail()
 		}
@@ -101,6 +113,7 @@ func TestReadSingleSet(t *testing.T) {
 		gin.SetMode(gin.TestMode)
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodGet, "", nil)
 		c.AddParam(SetIDFromParamsKey, v.id)
 
 		// set userID in context
@@ -193,7 +206,11 @@ func TestReadAllSets(t *testing.T) {
 			},
 			wantCode: http.StatusInternalServerError,
 			wantResp: *bytes.NewBufferString(`{
-				"message": "Expected Error"
+				"type": "urn:training-notebook:problem:internal-error",
+				"title": "Internal server error",
+				"status": 500,
+				"detail": "an internal error occurred, quote the request id when reporting it",
+				"code": "internal-error"
 			}`),
 		},
 		{
@@ -217,13 +234,17 @@ func TestReadAllSets(t *testing.T) {
 			},
 			wantCode: http.StatusInternalServerError,
 			wantResp: *bytes.NewBufferString(`{
-				"message": "Expected Error"
+				"type": "urn:training-notebook:problem:internal-error",
+				"title": "Internal server error",
+				"status": 500,
+				"detail": "an internal error occurred, quote the request id when reporting it",
+				"code": "internal-error"
 			}`),
 		},
 	}
 	for _, v := range tests {
 		// configure test case with data and test context
-		ts, err := New(v.db)
+		ts, err := New(v.db, nil, nil)
 		if err != nil {
 			t.Fail()
 		}
@@ -231,6 +252,7 @@ func TestReadAllSets(t *testing.T) {
 		gin.SetMode(gin.TestMode)
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodGet, "", nil)
 
 		// set userID in context
 		c.Set(users.UserIDFromContextKey, v.userID)
@@ -0,0 +1,117 @@
+package sets
+
+import (
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/version"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// The handlers operate on models.Set, and convert sets to and from the json
+// representation of the api version serving the request at the edges.
+
+// bindSet binds the request body to s.
+func bindSet(c *gin.Context, s *models.Set) error {
+	if version.FromContext(c) == version.V1 {
+		return c.BindJSON(s)
+	}
+
+	var v2 models.SetV2
+	if err := c.BindJSON(&v2); err != nil {
+		return err
+	}
+	*s = *v2.Set()
+	return nil
+}
+
+// decodeSet decodes a json document representing a set into s.
+func decodeSet(c *gin.Context, doc []byte, s *models.Set) error {
+	if version.FromContext(c) == version.V1 {
+		return json.Unmarshal(doc, s)
+	}
+
+	var v2 models.SetV2
+	if err := json.Unmarshal(doc, &v2); err != nil {
+		return err
+	}
+	*s = *v2.Set()
+	return nil
+}
+
+// setBody returns the representation of s.
+func setBody(c *gin.Context, s *models.Set) interface{} {
+	if version.FromContext(c) == version.V1 {
+		return s
+	}
+	return s.V2()
+}
+
+// setsBody returns the representation of sets, which is never null.
+func setsBody(c *gin.Context, sets []*models.Set) interface{} {
+	if version.FromContext(c) == version.V1 {
+		if sets == nil {
+			return []*models.Set{}
+		}
+		return sets
+	}
+
+	v2 := make([]*models.SetV2, 0, len(sets))
+	for _, s := range sets {
+		v2 = append(v2, s.V2())
+	}
+	return v2
+}
+
+// serverFields returns the fields of the representation of a set that are
+// assigned by the server, and cannot be changed by clients.
+func serverFields(c *gin.Context) []string {
+	if version.FromContext(c) == version.V1 {
+		return []string{"set-id", "user-id"}
+	}
+	return []string{"id", "owner-id"}
+}
+
+// idField returns the name of the field identifying a set in a batch
+// operation.
+func idField(c *gin.Context) string {
+	if version.FromContext(c) == version.V1 {
+		return "set-id"
+	}
+	return "id"
+}
+
+// decodeOperations decodes a json array of batch operations.
+func decodeOperations(c *gin.Context, doc []byte) ([]*models.SetOperation, error) {
+	var ops []*models.SetOperation
+	if version.FromContext(c) == version.V1 {
+		err := json.Unmarshal(doc, &ops)
+		return ops, err
+	}
+
+	var v2 []*models.SetOperationV2
+	if err := json.Unmarshal(doc, &v2); err != nil {
+		return nil, err
+	}
+	for _, op := range v2 {
+		if op == nil {
+			ops = append(ops, nil)
+			continue
+		}
+		ops = append(ops, op.SetOperation())
+	}
+	return ops, nil
+}
+
+// resultsBody returns the representation of the results of a batch.
+func resultsBody(c *gin.Context, results []models.SetOperationResult) interface{} {
+	if version.FromContext(c) == version.V1 {
+		return results
+	}
+
+	v2 := make([]*models.SetOperationResultV2, 0, len(results))
+	for i := range results {
+		v2 = append(v2, results[i].V2())
+	}
+	return v2
+}
@@ -0,0 +1,156 @@
+package sets
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/mergepatch"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/api/version"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// TestV2Representation checks that requests served by version 2 of the api
+// read and write sets in the version 2 representation.
+func TestV2Representation(t *testing.T) {
+	storedSet := func(setID models.SetID, userID models.UserID) (*models.Set, error) {
+		return &models.Set{ID: setID, UID: userID, Movement: "Squat", Volume: 5, Intensity: 80}, nil
+	}
+	db := &data.MockSetDB{
+		AddSetStub: func(s *models.Set) (models.SetID, error) {
+			return 1, nil
+		},
+		SetByIDForUserStub: storedSet,
+		SetsByUserIDStub: func(userID models.UserID) ([]*models.Set, error) {
+			s, _ := storedSet(1, userID)
+			return []*models.Set{s}, nil
+		},
+		UpdateSetForUserStub: func(setID models.SetID, userID models.UserID, s *models.Set) error {
+			return nil
+		},
+		BatchForUserStub: func(userID models.UserID, ops []*models.SetOperation) error {
+			for _, op := range ops {
+				if op.Op == models.SetOpCreate {
+					op.SetID = 2
+					op.Set.ID = 2
+					op.Set.UID = userID
+				}
+			}
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name        string
+		handler     func(s *set) gin.HandlerFunc
+		method      string
+		contentType string
+		requestBody string
+		wantCode    int
+		wantResp    string
+	}{
+		{
+			name:        "Create reads and returns a v2 set",
+			handler:     func(s *set) gin.HandlerFunc { return s.Create },
+			method:      http.MethodPost,
+			requestBody: `{"movement": "Squat", "volume": 5, "intensity": 80}`,
+			wantCode:    http.StatusCreated,
+			wantResp:    `{"id": 1, "owner-id": 1, "movement": "Squat", "volume": 5, "intensity": 80}`,
+		},
+		{
+			name:     "ReadAll returns v2 sets",
+			handler:  func(s *set) gin.HandlerFunc { return s.ReadAll },
+			method:   http.MethodGet,
+			wantCode: http.StatusOK,
+			wantResp: `[{"id": 1, "owner-id": 1, "movement": "Squat", "volume": 5, "intensity": 80}]`,
+		},
+		{
+			name:        "Patch applies to the v2 representation",
+			handler:     func(s *set) gin.HandlerFunc { return s.Patch },
+			method:      http.MethodPatch,
+			contentType: mergepatch.ContentType,
+			requestBody: `{"intensity": 85}`,
+			wantCode:    http.StatusOK,
+			wantResp:    `{"id": 1, "owner-id": 1, "movement": "Squat", "volume": 5, "intensity": 85}`,
+		},
+		{
+			name:        "Patch rejects changes to the v2 id",
+			handler:     func(s *set) gin.HandlerFunc { return s.Patch },
+			method:      http.MethodPatch,
+			contentType: mergepatch.ContentType,
+			requestBody: `{"id": 7}`,
+			wantCode:    http.StatusBadRequest,
+			wantResp: `{
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "'id' field cannot be changed.",
+				"code": "validation-failed"
+			}`,
+		},
+		{
+			name:        "Batch reads v2 operations and returns v2 results",
+			handler:     func(s *set) gin.HandlerFunc { return s.Batch },
+			method:      http.MethodPost,
+			requestBody: `[{"op": "create", "set": {"movement": "Bench", "volume": 3, "intensity": 90}}]`,
+			wantCode:    http.StatusOK,
+			wantResp: `[{
+				"index": 0,
+				"op": "create",
+				"status": 201,
+				"set": {"id": 2, "owner-id": 1, "movement": "Bench", "volume": 3, "intensity": 90}
+			}]`,
+		},
+		{
+			name:        "Batch names the v2 id field in errors",
+			handler:     func(s *set) gin.HandlerFunc { return s.Batch },
+			method:      http.MethodPost,
+			requestBody: `[{"op": "create", "id": 3, "set": {"movement": "Bench", "volume": 3, "intensity": 90}}]`,
+			wantCode:    http.StatusBadRequest,
+			wantResp: `{
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "batch contains invalid operations, no operations were applied",
+				"code": "validation-failed",
+				"operations": [{
+					"index": 0,
+					"op": "create",
+					"status": 400,
+					"message": "'id' and 'version' fields must not be set on create."
+				}]
+			}`,
+		},
+	}
+
+	for _, v := range tests {
+		ts, err := New(db, nil, nil)
+		if err != nil {
+			t.Fail()
+		}
+
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(v.method, "", bytes.NewBufferString(v.requestBody))
+		if v.contentType != "" {
+			c.Request.Header.Set("Content-Type", v.contentType)
+		}
+		c.AddParam(SetIDFromParamsKey, "1")
+		c.Set(users.UserIDFromContextKey, models.UserID(1))
+		c.Set(version.ContextKey, version.V2)
+
+		v.handler(ts)(c)
+
+		if v.wantCode != w.Code {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v\nBody: %v", v.name, v.wantCode, w.Code, w.Body.String())
+		}
+		if equal, _ := JSONBytesEqual([]byte(v.wantResp), w.Body.Bytes()); !equal {
+			t.Fatalf("%s: Wanted body: %v\nGot body: %v\n", v.name, v.wantResp, w.Body.String())
+		}
+	}
+}
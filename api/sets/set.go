@@ -8,9 +8,11 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
+	"github.com/hrand1005/training-notebook/api/events"
 	"github.com/hrand1005/training-notebook/api/users"
 	"github.com/hrand1005/training-notebook/data"
 	"github.com/hrand1005/training-notebook/models"
+	"github.com/hrand1005/training-notebook/tracing"
 )
 
 var ErrInvalidSetID = "Invalid set ID"
@@ -21,13 +23,15 @@ const (
 )
 
 // New registers custom validators with the validator engine and returns the
-// handler for the set resource.
-func New(db data.SetDB) (*set, error) {
+// handler for the set resource. Changes to sets are recorded in auditDB, which
+// may be nil to disable auditing, and published to hub, which may be nil to
+// disable live events.
+func New(db data.SetDB, auditDB data.AuditDB, hub *events.Hub) (*set, error) {
 	// register set validators
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		// TODO: remove this fancy business
 		v.RegisterValidation("movement", models.MovementValidator)
-		return &set{db: db}, nil
+		return &set{db: db, audit: auditDB, events: hub}, nil
 	}
 
 	return nil, errors.New("failed to access validator engine")
@@ -37,12 +41,29 @@ func (s *set) RegisterHandlers(g *gin.RouterGroup) {
 	// register RequireAuthorization middleware so that each request
 	// on the sets requires token
 	setGroup := g.Group("/sets")
-	setGroup.Use(users.RequireAuthorization())
+	setGroup.Use(users.RequireAuthorization(), tracing.HandlerSpan())
 	setGroup.GET("/", s.ReadAll)
 	setGroup.GET("/:"+SetIDFromParamsKey, s.Read)
 	setGroup.DELETE("/:"+SetIDFromParamsKey, s.Delete)
 	setGroup.POST("/", s.Create)
+	setGroup.POST("/batch", s.Batch)
 	setGroup.PUT("/:"+SetIDFromParamsKey, s.Update)
+	setGroup.PATCH("/:"+SetIDFromParamsKey, s.Patch)
+	if s.audit != nil {
+		setGroup.GET("/:"+SetIDFromParamsKey+"/history", s.History)
+	}
+	g.POST("/sync", users.RequireAuthorization(), tracing.HandlerSpan(), s.Sync)
+}
+
+// dbFor returns the set db, recording changes in the audit log as made by
+// the logged in user of the request being handled by c, and a span for each
+// call in the trace of the request.
+func (s *set) dbFor(c *gin.Context) data.SetDB {
+	db := s.db
+	if s.audit != nil {
+		db = db.AuditedBy(users.ActorFromContext(c))
+	}
+	return data.TraceSetDB(tracing.Context(c), db)
 }
 
 func SetIDFromParams(c *gin.Context) (models.SetID, error) {
@@ -56,3 +77,13 @@ func SetIDFromParams(c *gin.Context) (models.SetID, error) {
 
 	return models.SetID(id), nil
 }
+
+// publish announces a change to a set owned by ownerID to live event
+// subscribers. changed is the set after the change, or before it for
+// deletions.
+func (s *set) publish(eventType string, ownerID models.UserID, changed *models.Set) {
+	if s.events == nil {
+		return
+	}
+	s.events.Publish(events.Event{Type: eventType, OwnerID: ownerID, Set: *changed})
+}
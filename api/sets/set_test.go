@@ -0,0 +1,98 @@
+package sets
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/events"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// TestPublish checks that changes to sets are published to the event hub,
+// with the state of the set after the change, or before it for deletions.
+func TestPublish(t *testing.T) {
+	stored := &models.Set{ID: 2, UID: 1, Movement: "Squat", Volume: 5, Intensity: 80, Version: 1}
+	db := &data.MockSetDB{
+		AddSetStub: func(s *models.Set) (models.SetID, error) {
+			return 2, nil
+		},
+		SetByIDForUserStub: func(setID models.SetID, userID models.UserID) (*models.Set, error) {
+			return stored, nil
+		},
+		UpdateSetForUserStub: func(setID models.SetID, userID models.UserID, s *models.Set) error {
+			return nil
+		},
+		DeleteSetForUserStub: func(setID models.SetID, userID models.UserID) error {
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		body          string
+		handler       func(*set) gin.HandlerFunc
+		wantType      string
+		wantIntensity float64
+	}{
+		{
+			name:          "Created set is published",
+			method:        http.MethodPost,
+			body:          `{"movement": "Squat", "volume": 5, "intensity": 85}`,
+			handler:       func(s *set) gin.HandlerFunc { return s.Create },
+			wantType:      events.SetCreated,
+			wantIntensity: 85,
+		},
+		{
+			name:          "Updated set is published",
+			method:        http.MethodPut,
+			body:          `{"movement": "Squat", "volume": 5, "intensity": 90}`,
+			handler:       func(s *set) gin.HandlerFunc { return s.Update },
+			wantType:      events.SetUpdated,
+			wantIntensity: 90,
+		},
+		{
+			name:          "Deleted set is published as it was before the delete",
+			method:        http.MethodDelete,
+			handler:       func(s *set) gin.HandlerFunc { return s.Delete },
+			wantType:      events.SetDeleted,
+			wantIntensity: 80,
+		},
+	}
+
+	for _, v := range tests {
+		hub := events.NewHub(0, 0)
+		sub, _, _ := hub.Subscribe(0, func(events.Event) bool { return true })
+		ts, err := New(db, nil, hub)
+		if err != nil {
+			t.Fail()
+		}
+
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(v.method, "", bytes.NewBufferString(v.body))
+		c.AddParam(SetIDFromParamsKey, "2")
+		c.Set(users.UserIDFromContextKey, models.UserID(1))
+
+		v.handler(ts)(c)
+		hub.Close()
+
+		var got []events.Event
+		for e := range sub.Events {
+			got = append(got, e)
+		}
+		if len(got) != 1 {
+			t.Fatalf("%s: Wanted 1 event\nGot events: %+v", v.name, got)
+		}
+		e := got[0]
+		if e.Type != v.wantType || e.OwnerID != 1 || e.Set.ID != 2 || e.Set.Intensity != v.wantIntensity {
+			t.Fatalf("%s: Wanted %v event for set 2 of user 1 with intensity %v\nGot event: %+v", v.name, v.wantType, v.wantIntensity, e)
+		}
+	}
+}
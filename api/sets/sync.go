@@ -0,0 +1,135 @@
+package sets
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/events"
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// Limits of a single sync
+const (
+	MaxSyncChanges  = 500
+	SyncChangesPage = 1000
+)
+
+// ErrInvalidSyncToken is the detail of the problem returned for sync tokens
+// that were not handed out by the server.
+var ErrInvalidSyncToken = "sync token is invalid, sync again without a token"
+
+// SyncRequest is the body of a sync request
+// swagger:model
+type SyncRequest struct {
+	// the token of the previous sync, or empty for the first sync
+	Token string `json:"sync-token"`
+	// the changes made on the client since the previous sync, in the order
+	// they were made
+	Changes []*models.SyncChange `json:"changes" binding:"max=500,dive,required"`
+}
+
+// SyncResponse is the body of the response to a sync request
+type SyncResponse struct {
+	// the token to send with the next sync
+	Token string `json:"sync-token"`
+	// the latest state of the sets changed since the previous sync
+	Changes []*SyncedSetBody `json:"changes"`
+	// the changes of the client that were discarded
+	Conflicts []*models.SyncConflict `json:"conflicts"`
+	// set if there are more changes, which are returned by syncing again with
+	// the token
+	More bool `json:"more"`
+}
+
+// SyncedSetBody is the representation of a set changed since a sync
+type SyncedSetBody struct {
+	SetID    models.SetID `json:"set-id"`
+	ClientID string       `json:"client-id,omitempty"`
+	Deleted  bool         `json:"deleted,omitempty"`
+	// the set in the representation of the api version, omitted for deleted
+	// sets
+	Set interface{} `json:"set,omitempty"`
+}
+
+// swagger:route POST /sync sets syncSets
+// Sync the sets of the logged in user with an offline client, applying the
+// changes made on the client and returning the changes since its previous sync.
+// responses:
+//  200: syncResponse
+//  400: errorResponse
+//  401: errorResponse
+//  500: errorResponse
+//  default: errorResponse
+
+// Sync is the handler syncing the sets of the logged in user with a client.
+// Sets created on the client are identified by ids it generates until they are
+// assigned set ids, so that they can be created offline. Each field of a set
+// takes the value of its latest change, on the client or on the server, and
+// changes that lose are reported as conflicts rather than failing the sync.
+// Deleted sets are returned as tombstones so that clients delete them too.
+func (s *set) Sync(c *gin.Context) {
+	userID, err := users.UserIDFromContext(c)
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeUnauthenticated, "you must be logged in to perform this action"))
+		return
+	}
+
+	var req SyncRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Abort(c, problem.FromBindingError(err))
+		return
+	}
+
+	var since int64
+	if req.Token != "" {
+		since, err = strconv.ParseInt(req.Token, 10, 64)
+		if err != nil {
+			problem.Abort(c, problem.New(problem.CodeInvalidParameter, ErrInvalidSyncToken))
+			return
+		}
+	}
+
+	result, err := s.dbFor(c).SyncForUser(userID, since, req.Changes, SyncChangesPage)
+	if err != nil {
+		if err == data.ErrInvalidSyncToken {
+			problem.Abort(c, problem.New(problem.CodeInvalidParameter, ErrInvalidSyncToken))
+			return
+		}
+		problem.Internal(c, err)
+		return
+	}
+
+	for _, op := range result.Applied {
+		switch op.Op {
+		case models.SetOpCreate:
+			s.publish(events.SetCreated, userID, op.Set)
+		case models.SetOpUpdate:
+			s.publish(events.SetUpdated, userID, op.Set)
+		case models.SetOpDelete:
+			s.publish(events.SetDeleted, userID, op.Previous)
+		}
+	}
+
+	resp := SyncResponse{
+		Token:     strconv.FormatInt(result.Token, 10),
+		Changes:   make([]*SyncedSetBody, 0, len(result.Changes)),
+		Conflicts: result.Conflicts,
+		More:      result.More,
+	}
+	if resp.Conflicts == nil {
+		resp.Conflicts = []*models.SyncConflict{}
+	}
+	for _, change := range result.Changes {
+		body := &SyncedSetBody{SetID: change.SetID, ClientID: change.ClientID, Deleted: change.Deleted}
+		if change.Set != nil {
+			body.Set = setBody(c, change.Set)
+		}
+		resp.Changes = append(resp.Changes, body)
+	}
+
+	c.IndentedJSON(http.StatusOK, resp)
+}
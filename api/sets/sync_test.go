@@ -0,0 +1,170 @@
+package sets
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// TestSyncSets tests the API layer's Sync method for the Sets resource.
+// The test suite mocks the SetDB interface to test edge cases and error conditions.
+func TestSyncSets(t *testing.T) {
+	tests := []struct {
+		name        string
+		db          *data.MockSetDB
+		requestBody string
+		wantCode    int
+		wantResp    string
+	}{
+		{
+			name: "Valid sync returns StatusOK with changes and conflicts",
+			db: &data.MockSetDB{
+				SyncForUserStub: func(userID models.UserID, since int64, changes []*models.SyncChange, limit int) (*models.SyncResult, error) {
+					if since != 12 || len(changes) != 2 || changes[0].ClientID != "a" || *changes[1].Fields.Volume != 3 || limit != SyncChangesPage {
+						return nil, fmt.Errorf("unexpected sync of %v changes since %v", len(changes), since)
+					}
+					created := &models.Set{ID: 7, UID: userID, Movement: "Squat", Volume: 5, Intensity: 80, Version: 1}
+					return &models.SyncResult{
+						Changes: []*models.SyncedSet{
+							{SetID: 7, ClientID: "a", Set: created},
+							{SetID: 4, Deleted: true},
+						},
+						Conflicts: []*models.SyncConflict{
+							{SetID: 3, Field: "volume", Reason: models.ConflictServerNewer, ClientValue: 3.0, ServerValue: 5.0},
+						},
+						Applied: []*models.SetOperation{{Op: models.SetOpCreate, SetID: 7, Set: created}},
+						Token:   20,
+					}, nil
+				},
+			},
+			requestBody: `{
+				"sync-token": "12",
+				"changes": [
+					{"client-id": "a", "modified-at": "2026-10-19T08:00:00Z", "fields": {"movement": "Squat", "volume": 5, "intensity": 80}},
+					{"set-id": 3, "modified-at": "2026-10-19T08:01:00Z", "fields": {"volume": 3}}
+				]
+			}`,
+			wantCode: http.StatusOK,
+			wantResp: `{
+				"sync-token": "20",
+				"changes": [
+					{"set-id": 7, "client-id": "a", "set": {"set-id": 7, "user-id": 1, "movement": "Squat", "volume": 5, "intensity": 80}},
+					{"set-id": 4, "deleted": true}
+				],
+				"conflicts": [
+					{"set-id": 3, "field": "volume", "reason": "server-newer", "client-value": 3, "server-value": 5}
+				],
+				"more": false
+			}`,
+		},
+		{
+			name: "First sync without changes returns every change",
+			db: &data.MockSetDB{
+				SyncForUserStub: func(userID models.UserID, since int64, changes []*models.SyncChange, limit int) (*models.SyncResult, error) {
+					if since != 0 || len(changes) != 0 {
+						return nil, fmt.Errorf("unexpected sync of %v changes since %v", len(changes), since)
+					}
+					return &models.SyncResult{Token: 5, More: true}, nil
+				},
+			},
+			requestBody: `{}`,
+			wantCode:    http.StatusOK,
+			wantResp:    `{"sync-token": "5", "changes": [], "conflicts": [], "more": true}`,
+		},
+		{
+			name:        "Change without an id returns StatusBadRequest",
+			db:          &data.MockSetDB{},
+			requestBody: `{"changes": [{"modified-at": "2026-10-19T08:00:00Z", "fields": {"volume": 3}}]}`,
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "Change with an invalid field returns StatusBadRequest",
+			db:          &data.MockSetDB{},
+			requestBody: `{"changes": [{"set-id": 3, "modified-at": "2026-10-19T08:00:00Z", "fields": {"intensity": 101}}]}`,
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "Malformed token returns StatusBadRequest",
+			db:          &data.MockSetDB{},
+			requestBody: `{"sync-token": "abc"}`,
+			wantCode:    http.StatusBadRequest,
+			wantResp: fmt.Sprintf(`{
+				"type": "urn:training-notebook:problem:invalid-parameter",
+				"title": "Invalid parameter",
+				"status": 400,
+				"detail": %q,
+				"code": "invalid-parameter"
+			}`, ErrInvalidSyncToken),
+		},
+		{
+			name: "Token that was never handed out returns StatusBadRequest",
+			db: &data.MockSetDB{
+				SyncForUserStub: func(userID models.UserID, since int64, changes []*models.SyncChange, limit int) (*models.SyncResult, error) {
+					return nil, data.ErrInvalidSyncToken
+				},
+			},
+			requestBody: `{"sync-token": "99"}`,
+			wantCode:    http.StatusBadRequest,
+			wantResp: fmt.Sprintf(`{
+				"type": "urn:training-notebook:problem:invalid-parameter",
+				"title": "Invalid parameter",
+				"status": 400,
+				"detail": %q,
+				"code": "invalid-parameter"
+			}`, ErrInvalidSyncToken),
+		},
+		{
+			name: "Invalid db call returns InternalServerError",
+			db: &data.MockSetDB{
+				SyncForUserStub: func(userID models.UserID, since int64, changes []*models.SyncChange, limit int) (*models.SyncResult, error) {
+					return nil, fmt.Errorf("Expected error")
+				},
+			},
+			requestBody: `{}`,
+			wantCode:    http.StatusInternalServerError,
+			wantResp: `{
+				"type": "urn:training-notebook:problem:internal-error",
+				"title": "Internal server error",
+				"status": 500,
+				"detail": "an internal error occurred, quote the request id when reporting it",
+				"code": "internal-error"
+			}`,
+		},
+	}
+
+	for _, v := range tests {
+		ts, err := New(v.db, nil, nil)
+		if err != nil {
+			t.Fail()
+		}
+
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "", bytes.NewBufferString(v.requestBody))
+		c.Set(users.UserIDFromContextKey, models.UserID(1))
+
+		// execute sync with the test context
+		ts.Sync(c)
+
+		// check response code
+		if v.wantCode != w.Code {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v\nBody: %v", v.name, v.wantCode, w.Code, w.Body.String())
+		}
+
+		// check response body
+		if v.wantResp == "" {
+			continue
+		}
+		if equal, _ := JSONBytesEqual([]byte(v.wantResp), w.Body.Bytes()); !equal {
+			t.Fatalf("%s: Wanted body: %v\nGot body: %v\n", v.name, v.wantResp, w.Body.String())
+		}
+	}
+}
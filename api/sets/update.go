@@ -5,6 +5,8 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/events"
+	"github.com/hrand1005/training-notebook/api/problem"
 	"github.com/hrand1005/training-notebook/api/users"
 	"github.com/hrand1005/training-notebook/data"
 	"github.com/hrand1005/training-notebook/models"
@@ -17,42 +19,75 @@ import (
 //  400: errorResponse
 // 	401: errorResponse
 //  404: errorResponse
+//  412: errorResponse
 //  500: errorResponse
+//  default: errorResponse
 
 // Update is the handler for update requests on the set resource. An id must be
-// specified.
+// specified. If the If-Match header is set, the update only applies if it
+// matches the ETag of the stored set.
 func (s *set) Update(c *gin.Context) {
 	userID, err := users.UserIDFromContext(c)
 	if err != nil {
-		c.IndentedJSON(http.StatusUnauthorized, gin.H{"message": "you must be logged in to perform this action"})
+		problem.Abort(c, problem.New(problem.CodeUnauthenticated, "you must be logged in to perform this action"))
 		return
 	}
 
 	var newSet models.Set
 
-	if err := c.BindJSON(&newSet); err != nil {
-		msg := models.BindingErrorToMessage(err)
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": msg})
+	if err := bindSet(c, &newSet); err != nil {
+		problem.Abort(c, problem.FromBindingError(err))
 		return
 	}
 
 	setID, err := SetIDFromParams(c)
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": ErrInvalidSetID})
+		problem.Abort(c, problem.New(problem.CodeInvalidParameter, ErrInvalidSetID))
 		return
 	}
 
-	if err := s.db.UpdateSetForUser(setID, userID, &newSet); err != nil {
+	// the current state of the set is needed to check preconditions
+	ifMatch := c.GetHeader("If-Match")
+	var current *models.Set
+	if ifMatch != "" {
+		current, err = s.dbFor(c).SetByIDForUser(setID, userID)
+		if err != nil {
+			if err == data.ErrNotFound {
+				msg := fmt.Sprintf("no such set with id %v", setID)
+				problem.Abort(c, problem.New(problem.CodeNotFound, msg))
+				return
+			}
+			problem.Internal(c, err)
+			return
+		}
+	}
+
+	if ifMatch != "" {
+		if !etagMatches(ifMatch, setETag(current), false) {
+			problem.Abort(c, problem.New(problem.CodePreconditionFailed, ErrSetModified))
+			return
+		}
+		// guard against concurrent changes between the read and the update
+		newSet.Version = current.Version
+	}
+
+	if err := s.dbFor(c).UpdateSetForUser(setID, userID, &newSet); err != nil {
 		if err == data.ErrNotFound {
 			msg := fmt.Sprintf("no such set with id %v", setID)
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": msg})
+			problem.Abort(c, problem.New(problem.CodeNotFound, msg))
+			return
+		}
+		if err == data.ErrVersionMismatch {
+			problem.Abort(c, problem.New(problem.CodePreconditionFailed, ErrSetModified))
 			return
 		}
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		problem.Internal(c, err)
 		return
 	}
 
 	newSet.ID = setID
 	newSet.UID = userID
-	c.IndentedJSON(http.StatusOK, newSet)
+	s.publish(events.SetUpdated, userID, &newSet)
+	c.Header("ETag", setETag(&newSet))
+	c.IndentedJSON(http.StatusOK, setBody(c, &newSet))
 }
@@ -65,7 +65,11 @@ func TestUpdateSet(t *testing.T) {
 			} `),
 			wantCode: http.StatusBadRequest,
 			wantResp: *bytes.NewBufferString(` {
-				"message": "Invalid set ID"
+				"type": "urn:training-notebook:problem:invalid-parameter",
+				"title": "Invalid parameter",
+				"status": 400,
+				"detail": "Invalid set ID",
+				"code": "invalid-parameter"
 			} `),
 		},
 		{
@@ -84,7 +88,11 @@ func TestUpdateSet(t *testing.T) {
 			} `),
 			wantCode: http.StatusNotFound,
 			wantResp: *bytes.NewBufferString(` {
-				"message": "no such set with id 2"
+				"type": "urn:training-notebook:problem:not-found",
+				"title": "Resource not found",
+				"status": 404,
+				"detail": "no such set with id 2",
+				"code": "not-found"
 			} `),
 		},
 		{
@@ -103,7 +111,11 @@ func TestUpdateSet(t *testing.T) {
 			} `),
 			wantCode: http.StatusInternalServerError,
 			wantResp: *bytes.NewBufferString(` {
-				"message": "Expected error"
+				"type": "urn:training-notebook:problem:internal-error",
+				"title": "Internal server error",
+				"status": 500,
+				"detail": "an internal error occurred, quote the request id when reporting it",
+				"code": "internal-error"
 			} `),
 		},
 		{
@@ -116,7 +128,14 @@ func TestUpdateSet(t *testing.T) {
 			} `),
 			wantCode: http.StatusBadRequest,
 			wantResp: *bytes.NewBufferString(` {
-				"message": "'Volume' field must be greater than 0."
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "'Volume' field must be greater than 0.",
+				"code": "validation-failed",
+				"errors": [
+					{"field": "Volume", "code": "gt", "message": "'Volume' field must be greater than 0."}
+				]
 			} `),
 		},
 		{
@@ -130,7 +149,14 @@ func TestUpdateSet(t *testing.T) {
 			} `),
 			wantCode: http.StatusBadRequest,
 			wantResp: *bytes.NewBufferString(` {
-				"message": "'Volume' field must be greater than 0."
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "'Volume' field must be greater than 0.",
+				"code": "validation-failed",
+				"errors": [
+					{"field": "Volume", "code": "gt", "message": "'Volume' field must be greater than 0."}
+				]
 			} `),
 		},
 		{
@@ -143,7 +169,14 @@ func TestUpdateSet(t *testing.T) {
 			} `),
 			wantCode: http.StatusBadRequest,
 			wantResp: *bytes.NewBufferString(` {
-				"message": "'Intensity' field must be greater than 0."
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "'Intensity' field must be greater than 0.",
+				"code": "validation-failed",
+				"errors": [
+					{"field": "Intensity", "code": "gt", "message": "'Intensity' field must be greater than 0."}
+				]
 			} `),
 		},
 		{
@@ -157,7 +190,14 @@ func TestUpdateSet(t *testing.T) {
 			}`),
 			wantCode: http.StatusBadRequest,
 			wantResp: *bytes.NewBufferString(` {
-				"message": "'Intensity' field must be greater than 0."
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "'Intensity' field must be greater than 0.",
+				"code": "validation-failed",
+				"errors": [
+					{"field": "Intensity", "code": "gt", "message": "'Intensity' field must be greater than 0."}
+				]
 			} `),
 		},
 		{
@@ -171,13 +211,20 @@ func TestUpdateSet(t *testing.T) {
 			}`),
 			wantCode: http.StatusBadRequest,
 			wantResp: *bytes.NewBufferString(` {
-				"message": "'Intensity' field must be no more than 100."
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "'Intensity' field must be no more than 100.",
+				"code": "validation-failed",
+				"errors": [
+					{"field": "Intensity", "code": "lte", "message": "'Intensity' field must be no more than 100."}
+				]
 			} `),
 		},
 	}
 
 	for _, v := range tests {
-		ts, err := New(v.db)
+		ts, err := New(v.db, nil, nil)
 		if err != nil {
 			t.Fail()
 		}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/problem"
 	"github.com/hrand1005/training-notebook/models"
 )
 
@@ -22,18 +23,18 @@ func (u *user) Create(c *gin.Context) {
 	var newUser models.User
 
 	if err := c.BindJSON(&newUser); err != nil {
-		msg := models.BindingErrorToMessage(err)
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": msg})
+		problem.Abort(c, problem.FromBindingError(err))
 		return
 	}
 
 	// assigns ID to newUser
-	id, err := u.db.AddUser(&newUser)
+	id, err := u.dbFor(c).AddUser(&newUser)
 	if err != nil {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		problem.Internal(c, err)
 		return
 	}
 
 	newUser.ID = id
+	u.notify(models.AuditCreate, &newUser)
 	c.IndentedJSON(http.StatusCreated, newUser)
 }
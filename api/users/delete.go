@@ -5,7 +5,9 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/problem"
 	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
 )
 
 // NOTE: The following method is used for debugging, and should not be reachable
@@ -23,19 +25,31 @@ import (
 func (u *user) Delete(c *gin.Context) {
 	userID, err := UserIDFromParams(c)
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": ErrInvalidUserID})
+		problem.Abort(c, problem.New(problem.CodeInvalidParameter, ErrInvalidUserID))
 		return
 	}
 
-	if err := u.db.DeleteUser(userID); err != nil {
+	// the deleted state of the user is only needed for change notifications
+	var before *models.User
+	if u.onChange != nil {
+		if before, err = u.dbFor(c).UserByID(userID); err != nil && err != data.ErrNotFound {
+			problem.Internal(c, err)
+			return
+		}
+	}
+
+	if err := u.dbFor(c).DeleteUser(userID); err != nil {
 		if err == data.ErrNotFound {
 			msg := fmt.Sprintf("no such user with id %v", userID)
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": msg})
+			problem.Abort(c, problem.New(problem.CodeNotFound, msg))
 			return
 		}
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		problem.Internal(c, err)
 		return
 	}
 
+	if before != nil {
+		u.notify(models.AuditDelete, before)
+	}
 	c.IndentedJSON(http.StatusNoContent, gin.H{})
 }
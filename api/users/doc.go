@@ -3,7 +3,7 @@
 // Documentation for User API
 //
 //  Schemes: http
-//  BasePath: /
+//  BasePath: /api/v1
 //  Version: 1.0.0
 //
 //  Consumes:
@@ -11,17 +11,21 @@
 //
 //  Produces:
 //  - application/json
+//  - application/problem+json
 // swagger:meta
 package users
 
 import (
-	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/problem"
 	"github.com/hrand1005/training-notebook/data"
 	"github.com/hrand1005/training-notebook/models"
 )
 
 type user struct {
-	db data.UserDB
+	db    data.UserDB
+	audit data.AuditDB
+	// onChange is called for each change to a user, if set
+	onChange func(action models.AuditAction, changed *models.User)
 }
 
 // returns a user in the response
@@ -40,20 +44,61 @@ type usersResponse struct {
 	Body []models.User
 }
 
-// returns generic error message as string
+// returns an RFC 7807 problem describing the error
 // swagger:response errorResponse
 type errorResponse struct {
-	// Description of the error
+	// Description of the error, with a machine-readable code
 	// in: body
-	Body gin.H
+	Body problem.Problem
 }
 
-// swagger:parameters readuser
-// swagger:parameters updateuser
-// swagger:parameters deleteuser
+// confirms the login, whose token is set in the token cookie
+// swagger:response loginResponse
+type loginResponse struct {
+	// in: body
+	Body struct {
+		Message string `json:"message"`
+	}
+}
+
+// swagger:parameters readUser
+// swagger:parameters updateUser
+// swagger:parameters patchUser
+// swagger:parameters changePassword
 type userIDParameter struct {
 	// The id of the user
-	// in: required: true
+	// in: path
+	// required: true
+	ID int `json:"id"`
+}
+
+// swagger:parameters signup
+// swagger:parameters updateUser
+type userParameter struct {
+	// The user to create, or to replace the user with
+	// in: body
+	// required: true
+	Body models.User
+}
+
+// swagger:parameters patchUser
+type userPatchParameter struct {
+	// The fields to change
+	// in: body
+	// required: true
+	Body models.User
+}
+
+// swagger:parameters login
+type credentialsParameter struct {
+	// in: body
+	// required: true
+	Body models.Credentails
+}
+
+// swagger:parameters changePassword
+type passwordChangeParameter struct {
+	// in: body
 	// required: true
-	ID int `json:"user-id"`
+	Body models.PasswordChange
 }
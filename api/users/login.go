@@ -1,32 +1,40 @@
 package users
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
-	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/metrics"
 	"github.com/hrand1005/training-notebook/models"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// token settings
+// ErrIncorrectPassword is returned when login credentials do not match the user
+var ErrIncorrectPassword = errors.New("incorrect password")
+
+// token settings. Login tokens expire after TokenLifetime, along with the
+// login cookie holding them.
 const (
 	LoginCookieName     = "token"
 	LoginCookieMaxAge   = 3600
-	LoginCookieSecure   = false
 	LoginCookieHTTPOnly = true
+	TokenLifetime       = LoginCookieMaxAge * time.Second
 )
 
-// swagger:route POST /users/login users login
+// swagger:route POST /login users login
 // Login as user.
 // responses:
-//  201: userResponse
+//  200: loginResponse
 //  400: errorResponse
 //  401: errorResponse
 //  404: errorResponse
 //  500: errorResponse
+//  default: errorResponse
 
 // Login is the handler that attempts to login a user. Checks the
 // provided credentials and upon success sets the headers of the client
@@ -35,33 +43,46 @@ func (u *user) Login(c *gin.Context) {
 	var credentials models.Credentails
 
 	if err := c.BindJSON(&credentials); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		problem.Abort(c, problem.FromBindingError(err))
 		return
 	}
 
-	user, err := u.db.UserByID(credentials.UID)
+	user, err := u.dbFor(c).UserByID(credentials.UID)
 	if err != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		if err == data.ErrNotFound {
+			metrics.LoginAttempts.WithLabelValues(metrics.LoginFailure).Inc()
+			problem.Abort(c, problem.Newf(problem.CodeNotFound, "no such user with id %v", credentials.UID))
+			return
+		}
+		problem.Internal(c, err)
 		return
 	}
 
 	token, err := AuthenticateUser(user, credentials)
 	if err != nil {
-		c.IndentedJSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+		if err == ErrIncorrectPassword {
+			metrics.LoginAttempts.WithLabelValues(metrics.LoginFailure).Inc()
+			problem.Abort(c, problem.New(problem.CodeInvalidCredentials, err.Error()))
+			return
+		}
+		problem.Internal(c, err)
 		return
 	}
 
 	// TODO: get path and domain from configs or env
-	c.SetCookie(LoginCookieName, token, LoginCookieMaxAge, "", "", LoginCookieSecure, LoginCookieHTTPOnly)
+	c.SetSameSite(sameSite)
+	c.SetCookie(LoginCookieName, token, LoginCookieMaxAge, "", "", secureCookies, LoginCookieHTTPOnly)
+	metrics.LoginAttempts.WithLabelValues(metrics.LoginSuccess).Inc()
+	metrics.Sessions.Start(int(user.ID), time.Now().Add(TokenLifetime))
 	c.IndentedJSON(http.StatusOK, gin.H{"message": "logged in successfully"})
 }
 
 func AuthenticateUser(user *models.User, credentials models.Credentails) (string, error) {
 	if checkPasswordHash(user.Password, credentials.Password) {
-		return buildToken(user)
+		return buildToken(user, time.Now().Add(TokenLifetime))
 	}
 
-	return "", fmt.Errorf("incorrect password")
+	return "", ErrIncorrectPassword
 }
 
 func checkPasswordHash(hash, password string) bool {
@@ -71,16 +92,51 @@ func checkPasswordHash(hash, password string) bool {
 	return true
 }
 
+// Claims are the claims of a login token, identifying the logged in user
+// until the token expires.
 type Claims struct {
 	UserID models.UserID
-	jwt.StandardClaims
+	jwt.RegisteredClaims
+}
+
+// signingKey signs and verifies login tokens
+var signingKey []byte
+
+// SetSigningKey sets the key used to sign login tokens and verify them in
+// RequireAuthorization. It must be called before the server starts.
+func SetSigningKey(key []byte) {
+	signingKey = key
+}
+
+// secureCookies marks login cookies secure, so that browsers only send them
+// over HTTPS
+var secureCookies bool
+
+// SetSecureCookies sets whether login cookies are marked secure. It should be
+// set when the server is served over HTTPS, and must be called before the
+// server starts.
+func SetSecureCookies(secure bool) {
+	secureCookies = secure
+}
+
+// sameSite restricts when browsers send login cookies with cross-site
+// requests
+var sameSite = http.SameSiteLaxMode
+
+// SetCookieSameSite sets the SameSite attribute of login cookies. Browsers
+// only accept SameSiteNoneMode on secure cookies. It must be called before
+// the server starts.
+func SetCookieSameSite(mode http.SameSite) {
+	sameSite = mode
 }
 
-func buildToken(user *models.User) (string, error) {
-	// TODO: get this from configs
-	signingKey := []byte(os.Getenv("SIGNING_KEY"))
+// buildToken returns a login token for user, which expires at expires.
+func buildToken(user *models.User, expires time.Time) (string, error) {
 	jwtClaims := &Claims{
 		UserID: user.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expires),
+		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwtClaims)
 	return token.SignedString(signingKey)
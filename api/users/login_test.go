@@ -6,8 +6,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/hrand1005/training-notebook/data"
 	"github.com/hrand1005/training-notebook/models"
 )
@@ -17,20 +19,19 @@ func TestLogin(t *testing.T) {
 	testUserID := models.UserID(2)
 	testUserName := "TestUser"
 	testUserPassword := "12345"
-	hashedPassword, _ := hashPassword("12345")
+	hashedPassword, _ := HashPassword("12345")
 	testUser := &models.User{
 		ID:       testUserID,
 		Name:     testUserName,
 		Password: hashedPassword,
 	}
-	testToken, _ := buildToken(testUser)
 
 	tests := []struct {
 		name        string
 		requestBody bytes.Buffer
 		db          *data.MockUserDB
 		wantCode    int
-		wantToken   string
+		wantUserID  models.UserID
 	}{
 		{
 			name: "Valid request and DB call returns StatusOK",
@@ -43,13 +44,13 @@ func TestLogin(t *testing.T) {
 					return testUser, nil
 				},
 			},
-			wantCode:  http.StatusOK,
-			wantToken: testToken,
+			wantCode:   http.StatusOK,
+			wantUserID: testUserID,
 		},
 	}
 	for _, v := range tests {
 		// configure test case with data and test context
-		u, err := New(v.db)
+		u, err := New(v.db, nil)
 		if err != nil {
 			t.Fail()
 		}
@@ -72,9 +73,25 @@ func TestLogin(t *testing.T) {
 		}
 
 		// check that the token is the only cookie set in the response
-		gotToken := w.Result().Cookies()[0].Value
-		if v.wantToken != gotToken {
-			t.Fatalf("Wanted token: %s\nGot token: %s\n", v.wantToken, gotToken)
+		gotCookie := w.Result().Cookies()[0]
+		claims := Claims{}
+		if _, err := jwt.ParseWithClaims(gotCookie.Value, &claims, func(*jwt.Token) (interface{}, error) {
+			return signingKey, nil
+		}); err != nil {
+			t.Fatalf("Encountered unexpected error parsing token: %v", err)
+		}
+		if v.wantUserID != claims.UserID {
+			t.Fatalf("Wanted token for user: %v\nGot token for user: %v\n", v.wantUserID, claims.UserID)
+		}
+		// the token expires with the cookie
+		if claims.ExpiresAt == nil || time.Until(claims.ExpiresAt.Time) > TokenLifetime || time.Until(claims.ExpiresAt.Time) < TokenLifetime-time.Minute {
+			t.Fatalf("Wanted token to expire in: %v\nGot expiry: %v\n", TokenLifetime, claims.ExpiresAt)
+		}
+		if gotCookie.MaxAge != LoginCookieMaxAge {
+			t.Fatalf("Wanted cookie max age: %v\nGot max age: %v\n", LoginCookieMaxAge, gotCookie.MaxAge)
+		}
+		if gotCookie.SameSite != http.SameSiteLaxMode {
+			t.Fatalf("Wanted SameSite: %v\nGot SameSite: %v\n", http.SameSiteLaxMode, gotCookie.SameSite)
 		}
 	}
 }
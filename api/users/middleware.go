@@ -3,27 +3,59 @@ package users
 import (
 	"fmt"
 	"net/http"
-	"os"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/hrand1005/training-notebook/api/problem"
 	"github.com/hrand1005/training-notebook/data"
 	"github.com/hrand1005/training-notebook/models"
+	"github.com/hrand1005/training-notebook/tracing"
 )
 
+// BearerScheme is the Authorization scheme of login tokens sent in headers
+const BearerScheme = "Bearer"
+
+// BearerToken returns the login token sent in the Authorization header,
+// which clients that do not keep cookies may send instead of the login
+// cookie.
+func BearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, BearerScheme) {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// LoginToken returns the login token of the request. A bearer token takes
+// precedence over the login cookie, so that requests exempt from cookie
+// protections are never authenticated by the cookie.
+func LoginToken(c *gin.Context) (string, bool) {
+	if token, ok := BearerToken(c.Request); ok {
+		return token, true
+	}
+	token, err := c.Cookie(LoginCookieName)
+	return token, err == nil && token != ""
+}
+
 // TODO: authorization/permissions levels
-// RequireAuthorization checks that the LoginCookie is set in the http request. If not found, sets
-// StatusUnauthorized, else sets the token in the gin context.
+// RequireAuthorization checks that the request carries a login token, in a
+// bearer token or the LoginCookie. If not found, sets StatusUnauthorized,
+// else sets the user in the gin context.
 func RequireAuthorization( /*l AuthorizationLevel*/ ) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token, err := c.Cookie(LoginCookieName)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "you must be logged in to perform this action"})
+		token, ok := LoginToken(c)
+		if !ok {
+			problem.Abort(c, problem.New(problem.CodeUnauthenticated, "you must be logged in to perform this action"))
+			return
 		}
 
-		userID, err := parseUserIDFromToken(token)
+		_, end := tracing.MiddlewareSpan(c, "users.RequireAuthorization")
+		userID, err := UserIDFromToken(token)
+		end(err)
 		if err != nil {
-			c.AbortWithStatus(http.StatusUnauthorized)
+			problem.Abort(c, problem.New(problem.CodeUnauthenticated, "your login is invalid or has expired, log in again"))
 			return
 		}
 
@@ -34,10 +66,31 @@ func RequireAuthorization( /*l AuthorizationLevel*/ ) gin.HandlerFunc {
 	}
 }
 
-func parseUserIDFromToken(token string) (models.UserID, error) {
+// Authenticate sets the user in the gin context if the request carries a
+// valid login, so that middleware running before RequireAuthorization can
+// identify the user. Requests without a valid login continue anonymously.
+func Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := LoginToken(c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		if userID, err := UserIDFromToken(token); err == nil {
+			c.Set(UserIDFromContextKey, userID)
+		}
+
+		c.Next()
+	}
+}
+
+// UserIDFromToken returns the id of the user logged in by token, or an error
+// if the token is invalid, has expired or was not signed with the signing key.
+func UserIDFromToken(token string) (models.UserID, error) {
 	claims := Claims{}
 	parsedToken, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("SIGNING_KEY")), nil
+		return signingKey, nil
 	})
 	if err != nil {
 		return data.InvalidUserID, err
@@ -47,6 +100,44 @@ func parseUserIDFromToken(token string) (models.UserID, error) {
 		return data.InvalidUserID, fmt.Errorf("invalid token")
 	}
 
+	// the expiry is only checked by the parser if it is set, and tokens
+	// without one would never expire
+	if claims.ExpiresAt == nil {
+		return data.InvalidUserID, fmt.Errorf("token has no expiry")
+	}
+
 	// TODO: can this return zero? how can I verify that it's initialized?
 	return claims.UserID, nil
 }
+
+// RequireAdmin checks that the user set in the gin context by RequireAuthorization
+// is an administrator, and aborts with StatusForbidden otherwise. It must be
+// chained after RequireAuthorization.
+func RequireAdmin(db data.UserDB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := UserIDFromContext(c)
+		if err != nil {
+			problem.Abort(c, problem.New(problem.CodeUnauthenticated, "you must be logged in to perform this action"))
+			return
+		}
+
+		ctx, end := tracing.MiddlewareSpan(c, "users.RequireAdmin")
+		user, err := data.TraceUserDB(ctx, db).UserByID(userID)
+		end(err)
+		if err != nil {
+			if err == data.ErrNotFound {
+				problem.Abort(c, problem.New(problem.CodeUnauthenticated, "you must be logged in to perform this action"))
+				return
+			}
+			problem.Internal(c, err)
+			return
+		}
+
+		if !user.Admin {
+			problem.Abort(c, problem.New(problem.CodeForbidden, "you must be an administrator to perform this action"))
+			return
+		}
+
+		c.Next()
+	}
+}
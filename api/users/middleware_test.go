@@ -0,0 +1,98 @@
+package users
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// TestRequireAuthorization checks that users are authenticated by a bearer
+// token or the login cookie, with the bearer token taking precedence.
+func TestRequireAuthorization(t *testing.T) {
+	cookieToken, _ := buildToken(&models.User{ID: 2}, time.Now().Add(TokenLifetime))
+	bearerToken, _ := buildToken(&models.User{ID: 3}, time.Now().Add(TokenLifetime))
+	expiredToken, _ := buildToken(&models.User{ID: 3}, time.Now().Add(-time.Minute))
+	// tokens were once issued without an expiry
+	unexpiringToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{UserID: 3}).SignedString(signingKey)
+
+	tests := []struct {
+		name       string
+		cookie     string
+		auth       string
+		wantCode   int
+		wantUserID models.UserID
+	}{
+		{
+			name:       "Login cookie is accepted",
+			cookie:     cookieToken,
+			wantCode:   http.StatusOK,
+			wantUserID: 2,
+		},
+		{
+			name:       "Bearer token is accepted",
+			auth:       "Bearer " + bearerToken,
+			wantCode:   http.StatusOK,
+			wantUserID: 3,
+		},
+		{
+			name:       "Bearer token takes precedence over login cookie",
+			cookie:     cookieToken,
+			auth:       "bearer " + bearerToken,
+			wantCode:   http.StatusOK,
+			wantUserID: 3,
+		},
+		{
+			name:     "Invalid bearer token is rejected despite login cookie",
+			cookie:   cookieToken,
+			auth:     "Bearer invalid",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "Expired token is rejected",
+			auth:     "Bearer " + expiredToken,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "Token without expiry is rejected",
+			auth:     "Bearer " + unexpiringToken,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "Other authorization schemes are ignored",
+			auth:     "Basic " + bearerToken,
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, v := range tests {
+		var gotUserID models.UserID
+		router := gin.New()
+		router.GET("/sets", RequireAuthorization(), func(c *gin.Context) {
+			gotUserID, _ = UserIDFromContext(c)
+			c.Status(http.StatusOK)
+		})
+
+		req, _ := http.NewRequest(http.MethodGet, "/sets", nil)
+		if v.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: LoginCookieName, Value: v.cookie})
+		}
+		if v.auth != "" {
+			req.Header.Set("Authorization", v.auth)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != v.wantCode {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v", v.name, v.wantCode, w.Code)
+		}
+		if gotUserID != v.wantUserID {
+			t.Fatalf("%s: Wanted user id: %v\nGot user id: %v", v.name, v.wantUserID, gotUserID)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package users
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// swagger:route PUT /users/{id}/password users changePassword
+// Change the password of the logged in user.
+// responses:
+//  204: noContent
+//  400: errorResponse
+//  401: errorResponse
+//  403: errorResponse
+//  404: errorResponse
+//  500: errorResponse
+//  default: errorResponse
+
+// ChangePassword is the handler for password changes on the user resource. An
+// id must be specified, and must be the id of the logged in user. The current
+// password is verified before the new password is hashed and stored.
+func (u *user) ChangePassword(c *gin.Context) {
+	userID, err := UserIDFromParams(c)
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeInvalidParameter, ErrInvalidUserID))
+		return
+	}
+
+	if !requireSelf(c, userID) {
+		return
+	}
+
+	var change models.PasswordChange
+	if err := c.ShouldBindJSON(&change); err != nil {
+		problem.Abort(c, problem.FromBindingError(err))
+		return
+	}
+
+	current, err := u.dbFor(c).UserByID(userID)
+	if err != nil {
+		if err == data.ErrNotFound {
+			msg := fmt.Sprintf("no such user with id %v", userID)
+			problem.Abort(c, problem.New(problem.CodeNotFound, msg))
+			return
+		}
+		problem.Internal(c, err)
+		return
+	}
+
+	if !checkPasswordHash(current.Password, change.CurrentPassword) {
+		problem.Abort(c, problem.New(problem.CodeInvalidCredentials, ErrIncorrectPassword.Error()))
+		return
+	}
+
+	if err := CheckPasswordRequirements(change.NewPassword); err != nil {
+		problem.Abort(c, problem.New(problem.CodeValidationFailed, err.Error()))
+		return
+	}
+
+	hashedPassword, err := HashPassword(change.NewPassword)
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeValidationFailed, "invalid password"))
+		return
+	}
+
+	if err := u.dbFor(c).UpdatePassword(userID, hashedPassword); err != nil {
+		if err == data.ErrNotFound {
+			msg := fmt.Sprintf("no such user with id %v", userID)
+			problem.Abort(c, problem.New(problem.CodeNotFound, msg))
+			return
+		}
+		problem.Internal(c, err)
+		return
+	}
+
+	// the audit trail records that the password changed, but never the hash
+	u.notify(models.AuditUpdate, current)
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,166 @@
+package users
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// TestChangePassword tests the API layer's ChangePassword method for the Users resource.
+// The test suite mocks the UserDB interface to test edge cases and error conditions.
+func TestChangePassword(t *testing.T) {
+	hashedPassword, _ := HashPassword("cookies")
+	storedUser := func(id models.UserID) (*models.User, error) {
+		return &models.User{ID: id, Name: "Herb", Password: hashedPassword}, nil
+	}
+
+	tests := []struct {
+		name        string
+		db          *data.MockUserDB
+		id          string
+		loggedInID  models.UserID
+		requestBody string
+		wantCode    int
+		wantResp    string
+	}{
+		{
+			name: "Correct current password rehashes and returns StatusNoContent",
+			db: &data.MockUserDB{
+				UserByIDStub: storedUser,
+				UpdatePasswordStub: func(id models.UserID, hash string) error {
+					if !checkPasswordHash(hash, "brownies") {
+						return fmt.Errorf("new password was not hashed")
+					}
+					return nil
+				},
+			},
+			id:          "1",
+			loggedInID:  1,
+			requestBody: `{"current-password": "cookies", "new-password": "brownies"}`,
+			wantCode:    http.StatusNoContent,
+		},
+		{
+			name: "Incorrect current password returns StatusUnauthorized",
+			db: &data.MockUserDB{
+				UserByIDStub: storedUser,
+			},
+			id:          "1",
+			loggedInID:  1,
+			requestBody: `{"current-password": "muffins", "new-password": "brownies"}`,
+			wantCode:    http.StatusUnauthorized,
+			wantResp: `{
+				"type": "urn:training-notebook:problem:invalid-credentials",
+				"title": "Invalid credentials",
+				"status": 401,
+				"detail": "incorrect password",
+				"code": "invalid-credentials"
+			}`,
+		},
+		{
+			name: "Short new password returns StatusBadRequest",
+			db: &data.MockUserDB{
+				UserByIDStub: storedUser,
+			},
+			id:          "1",
+			loggedInID:  1,
+			requestBody: `{"current-password": "cookies", "new-password": "pie"}`,
+			wantCode:    http.StatusBadRequest,
+			wantResp: fmt.Sprintf(`{
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "password too short, must be at least %v characters",
+				"code": "validation-failed"
+			}`, minPasswordLength),
+		},
+		{
+			name:        "Missing new password returns StatusBadRequest",
+			db:          &data.MockUserDB{},
+			id:          "1",
+			loggedInID:  1,
+			requestBody: `{"current-password": "cookies"}`,
+			wantCode:    http.StatusBadRequest,
+			wantResp: `{
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "'NewPassword' field is required.",
+				"code": "validation-failed",
+				"errors": [
+					{"field": "NewPassword", "code": "required", "message": "'NewPassword' field is required."}
+				]
+			}`,
+		},
+		{
+			name:        "Changing another user's password returns StatusForbidden",
+			db:          &data.MockUserDB{},
+			id:          "2",
+			loggedInID:  1,
+			requestBody: `{"current-password": "cookies", "new-password": "brownies"}`,
+			wantCode:    http.StatusForbidden,
+			wantResp: `{
+				"type": "urn:training-notebook:problem:forbidden",
+				"title": "Forbidden",
+				"status": 403,
+				"detail": "you may only change your own account",
+				"code": "forbidden"
+			}`,
+		},
+		{
+			name: "Invalid db call returns InternalServerError",
+			db: &data.MockUserDB{
+				UserByIDStub: storedUser,
+				UpdatePasswordStub: func(id models.UserID, hash string) error {
+					return fmt.Errorf("Expected error")
+				},
+			},
+			id:          "1",
+			loggedInID:  1,
+			requestBody: `{"current-password": "cookies", "new-password": "brownies"}`,
+			wantCode:    http.StatusInternalServerError,
+			wantResp: `{
+				"type": "urn:training-notebook:problem:internal-error",
+				"title": "Internal server error",
+				"status": 500,
+				"detail": "an internal error occurred, quote the request id when reporting it",
+				"code": "internal-error"
+			}`,
+		},
+	}
+
+	for _, v := range tests {
+		u, err := New(v.db, nil)
+		if err != nil {
+			t.Fail()
+		}
+
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPut, "", bytes.NewBufferString(v.requestBody))
+		c.AddParam(UserIDFromParamsKey, v.id)
+		c.Set(UserIDFromContextKey, v.loggedInID)
+
+		// execute password change with the test context
+		u.ChangePassword(c)
+		c.Writer.WriteHeaderNow()
+
+		// check response code
+		if v.wantCode != w.Code {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v\nBody: %v", v.name, v.wantCode, w.Code, w.Body.String())
+		}
+
+		// check response body
+		if v.wantCode != http.StatusNoContent {
+			if equal, _ := JSONBytesEqual([]byte(v.wantResp), w.Body.Bytes()); !equal {
+				t.Fatalf("%s: Wanted body: %v\nGot body: %v\n", v.name, v.wantResp, w.Body.String())
+			}
+		}
+	}
+}
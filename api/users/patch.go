@@ -0,0 +1,139 @@
+package users
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/mergepatch"
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// swagger:route PATCH /users/{id} users patchUser
+// Partially update the logged in user with a JSON merge patch.
+// consumes:
+//  - application/merge-patch+json
+// responses:
+//  200: userResponse
+//  400: errorResponse
+//  401: errorResponse
+//  403: errorResponse
+//  404: errorResponse
+//  415: errorResponse
+//  500: errorResponse
+//  default: errorResponse
+
+// Patch is the handler for merge patch requests on the user resource. An id
+// must be specified, and must be the id of the logged in user. Passwords
+// cannot be patched, and must be changed with ChangePassword.
+func (u *user) Patch(c *gin.Context) {
+	userID, err := UserIDFromParams(c)
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeInvalidParameter, ErrInvalidUserID))
+		return
+	}
+
+	if !requireSelf(c, userID) {
+		return
+	}
+
+	if !mergepatch.AcceptsContentType(c.GetHeader("Content-Type")) {
+		msg := fmt.Sprintf("patch must have content type %s", mergepatch.ContentType)
+		problem.Abort(c, problem.New(problem.CodeUnsupportedMediaType, msg))
+		return
+	}
+
+	patch, err := c.GetRawData()
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeMalformedRequest, "Malformed JSON"))
+		return
+	}
+
+	fields, err := mergepatch.Fields(patch)
+	if err != nil {
+		if err == mergepatch.ErrNotObject {
+			problem.Abort(c, problem.New(problem.CodeMalformedRequest, err.Error()))
+			return
+		}
+		problem.Abort(c, problem.New(problem.CodeMalformedRequest, "Malformed JSON"))
+		return
+	}
+	if _, ok := fields["password"]; ok {
+		problem.Abort(c, problem.New(problem.CodeValidationFailed, "'password' field cannot be patched, use the password endpoint."))
+		return
+	}
+	if _, ok := fields[idField(c)]; ok {
+		msg := fmt.Sprintf("'%s' field cannot be changed.", idField(c))
+		problem.Abort(c, problem.New(problem.CodeValidationFailed, msg))
+		return
+	}
+
+	current, err := u.dbFor(c).UserByID(userID)
+	if err != nil {
+		if err == data.ErrNotFound {
+			msg := fmt.Sprintf("no such user with id %v", userID)
+			problem.Abort(c, problem.New(problem.CodeNotFound, msg))
+			return
+		}
+		problem.Internal(c, err)
+		return
+	}
+
+	// patch the public representation of the user, so the password hash is
+	// never part of the patched document
+	doc, err := json.Marshal(userBody(c, current.Sanitized()))
+	if err != nil {
+		problem.Internal(c, err)
+		return
+	}
+	patchedDoc, err := mergepatch.Apply(doc, patch)
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeMalformedRequest, "Malformed JSON"))
+		return
+	}
+
+	var patched models.User
+	if err := decodeUser(c, patchedDoc, &patched); err != nil {
+		problem.Abort(c, problem.FromBindingError(err))
+		return
+	}
+	if strings.TrimSpace(patched.Name) == "" {
+		problem.Abort(c, problem.New(problem.CodeValidationFailed, "'Name' field must not be empty."))
+		return
+	}
+
+	if err := u.dbFor(c).UpdateUser(userID, &patched); err != nil {
+		if err == data.ErrNotFound {
+			msg := fmt.Sprintf("no such user with id %v", userID)
+			problem.Abort(c, problem.New(problem.CodeNotFound, msg))
+			return
+		}
+		problem.Internal(c, err)
+		return
+	}
+
+	patched.ID = userID
+	u.notify(models.AuditUpdate, &patched)
+	c.IndentedJSON(http.StatusOK, userBody(c, patched.Sanitized()))
+}
+
+// requireSelf checks that the logged in user is the user with the given id.
+// If not, the appropriate error response is written and false is returned.
+func requireSelf(c *gin.Context, userID models.UserID) bool {
+	loggedInID, err := UserIDFromContext(c)
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeUnauthenticated, "you must be logged in to perform this action"))
+		return false
+	}
+
+	if loggedInID != userID {
+		problem.Abort(c, problem.New(problem.CodeForbidden, "you may only change your own account"))
+		return false
+	}
+
+	return true
+}
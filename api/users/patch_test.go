@@ -0,0 +1,167 @@
+package users
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/mergepatch"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// TestPatchUser tests the API layer's Patch method for the Users resource.
+// The test suite mocks the UserDB interface to test edge cases and error conditions.
+func TestPatchUser(t *testing.T) {
+	storedUser := func(id models.UserID) (*models.User, error) {
+		return &models.User{ID: id, Name: "Herb", Password: "hash"}, nil
+	}
+
+	tests := []struct {
+		name        string
+		db          *data.MockUserDB
+		id          string
+		loggedInID  models.UserID
+		requestBody string
+		wantCode    int
+		wantResp    string
+	}{
+		{
+			name: "Valid patch returns StatusOK without password",
+			db: &data.MockUserDB{
+				UserByIDStub: storedUser,
+				UpdateUserStub: func(id models.UserID, u *models.User) error {
+					if u.Name != "Herbert" || u.Password != "" {
+						return fmt.Errorf("unexpected update: %+v", u)
+					}
+					return nil
+				},
+			},
+			id:          "1",
+			loggedInID:  1,
+			requestBody: `{"name": "Herbert"}`,
+			wantCode:    http.StatusOK,
+			wantResp: `{
+				"user-id": 1,
+				"name": "Herbert"
+			}`,
+		},
+		{
+			name:        "Patching password returns StatusBadRequest",
+			db:          &data.MockUserDB{},
+			id:          "1",
+			loggedInID:  1,
+			requestBody: `{"password": "hunter2"}`,
+			wantCode:    http.StatusBadRequest,
+			wantResp: `{
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "'password' field cannot be patched, use the password endpoint.",
+				"code": "validation-failed"
+			}`,
+		},
+		{
+			name: "Removing name returns StatusBadRequest",
+			db: &data.MockUserDB{
+				UserByIDStub: storedUser,
+			},
+			id:          "1",
+			loggedInID:  1,
+			requestBody: `{"name": null}`,
+			wantCode:    http.StatusBadRequest,
+			wantResp: `{
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "'Name' field must not be empty.",
+				"code": "validation-failed"
+			}`,
+		},
+		{
+			name:        "Patching another user returns StatusForbidden",
+			db:          &data.MockUserDB{},
+			id:          "2",
+			loggedInID:  1,
+			requestBody: `{"name": "Herbert"}`,
+			wantCode:    http.StatusForbidden,
+			wantResp: `{
+				"type": "urn:training-notebook:problem:forbidden",
+				"title": "Forbidden",
+				"status": 403,
+				"detail": "you may only change your own account",
+				"code": "forbidden"
+			}`,
+		},
+		{
+			name: "User not found returns StatusNotFound",
+			db: &data.MockUserDB{
+				UserByIDStub: func(id models.UserID) (*models.User, error) {
+					return nil, data.ErrNotFound
+				},
+			},
+			id:          "1",
+			loggedInID:  1,
+			requestBody: `{"name": "Herbert"}`,
+			wantCode:    http.StatusNotFound,
+			wantResp: `{
+				"type": "urn:training-notebook:problem:not-found",
+				"title": "Resource not found",
+				"status": 404,
+				"detail": "no such user with id 1",
+				"code": "not-found"
+			}`,
+		},
+		{
+			name: "Invalid db call returns InternalServerError",
+			db: &data.MockUserDB{
+				UserByIDStub: storedUser,
+				UpdateUserStub: func(id models.UserID, u *models.User) error {
+					return fmt.Errorf("Expected error")
+				},
+			},
+			id:          "1",
+			loggedInID:  1,
+			requestBody: `{"name": "Herbert"}`,
+			wantCode:    http.StatusInternalServerError,
+			wantResp: `{
+				"type": "urn:training-notebook:problem:internal-error",
+				"title": "Internal server error",
+				"status": 500,
+				"detail": "an internal error occurred, quote the request id when reporting it",
+				"code": "internal-error"
+			}`,
+		},
+	}
+
+	for _, v := range tests {
+		u, err := New(v.db, nil)
+		if err != nil {
+			t.Fail()
+		}
+
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPatch, "", bytes.NewBufferString(v.requestBody))
+		c.Request.Header.Set("Content-Type", mergepatch.ContentType)
+		c.AddParam(UserIDFromParamsKey, v.id)
+		c.Set(UserIDFromContextKey, v.loggedInID)
+
+		// execute patch with the test context
+		u.Patch(c)
+
+		// check response code
+		if v.wantCode != w.Code {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v\nBody: %v", v.name, v.wantCode, w.Code, w.Body.String())
+		}
+
+		// check response body
+		if equal, _ := JSONBytesEqual([]byte(v.wantResp), w.Body.Bytes()); !equal {
+			t.Fatalf("%s: Wanted body: %v\nGot body: %v\n", v.name, v.wantResp, w.Body.String())
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/problem"
 	"github.com/hrand1005/training-notebook/data"
 	"github.com/hrand1005/training-notebook/models"
 )
@@ -21,9 +22,9 @@ import (
 // ReadAll is the handler for read requests on the users resource where no id is
 // specified. Returns all users on this resource's data source.
 func (u *user) ReadAll(c *gin.Context) {
-	users, err := u.db.Users()
+	users, err := u.dbFor(c).Users()
 	if err != nil {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		problem.Internal(c, err)
 		return
 	}
 	if len(users) == 0 {
@@ -43,27 +44,28 @@ func (u *user) ReadAll(c *gin.Context) {
 //  400: errorResponse
 //  404: errorResponse
 //  500: errorResponse
+//  default: errorResponse
 
 // Read is the handler for read requests on the user resource where an id is
 // specified.
 func (s *user) Read(c *gin.Context) {
 	userID, err := UserIDFromParams(c)
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": ErrInvalidUserID})
+		problem.Abort(c, problem.New(problem.CodeInvalidParameter, ErrInvalidUserID))
 		return
 	}
 
-	r, err := s.db.UserByID(userID)
+	r, err := s.dbFor(c).UserByID(userID)
 	if err != nil {
 		if err == data.ErrNotFound {
 			msg := fmt.Sprintf("no such user with id %v", userID)
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": msg})
+			problem.Abort(c, problem.New(problem.CodeNotFound, msg))
 			return
 		}
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		problem.Internal(c, err)
 		return
 	}
 
 	// TODO: clean r of personal data if this is going to be a public api endpoint
-	c.IndentedJSON(http.StatusOK, r)
+	c.IndentedJSON(http.StatusOK, userBody(c, r))
 }
@@ -54,7 +54,11 @@ func TestReadSingleUser(t *testing.T) {
 			id:       "4",
 			wantCode: http.StatusNotFound,
 			wantResp: *bytes.NewBufferString(`{
-				"message": "no such user with id 4"
+				"type": "urn:training-notebook:problem:not-found",
+				"title": "Resource not found",
+				"status": 404,
+				"detail": "no such user with id 4",
+				"code": "not-found"
 			}`),
 		},
 		{
@@ -67,7 +71,11 @@ func TestReadSingleUser(t *testing.T) {
 			id:       "4",
 			wantCode: http.StatusInternalServerError,
 			wantResp: *bytes.NewBufferString(`{
-				"message": "Expected error"
+				"type": "urn:training-notebook:problem:internal-error",
+				"title": "Internal server error",
+				"status": 500,
+				"detail": "an internal error occurred, quote the request id when reporting it",
+				"code": "internal-error"
 			}`),
 		},
 		{
@@ -75,14 +83,18 @@ func TestReadSingleUser(t *testing.T) {
 			id:       "-1",
 			wantCode: http.StatusBadRequest,
 			wantResp: *bytes.NewBufferString(fmt.Sprintf(`{
-				"message": %q
+				"type": "urn:training-notebook:problem:invalid-parameter",
+				"title": "Invalid parameter",
+				"status": 400,
+				"detail": %q,
+				"code": "invalid-parameter"
 			}`, ErrInvalidUserID)),
 		},
 	}
 
 	for _, v := range tests {
 		// configure test case with data and test context
-		ts, err := New(v.db)
+		ts, err := New(v.db, nil)
 		if err != nil {
 			t.Fail()
 		}
@@ -147,7 +159,7 @@ func TestReadAllUsers(t *testing.T) {
 	}
 	for _, v := range tests {
 		// configure test case with data and test context
-		u, err := New(v.db)
+		u, err := New(v.db, nil)
 		if err != nil {
 			t.Fail()
 		}
@@ -0,0 +1,57 @@
+package users
+
+import (
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/version"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// The handlers operate on models.User, and convert users to and from the json
+// representation of the api version serving the request at the edges.
+
+// bindUser binds the request body to u.
+func bindUser(c *gin.Context, u *models.User) error {
+	if version.FromContext(c) == version.V1 {
+		return c.BindJSON(u)
+	}
+
+	var v2 models.UserV2
+	if err := c.BindJSON(&v2); err != nil {
+		return err
+	}
+	*u = *v2.User()
+	return nil
+}
+
+// decodeUser decodes a json document representing a user into u.
+func decodeUser(c *gin.Context, doc []byte, u *models.User) error {
+	if version.FromContext(c) == version.V1 {
+		return json.Unmarshal(doc, u)
+	}
+
+	var v2 models.UserV2
+	if err := json.Unmarshal(doc, &v2); err != nil {
+		return err
+	}
+	*u = *v2.User()
+	return nil
+}
+
+// userBody returns the representation of u. Version 2 never includes
+// sensitive data.
+func userBody(c *gin.Context, u *models.User) interface{} {
+	if version.FromContext(c) == version.V1 {
+		return u
+	}
+	return u.V2()
+}
+
+// idField returns the name of the field identifying a user.
+func idField(c *gin.Context) string {
+	if version.FromContext(c) == version.V1 {
+		return "user-id"
+	}
+	return "id"
+}
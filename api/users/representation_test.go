@@ -0,0 +1,107 @@
+package users
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/mergepatch"
+	"github.com/hrand1005/training-notebook/api/version"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// TestV2Representation checks that requests served by version 2 of the api
+// read and write users in the version 2 representation, without the password.
+func TestV2Representation(t *testing.T) {
+	db := &data.MockUserDB{
+		AddUserStub: func(u *models.User) (models.UserID, error) {
+			return 1, nil
+		},
+		UserByIDStub: func(id models.UserID) (*models.User, error) {
+			return &models.User{ID: id, Name: "Mark", Password: "hash"}, nil
+		},
+		UpdateUserStub: func(id models.UserID, u *models.User) error {
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name        string
+		handler     func(u *user) gin.HandlerFunc
+		method      string
+		contentType string
+		requestBody string
+		wantCode    int
+		wantResp    string
+	}{
+		{
+			name:        "Signup reads and returns a v2 user",
+			handler:     func(u *user) gin.HandlerFunc { return u.Signup },
+			method:      http.MethodPost,
+			requestBody: `{"name": "Mark", "password": "secret"}`,
+			wantCode:    http.StatusCreated,
+			wantResp:    `{"id": 1, "name": "Mark"}`,
+		},
+		{
+			name:     "Read returns a v2 user without the password",
+			handler:  func(u *user) gin.HandlerFunc { return u.Read },
+			method:   http.MethodGet,
+			wantCode: http.StatusOK,
+			wantResp: `{"id": 1, "name": "Mark"}`,
+		},
+		{
+			name:        "Patch rejects changes to the v2 id",
+			handler:     func(u *user) gin.HandlerFunc { return u.Patch },
+			method:      http.MethodPatch,
+			contentType: mergepatch.ContentType,
+			requestBody: `{"id": 2}`,
+			wantCode:    http.StatusBadRequest,
+			wantResp: `{
+				"type": "urn:training-notebook:problem:validation-failed",
+				"title": "Validation failed",
+				"status": 400,
+				"detail": "'id' field cannot be changed.",
+				"code": "validation-failed"
+			}`,
+		},
+		{
+			name:        "Patch applies to the v2 representation",
+			handler:     func(u *user) gin.HandlerFunc { return u.Patch },
+			method:      http.MethodPatch,
+			contentType: mergepatch.ContentType,
+			requestBody: `{"name": "Marcus"}`,
+			wantCode:    http.StatusOK,
+			wantResp:    `{"id": 1, "name": "Marcus"}`,
+		},
+	}
+
+	for _, v := range tests {
+		tu, err := New(db, nil)
+		if err != nil {
+			t.Fail()
+		}
+
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(v.method, "", bytes.NewBufferString(v.requestBody))
+		if v.contentType != "" {
+			c.Request.Header.Set("Content-Type", v.contentType)
+		}
+		c.AddParam(UserIDFromParamsKey, "1")
+		c.Set(UserIDFromContextKey, models.UserID(1))
+		c.Set(version.ContextKey, version.V2)
+
+		v.handler(tu)(c)
+
+		if v.wantCode != w.Code {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v\nBody: %v", v.name, v.wantCode, w.Code, w.Body.String())
+		}
+		if equal, _ := JSONBytesEqual([]byte(v.wantResp), w.Body.Bytes()); !equal {
+			t.Fatalf("%s: Wanted body: %v\nGot body: %v\n", v.name, v.wantResp, w.Body.String())
+		}
+	}
+}
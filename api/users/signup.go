@@ -5,44 +5,47 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/problem"
 	"github.com/hrand1005/training-notebook/models"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// swagger:route POST /users/signup users
+// swagger:route POST /signup users signup
 // Signup a new user.
 // responses:
 //  201: userResponse
 //  400: errorResponse
 //  500: errorResponse
+//  default: errorResponse
 
 // Signup is the handler for post requests on the user resource.
 // Creates a new user if fields are valid and returns the newly created user.
 func (u *user) Signup(c *gin.Context) {
 	var newUser models.User
 
-	if err := c.BindJSON(&newUser); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+	if err := bindUser(c, &newUser); err != nil {
+		problem.Abort(c, problem.FromBindingError(err))
 		return
 	}
 
-	if err := checkPasswordRequirements(newUser.Password); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+	if err := CheckPasswordRequirements(newUser.Password); err != nil {
+		problem.Abort(c, problem.New(problem.CodeValidationFailed, err.Error()))
 		return
 	}
 
 	// hash the password so that user information is protected
-	hashedPassword, err := hashPassword(newUser.Password)
+	hashedPassword, err := HashPassword(newUser.Password)
 	if err != nil {
 		// TODO: use configured logger for this server
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "invalid password"})
+		problem.Abort(c, problem.New(problem.CodeValidationFailed, "invalid password"))
+		return
 	}
 	newUser.Password = hashedPassword
 
 	// assigns ID to newUser
-	id, err := u.db.AddUser(&newUser)
+	id, err := u.dbFor(c).AddUser(&newUser)
 	if err != nil {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		problem.Internal(c, err)
 		return
 	}
 
@@ -53,19 +56,23 @@ func (u *user) Signup(c *gin.Context) {
 		Name: newUser.Name,
 	}
 
-	c.IndentedJSON(http.StatusCreated, resp)
+	u.notify(models.AuditCreate, resp)
+	c.IndentedJSON(http.StatusCreated, userBody(c, resp))
 }
 
 const minPasswordLength = 5
 
-func checkPasswordRequirements(password string) error {
+// CheckPasswordRequirements returns an error describing why password may not
+// be used, or nil if it may.
+func CheckPasswordRequirements(password string) error {
 	if len(password) < minPasswordLength {
 		return fmt.Errorf("password too short, must be at least %v characters", minPasswordLength)
 	}
 	return nil
 }
 
-func hashPassword(raw string) (string, error) {
+// HashPassword returns the hash of raw stored in place of the password.
+func HashPassword(raw string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(raw), bcrypt.DefaultCost)
 	return string(bytes), err
 }
@@ -53,13 +53,17 @@ func TestSignup(t *testing.T) {
 			},
 			wantCode: http.StatusInternalServerError,
 			wantResp: *bytes.NewBufferString(` {
-				"message": "Expected Error"
+				"type": "urn:training-notebook:problem:internal-error",
+				"title": "Internal server error",
+				"status": 500,
+				"detail": "an internal error occurred, quote the request id when reporting it",
+				"code": "internal-error"
 			} `),
 		},
 	}
 	for _, v := range tests {
 		// configure test case with data and test context
-		u, err := New(v.db)
+		u, err := New(v.db, nil)
 		if err != nil {
 			t.Fail()
 		}
@@ -5,45 +5,54 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/problem"
 	"github.com/hrand1005/training-notebook/data"
 	"github.com/hrand1005/training-notebook/models"
 )
 
-// <SWAGGER-IGNORE>:route PUT /users/{id} users updateUser
+// swagger:route PUT /users/{id} users updateUser
 // Update a user.
 // responses:
 //  200: userResponse
 //  400: errorResponse
+//  401: errorResponse
+//  403: errorResponse
 //  404: errorResponse
 //  500: errorResponse
+//  default: errorResponse
 
 // Update is the handler for update requests on the user resource. An id must be
-// specified.
+// specified, and must be the id of the logged in user. Passwords are not changed by updates, and must be changed with
+// ChangePassword.
 func (u *user) Update(c *gin.Context) {
-	var newUser models.User
+	userID, err := UserIDFromParams(c)
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeInvalidParameter, ErrInvalidUserID))
+		return
+	}
 
-	if err := c.BindJSON(&newUser); err != nil {
-		msg := models.BindingErrorToMessage(err)
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": msg})
+	if !requireSelf(c, userID) {
 		return
 	}
 
-	userID, err := UserIDFromParams(c)
-	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": ErrInvalidUserID})
+	var newUser models.User
+
+	if err := bindUser(c, &newUser); err != nil {
+		problem.Abort(c, problem.FromBindingError(err))
 		return
 	}
 
-	if err := u.db.UpdateUser(userID, &newUser); err != nil {
+	if err := u.dbFor(c).UpdateUser(userID, &newUser); err != nil {
 		if err == data.ErrNotFound {
 			msg := fmt.Sprintf("no such user with id %v", userID)
-			c.IndentedJSON(http.StatusNotFound, gin.H{"message": msg})
+			problem.Abort(c, problem.New(problem.CodeNotFound, msg))
 			return
 		}
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		problem.Internal(c, err)
 		return
 	}
 
 	newUser.ID = userID
-	c.IndentedJSON(http.StatusOK, newUser)
+	u.notify(models.AuditUpdate, &newUser)
+	c.IndentedJSON(http.StatusOK, userBody(c, newUser.Sanitized()))
 }
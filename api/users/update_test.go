@@ -20,6 +20,7 @@ func TestUpdateUser(t *testing.T) {
 		db   *data.MockUserDB
 		// id from URL, not part of body for updates
 		id          string
+		loggedInID  models.UserID
 		requestBody bytes.Buffer
 		wantCode    int
 		wantResp    bytes.Buffer
@@ -31,7 +32,8 @@ func TestUpdateUser(t *testing.T) {
 					return nil
 				},
 			},
-			id: "1",
+			id:         "1",
+			loggedInID: 1,
 			requestBody: *bytes.NewBufferString(` {
 				"name": "Keonwoo Oh"
 			} `),
@@ -48,13 +50,35 @@ func TestUpdateUser(t *testing.T) {
 					return data.ErrNotFound
 				},
 			},
-			id: "-1",
+			id:         "-1",
+			loggedInID: 1,
 			requestBody: *bytes.NewBufferString(` {
 				"name": "someone"
 			} `),
 			wantCode: http.StatusBadRequest,
 			wantResp: *bytes.NewBufferString(` {
-				"message": "Invalid user ID"
+				"type": "urn:training-notebook:problem:invalid-parameter",
+				"title": "Invalid parameter",
+				"status": 400,
+				"detail": "Invalid user ID",
+				"code": "invalid-parameter"
+			} `),
+		},
+		{
+			name:       "Updating another user returns StatusForbidden",
+			db:         &data.MockUserDB{},
+			id:         "2",
+			loggedInID: 1,
+			requestBody: *bytes.NewBufferString(` {
+				"name": "someone else"
+			} `),
+			wantCode: http.StatusForbidden,
+			wantResp: *bytes.NewBufferString(` {
+				"type": "urn:training-notebook:problem:forbidden",
+				"title": "Forbidden",
+				"status": 403,
+				"detail": "you may only change your own account",
+				"code": "forbidden"
 			} `),
 		},
 		{
@@ -64,13 +88,18 @@ func TestUpdateUser(t *testing.T) {
 					return data.ErrNotFound
 				},
 			},
-			id: "2",
+			id:         "2",
+			loggedInID: 2,
 			requestBody: *bytes.NewBufferString(` {
 				"name": "unknown"
 			} `),
 			wantCode: http.StatusNotFound,
 			wantResp: *bytes.NewBufferString(` {
-				"message": "no such user with id 2"
+				"type": "urn:training-notebook:problem:not-found",
+				"title": "Resource not found",
+				"status": 404,
+				"detail": "no such user with id 2",
+				"code": "not-found"
 			} `),
 		},
 		{
@@ -80,19 +109,24 @@ func TestUpdateUser(t *testing.T) {
 					return fmt.Errorf("Expected error")
 				},
 			},
-			id: "2",
+			id:         "2",
+			loggedInID: 2,
 			requestBody: *bytes.NewBufferString(` {
 				"name": "some user"
 			} `),
 			wantCode: http.StatusInternalServerError,
 			wantResp: *bytes.NewBufferString(` {
-				"message": "Expected error"
+				"type": "urn:training-notebook:problem:internal-error",
+				"title": "Internal server error",
+				"status": 500,
+				"detail": "an internal error occurred, quote the request id when reporting it",
+				"code": "internal-error"
 			} `),
 		},
 	}
 
 	for _, v := range tests {
-		ts, err := New(v.db)
+		ts, err := New(v.db, nil)
 		if err != nil {
 			t.Fail()
 		}
@@ -106,6 +140,7 @@ func TestUpdateUser(t *testing.T) {
 
 		// add id to URL params
 		c.AddParam(UserIDFromParamsKey, v.id)
+		c.Set(UserIDFromContextKey, v.loggedInID)
 		c.Request, _ = http.NewRequest("", "", bodyReader)
 
 		// execute update with the test context
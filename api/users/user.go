@@ -5,8 +5,10 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/requestid"
 	"github.com/hrand1005/training-notebook/data"
 	"github.com/hrand1005/training-notebook/models"
+	"github.com/hrand1005/training-notebook/tracing"
 )
 
 var ErrInvalidUserID = "Invalid user ID"
@@ -17,20 +19,44 @@ const (
 	UserIDFromParamsKey  = "paramUserID"
 )
 
-func New(db data.UserDB) (*user, error) {
-	return &user{db: db}, nil
+// New returns the handler for the user resource. Changes to users are recorded
+// in auditDB, which may be nil to disable auditing.
+func New(db data.UserDB, auditDB data.AuditDB) (*user, error) {
+	return &user{db: db, audit: auditDB}, nil
+}
+
+// OnChange registers f to be called with each user created, updated or
+// deleted through the handler, as it was after the change, or before it for
+// deletions.
+func (u *user) OnChange(f func(action models.AuditAction, changed *models.User)) {
+	u.onChange = f
 }
 
 func (u *user) RegisterHandlers(g *gin.RouterGroup) {
 	// Authorization NOT required
-	g.POST("/signup", u.Signup)
-	g.POST("/login", u.Login)
+	publicGroup := g.Group("")
+	publicGroup.Use(tracing.HandlerSpan())
+	publicGroup.POST("/signup", u.Signup)
+	publicGroup.POST("/login", u.Login)
 
 	// Require User Authentication for Read/Updates on a user
 	authGroup := g.Group("/users")
-	authGroup.Use(RequireAuthorization())
+	authGroup.Use(RequireAuthorization(), tracing.HandlerSpan())
 	authGroup.GET("/:"+UserIDFromParamsKey, u.Read)
 	authGroup.PUT("/:"+UserIDFromParamsKey, u.Update)
+	authGroup.PATCH("/:"+UserIDFromParamsKey, u.Patch)
+	authGroup.PUT("/:"+UserIDFromParamsKey+"/password", u.ChangePassword)
+}
+
+// dbFor returns the user db, recording changes in the audit log as made by
+// the actor of the request being handled by c, and a span for each call in
+// the trace of the request.
+func (u *user) dbFor(c *gin.Context) data.UserDB {
+	db := u.db
+	if u.audit != nil {
+		db = db.AuditedBy(ActorFromContext(c))
+	}
+	return data.TraceUserDB(tracing.Context(c), db)
 }
 
 func UserIDFromContext(c *gin.Context) (models.UserID, error) {
@@ -57,3 +83,23 @@ func UserIDFromParams(c *gin.Context) (models.UserID, error) {
 
 	return models.UserID(id), nil
 }
+
+// notify reports a change to a user to the function registered with
+// OnChange, if any.
+func (u *user) notify(action models.AuditAction, changed *models.User) {
+	if u.onChange == nil {
+		return
+	}
+	u.onChange(action, changed.Sanitized())
+}
+
+// ActorFromContext returns who makes the changes in the request handled by c,
+// for the audit log. Signups have no logged in user, so the actor is left
+// unset and the new user is recorded as having signed themselves up.
+func ActorFromContext(c *gin.Context) data.Actor {
+	actor := data.Actor{RequestID: requestid.FromContext(c)}
+	if userID, err := UserIDFromContext(c); err == nil {
+		actor.UserID = userID
+	}
+	return actor
+}
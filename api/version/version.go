@@ -0,0 +1,204 @@
+// Package version routes api requests to a version of the api, chosen by the
+// request path, e.g. /api/v2/sets/, or for unversioned paths such as
+// /api/sets/ by the Accept header. Old versions remain served until their
+// sunset, and announce their deprecation in response headers once it is
+// scheduled.
+package version
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/problem"
+)
+
+// Version is a major version of the api. Versions differ in the json shape of
+// their resources, while the operations on them remain the same.
+type Version int
+
+const (
+	// V1 identifies resources by fields named after them, e.g. set-id
+	V1 Version = 1
+	// V2 identifies every resource by an id field
+	V2 Version = 2
+)
+
+// Default is the version serving unversioned requests that do not ask for a
+// version, so that clients written before versioning keep working
+const Default = V1
+
+// Latest is the newest version of the api
+const Latest = V2
+
+// Supported lists the versions served, oldest first
+var Supported = []Version{V1, V2}
+
+// Response headers describing the version serving a request
+const (
+	Header            = "API-Version"
+	DeprecationHeader = "Deprecation"
+	SunsetHeader      = "Sunset"
+)
+
+// MediaType is the vendor media type asking for a version in the Accept
+// header, formatted with the version number, e.g.
+// application/vnd.training-notebook.v2+json
+const MediaType = "application/vnd.training-notebook.v%d+json"
+
+// ContextKey is used to retrieve the version from gin.Context
+const ContextKey = "apiVersion"
+
+// ErrUnsupported is the detail of responses to requests for unknown versions
+const ErrUnsupported = "api version %v is not supported, supported versions are %v"
+
+// lifecycle is when a version was deprecated, and when it stops being served
+type lifecycle struct {
+	deprecated time.Time
+	sunset     time.Time
+}
+
+// lifecycles of deprecated versions. Versions without one are current.
+var lifecycles = map[Version]lifecycle{}
+
+// Deprecate schedules the deprecation of v, after which it remains served
+// until sunset. It must be called before the server starts.
+func Deprecate(v Version, deprecated, sunset time.Time) {
+	lifecycles[v] = lifecycle{deprecated: deprecated, sunset: sunset}
+}
+
+// mediaType matches the vendor media type of a version
+var mediaType = regexp.MustCompile(`^application/vnd\.training-notebook\.v([0-9]+)\+json$`)
+
+// versionSegment matches the version segment following the first segment of
+// a route, e.g. /v2 in /api/v2/sets/
+var versionSegment = regexp.MustCompile(`^(/[^/]+)/v[0-9]+(/|$)`)
+
+// versioned matches paths relative to the api root that start with a version
+var versioned = regexp.MustCompile(`^/v[0-9]+(/|$)`)
+
+func (v Version) String() string {
+	return "v" + strconv.Itoa(int(v))
+}
+
+// Path is the prefix of the version's routes, relative to the api root.
+func (v Version) Path() string {
+	return "/" + v.String()
+}
+
+// Supported reports whether v is served.
+func (v Version) Supported() bool {
+	for _, s := range Supported {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Deprecated returns when v was deprecated and when it will stop being served,
+// and false if v is not deprecated.
+func (v Version) Deprecated() (deprecated, sunset time.Time, ok bool) {
+	l, ok := lifecycles[v]
+	return l.deprecated, l.sunset, ok
+}
+
+// FromAccept returns the version asked for by an Accept header, either with
+// the vendor media type or a version parameter, e.g. application/json;
+// version=2. Returns Default if no version is asked for, and an error if the
+// version asked for is not supported.
+func FromAccept(accept string) (Version, error) {
+	for _, part := range strings.Split(accept, ",") {
+		mediatype, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		requested := params["version"]
+		if m := mediaType.FindStringSubmatch(mediatype); m != nil {
+			requested = m[1]
+		}
+		if requested == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(strings.TrimPrefix(requested, "v"))
+		if v := Version(n); err == nil && v.Supported() {
+			return v, nil
+		}
+		return 0, fmt.Errorf(ErrUnsupported, requested, Supported)
+	}
+	return Default, nil
+}
+
+// Unversioned strips the version from a route, e.g. /api/v2/login becomes
+// /api/login, so that every version of a route can be treated alike.
+func Unversioned(route string) string {
+	return versionSegment.ReplaceAllString(route, "$1$2")
+}
+
+// Negotiate serves requests to unversioned paths under prefix, e.g.
+// /api/sets/ under /api, with the version asked for by the Accept header, by
+// handling them again on engine as requests to the versioned path. Requests
+// for unsupported versions are rejected with StatusNotAcceptable. Must be the
+// first middleware of engine, since every middleware before it runs twice for
+// negotiated requests.
+func Negotiate(engine *gin.Engine, prefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if !strings.HasPrefix(path, prefix+"/") || versioned.MatchString(strings.TrimPrefix(path, prefix)) {
+			c.Next()
+			return
+		}
+
+		v, err := FromAccept(c.GetHeader("Accept"))
+		if err != nil {
+			problem.Abort(c, problem.New(problem.CodeUnsupportedVersion, err.Error()))
+			return
+		}
+
+		// the representation of unversioned paths depends on the Accept header
+		c.Writer.Header().Add("Vary", "Accept")
+		c.Request.URL.Path = prefix + v.Path() + strings.TrimPrefix(path, prefix)
+		if c.Request.URL.RawPath != "" {
+			c.Request.URL.RawPath = prefix + v.Path() + strings.TrimPrefix(c.Request.URL.RawPath, prefix)
+		}
+		engine.HandleContext(c)
+		c.Abort()
+	}
+}
+
+// Middleware marks requests as served by v, reporting it in the API-Version
+// header. If v is deprecated, responses carry its Deprecation and Sunset
+// dates, and a link to the same path in the latest version.
+func Middleware(v Version) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(ContextKey, v)
+		c.Header(Header, strconv.Itoa(int(v)))
+
+		if deprecated, sunset, ok := v.Deprecated(); ok {
+			c.Header(DeprecationHeader, "@"+strconv.FormatInt(deprecated.Unix(), 10))
+			c.Header(SunsetHeader, sunset.Format(http.TimeFormat))
+			successor := strings.Replace(c.Request.URL.Path, v.Path()+"/", Latest.Path()+"/", 1)
+			c.Writer.Header().Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successor))
+		}
+
+		c.Next()
+	}
+}
+
+// FromContext returns the version serving the request, or Default if it was
+// not routed to a version.
+func FromContext(c *gin.Context) Version {
+	if v, ok := c.Get(ContextKey); ok {
+		if v, ok := v.(Version); ok {
+			return v
+		}
+	}
+	return Default
+}
@@ -0,0 +1,214 @@
+package version
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestFromAccept checks that versions are read from the vendor media type or
+// a version parameter, and that unsupported versions are rejected.
+func TestFromAccept(t *testing.T) {
+	tests := []struct {
+		name    string
+		accept  string
+		want    Version
+		wantErr bool
+	}{
+		{
+			name: "No Accept header returns the default version",
+			want: Default,
+		},
+		{
+			name:   "Plain json returns the default version",
+			accept: "application/json",
+			want:   Default,
+		},
+		{
+			name:   "Vendor media type returns its version",
+			accept: fmt.Sprintf(MediaType, 2),
+			want:   V2,
+		},
+		{
+			name:   "Version parameter returns its version",
+			accept: "text/html, application/json; version=2",
+			want:   V2,
+		},
+		{
+			name:    "Unsupported version is rejected",
+			accept:  fmt.Sprintf(MediaType, 9),
+			wantErr: true,
+		},
+		{
+			name:    "Malformed version is rejected",
+			accept:  "application/json; version=two",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := FromAccept(tc.accept)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("got version %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestUnversioned checks that only the version segment following the api root
+// is stripped.
+func TestUnversioned(t *testing.T) {
+	tests := map[string]string{
+		"/api/v2/login":            "/api/login",
+		"/api/v1/sets/:paramSetID": "/api/sets/:paramSetID",
+		"/api/v1":                  "/api",
+		"/api/login":               "/api/login",
+		"/api/sets/v1":             "/api/sets/v1",
+		"*":                        "*",
+	}
+
+	for route, want := range tests {
+		if got := Unversioned(route); got != want {
+			t.Errorf("Unversioned(%q) = %q, want %q", route, got, want)
+		}
+	}
+}
+
+// TestNegotiate checks that requests are served by the version in their path,
+// or for unversioned paths by the version asked for in the Accept header, and
+// that versions announce their sunset only once their deprecation is scheduled.
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		accept         string
+		deprecateV1    bool
+		wantCode       int
+		wantVersion    string
+		wantDeprecated bool
+		wantVary       bool
+	}{
+		{
+			name:        "Versioned path is served by its version",
+			path:        "/api/v2/things",
+			wantCode:    http.StatusOK,
+			wantVersion: "2",
+		},
+		{
+			name:        "Version without a scheduled deprecation is current",
+			path:        "/api/v1/things",
+			wantCode:    http.StatusOK,
+			wantVersion: "1",
+		},
+		{
+			name:           "Deprecated version announces its sunset",
+			path:           "/api/v1/things",
+			deprecateV1:    true,
+			wantCode:       http.StatusOK,
+			wantVersion:    "1",
+			wantDeprecated: true,
+		},
+		{
+			name:           "Path takes precedence over the Accept header",
+			path:           "/api/v1/things",
+			accept:         fmt.Sprintf(MediaType, 2),
+			deprecateV1:    true,
+			wantCode:       http.StatusOK,
+			wantVersion:    "1",
+			wantDeprecated: true,
+		},
+		{
+			name:        "Unversioned path is served by the default version",
+			path:        "/api/things",
+			wantCode:    http.StatusOK,
+			wantVersion: "1",
+			wantVary:    true,
+		},
+		{
+			name:           "Unversioned path announces the sunset of the default version",
+			path:           "/api/things",
+			deprecateV1:    true,
+			wantCode:       http.StatusOK,
+			wantVersion:    "1",
+			wantDeprecated: true,
+			wantVary:       true,
+		},
+		{
+			name:        "Unversioned path is served by the version asked for",
+			path:        "/api/things",
+			accept:      fmt.Sprintf(MediaType, 2),
+			wantCode:    http.StatusOK,
+			wantVersion: "2",
+			wantVary:    true,
+		},
+		{
+			name:     "Unsupported version is not acceptable",
+			path:     "/api/things",
+			accept:   fmt.Sprintf(MediaType, 9),
+			wantCode: http.StatusNotAcceptable,
+		},
+		{
+			name:     "Paths outside the api are not negotiated",
+			path:     "/things",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Negotiate(router, "/api"))
+	for _, v := range Supported {
+		g := router.Group("/api" + v.Path())
+		g.Use(Middleware(v))
+		g.GET("/things", func(c *gin.Context) {
+			c.String(http.StatusOK, FromContext(c).String())
+		})
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.deprecateV1 {
+				Deprecate(V1, time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(2027, time.July, 1, 0, 0, 0, 0, time.UTC))
+				defer delete(lifecycles, V1)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.wantCode {
+				t.Fatalf("got status %v, want %v: %s", w.Code, tc.wantCode, w.Body)
+			}
+			if got := w.Header().Get(Header); got != tc.wantVersion {
+				t.Errorf("got %v header %q, want %q", Header, got, tc.wantVersion)
+			}
+			if tc.wantVersion != "" && w.Body.String() != "v"+tc.wantVersion {
+				t.Errorf("got version %q from context, want v%v", w.Body, tc.wantVersion)
+			}
+
+			deprecated := w.Header().Get(DeprecationHeader) != "" && w.Header().Get(SunsetHeader) != ""
+			if deprecated != tc.wantDeprecated {
+				t.Errorf("got deprecation headers %v, want %v", deprecated, tc.wantDeprecated)
+			}
+			if tc.wantDeprecated {
+				if got, want := w.Header().Get("Link"), `</api/v2/things>; rel="successor-version"`; got != want {
+					t.Errorf("got Link %q, want %q", got, want)
+				}
+			}
+			if vary := w.Header().Get("Vary") == "Accept"; vary != tc.wantVary {
+				t.Errorf("got Vary: Accept %v, want %v", vary, tc.wantVary)
+			}
+		})
+	}
+}
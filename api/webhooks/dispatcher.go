@@ -0,0 +1,425 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/hrand1005/training-notebook/api/events"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// Defaults for delivering events
+const (
+	// DefaultMaxAttempts is how many times a delivery is attempted before it
+	// is marked failed
+	DefaultMaxAttempts = 10
+	// DefaultRetryBase is the delay before the first retry, which doubles
+	// with every further attempt
+	DefaultRetryBase = 30 * time.Second
+	// DefaultMaxRetryDelay caps the delay between attempts
+	DefaultMaxRetryDelay = 6 * time.Hour
+	// DefaultTimeout is how long receivers have to respond to an attempt
+	DefaultTimeout = 10 * time.Second
+)
+
+// Ping is the type of the events sent to test a webhook
+const Ping = "ping"
+
+const (
+	// pollInterval is how often the queue is checked for deliveries due
+	// for a retry
+	pollInterval = time.Second
+	// batchSize is how many due deliveries are loaded at once
+	batchSize = 32
+	// concurrency is how many deliveries are attempted at once
+	concurrency = 4
+	// userAgent identifies deliveries to receivers
+	userAgent = "training-notebook-webhooks"
+)
+
+// errAddressNotAllowed fails attempts to deliver to addresses that are not
+// public, so that webhooks cannot be used to reach the server's network.
+var errAddressNotAllowed = errors.New("webhooks may not be delivered to loopback, private or link-local addresses")
+
+// errWebhookDeleted is recorded on deliveries whose webhook was deleted
+var errWebhookDeleted = errors.New("the webhook was deleted")
+
+// allowPrivateNetworks disables the check for private addresses, see
+// AllowPrivateNetworks.
+var allowPrivateNetworks atomic.Bool
+
+// AllowPrivateNetworks sets whether webhooks may be delivered to loopback,
+// private and link-local addresses. It is disabled by default, and should
+// only be enabled in development.
+func AllowPrivateNetworks(allow bool) {
+	allowPrivateNetworks.Store(allow)
+}
+
+// payload is the body of the requests delivering events
+type payload struct {
+	Event   string        `json:"event"`
+	Time    time.Time     `json:"time"`
+	OwnerID models.UserID `json:"owner-id"`
+	// the changed set or user in the representation of the latest version,
+	// or the id of the webhook for pings
+	Data interface{} `json:"data"`
+}
+
+// dispatcher queues the events published to the hub for the webhooks
+// subscribed to them, and delivers the queue, retrying failed attempts with
+// exponential backoff. The queue is kept in the db, so deliveries survive
+// restarts.
+type dispatcher struct {
+	db     data.WebhookDB
+	hub    *events.Hub
+	client *http.Client
+	// wake signals that deliveries were queued
+	wake chan struct{}
+	// done is closed once the dispatcher has stopped
+	done chan struct{}
+
+	maxAttempts   int
+	retryBase     time.Duration
+	maxRetryDelay time.Duration
+	pollInterval  time.Duration
+}
+
+func newDispatcher(db data.WebhookDB, hub *events.Hub) *dispatcher {
+	dialer := &net.Dialer{
+		Timeout: DefaultTimeout,
+		// checked after resolving the host, so that public names cannot
+		// point to private addresses
+		Control: checkAddress,
+	}
+	return &dispatcher{
+		db:  db,
+		hub: hub,
+		client: &http.Client{
+			Transport: &http.Transport{
+				// proxies would be dialed instead of the receiver, bypassing
+				// the address check
+				Proxy:               nil,
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: DefaultTimeout,
+				MaxIdleConnsPerHost: concurrency,
+			},
+			Timeout: DefaultTimeout,
+			// receivers are expected to respond to the url they registered
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		wake:          make(chan struct{}, 1),
+		done:          make(chan struct{}),
+		maxAttempts:   DefaultMaxAttempts,
+		retryBase:     DefaultRetryBase,
+		maxRetryDelay: DefaultMaxRetryDelay,
+		pollInterval:  pollInterval,
+	}
+}
+
+// start subscribes to the hub, so that no event published afterwards is
+// missed, and queues and delivers events until the hub is closed. Attempts in
+// flight are then abandoned, and retried once the server restarts.
+func (d *dispatcher) start() {
+	sub, _, _ := d.hub.Subscribe(0, all)
+	ctx, cancel := context.WithCancel(context.Background())
+	worked := make(chan struct{})
+	go func() {
+		d.work(ctx)
+		close(worked)
+	}()
+	go func() {
+		d.follow(sub)
+		cancel()
+		<-worked
+		close(d.done)
+	}()
+}
+
+// follow queues deliveries for the events received by sub, until the hub is
+// closed. Subscriptions dropped for falling behind are resumed from the log.
+func (d *dispatcher) follow(sub *events.Subscription) {
+	lastID := sub.Start
+	for {
+		for e := range sub.Events {
+			lastID = e.ID
+			d.enqueue(e)
+		}
+		if !sub.Dropped {
+			return
+		}
+
+		var missed []events.Event
+		var ok bool
+		sub, missed, ok = d.hub.Subscribe(lastID, all)
+		if !ok {
+			slog.Warn("webhooks missed events that are no longer logged", "last_event_id", lastID)
+		}
+		for _, e := range missed {
+			lastID = e.ID
+			d.enqueue(e)
+		}
+	}
+}
+
+// all subscribes to every event, since webhooks may subscribe to any
+func all(events.Event) bool { return true }
+
+// enqueue queues a delivery of e for every webhook subscribed to it.
+func (d *dispatcher) enqueue(e events.Event) {
+	hooks, err := d.db.WebhooksFor(e.Type, e.OwnerID)
+	if err != nil {
+		slog.Error("failed to find webhooks for event", "event", e.Type, "event_id", e.ID, "error", err)
+		return
+	}
+	if len(hooks) == 0 {
+		return
+	}
+
+	p := payload{Event: e.Type, Time: e.Time, OwnerID: e.OwnerID}
+	if e.IsSet() {
+		p.Data = e.Set.V2()
+	} else {
+		p.Data = e.User.V2()
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		slog.Error("failed to encode webhook payload", "event", e.Type, "event_id", e.ID, "error", err)
+		return
+	}
+
+	for _, hook := range hooks {
+		if _, err := d.queue(hook.ID, e.Type, body); err != nil {
+			slog.Error("failed to queue webhook delivery", "webhook_id", hook.ID, "event_id", e.ID, "error", err)
+		}
+	}
+	d.notify()
+}
+
+// ping queues a test delivery to hook, and wakes the worker to attempt it.
+func (d *dispatcher) ping(hook *models.Webhook) (*models.WebhookDelivery, error) {
+	body, err := json.Marshal(payload{Event: Ping, Time: time.Now().UTC(), OwnerID: hook.OwnerID, Data: hook.ID})
+	if err != nil {
+		return nil, err
+	}
+	delivery, err := d.queue(hook.ID, Ping, body)
+	if err != nil {
+		return nil, err
+	}
+	d.notify()
+	return delivery, nil
+}
+
+// queue adds a pending delivery of body to the webhook, due immediately.
+func (d *dispatcher) queue(webhookID models.WebhookID, eventType string, body []byte) (*models.WebhookDelivery, error) {
+	now := time.Now().UTC()
+	delivery := &models.WebhookDelivery{
+		WebhookID:     webhookID,
+		Event:         eventType,
+		Payload:       body,
+		Status:        models.DeliveryPending,
+		CreatedAt:     now,
+		NextAttemptAt: &now,
+	}
+	id, err := d.db.AddDelivery(delivery)
+	if err != nil {
+		return nil, err
+	}
+	delivery.ID = id
+	return delivery, nil
+}
+
+// notify wakes the worker, unless it has already been woken.
+func (d *dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// work attempts due deliveries whenever deliveries are queued, and polls for
+// retries that have come due, until ctx is cancelled.
+func (d *dispatcher) work(ctx context.Context) {
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.deliverDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverDue attempts every delivery that is due, a batch at a time.
+// Deliveries are attempted concurrently, so receivers may get them out of
+// order.
+func (d *dispatcher) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		due, err := d.db.DueDeliveries(time.Now().UTC(), batchSize)
+		if err != nil {
+			slog.Error("failed to load due webhook deliveries", "error", err)
+			return
+		}
+
+		hooks := make(map[models.WebhookID]*models.Webhook)
+		sem := make(chan struct{}, concurrency)
+		var wg sync.WaitGroup
+		// set if deliveries were skipped, which are still due
+		skipped := false
+		for _, delivery := range due {
+			hook, ok := hooks[delivery.WebhookID]
+			if !ok {
+				hook, err = d.db.WebhookByID(delivery.WebhookID)
+				if err == data.ErrNotFound {
+					// the webhook was deleted after the batch was loaded
+					d.drop(delivery)
+					continue
+				}
+				if err != nil {
+					slog.Error("failed to load webhook", "webhook_id", delivery.WebhookID, "delivery_id", delivery.ID, "error", err)
+					skipped = true
+					continue
+				}
+				hooks[hook.ID] = hook
+			}
+
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(delivery *models.WebhookDelivery) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				d.attempt(ctx, hook, delivery)
+			}(delivery)
+		}
+		wg.Wait()
+
+		// skipped deliveries are left for the next poll, rather than loaded
+		// again at once
+		if len(due) < batchSize || skipped {
+			return
+		}
+	}
+}
+
+// drop fails delivery without attempting it, since its webhook no longer
+// exists.
+func (d *dispatcher) drop(delivery *models.WebhookDelivery) {
+	delivery.Status = models.DeliveryFailed
+	delivery.Error = errWebhookDeleted.Error()
+	delivery.NextAttemptAt = nil
+	if err := d.db.UpdateDelivery(delivery); err != nil && err != data.ErrNotFound {
+		slog.Error("failed to drop webhook delivery", "webhook_id", delivery.WebhookID, "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// attempt posts delivery to hook and records the outcome. Failed attempts are
+// retried after a delay that doubles with every attempt, until the delivery
+// runs out of attempts.
+func (d *dispatcher) attempt(ctx context.Context, hook *models.Webhook, delivery *models.WebhookDelivery) {
+	code, err := d.send(ctx, hook, delivery)
+	if ctx.Err() != nil {
+		// abandoned on shutdown, so not counted as an attempt
+		return
+	}
+
+	now := time.Now().UTC()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseCode = code
+	delivery.Error = ""
+	delivery.NextAttemptAt = nil
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliveryDelivered
+	case delivery.Attempts >= d.maxAttempts:
+		delivery.Status = models.DeliveryFailed
+		delivery.Error = err.Error()
+	default:
+		next := now.Add(d.backoff(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+		delivery.Error = err.Error()
+	}
+
+	if err := d.db.UpdateDelivery(delivery); err != nil && err != data.ErrNotFound {
+		slog.Error("failed to record webhook delivery attempt", "webhook_id", hook.ID, "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// backoff returns the delay before the attempt following the given number of
+// failed attempts.
+func (d *dispatcher) backoff(attempts int) time.Duration {
+	delay := d.retryBase
+	for i := 1; i < attempts && delay < d.maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > d.maxRetryDelay {
+		return d.maxRetryDelay
+	}
+	return delay
+}
+
+// send posts the payload of delivery to hook, signed with its secret.
+// Returns the status of the response, or zero if the receiver did not
+// respond, and an error unless the status is 2xx.
+func (d *dispatcher) send(ctx context.Context, hook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, time.Now(), delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// drained so that the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with status %v", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// checkAddress rejects connections to addresses that are not public, unless
+// private networks are allowed.
+func checkAddress(network, address string, _ syscall.RawConn) error {
+	if allowPrivateNetworks.Load() {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return errAddressNotAllowed
+	}
+	return nil
+}
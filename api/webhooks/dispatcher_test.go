@@ -0,0 +1,323 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hrand1005/training-notebook/api/events"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// received is a request delivered to the test receiver
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver records the deliveries posted to it, and responds with the next
+// of its codes, or 200 once they run out.
+type receiver struct {
+	mu       sync.Mutex
+	codes    []int
+	requests []received
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, received{header: req.Header, body: body})
+	code := http.StatusOK
+	if len(r.codes) > 0 {
+		code, r.codes = r.codes[0], r.codes[1:]
+	}
+	w.WriteHeader(code)
+}
+
+func (r *receiver) received() []received {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]received(nil), r.requests...)
+}
+
+// TestDeliver tests that events are delivered, signed, to the webhooks
+// subscribed to them, and only to those.
+func TestDeliver(t *testing.T) {
+	rcv := &receiver{}
+	_, db, hub := testDispatcher(t)
+	hook := addWebhook(t, db, rcv, 1, false, "set.created", "user.updated")
+	addWebhook(t, db, rcv, 2, false, "set.created")
+
+	hub.Publish(events.Event{Type: events.SetUpdated, OwnerID: 1, Set: models.Set{ID: 3, UID: 1}})
+	hub.Publish(events.Event{Type: events.SetCreated, OwnerID: 1, Set: models.Set{ID: 4, UID: 1, Movement: "Squat", Volume: 5, Intensity: 80}})
+	hub.Publish(events.UserEvent(models.AuditUpdate, &models.User{ID: 1, Name: "Herbie", Password: "hash"}))
+	hub.Publish(events.Event{Type: events.SetCreated, OwnerID: 3, Set: models.Set{ID: 5, UID: 3}})
+
+	eventually(t, "two deliveries", func() bool { return len(rcv.received()) >= 2 })
+	waitDelivered(t, db, hook.ID, 2)
+	got := rcv.received()
+	if len(got) != 2 {
+		t.Fatalf("Wanted 2 deliveries\nGot deliveries: %v", len(got))
+	}
+
+	byEvent := map[string]payload{}
+	for _, r := range got {
+		if err := Verify(hook.Secret, r.header.Get(SignatureHeader), r.body, DefaultTolerance); err != nil {
+			t.Fatalf("Wanted a valid signature\nGot error: %v", err)
+		}
+		if r.header.Get(DeliveryHeader) == "" || r.header.Get("Content-Type") != "application/json" {
+			t.Fatalf("Wanted delivery and content type headers\nGot headers: %v", r.header)
+		}
+		var p payload
+		if err := json.Unmarshal(r.body, &p); err != nil {
+			t.Fatalf("failed to decode payload: %v", err)
+		}
+		if p.Event != r.header.Get(EventHeader) || p.OwnerID != 1 {
+			t.Fatalf("Wanted an event of user 1 matching its header\nGot payload: %+v, header: %v", p, r.header.Get(EventHeader))
+		}
+		byEvent[p.Event] = p
+	}
+
+	var set models.SetV2
+	raw, _ := json.Marshal(byEvent[events.SetCreated].Data)
+	json.Unmarshal(raw, &set)
+	want := models.SetV2{ID: 4, OwnerID: 1, Movement: "Squat", Volume: 5, Intensity: 80}
+	if set != want {
+		t.Fatalf("Wanted the created set as the data: %+v\nGot data: %s", want, raw)
+	}
+	user, _ := json.Marshal(byEvent[events.UserUpdated].Data)
+	if strings.Contains(string(user), "hash") {
+		t.Fatalf("Wanted the user without their password\nGot data: %s", user)
+	}
+}
+
+// TestRetry tests that failed attempts are retried with backoff, until the
+// delivery succeeds or runs out of attempts.
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		codes        []int
+		wantStatus   models.DeliveryStatus
+		wantAttempts int
+		wantCode     int
+	}{
+		{
+			name:         "Delivery succeeds after failed attempts",
+			codes:        []int{http.StatusInternalServerError, http.StatusServiceUnavailable},
+			wantStatus:   models.DeliveryDelivered,
+			wantAttempts: 3,
+			wantCode:     http.StatusOK,
+		},
+		{
+			name:         "Delivery fails after running out of attempts",
+			codes:        []int{500, 500, 500, 500},
+			wantStatus:   models.DeliveryFailed,
+			wantAttempts: 3,
+			wantCode:     http.StatusInternalServerError,
+		},
+		{
+			name:         "Redirects are not followed",
+			codes:        []int{http.StatusFound, http.StatusFound, http.StatusFound},
+			wantStatus:   models.DeliveryFailed,
+			wantAttempts: 3,
+			wantCode:     http.StatusFound,
+		},
+	}
+
+	for _, v := range tests {
+		rcv := &receiver{codes: v.codes}
+		d, db, _ := testDispatcher(t)
+		hook := addWebhook(t, db, rcv, 1, false, "set.created")
+
+		if _, err := d.ping(hook); err != nil {
+			t.Fatalf("%s: failed to queue ping: %v", v.name, err)
+		}
+		delivery := waitDone(t, db, hook.ID)
+		if delivery.Status != v.wantStatus || delivery.Attempts != v.wantAttempts || delivery.ResponseCode != v.wantCode {
+			t.Fatalf("%s: Wanted status %v after %v attempts with code %v\nGot delivery: %+v", v.name, v.wantStatus, v.wantAttempts, v.wantCode, delivery)
+		}
+		if got := len(rcv.received()); got != v.wantAttempts {
+			t.Fatalf("%s: Wanted %v requests\nGot requests: %v", v.name, v.wantAttempts, got)
+		}
+		if delivery.NextAttemptAt != nil || delivery.LastAttemptAt == nil {
+			t.Fatalf("%s: Wanted the time of the last attempt only\nGot delivery: %+v", v.name, delivery)
+		}
+	}
+}
+
+// TestBackoff tests that the delay between attempts doubles up to the
+// maximum.
+func TestBackoff(t *testing.T) {
+	d := &dispatcher{retryBase: 30 * time.Second, maxRetryDelay: 5 * time.Minute}
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{4, 4 * time.Minute},
+		{5, 5 * time.Minute},
+		{100, 5 * time.Minute},
+	}
+
+	for _, v := range tests {
+		if got := d.backoff(v.attempts); got != v.want {
+			t.Fatalf("After %v attempts: Wanted delay: %v\nGot delay: %v", v.attempts, v.want, got)
+		}
+	}
+}
+
+// TestPrivateNetworks tests that deliveries to loopback addresses fail unless
+// private networks are allowed.
+func TestPrivateNetworks(t *testing.T) {
+	rcv := &receiver{}
+	d, db, _ := testDispatcher(t)
+	d.maxAttempts = 1
+	hook := addWebhook(t, db, rcv, 1, false, "set.created")
+
+	AllowPrivateNetworks(false)
+	defer AllowPrivateNetworks(true)
+	if _, err := d.ping(hook); err != nil {
+		t.Fatalf("failed to queue ping: %v", err)
+	}
+
+	delivery := waitDone(t, db, hook.ID)
+	if delivery.Status != models.DeliveryFailed || !strings.Contains(delivery.Error, errAddressNotAllowed.Error()) {
+		t.Fatalf("Wanted a failed delivery to a private address\nGot delivery: %+v", delivery)
+	}
+	if len(rcv.received()) != 0 {
+		t.Fatalf("Wanted no requests\nGot requests: %v", len(rcv.received()))
+	}
+}
+
+// TestDeletedWebhook tests that deliveries to deleted webhooks are dropped,
+// without holding up the other deliveries in their batch.
+func TestDeletedWebhook(t *testing.T) {
+	rcv := &receiver{}
+	d, db, _ := testDispatcher(t)
+	hook := addWebhook(t, db, rcv, 1, false, "set.created")
+
+	const deletedID = 99
+	if _, err := d.queue(deletedID, Ping, []byte(`{}`)); err != nil {
+		t.Fatalf("failed to queue delivery: %v", err)
+	}
+	if _, err := d.ping(hook); err != nil {
+		t.Fatalf("failed to queue ping: %v", err)
+	}
+
+	if delivery := waitDone(t, db, hook.ID); delivery.Status != models.DeliveryDelivered {
+		t.Fatalf("Wanted a delivered ping\nGot delivery: %+v", delivery)
+	}
+	dropped := waitDone(t, db, deletedID)
+	if dropped.Status != models.DeliveryFailed || dropped.Attempts != 0 || dropped.Error != errWebhookDeleted.Error() {
+		t.Fatalf("Wanted a dropped delivery\nGot delivery: %+v", dropped)
+	}
+}
+
+// testDispatcher returns a running dispatcher over a fresh database, which
+// retries quickly and delivers to private networks. It is stopped when the
+// test ends.
+func testDispatcher(t *testing.T) (*dispatcher, data.WebhookDB, *events.Hub) {
+	t.Helper()
+
+	handle, err := data.SqliteDB(filepath.Join(t.TempDir(), "webhooks.sqlite"))
+	if err != nil {
+		t.Fatalf("failed to open test db: %v", err)
+	}
+	db, err := data.NewWebhookDB(handle)
+	if err != nil {
+		t.Fatalf("failed to create webhook db: %v", err)
+	}
+
+	AllowPrivateNetworks(true)
+	hub := events.NewHub(0, 0)
+	d := newDispatcher(db, hub)
+	d.maxAttempts = 3
+	d.retryBase = time.Millisecond
+	d.maxRetryDelay = 5 * time.Millisecond
+	d.pollInterval = 5 * time.Millisecond
+	d.start()
+	t.Cleanup(func() {
+		hub.Close()
+		<-d.done
+		handle.Close()
+	})
+	return d, db, hub
+}
+
+// addWebhook adds a webhook of ownerID delivering to rcv.
+func addWebhook(t *testing.T, db data.WebhookDB, rcv *receiver, ownerID models.UserID, allUsers bool, eventTypes ...string) *models.Webhook {
+	t.Helper()
+
+	server := httptest.NewServer(rcv)
+	t.Cleanup(server.Close)
+	hook := &models.Webhook{
+		OwnerID:   ownerID,
+		URL:       server.URL + "/hook",
+		Events:    eventTypes,
+		AllUsers:  allUsers,
+		Secret:    "secret",
+		CreatedAt: time.Now().UTC(),
+	}
+	id, err := db.AddWebhook(hook)
+	if err != nil {
+		t.Fatalf("failed to add webhook: %v", err)
+	}
+	hook.ID = id
+	return hook
+}
+
+// waitDone waits until the only delivery to the webhook is no longer
+// pending, and returns it.
+func waitDone(t *testing.T, db data.WebhookDB, id models.WebhookID) *models.WebhookDelivery {
+	t.Helper()
+
+	var delivery *models.WebhookDelivery
+	eventually(t, "delivery to be done", func() bool {
+		deliveries, err := db.Deliveries(id, 10)
+		if err != nil || len(deliveries) != 1 {
+			return false
+		}
+		delivery = deliveries[0]
+		return delivery.Status != models.DeliveryPending
+	})
+	return delivery
+}
+
+// waitDelivered waits until n deliveries to the webhook are delivered.
+func waitDelivered(t *testing.T, db data.WebhookDB, id models.WebhookID, n int) {
+	t.Helper()
+
+	eventually(t, "deliveries to be delivered", func() bool {
+		deliveries, err := db.Deliveries(id, 10)
+		if err != nil || len(deliveries) != n {
+			return false
+		}
+		for _, d := range deliveries {
+			if d.Status != models.DeliveryDelivered {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %v", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
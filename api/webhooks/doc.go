@@ -0,0 +1,89 @@
+// Package classification of Webhooks API
+//
+// Documentation for Webhooks API, delivering changes to sets and users as
+// signed POST requests to the subscribers' urls
+//
+//  Schemes: http
+//  BasePath: /api/v1
+//  Version: 1.0.0
+//
+//  Consumes:
+//  - application/json
+//
+//  Produces:
+//  - application/json
+//  - application/problem+json
+// swagger:meta
+package webhooks
+
+import (
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+type webhooks struct {
+	db         data.WebhookDB
+	users      data.UserDB
+	dispatcher *dispatcher
+}
+
+// swagger:parameters readWebhook
+// swagger:parameters deleteWebhook
+// swagger:parameters readWebhookDeliveries
+// swagger:parameters testWebhook
+type webhookIDParameter struct {
+	// The id of the webhook
+	// in: path
+	// required: true
+	ID int `json:"id"`
+}
+
+// swagger:parameters createWebhook
+type webhookParameter struct {
+	// The url and events of the webhook
+	// in: body
+	// required: true
+	Body WebhookRequest
+}
+
+// returns a webhook in the response
+// swagger:response webhookResponse
+type webhookResponse struct {
+	// in: body
+	Body models.Webhook
+}
+
+// returns webhooks in the response
+// swagger:response webhooksResponse
+type webhooksResponse struct {
+	// in: body
+	Body []models.Webhook
+}
+
+// returns deliveries to a webhook in the response
+// swagger:response deliveriesResponse
+type deliveriesResponse struct {
+	// A list of deliveries, newest first
+	// in: body
+	Body []models.WebhookDelivery
+}
+
+// returns a delivery queued for a webhook in the response
+// swagger:response deliveryResponse
+type deliveryResponse struct {
+	// in: body
+	Body models.WebhookDelivery
+}
+
+// returns an RFC 7807 problem describing the error
+// swagger:response errorResponse
+type errorResponse struct {
+	// Description of the error, with a machine-readable code
+	// in: body
+	Body problem.Problem
+}
+
+// the request succeeded without a response body
+// swagger:response noContent
+type noContent struct{}
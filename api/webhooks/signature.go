@@ -0,0 +1,81 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of the requests delivering events to webhooks
+const (
+	// EventHeader is the type of the delivered event
+	EventHeader = "X-Notebook-Event"
+	// DeliveryHeader is the id of the delivery, which is the same for every
+	// attempt, so that receivers can ignore deliveries they have seen
+	DeliveryHeader = "X-Notebook-Delivery"
+	// SignatureHeader signs the body of the request, see Sign
+	SignatureHeader = "X-Notebook-Signature"
+)
+
+// DefaultTolerance is how old a signature Verify accepts by default
+const DefaultTolerance = 5 * time.Minute
+
+// Errors returned by Verify
+var (
+	ErrMalformedSignature = errors.New("malformed signature header")
+	ErrSignatureMismatch  = errors.New("signature does not match the body")
+	ErrSignatureExpired   = errors.New("signature is too old")
+)
+
+// Sign returns the signature header of a delivery of body at t, of the form
+// t=<unix seconds>,v1=<hex HMAC-SHA256 of "<unix seconds>.<body>">, keyed
+// with the webhook's secret. Signing the time lets receivers reject replayed
+// deliveries.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, signature(secret, ts, body))
+}
+
+// Verify checks that header is a signature of body made with secret no more
+// than tolerance ago, for receivers written in Go.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			return ErrMalformedSignature
+		}
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return ErrMalformedSignature
+	}
+
+	if !hmac.Equal([]byte(sig), []byte(signature(secret, ts, body))) {
+		return ErrSignatureMismatch
+	}
+	if time.Since(time.Unix(unix, 0)) > tolerance {
+		return ErrSignatureExpired
+	}
+	return nil
+}
+
+// signature returns the hex HMAC-SHA256 of the signed payload.
+func signature(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
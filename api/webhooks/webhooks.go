@@ -0,0 +1,373 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/events"
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+	"github.com/hrand1005/training-notebook/tracing"
+)
+
+// Keys used to retrieve values from gin.Context
+const (
+	WebhookIDFromParamsKey = "paramWebhookID"
+)
+
+// Query limits for reading deliveries
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// ErrInvalidWebhookID is the detail of the problem returned for malformed
+// webhook ids.
+var ErrInvalidWebhookID = "webhook id must be a non-negative integer"
+
+// WebhookRequest is the body of a request to create a webhook
+// swagger:model
+type WebhookRequest struct {
+	// the http or https url that events are posted to
+	URL string `json:"url" binding:"required,url,max=2048"`
+	// the types of the events delivered
+	Events []string `json:"events" binding:"required,min=1,dive,oneof=set.created set.updated set.deleted user.created user.updated user.deleted"`
+	// deliver the changes of every user, rather than only the logged in
+	// user's. Only administrators may create such webhooks.
+	AllUsers bool `json:"all-users"`
+}
+
+// deliveriesQuery defines the query parameters accepted when reading the
+// deliveries to a webhook
+// swagger:parameters readWebhookDeliveries
+type deliveriesQuery struct {
+	// in: query
+	Limit int `form:"limit" binding:"gte=0,lte=1000"`
+}
+
+// New returns the handler for webhooks. Deliveries are queued in db for the
+// events published to hub, and delivered until the hub is closed. If hub is
+// nil, webhooks are only managed and nothing is delivered. userDB is used to
+// check that webhooks receiving every user's changes are created by
+// administrators.
+func New(db data.WebhookDB, userDB data.UserDB, hub *events.Hub) (*webhooks, error) {
+	w := &webhooks{db: db, users: userDB, dispatcher: newDispatcher(db, hub)}
+	if hub != nil {
+		w.dispatcher.start()
+	} else {
+		close(w.dispatcher.done)
+	}
+	return w, nil
+}
+
+// Stopped returns a channel that is closed once deliveries have stopped after
+// the hub is closed. Attempts in flight are abandoned, and retried once the
+// server restarts.
+func (w *webhooks) Stopped() <-chan struct{} {
+	return w.dispatcher.done
+}
+
+func (w *webhooks) RegisterHandlers(g *gin.RouterGroup) {
+	webhookGroup := g.Group("/webhooks")
+	webhookGroup.Use(users.RequireAuthorization(), tracing.HandlerSpan())
+	webhookGroup.GET("/", w.ReadAll)
+	webhookGroup.POST("/", w.Create)
+	webhookGroup.GET("/:"+WebhookIDFromParamsKey, w.Read)
+	webhookGroup.DELETE("/:"+WebhookIDFromParamsKey, w.Delete)
+	webhookGroup.GET("/:"+WebhookIDFromParamsKey+"/deliveries", w.Deliveries)
+	webhookGroup.POST("/:"+WebhookIDFromParamsKey+"/test", w.Test)
+}
+
+// swagger:route POST /webhooks/ webhooks createWebhook
+// Create a webhook delivering the given events. The response carries the
+// secret signing deliveries, which is never returned again.
+// responses:
+//  201: webhookResponse
+//  400: errorResponse
+//  401: errorResponse
+//  403: errorResponse
+//  500: errorResponse
+//  default: errorResponse
+
+// Create is the handler creating a webhook owned by the logged in user.
+func (w *webhooks) Create(c *gin.Context) {
+	userID, err := users.UserIDFromContext(c)
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeUnauthenticated, "you must be logged in to perform this action"))
+		return
+	}
+
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Abort(c, problem.FromBindingError(err))
+		return
+	}
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		p := problem.New(problem.CodeValidationFailed, "webhooks must have an http or https url")
+		p.Errors = []models.FieldError{{
+			Field:   "URL",
+			Tag:     "scheme",
+			Message: fmt.Sprintf("%q is not an http or https url", req.URL),
+		}}
+		problem.Abort(c, p)
+		return
+	}
+
+	if req.AllUsers {
+		user, err := data.TraceUserDB(tracing.Context(c), w.users).UserByID(userID)
+		if err != nil {
+			if err == data.ErrNotFound {
+				problem.Abort(c, problem.New(problem.CodeUnauthenticated, "you must be logged in to perform this action"))
+				return
+			}
+			problem.Internal(c, err)
+			return
+		}
+		if !user.Admin {
+			problem.Abort(c, problem.New(problem.CodeForbidden, "only administrators may receive the changes of every user"))
+			return
+		}
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		problem.Internal(c, err)
+		return
+	}
+
+	// events subscribed to twice are delivered once
+	eventTypes := []string{}
+	for _, e := range req.Events {
+		if !contains(eventTypes, e) {
+			eventTypes = append(eventTypes, e)
+		}
+	}
+
+	hook := &models.Webhook{
+		OwnerID:   userID,
+		URL:       req.URL,
+		Events:    eventTypes,
+		AllUsers:  req.AllUsers,
+		Secret:    secret,
+		CreatedAt: time.Now().UTC(),
+	}
+	id, err := w.db.AddWebhook(hook)
+	if err != nil {
+		problem.Internal(c, err)
+		return
+	}
+	hook.ID = id
+
+	c.IndentedJSON(http.StatusCreated, hook)
+}
+
+// swagger:route GET /webhooks/ webhooks readWebhooks
+// Read the webhooks of the logged in user.
+// responses:
+//  200: webhooksResponse
+//  401: errorResponse
+//  500: errorResponse
+//  default: errorResponse
+
+// ReadAll is the handler listing the webhooks of the logged in user, without
+// their secrets.
+func (w *webhooks) ReadAll(c *gin.Context) {
+	userID, err := users.UserIDFromContext(c)
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeUnauthenticated, "you must be logged in to perform this action"))
+		return
+	}
+
+	hooks, err := w.db.WebhooksByOwner(userID)
+	if err != nil {
+		problem.Internal(c, err)
+		return
+	}
+
+	// if no webhooks are found, an empty slice is returned
+	body := make([]*models.Webhook, 0, len(hooks))
+	for _, hook := range hooks {
+		body = append(body, withoutSecret(hook))
+	}
+	c.IndentedJSON(http.StatusOK, body)
+}
+
+// swagger:route GET /webhooks/{id} webhooks readWebhook
+// Read a webhook.
+// responses:
+//  200: webhookResponse
+//  400: errorResponse
+//  401: errorResponse
+//  404: errorResponse
+//  500: errorResponse
+//  default: errorResponse
+
+// Read is the handler reading a webhook of the logged in user, without its
+// secret.
+func (w *webhooks) Read(c *gin.Context) {
+	hook, ok := w.owned(c)
+	if !ok {
+		return
+	}
+	c.IndentedJSON(http.StatusOK, withoutSecret(hook))
+}
+
+// swagger:route DELETE /webhooks/{id} webhooks deleteWebhook
+// Delete a webhook, discarding its deliveries.
+// responses:
+//  204: noContent
+//  400: errorResponse
+//  401: errorResponse
+//  404: errorResponse
+//  500: errorResponse
+//  default: errorResponse
+
+// Delete is the handler deleting a webhook of the logged in user. Deliveries
+// that are still pending are never attempted.
+func (w *webhooks) Delete(c *gin.Context) {
+	hook, ok := w.owned(c)
+	if !ok {
+		return
+	}
+
+	if err := w.db.DeleteWebhook(hook.ID); err != nil {
+		if err == data.ErrNotFound {
+			problem.Abort(c, problem.New(problem.CodeNotFound, fmt.Sprintf("no such webhook with id %v for logged in user", hook.ID)))
+			return
+		}
+		problem.Internal(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// swagger:route GET /webhooks/{id}/deliveries webhooks readWebhookDeliveries
+// Read the latest deliveries to a webhook, with the outcome of their latest
+// attempt.
+// responses:
+//  200: deliveriesResponse
+//  400: errorResponse
+//  401: errorResponse
+//  404: errorResponse
+//  500: errorResponse
+//  default: errorResponse
+
+// Deliveries is the handler reading the delivery log of a webhook of the
+// logged in user, newest first.
+func (w *webhooks) Deliveries(c *gin.Context) {
+	var q deliveriesQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		problem.Abort(c, problem.FromBindingError(err))
+		return
+	}
+	limit := q.Limit
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+
+	hook, ok := w.owned(c)
+	if !ok {
+		return
+	}
+
+	deliveries, err := w.db.Deliveries(hook.ID, limit)
+	if err != nil {
+		problem.Internal(c, err)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []*models.WebhookDelivery{}
+	}
+
+	c.IndentedJSON(http.StatusOK, deliveries)
+}
+
+// swagger:route POST /webhooks/{id}/test webhooks testWebhook
+// Queue a ping event for delivery to a webhook. The outcome is found in the
+// webhook's deliveries.
+// responses:
+//  202: deliveryResponse
+//  400: errorResponse
+//  401: errorResponse
+//  404: errorResponse
+//  500: errorResponse
+//  default: errorResponse
+
+// Test is the handler queueing a ping to a webhook of the logged in user. The
+// ping is delivered like any event, so it is retried if it fails.
+func (w *webhooks) Test(c *gin.Context) {
+	hook, ok := w.owned(c)
+	if !ok {
+		return
+	}
+
+	delivery, err := w.dispatcher.ping(hook)
+	if err != nil {
+		problem.Internal(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusAccepted, delivery)
+}
+
+// owned returns the webhook with the id in the path of c, if it is owned by
+// the logged in user. Otherwise it aborts with a problem and returns false.
+// Webhooks of other users are not found, so that they cannot be discovered.
+func (w *webhooks) owned(c *gin.Context) (*models.Webhook, bool) {
+	userID, err := users.UserIDFromContext(c)
+	if err != nil {
+		problem.Abort(c, problem.New(problem.CodeUnauthenticated, "you must be logged in to perform this action"))
+		return nil, false
+	}
+
+	id, err := strconv.Atoi(c.Param(WebhookIDFromParamsKey))
+	if err != nil || id < 0 {
+		problem.Abort(c, problem.New(problem.CodeInvalidParameter, ErrInvalidWebhookID))
+		return nil, false
+	}
+
+	hook, err := w.db.WebhookByID(models.WebhookID(id))
+	if err == data.ErrNotFound || (err == nil && hook.OwnerID != userID) {
+		problem.Abort(c, problem.New(problem.CodeNotFound, fmt.Sprintf("no such webhook with id %v for logged in user", id)))
+		return nil, false
+	}
+	if err != nil {
+		problem.Internal(c, err)
+		return nil, false
+	}
+	return hook, true
+}
+
+// withoutSecret returns a copy of hook without its secret.
+func withoutSecret(hook *models.Webhook) *models.Webhook {
+	h := *hook
+	h.Secret = ""
+	return &h
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// newSecret returns a random secret for signing deliveries.
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,415 @@
+package webhooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// testUserHeader names the logged in user of requests to the test router
+const testUserHeader = "Test-User"
+
+// TestCreate tests the API layer's Create method for the Webhooks resource.
+// User 1 is an administrator, and user 2 is not.
+func TestCreate(t *testing.T) {
+	tests := []struct {
+		name       string
+		userID     models.UserID
+		body       string
+		addErr     error
+		wantCode   int
+		wantEvents []string
+	}{
+		{
+			name:       "Webhook is created with its events",
+			userID:     2,
+			body:       `{"url": "https://example.com/hook", "events": ["set.created", "user.updated"]}`,
+			wantCode:   http.StatusCreated,
+			wantEvents: []string{"set.created", "user.updated"},
+		},
+		{
+			name:       "Duplicate events are subscribed once",
+			userID:     2,
+			body:       `{"url": "http://example.com/hook", "events": ["set.created", "set.created"]}`,
+			wantCode:   http.StatusCreated,
+			wantEvents: []string{"set.created"},
+		},
+		{
+			name:       "Administrator creates a webhook for every user",
+			userID:     1,
+			body:       `{"url": "https://example.com/hook", "events": ["user.created"], "all-users": true}`,
+			wantCode:   http.StatusCreated,
+			wantEvents: []string{"user.created"},
+		},
+		{
+			name:     "Webhook for every user returns StatusForbidden for others",
+			userID:   2,
+			body:     `{"url": "https://example.com/hook", "events": ["user.created"], "all-users": true}`,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Unknown event returns StatusBadRequest",
+			userID:   2,
+			body:     `{"url": "https://example.com/hook", "events": ["set.read"]}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "No events returns StatusBadRequest",
+			userID:   2,
+			body:     `{"url": "https://example.com/hook", "events": []}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Url that is not http returns StatusBadRequest",
+			userID:   2,
+			body:     `{"url": "ftp://example.com/hook", "events": ["set.created"]}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Missing url returns StatusBadRequest",
+			userID:   2,
+			body:     `{"events": ["set.created"]}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Database error returns StatusInternalServerError",
+			userID:   2,
+			body:     `{"url": "https://example.com/hook", "events": ["set.created"]}`,
+			addErr:   data.ErrNotFound,
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, v := range tests {
+		var added *models.Webhook
+		db := &data.MockWebhookDB{
+			AddWebhookStub: func(w *models.Webhook) (models.WebhookID, error) {
+				added = w
+				return 7, v.addErr
+			},
+		}
+		resp := serve(t, db, http.MethodPost, "/webhooks/", v.userID, v.body)
+		if resp.Code != v.wantCode {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v\nBody: %s", v.name, v.wantCode, resp.Code, resp.Body)
+		}
+		if v.wantCode != http.StatusCreated {
+			continue
+		}
+
+		var got models.Webhook
+		if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", v.name, err)
+		}
+		if got.ID != 7 || got.OwnerID != v.userID || !reflect.DeepEqual(got.Events, v.wantEvents) {
+			t.Fatalf("%s: Wanted webhook 7 of user %v with events %v\nGot webhook: %+v", v.name, v.userID, v.wantEvents, got)
+		}
+		if len(got.Secret) != 64 || got.Secret != added.Secret {
+			t.Fatalf("%s: Wanted the stored secret in the response\nGot secret: %q, stored: %q", v.name, got.Secret, added.Secret)
+		}
+	}
+}
+
+// TestRead tests the API layer's Read and ReadAll methods for the Webhooks
+// resource. Webhook 7 is owned by user 2.
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   models.UserID
+		path     string
+		wantCode int
+	}{
+		{
+			name:     "Owner reads their webhook",
+			userID:   2,
+			path:     "/webhooks/7",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Owner reads their webhooks",
+			userID:   2,
+			path:     "/webhooks/",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Other user returns StatusNotFound",
+			userID:   3,
+			path:     "/webhooks/7",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Unknown webhook returns StatusNotFound",
+			userID:   2,
+			path:     "/webhooks/8",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid id returns StatusBadRequest",
+			userID:   2,
+			path:     "/webhooks/seven",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, v := range tests {
+		resp := serve(t, ownedWebhookDB(), http.MethodGet, v.path, v.userID, "")
+		if resp.Code != v.wantCode {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v", v.name, v.wantCode, resp.Code)
+		}
+		if v.wantCode == http.StatusOK && bytes.Contains(resp.Body.Bytes(), []byte("secret")) {
+			t.Fatalf("%s: Wanted no secret\nGot body: %s", v.name, resp.Body)
+		}
+	}
+}
+
+// TestDelete tests the API layer's Delete method for the Webhooks resource.
+// Webhook 7 is owned by user 2.
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name        string
+		userID      models.UserID
+		wantCode    int
+		wantDeleted bool
+	}{
+		{
+			name:        "Owner deletes their webhook",
+			userID:      2,
+			wantCode:    http.StatusNoContent,
+			wantDeleted: true,
+		},
+		{
+			name:     "Other user returns StatusNotFound",
+			userID:   3,
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, v := range tests {
+		db := ownedWebhookDB()
+		deleted := false
+		db.DeleteWebhookStub = func(id models.WebhookID) error {
+			deleted = id == 7
+			return nil
+		}
+
+		resp := serve(t, db, http.MethodDelete, "/webhooks/7", v.userID, "")
+		if resp.Code != v.wantCode {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v", v.name, v.wantCode, resp.Code)
+		}
+		if deleted != v.wantDeleted {
+			t.Fatalf("%s: Wanted deleted: %v\nGot deleted: %v", v.name, v.wantDeleted, deleted)
+		}
+	}
+}
+
+// TestDeliveries tests the API layer's Deliveries method for the Webhooks
+// resource. Webhook 7 is owned by user 2.
+func TestDeliveries(t *testing.T) {
+	tests := []struct {
+		name      string
+		userID    models.UserID
+		query     string
+		wantCode  int
+		wantLimit int
+	}{
+		{
+			name:      "Owner reads deliveries with the default limit",
+			userID:    2,
+			wantCode:  http.StatusOK,
+			wantLimit: DefaultLimit,
+		},
+		{
+			name:      "Owner reads deliveries with a limit",
+			userID:    2,
+			query:     "?limit=5",
+			wantCode:  http.StatusOK,
+			wantLimit: 5,
+		},
+		{
+			name:     "Limit above the maximum returns StatusBadRequest",
+			userID:   2,
+			query:    "?limit=1001",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Other user returns StatusNotFound",
+			userID:   3,
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, v := range tests {
+		db := ownedWebhookDB()
+		gotLimit := 0
+		db.DeliveriesStub = func(id models.WebhookID, limit int) ([]*models.WebhookDelivery, error) {
+			gotLimit = limit
+			return nil, nil
+		}
+
+		resp := serve(t, db, http.MethodGet, "/webhooks/7/deliveries"+v.query, v.userID, "")
+		if resp.Code != v.wantCode {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v", v.name, v.wantCode, resp.Code)
+		}
+		if gotLimit != v.wantLimit {
+			t.Fatalf("%s: Wanted limit: %v\nGot limit: %v", v.name, v.wantLimit, gotLimit)
+		}
+		if v.wantCode == http.StatusOK && resp.Body.String() != "[]" {
+			t.Fatalf("%s: Wanted an empty list\nGot body: %s", v.name, resp.Body)
+		}
+	}
+}
+
+// TestTest tests the API layer's Test method for the Webhooks resource, which
+// queues a ping for delivery. Webhook 7 is owned by user 2.
+func TestTest(t *testing.T) {
+	db := ownedWebhookDB()
+	var queued *models.WebhookDelivery
+	db.AddDeliveryStub = func(d *models.WebhookDelivery) (int64, error) {
+		queued = d
+		return 11, nil
+	}
+
+	resp := serve(t, db, http.MethodPost, "/webhooks/7/test", 2, "")
+	if resp.Code != http.StatusAccepted {
+		t.Fatalf("Wanted code: %v\nGot code: %v", http.StatusAccepted, resp.Code)
+	}
+	if queued == nil || queued.WebhookID != 7 || queued.Event != Ping || queued.Status != models.DeliveryPending {
+		t.Fatalf("Wanted a pending ping to webhook 7\nGot delivery: %+v", queued)
+	}
+	var got models.WebhookDelivery
+	if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil || got.ID != 11 {
+		t.Fatalf("Wanted delivery 11 in the response\nGot body: %s", resp.Body)
+	}
+
+	resp = serve(t, ownedWebhookDB(), http.MethodPost, "/webhooks/7/test", 3, "")
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("Wanted code: %v\nGot code: %v", http.StatusNotFound, resp.Code)
+	}
+}
+
+// TestVerify tests that Verify accepts signatures made by Sign, and rejects
+// signatures that are tampered with, made with another secret or too old.
+func TestVerify(t *testing.T) {
+	body := []byte(`{"event":"set.created"}`)
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		header  string
+		body    []byte
+		wantErr error
+	}{
+		{
+			name:   "Signature of the body is valid",
+			header: Sign("secret", now, body),
+			body:   body,
+		},
+		{
+			name:    "Tampered body does not match",
+			header:  Sign("secret", now, body),
+			body:    []byte(`{"event":"set.deleted"}`),
+			wantErr: ErrSignatureMismatch,
+		},
+		{
+			name:    "Other secret does not match",
+			header:  Sign("other", now, body),
+			body:    body,
+			wantErr: ErrSignatureMismatch,
+		},
+		{
+			name:    "Old signature is expired",
+			header:  Sign("secret", now.Add(-time.Hour), body),
+			body:    body,
+			wantErr: ErrSignatureExpired,
+		},
+		{
+			name:    "Missing timestamp is malformed",
+			header:  "v1=abc",
+			body:    body,
+			wantErr: ErrMalformedSignature,
+		},
+		{
+			name:    "Garbage is malformed",
+			header:  "signature",
+			body:    body,
+			wantErr: ErrMalformedSignature,
+		},
+	}
+
+	for _, v := range tests {
+		if err := Verify("secret", v.header, v.body, DefaultTolerance); err != v.wantErr {
+			t.Fatalf("%s: Wanted error: %v\nGot error: %v", v.name, v.wantErr, err)
+		}
+	}
+}
+
+// ownedWebhookDB returns a mock db holding webhook 7, owned by user 2.
+func ownedWebhookDB() *data.MockWebhookDB {
+	hook := &models.Webhook{
+		ID:      7,
+		OwnerID: 2,
+		URL:     "https://example.com/hook",
+		Events:  []string{"set.created"},
+		Secret:  "secret",
+	}
+	return &data.MockWebhookDB{
+		WebhookByIDStub: func(id models.WebhookID) (*models.Webhook, error) {
+			if id != hook.ID {
+				return nil, data.ErrNotFound
+			}
+			h := *hook
+			return &h, nil
+		},
+		WebhooksByOwnerStub: func(ownerID models.UserID) ([]*models.Webhook, error) {
+			if ownerID != hook.OwnerID {
+				return nil, nil
+			}
+			h := *hook
+			return []*models.Webhook{&h}, nil
+		},
+	}
+}
+
+// serve sends a request from userID to the webhooks resource over db, which
+// delivers nothing. User 1 is an administrator.
+func serve(t *testing.T, db data.WebhookDB, method, path string, userID models.UserID, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w, err := New(db, &data.MockUserDB{
+		UserByIDStub: func(id models.UserID) (*models.User, error) {
+			return &models.User{ID: id, Admin: id == 1}, nil
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to create webhooks resource: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	g := router.Group("/webhooks", func(c *gin.Context) {
+		id, _ := strconv.Atoi(c.GetHeader(testUserHeader))
+		c.Set(users.UserIDFromContextKey, models.UserID(id))
+	})
+	g.GET("/", w.ReadAll)
+	g.POST("/", w.Create)
+	g.GET("/:"+WebhookIDFromParamsKey, w.Read)
+	g.DELETE("/:"+WebhookIDFromParamsKey, w.Delete)
+	g.GET("/:"+WebhookIDFromParamsKey+"/deliveries", w.Deliveries)
+	g.POST("/:"+WebhookIDFromParamsKey+"/test", w.Test)
+
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set(testUserHeader, strconv.Itoa(int(userID)))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	return resp
+}
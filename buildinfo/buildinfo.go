@@ -0,0 +1,55 @@
+// Package buildinfo reports the version of the running server. The commit
+// and build time are read from the version control information the go tool
+// embeds in the binary, and may be overridden at link time, e.g.
+//
+//	go build -ldflags "-X github.com/hrand1005/training-notebook/buildinfo.Commit=$(git rev-parse HEAD)"
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at link time with -ldflags "-X", overriding the embedded vcs settings
+var (
+	Commit    string
+	BuildTime string
+)
+
+// Info describes the build of the running server
+type Info struct {
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build-time"`
+	// Modified is set if the binary was built with uncommitted changes
+	Modified  bool   `json:"modified"`
+	GoVersion string `json:"go-version"`
+}
+
+// Get returns the build information of the running server. Without link time
+// overrides, BuildTime is the time of the embedded commit. Commit and
+// BuildTime are empty if they were neither embedded nor set at link time.
+func Get() Info {
+	info := Info{GoVersion: runtime.Version()}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				info.Commit = s.Value
+			case "vcs.time":
+				info.BuildTime = s.Value
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+
+	if Commit != "" {
+		info.Commit = Commit
+	}
+	if BuildTime != "" {
+		info.BuildTime = BuildTime
+	}
+
+	return info
+}
@@ -1,50 +1,760 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"net"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/hrand1005/training-notebook/api/cors"
+	"github.com/hrand1005/training-notebook/api/ratelimit"
+	"github.com/hrand1005/training-notebook/frontend"
 	"gopkg.in/yaml.v3"
 )
 
+// EnvPrefix prefixes the environment variables that override configs. The
+// variable for a setting is its key in upper case, with '.' and '-' replaced
+// by '_', e.g. TN_SERVER_SETTINGS_PORT for server-settings.port.
+const EnvPrefix = "TN_"
+
+// Redacted replaces the values of secret settings when configs are printed
+// or logged
+const Redacted = "[REDACTED]"
+
+// Mode is the deployment mode of the server
+type Mode string
+
+// Deployment modes
+const (
+	ModeDev  Mode = "dev"
+	ModeTest Mode = "test"
+	// ModeProd serves the frontend and requires secrets to be configured
+	ModeProd Mode = "prod"
+)
+
+// DevSigningKey signs login tokens in dev and test mode if no key is set
+const DevSigningKey = "development"
+
 type Config struct {
-	Database    DBConfig     `yaml:"database"`
-	Frontend    string       `yaml:"frontend"`
-	LogFile     string       `yaml:"log-file"`
-	Server      ServerConfig `yaml:"server-settings"`
-	SwaggerSpec string       `yaml:"swagger-spec"`
-	// TODO: change Prod field to deployment mode field
-	Prod bool
+	Mode        Mode              `yaml:"mode"`
+	APIVersions APIVersionsConfig `yaml:"api-versions"`
+	Auth        AuthConfig        `yaml:"auth"`
+	CORS        CORSConfig        `yaml:"cors"`
+	Database    DBConfig          `yaml:"database"`
+	// EmbedAssets serves the frontend and swagger spec built into the binary
+	// instead of Frontend and SwaggerSpec on disk
+	EmbedAssets bool      `yaml:"embed-assets"`
+	Frontend    string    `yaml:"frontend"`
+	Log         LogConfig `yaml:"log"`
+	// Deprecated: LogFile is used as Log.File if that is not set
+	LogFile   string          `yaml:"log-file"`
+	RateLimit RateLimitConfig `yaml:"rate-limit"`
+	Server    ServerConfig    `yaml:"server-settings"`
+	// SwaggerSpec is the path of the version 1 swagger spec. Specs of later
+	// versions are read from the same directory, e.g. swagger.v2.yaml.
+	SwaggerSpec string        `yaml:"swagger-spec"`
+	Tracing     TracingConfig `yaml:"tracing"`
+	// ValidateRequests rejects api requests that do not match the swagger
+	// spec. Responses are validated too in test mode, and replaced by an
+	// internal error if they do not match.
+	ValidateRequests bool           `yaml:"validate-requests"`
+	Webhooks         WebhooksConfig `yaml:"webhooks"`
+}
+
+// DateFormat is the format of dates in configs, e.g. 2027-04-19
+const DateFormat = "2006-01-02"
+
+// APIVersionsConfig schedules the deprecation of api versions. Deprecated
+// versions announce their deprecation and sunset dates in response headers,
+// and versions without a deprecation date are current.
+type APIVersionsConfig struct {
+	// V1Deprecated is the date version 1 is deprecated. It should be set in a
+	// release after the one serving version 2, so that clients can move to
+	// it before they are warned.
+	V1Deprecated string `yaml:"v1-deprecated"`
+	// V1Sunset is the date version 1 stops being served, after V1Deprecated
+	V1Sunset string `yaml:"v1-sunset"`
+}
+
+// v1 returns the deprecation and sunset dates of version 1, and false if its
+// deprecation is not scheduled. Returns an error if the dates are invalid.
+func (a APIVersionsConfig) v1() (deprecated, sunset time.Time, ok bool, err error) {
+	if a.V1Deprecated == "" && a.V1Sunset == "" {
+		return time.Time{}, time.Time{}, false, nil
+	}
+	if a.V1Deprecated == "" || a.V1Sunset == "" {
+		return time.Time{}, time.Time{}, false, errors.New("v1-deprecated and v1-sunset must be set together")
+	}
+	if deprecated, err = time.Parse(DateFormat, a.V1Deprecated); err != nil {
+		return time.Time{}, time.Time{}, false, fmt.Errorf("v1-deprecated must be a date such as 2027-04-19, got %q", a.V1Deprecated)
+	}
+	if sunset, err = time.Parse(DateFormat, a.V1Sunset); err != nil {
+		return time.Time{}, time.Time{}, false, fmt.Errorf("v1-sunset must be a date such as 2027-04-19, got %q", a.V1Sunset)
+	}
+	if !sunset.After(deprecated) {
+		return time.Time{}, time.Time{}, false, errors.New("v1-sunset must be after v1-deprecated")
+	}
+	return deprecated, sunset, true, nil
+}
+
+// AuthConfig configures authentication. Settings tagged secret are redacted
+// when configs are printed or logged.
+type AuthConfig struct {
+	// SigningKey signs login tokens. It is required in prod mode.
+	SigningKey string `yaml:"signing-key" secret:"true"`
+	// CookieSameSite is the SameSite attribute of login and CSRF cookies,
+	// one of lax, strict or none. none requires TLS.
+	CookieSameSite string `yaml:"cookie-same-site"`
+}
+
+// CORSConfig allows browsers to call the api from AllowedOrigins, e.g. a
+// frontend dev server on another port. Cross-origin requests are not
+// allowed if no origins are set.
+type CORSConfig struct {
+	// AllowedOrigins are origins such as http://localhost:3000, or * for any
+	// origin, which cannot be combined with AllowCredentials
+	AllowedOrigins []string `yaml:"allowed-origins" reload:"true"`
+	// AllowCredentials lets browsers send login cookies with cross-origin
+	// requests
+	AllowCredentials bool `yaml:"allow-credentials" reload:"true"`
+	// MaxAge is how long browsers may cache preflight responses
+	MaxAge time.Duration `yaml:"max-age" reload:"true"`
+}
+
+// options returns the configured options as applied by the CORS policy.
+func (c CORSConfig) options() cors.Options {
+	return cors.Options{
+		AllowedOrigins:   c.AllowedOrigins,
+		AllowCredentials: c.AllowCredentials,
+		MaxAge:           c.MaxAge,
+	}
 }
 
 type DBConfig struct {
 	Path string `yaml:"path"`
 }
 
+// LogConfig configures structured logging. Logs are written to stderr if no
+// file is set, and files are rotated once they reach MaxSizeMB if it is set.
+type LogConfig struct {
+	File       string `yaml:"file"`
+	Level      string `yaml:"level" reload:"true"`
+	Format     string `yaml:"format"`
+	MaxSizeMB  int    `yaml:"max-size-mb"`
+	MaxBackups int    `yaml:"max-backups"`
+	MaxAgeDays int    `yaml:"max-age-days"`
+	Compress   bool   `yaml:"compress"`
+}
+
+// RateLimitConfig limits requests to the api per logged in user, or per
+// client address for requests without one. Routes without a policy are not
+// limited.
+type RateLimitConfig struct {
+	Policies []RateLimitPolicy `yaml:"policies" reload:"true"`
+}
+
+// RateLimitPolicy allows Requests per Per to Route, with bursts of up to
+// Burst requests. Route is a route template, e.g. /api/sets/:paramSetID, or *
+// for every route without a policy of its own, and Method restricts the
+// policy to one HTTP method if it is set.
+type RateLimitPolicy struct {
+	Route    string        `yaml:"route"`
+	Method   string        `yaml:"method,omitempty"`
+	Requests int           `yaml:"requests"`
+	Per      time.Duration `yaml:"per"`
+	Burst    int           `yaml:"burst,omitempty"`
+}
+
+// policies returns the configured policies as applied by the rate limiter.
+func (r RateLimitConfig) policies() []ratelimit.Policy {
+	if len(r.Policies) == 0 {
+		return nil
+	}
+	policies := make([]ratelimit.Policy, 0, len(r.Policies))
+	for _, p := range r.Policies {
+		policies = append(policies, ratelimit.Policy(p))
+	}
+	return policies
+}
+
+// TracingConfig configures OpenTelemetry tracing. Spans are exported to an
+// OTLP/HTTP collector at OTLPEndpoint, to stdout, or to File, and tracing is
+// disabled if Exporter is empty or none.
+type TracingConfig struct {
+	Exporter     string  `yaml:"exporter"`
+	File         string  `yaml:"file"`
+	OTLPEndpoint string  `yaml:"otlp-endpoint"`
+	OTLPInsecure bool    `yaml:"otlp-insecure"`
+	SampleRatio  float64 `yaml:"sample-ratio"`
+	ServiceName  string  `yaml:"service-name"`
+}
+
+// WebhooksConfig configures the delivery of events to webhooks.
+type WebhooksConfig struct {
+	// AllowPrivateNetworks lets webhooks be delivered to loopback, private
+	// and link-local addresses, which users could otherwise use to reach
+	// services on the server's network. Only enable it in development; it
+	// is rejected in prod mode.
+	AllowPrivateNetworks bool `yaml:"allow-private-networks"`
+}
+
 type ServerConfig struct {
 	Port         string        `yaml:"port"`
 	IdleTimeout  time.Duration `yaml:"idle-timeout"`
 	ReadTimeout  time.Duration `yaml:"read-timeout"`
 	WriteTimeout time.Duration `yaml:"write-timeout"`
+	// IdempotencyWindow is how long responses to requests with an
+	// Idempotency-Key are kept for replay
+	IdempotencyWindow time.Duration `yaml:"idempotency-window" reload:"true"`
+	// AdminPort serves metrics separately from the api if set, otherwise
+	// metrics are served on Port
+	AdminPort string `yaml:"admin-port"`
+	// GRPCPort serves the gRPC api if set, over TLS if TLS is enabled
+	GRPCPort string `yaml:"grpc-port"`
+	// ShutdownGracePeriod is how long in-flight requests are given to
+	// complete once shutdown begins
+	ShutdownGracePeriod time.Duration `yaml:"shutdown-grace-period"`
+	// ShutdownDrainDelay is how long the server keeps serving while its
+	// readiness probe fails, before it stops accepting connections
+	ShutdownDrainDelay time.Duration `yaml:"shutdown-drain-delay"`
+	TLS                TLSConfig     `yaml:"tls"`
+	// TrustedProxies are the addresses or CIDR ranges of proxies trusted to
+	// report the client address in forwarding headers, which are ignored
+	// if none are set
+	TrustedProxies []string `yaml:"trusted-proxies"`
+}
+
+// TLSConfig enables HTTPS on the server port if CertFile is set. The
+// certificate and key files are reloaded when they change, and RedirectPort
+// serves plain HTTP redirecting to HTTPS if it is set.
+type TLSConfig struct {
+	CertFile string `yaml:"cert-file"`
+	KeyFile  string `yaml:"key-file"`
+	// MinVersion is the minimum TLS version accepted, 1.2 or 1.3
+	MinVersion string `yaml:"min-version"`
+	// CipherPolicy is default, allowing the Go runtime's cipher suites, or
+	// modern, allowing only forward secret AEAD cipher suites
+	CipherPolicy string `yaml:"cipher-policy"`
+	RedirectPort string `yaml:"redirect-port"`
+}
+
+// Enabled reports whether the server is served over HTTPS
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != ""
 }
 
-// loadConfig decodes a yaml configuration file, and sets deployment mode
-func loadConfig(prodMode bool, configPath string) (*Config, error) {
-	f, err := os.Open(configPath)
+// defaultConfig returns the configs used for any setting that is not set by
+// the yaml file, environment or flags.
+func defaultConfig() *Config {
+	return &Config{
+		Mode: ModeDev,
+		Auth: AuthConfig{
+			CookieSameSite: "lax",
+		},
+		CORS: CORSConfig{
+			MaxAge: 10 * time.Minute,
+		},
+		Database: DBConfig{
+			Path: "./training-notebook.sqlite",
+		},
+		Frontend: "./frontend/build",
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Server: ServerConfig{
+			Port:                ":8080",
+			IdleTimeout:         120 * time.Second,
+			ReadTimeout:         time.Second,
+			WriteTimeout:        time.Second,
+			IdempotencyWindow:   24 * time.Hour,
+			ShutdownGracePeriod: 30 * time.Second,
+			ShutdownDrainDelay:  5 * time.Second,
+			TLS: TLSConfig{
+				MinVersion:   "1.2",
+				CipherPolicy: "default",
+			},
+		},
+		SwaggerSpec: "./docs/swagger.yaml",
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			SampleRatio: 1,
+			ServiceName: "training-notebook",
+		},
+		ValidateRequests: true,
+	}
+}
+
+// loadConfig builds the effective configs by layering, from lowest to highest
+// precedence, defaults, the yaml file at configPath if it is set, environment
+// variables looked up with env, and overrides keyed by setting, e.g. from
+// command line flags. The resulting configs are validated.
+func loadConfig(configPath string, env func(string) (string, bool), overrides map[string]string) (*Config, error) {
+	conf, err := layerConfig(configPath, env, overrides)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
 
-	// decode server config
-	conf := &Config{}
-	d := yaml.NewDecoder(f)
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	return conf, nil
+}
+
+// layerConfig builds configs as described by loadConfig, without validating
+// them. Returns an error if any layer cannot be parsed.
+func layerConfig(configPath string, env func(string) (string, bool), overrides map[string]string) (*Config, error) {
+	conf := defaultConfig()
 
-	if err := d.Decode(conf); err != nil {
+	if configPath != "" {
+		f, err := os.Open(configPath)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		// decode server config
+		d := yaml.NewDecoder(f)
+		d.KnownFields(true)
+		if err := d.Decode(conf); err != nil {
+			return nil, fmt.Errorf("decoding %v: %v", configPath, err)
+		}
+	}
+
+	var errs []error
+	visitSettings(conf, func(s setting) {
+		value, ok := env(s.env())
+		if !ok {
+			return
+		}
+		if err := s.set(value); err != nil {
+			errs = append(errs, fmt.Errorf("%v: %v", s.env(), err))
+		}
+	})
+	// the signing key was read from SIGNING_KEY before configs were layered
+	if _, ok := env(EnvPrefix + "AUTH_SIGNING_KEY"); !ok {
+		if key, ok := env("SIGNING_KEY"); ok {
+			conf.Auth.SigningKey = key
+		}
+	}
+
+	applied := make(map[string]bool)
+	visitSettings(conf, func(s setting) {
+		value, ok := overrides[s.key]
+		if !ok {
+			return
+		}
+		applied[s.key] = true
+		if err := s.set(value); err != nil {
+			errs = append(errs, fmt.Errorf("%v: %v", s.key, err))
+		}
+	})
+	for key := range overrides {
+		if !applied[key] {
+			errs = append(errs, fmt.Errorf("unknown setting %q", key))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
 		return nil, err
 	}
 
-	conf.Prod = prodMode
+	if conf.Log.File == "" {
+		conf.Log.File = conf.LogFile
+	}
+	if conf.Auth.SigningKey == "" && conf.Mode != ModeProd {
+		conf.Auth.SigningKey = DevSigningKey
+	}
 
 	return conf, nil
 }
+
+// Validate checks every setting, and returns an error describing each
+// invalid one.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(key, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%v: %v", key, fmt.Sprintf(format, args...)))
+	}
+
+	switch c.Mode {
+	case ModeDev, ModeTest, ModeProd:
+	default:
+		invalid("mode", "must be one of %v %v %v, got %q", ModeDev, ModeTest, ModeProd, c.Mode)
+	}
+
+	if c.Mode == ModeProd {
+		if c.Auth.SigningKey == "" {
+			invalid("auth.signing-key", "is required in prod mode")
+		}
+		// users could reach services on the server's network
+		if c.Webhooks.AllowPrivateNetworks {
+			invalid("webhooks.allow-private-networks", "must not be set in prod mode")
+		}
+		if c.EmbedAssets {
+			if _, ok := frontend.FS(); !ok {
+				invalid("embed-assets", "requires a binary built with the embed tag in prod mode")
+			}
+		} else if c.Frontend == "" {
+			invalid("frontend", "is required in prod mode")
+		}
+	}
+
+	if _, _, _, err := c.APIVersions.v1(); err != nil {
+		invalid("api-versions", "%v", err)
+	}
+
+	if c.ValidateRequests && !c.EmbedAssets && c.SwaggerSpec == "" {
+		invalid("validate-requests", "requires swagger-spec or embed-assets")
+	}
+
+	switch c.Auth.CookieSameSite {
+	case "lax", "strict":
+	case "none":
+		// browsers reject cookies with SameSite=None unless they are secure
+		if !c.Server.TLS.Enabled() {
+			invalid("auth.cookie-same-site", "none requires TLS to be enabled")
+		}
+	default:
+		invalid("auth.cookie-same-site", "must be one of lax strict none, got %q", c.Auth.CookieSameSite)
+	}
+
+	if err := c.CORS.options().Validate(); err != nil {
+		invalid("cors", "%v", err)
+	}
+
+	if c.Database.Path == "" {
+		invalid("database.path", "is required")
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		invalid("log.level", "must be one of debug info warn error, got %q", c.Log.Level)
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case "json", "text":
+	default:
+		invalid("log.format", "must be one of json text, got %q", c.Log.Format)
+	}
+	if c.Log.MaxSizeMB < 0 {
+		invalid("log.max-size-mb", "must not be negative")
+	}
+	if c.Log.MaxBackups < 0 {
+		invalid("log.max-backups", "must not be negative")
+	}
+	if c.Log.MaxAgeDays < 0 {
+		invalid("log.max-age-days", "must not be negative")
+	}
+
+	if _, _, err := net.SplitHostPort(c.Server.Port); err != nil {
+		invalid("server-settings.port", "must be a [host]:port address, got %q", c.Server.Port)
+	}
+	if c.Server.AdminPort != "" {
+		if _, _, err := net.SplitHostPort(c.Server.AdminPort); err != nil {
+			invalid("server-settings.admin-port", "must be a [host]:port address, got %q", c.Server.AdminPort)
+		} else if c.Server.AdminPort == c.Server.Port {
+			invalid("server-settings.admin-port", "must differ from server-settings.port")
+		}
+	}
+	if c.Server.GRPCPort != "" {
+		if _, _, err := net.SplitHostPort(c.Server.GRPCPort); err != nil {
+			invalid("server-settings.grpc-port", "must be a [host]:port address, got %q", c.Server.GRPCPort)
+		} else if c.Server.GRPCPort == c.Server.Port || c.Server.GRPCPort == c.Server.AdminPort {
+			invalid("server-settings.grpc-port", "must differ from the server and admin ports")
+		}
+	}
+	tlsConf := c.Server.TLS
+	if (tlsConf.CertFile == "") != (tlsConf.KeyFile == "") {
+		invalid("server-settings.tls", "cert-file and key-file must be set together")
+	}
+	files := []struct {
+		key, path string
+	}{
+		{"server-settings.tls.cert-file", tlsConf.CertFile},
+		{"server-settings.tls.key-file", tlsConf.KeyFile},
+	}
+	for _, f := range files {
+		if f.path == "" {
+			continue
+		}
+		if _, err := os.Stat(f.path); err != nil {
+			invalid(f.key, "%v", err)
+		}
+	}
+	switch tlsConf.MinVersion {
+	case "1.2", "1.3":
+	default:
+		invalid("server-settings.tls.min-version", "must be one of 1.2 1.3, got %q", tlsConf.MinVersion)
+	}
+	switch tlsConf.CipherPolicy {
+	case "default", "modern":
+	default:
+		invalid("server-settings.tls.cipher-policy", "must be one of default modern, got %q", tlsConf.CipherPolicy)
+	}
+	if tlsConf.RedirectPort != "" {
+		if !tlsConf.Enabled() {
+			invalid("server-settings.tls.redirect-port", "requires TLS to be enabled")
+		} else if _, _, err := net.SplitHostPort(tlsConf.RedirectPort); err != nil {
+			invalid("server-settings.tls.redirect-port", "must be a [host]:port address, got %q", tlsConf.RedirectPort)
+		} else if tlsConf.RedirectPort == c.Server.Port || tlsConf.RedirectPort == c.Server.AdminPort || tlsConf.RedirectPort == c.Server.GRPCPort {
+			invalid("server-settings.tls.redirect-port", "must differ from the server, admin and gRPC ports")
+		}
+	}
+
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			invalid("server-settings.trusted-proxies", "must be IP addresses or CIDR ranges, got %q", proxy)
+		}
+	}
+
+	durations := []struct {
+		key string
+		d   time.Duration
+	}{
+		{"server-settings.idle-timeout", c.Server.IdleTimeout},
+		{"server-settings.read-timeout", c.Server.ReadTimeout},
+		{"server-settings.write-timeout", c.Server.WriteTimeout},
+		{"server-settings.idempotency-window", c.Server.IdempotencyWindow},
+		{"server-settings.shutdown-grace-period", c.Server.ShutdownGracePeriod},
+		{"server-settings.shutdown-drain-delay", c.Server.ShutdownDrainDelay},
+	}
+	for _, v := range durations {
+		if v.d < 0 {
+			invalid(v.key, "must not be negative")
+		}
+	}
+
+	for i, p := range c.RateLimit.policies() {
+		if err := p.Validate(); err != nil {
+			invalid(fmt.Sprintf("rate-limit.policies[%d]", i), "%v", err)
+		}
+	}
+
+	switch strings.ToLower(c.Tracing.Exporter) {
+	case "", "none", "stdout", "otlp":
+	case "file":
+		if c.Tracing.File == "" {
+			invalid("tracing.file", "is required by the file exporter")
+		}
+	default:
+		invalid("tracing.exporter", "must be one of none stdout file otlp, got %q", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio <= 0 || c.Tracing.SampleRatio > 1 {
+		invalid("tracing.sample-ratio", "must be greater than 0 and at most 1, got %v", c.Tracing.SampleRatio)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Redact returns a copy of the configs with the values of secret settings
+// replaced, so that they can be printed.
+func (c *Config) Redact() *Config {
+	redacted := *c
+	visitSettings(&redacted, func(s setting) {
+		if s.secret && s.value.String() != "" {
+			s.value.SetString(Redacted)
+		}
+	})
+	return &redacted
+}
+
+// withReloadable returns a copy of the configs with the value of each
+// reloadable setting taken from next.
+func (c *Config) withReloadable(next *Config) *Config {
+	values := make(map[string]reflect.Value)
+	visitSettings(next, func(s setting) {
+		values[s.key] = s.value
+	})
+
+	merged := *c
+	visitSettings(&merged, func(s setting) {
+		if s.reloadable {
+			s.value.Set(values[s.key])
+		}
+	})
+	return &merged
+}
+
+// settingChange describes a setting that differs between two configs
+type settingChange struct {
+	key      string
+	from, to string
+	// reloadable is set if the change can be applied without a restart
+	reloadable bool
+}
+
+func (c settingChange) String() string {
+	return fmt.Sprintf("%v: %q -> %q", c.key, c.from, c.to)
+}
+
+// diffConfig returns the settings that differ between from and to, in field
+// order. The values of secret settings are redacted.
+func diffConfig(from, to *Config) []settingChange {
+	before := make(map[string]string)
+	visitSettings(from, func(s setting) {
+		before[s.key] = s.String()
+	})
+
+	var changes []settingChange
+	visitSettings(to, func(s setting) {
+		if before[s.key] == s.String() {
+			return
+		}
+		change := settingChange{
+			key:        s.key,
+			from:       before[s.key],
+			to:         s.String(),
+			reloadable: s.reloadable,
+		}
+		if s.secret {
+			change.from, change.to = Redacted, Redacted
+		}
+		changes = append(changes, change)
+	})
+
+	return changes
+}
+
+// setting is a single configurable value, identified by its key: the yaml
+// keys of the fields leading to it joined by '.', e.g. log.level
+type setting struct {
+	key    string
+	secret bool
+	// reloadable is set if the setting can be changed while the server runs
+	reloadable bool
+	value      reflect.Value
+}
+
+// String formats the value of the setting as it would be parsed by set.
+func (s setting) String() string {
+	switch v := s.value.Interface().(type) {
+	case []string:
+		return strings.Join(v, ",")
+	}
+
+	if s.value.Kind() == reflect.Slice {
+		var node yaml.Node
+		if err := node.Encode(s.value.Interface()); err != nil {
+			return fmt.Sprint(s.value.Interface())
+		}
+		flowStyle(&node)
+		out, err := yaml.Marshal(&node)
+		if err != nil {
+			return fmt.Sprint(s.value.Interface())
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	return fmt.Sprint(s.value.Interface())
+}
+
+// flowStyle formats node and its children on a single line.
+func flowStyle(node *yaml.Node) {
+	node.Style |= yaml.FlowStyle
+	for _, child := range node.Content {
+		flowStyle(child)
+	}
+}
+
+// env returns the environment variable that overrides the setting.
+func (s setting) env() string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(s.key))
+}
+
+// isBool reports whether the setting is a boolean, which may be set as a
+// flag without a value.
+func (s setting) isBool() bool {
+	return s.value.Kind() == reflect.Bool
+}
+
+// set parses value into the setting according to its type. Lists of strings
+// are separated by commas, and other lists are parsed as yaml, e.g.
+// [{route: /api/login, requests: 5, per: 1m}].
+func (s setting) set(value string) error {
+	switch s.value.Interface().(type) {
+	case []string:
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		s.value.Set(reflect.ValueOf(list))
+		return nil
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		s.value.SetInt(int64(d))
+		return nil
+	}
+
+	switch s.value.Kind() {
+	case reflect.String:
+		s.value.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		s.value.SetBool(b)
+	case reflect.Int:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		s.value.SetInt(int64(i))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		s.value.SetFloat(f)
+	case reflect.Slice:
+		list := reflect.New(s.value.Type())
+		d := yaml.NewDecoder(strings.NewReader(value))
+		d.KnownFields(true)
+		if err := d.Decode(list.Interface()); err != nil {
+			return err
+		}
+		s.value.Set(list.Elem())
+	default:
+		return fmt.Errorf("unsupported setting type %v", s.value.Type())
+	}
+	return nil
+}
+
+// visitSettings calls fn for each setting in conf, in field order.
+func visitSettings(conf *Config, fn func(setting)) {
+	visitStruct(reflect.ValueOf(conf).Elem(), "", fn)
+}
+
+func visitStruct(v reflect.Value, prefix string, fn func(setting)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		key := prefix + name
+
+		if field.Type.Kind() == reflect.Struct {
+			visitStruct(v.Field(i), key+".", fn)
+			continue
+		}
+
+		fn(setting{
+			key:        key,
+			secret:     field.Tag.Get("secret") == "true",
+			reloadable: field.Tag.Get("reload") == "true",
+			value:      v.Field(i),
+		})
+	}
+}
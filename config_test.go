@@ -0,0 +1,283 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLoadConfig checks that configs are layered with the yaml file over
+// defaults, environment variables over the file, and overrides over both.
+func TestLoadConfig(t *testing.T) {
+	const file = `
+mode: "test"
+log:
+  level: "warn"
+server-settings:
+  port: ":9000"
+  read-timeout: 5s
+`
+	tests := []struct {
+		name      string
+		env       map[string]string
+		overrides map[string]string
+		check     func(*Config) string
+	}{
+		{
+			name: "Yaml file overrides defaults",
+			check: func(c *Config) string {
+				if c.Mode != ModeTest || c.Log.Level != "warn" || c.Server.Port != ":9000" || c.Server.ReadTimeout != 5*time.Second {
+					return "yaml settings not applied"
+				}
+				if c.Log.Format != "json" || c.Server.ShutdownGracePeriod != 30*time.Second {
+					return "defaults not applied"
+				}
+				return ""
+			},
+		},
+		{
+			name: "Environment overrides yaml file",
+			env: map[string]string{
+				"TN_LOG_LEVEL":                    "debug",
+				"TN_SERVER_SETTINGS_READ_TIMEOUT": "2s",
+				"TN_LOG_COMPRESS":                 "true",
+				"TN_TRACING_SAMPLE_RATIO":         "0.5",
+			},
+			check: func(c *Config) string {
+				if c.Log.Level != "debug" || c.Server.ReadTimeout != 2*time.Second || !c.Log.Compress || c.Tracing.SampleRatio != 0.5 {
+					return "environment settings not applied"
+				}
+				return ""
+			},
+		},
+		{
+			name:      "Overrides take precedence over environment",
+			env:       map[string]string{"TN_LOG_LEVEL": "debug"},
+			overrides: map[string]string{"log.level": "error", "log.max-backups": "3"},
+			check: func(c *Config) string {
+				if c.Log.Level != "error" || c.Log.MaxBackups != 3 {
+					return "overrides not applied"
+				}
+				return ""
+			},
+		},
+		{
+			name: "Signing key defaults outside prod mode",
+			check: func(c *Config) string {
+				if c.Auth.SigningKey != DevSigningKey {
+					return "dev signing key not set"
+				}
+				return ""
+			},
+		},
+		{
+			name:      "Signing key is loaded from the environment",
+			env:       map[string]string{"TN_AUTH_SIGNING_KEY": "secret", "SIGNING_KEY": "legacy"},
+			overrides: map[string]string{"mode": "prod"},
+			check: func(c *Config) string {
+				if c.Auth.SigningKey != "secret" {
+					return "signing key not loaded"
+				}
+				return ""
+			},
+		},
+		{
+			name:      "Legacy signing key variable is still read",
+			env:       map[string]string{"SIGNING_KEY": "legacy"},
+			overrides: map[string]string{"mode": "prod"},
+			check: func(c *Config) string {
+				if c.Auth.SigningKey != "legacy" {
+					return "legacy signing key not loaded"
+				}
+				return ""
+			},
+		},
+		{
+			name: "Lists are parsed from the environment",
+			env: map[string]string{
+				"TN_SERVER_SETTINGS_TRUSTED_PROXIES": "10.0.0.0/8, 192.168.1.1",
+				"TN_RATE_LIMIT_POLICIES":             "[{route: /api/login, method: POST, requests: 5, per: 1m}]",
+			},
+			check: func(c *Config) string {
+				if len(c.Server.TrustedProxies) != 2 || c.Server.TrustedProxies[1] != "192.168.1.1" {
+					return "trusted proxies not parsed"
+				}
+				want := RateLimitPolicy{Route: "/api/login", Method: "POST", Requests: 5, Per: time.Minute}
+				if len(c.RateLimit.Policies) != 1 || c.RateLimit.Policies[0] != want {
+					return "rate limit policies not parsed"
+				}
+				return ""
+			},
+		},
+	}
+
+	path := writeConfigFile(t, file)
+	for _, v := range tests {
+		conf, err := loadConfig(path, lookup(v.env), v.overrides)
+		if err != nil {
+			t.Fatalf("%s: Encountered unexpected error: %v", v.name, err)
+		}
+		if msg := v.check(conf); msg != "" {
+			t.Fatalf("%s: %s: %+v", v.name, msg, conf)
+		}
+	}
+}
+
+// TestLoadConfigErrors checks that every invalid setting is reported.
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		env       map[string]string
+		overrides map[string]string
+		wantErrs  []string
+	}{
+		{
+			name:     "Unknown yaml field is rejected",
+			file:     "server-settings:\n  prot: \":80\"\n",
+			wantErrs: []string{"prot"},
+		},
+		{
+			name:     "Unparseable environment variable is rejected",
+			env:      map[string]string{"TN_SERVER_SETTINGS_IDLE_TIMEOUT": "soon"},
+			wantErrs: []string{"TN_SERVER_SETTINGS_IDLE_TIMEOUT"},
+		},
+		{
+			name:      "Unknown override is rejected",
+			overrides: map[string]string{"log.colour": "blue"},
+			wantErrs:  []string{"log.colour"},
+		},
+		{
+			name: "Each invalid setting is reported",
+			overrides: map[string]string{
+				"mode":                         "staging",
+				"log.level":                    "loud",
+				"server-settings.port":         "8080",
+				"server-settings.read-timeout": "-1s",
+				"tracing.exporter":             "file",
+				"tracing.sample-ratio":         "2",
+			},
+			wantErrs: []string{"mode", "log.level", "server-settings.port", "server-settings.read-timeout", "tracing.file", "tracing.sample-ratio"},
+		},
+		{
+			name: "Invalid TLS settings are reported",
+			overrides: map[string]string{
+				"server-settings.tls.cert-file":     "missing.pem",
+				"server-settings.tls.min-version":   "1.0",
+				"server-settings.tls.cipher-policy": "legacy",
+			},
+			wantErrs: []string{"cert-file and key-file", "server-settings.tls.cert-file", "min-version", "cipher-policy"},
+		},
+		{
+			name:      "Redirect requires TLS",
+			overrides: map[string]string{"server-settings.tls.redirect-port": ":80"},
+			wantErrs:  []string{"server-settings.tls.redirect-port"},
+		},
+		{
+			name:      "gRPC port must differ from the server port",
+			overrides: map[string]string{"server-settings.grpc-port": ":8080"},
+			wantErrs:  []string{"server-settings.grpc-port"},
+		},
+		{
+			name: "Invalid rate limits and proxies are reported",
+			overrides: map[string]string{
+				"rate-limit.policies":             "[{route: /api/login, requests: 0, per: 1m}, {route: '*', requests: 5}]",
+				"server-settings.trusted-proxies": "10.0.0.0/8,proxy.local",
+			},
+			wantErrs: []string{"rate-limit.policies[0]", "rate-limit.policies[1]", "proxy.local"},
+		},
+		{
+			name: "Invalid cookie and CORS settings are reported",
+			overrides: map[string]string{
+				"auth.cookie-same-site":  "none",
+				"cors.allowed-origins":   "*",
+				"cors.allow-credentials": "true",
+			},
+			wantErrs: []string{"auth.cookie-same-site", "cors"},
+		},
+		{
+			name:      "Embedded assets require the embed build tag in prod mode",
+			overrides: map[string]string{"mode": "prod", "embed-assets": "true"},
+			wantErrs:  []string{"embed-assets"},
+		},
+		{
+			name:      "Request validation requires a swagger spec",
+			overrides: map[string]string{"swagger-spec": ""},
+			wantErrs:  []string{"validate-requests"},
+		},
+		{
+			name:      "Sunset must follow deprecation",
+			overrides: map[string]string{"api-versions.v1-deprecated": "2027-04-19", "api-versions.v1-sunset": "2027-01-01"},
+			wantErrs:  []string{"api-versions"},
+		},
+		{
+			name:      "Deprecation requires a sunset",
+			overrides: map[string]string{"api-versions.v1-deprecated": "2027-04-19"},
+			wantErrs:  []string{"api-versions"},
+		},
+		{
+			name:      "Private webhook networks are rejected in prod mode",
+			env:       map[string]string{"TN_AUTH_SIGNING_KEY": "secret"},
+			overrides: map[string]string{"mode": "prod", "webhooks.allow-private-networks": "true"},
+			wantErrs:  []string{"webhooks.allow-private-networks"},
+		},
+		{
+			name:      "Prod mode requires a signing key",
+			overrides: map[string]string{"mode": "prod"},
+			wantErrs:  []string{"auth.signing-key"},
+		},
+	}
+
+	for _, v := range tests {
+		path := ""
+		if v.file != "" {
+			path = writeConfigFile(t, v.file)
+		}
+
+		_, err := loadConfig(path, lookup(v.env), v.overrides)
+		if err == nil {
+			t.Fatalf("%s: wanted error, got nil", v.name)
+		}
+		for _, want := range v.wantErrs {
+			if !strings.Contains(err.Error(), want) {
+				t.Fatalf("%s: wanted error mentioning %q, got: %v", v.name, want, err)
+			}
+		}
+	}
+}
+
+// TestRedact checks that secrets are redacted without modifying the configs.
+func TestRedact(t *testing.T) {
+	conf := defaultConfig()
+	conf.Auth.SigningKey = "secret"
+
+	redacted := conf.Redact()
+	if redacted.Auth.SigningKey != Redacted {
+		t.Fatalf("wanted signing key redacted, got %q", redacted.Auth.SigningKey)
+	}
+	if conf.Auth.SigningKey != "secret" {
+		t.Fatalf("redacting modified the configs")
+	}
+	if redacted.Database.Path != conf.Database.Path {
+		t.Fatalf("wanted settings that are not secret unchanged")
+	}
+}
+
+// lookup returns an environment lookup backed by env.
+func lookup(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+// writeConfigFile writes contents to a yaml file in a temporary directory.
+func writeConfigFile(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
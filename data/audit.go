@@ -0,0 +1,293 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hrand1005/training-notebook/metrics"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+const (
+	// audit events are append-only, so the table is guarded by triggers that
+	// reject any attempt to modify or remove existing rows
+	createAuditTable = `
+	CREATE TABLE IF NOT EXISTS audit_events (
+		id integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+		actorid INT,
+		ownerid INT,
+		action TEXT,
+		targettype TEXT,
+		targetid INT,
+		beforejson TEXT,
+		afterjson TEXT,
+		requestid TEXT,
+		timestamp TEXT
+	);
+	CREATE INDEX IF NOT EXISTS audit_events_target ON audit_events(targettype, targetid);
+	CREATE TRIGGER IF NOT EXISTS audit_events_no_update BEFORE UPDATE ON audit_events
+	BEGIN
+		SELECT RAISE(ABORT, 'audit events are append-only');
+	END;
+	CREATE TRIGGER IF NOT EXISTS audit_events_no_delete BEFORE DELETE ON audit_events
+	BEGIN
+		SELECT RAISE(ABORT, 'audit events are append-only');
+	END;
+	`
+	insertAuditEvent  = `INSERT INTO audit_events(actorid, ownerid, action, targettype, targetid, beforejson, afterjson, requestid, timestamp) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	selectAuditEvents = `SELECT id, actorid, ownerid, action, targettype, targetid, beforejson, afterjson, requestid, timestamp FROM audit_events`
+)
+
+// auditTimeLayout is a fixed width UTC timestamp layout, so that stored
+// timestamps can be compared as strings
+const auditTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+func init() {
+	requireTable("audit_events")
+}
+
+// AuditDB defines the interface for recording and querying audit events.
+// There are intentionally no methods for changing recorded events.
+type AuditDB interface {
+	AddEvent(e *models.AuditEvent) (int64, error)
+	Events(f models.AuditFilter) ([]*models.AuditEvent, error)
+	Close() error
+}
+
+// auditDB contains a handle to the underlying sql database, and implements AuditDB
+type auditDB struct {
+	handle *sql.DB
+}
+
+// NewAuditDB prepares the audit tables on the given sql db handle and returns
+// an AuditDB interface or error
+func NewAuditDB(db *sql.DB) (AuditDB, error) {
+	return newAuditDB(db)
+}
+
+// newAuditDB returns the underlying auditDB and error created from the given sql db handle.
+func newAuditDB(db *sql.DB) (*auditDB, error) {
+	_, err := db.Exec(createAuditTable)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't prepare SQL statement:\n%s\nerr: %v", createAuditTable, err)
+	}
+
+	return &auditDB{
+		handle: db,
+	}, nil
+}
+
+// AddEvent implements the AuditDB interface method for appending an event to the audit log.
+// Returns the id assigned to the event. If the event has no timestamp, the
+// current time is used.
+func (ad *auditDB) AddEvent(e *models.AuditEvent) (int64, error) {
+	defer metrics.ObserveQuery("AddEvent")()
+	return addEvent(ad.handle, e)
+}
+
+// addEvent performs AddEvent using the given execer.
+func addEvent(ex execer, e *models.AuditEvent) (int64, error) {
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now().UTC()
+	}
+
+	result, err := ex.Exec(insertAuditEvent, e.ActorID, e.OwnerID, e.Action, e.TargetType, e.TargetID,
+		string(e.Before), string(e.After), e.RequestID, e.Timestamp.UTC().Format(auditTimeLayout))
+	if err != nil {
+		return 0, fmt.Errorf("encountered error executing SQL statement: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("encountered error retrieving last inserted id: %v", err)
+	}
+
+	return id, nil
+}
+
+// Actor identifies who makes the changes recorded in the audit log, and the
+// request they are made in. A zero UserID means the owner of each changed
+// record made the change, as for signups, which have no logged in user.
+type Actor struct {
+	UserID    models.UserID
+	RequestID string
+}
+
+// change is a change to a set or user, to be recorded in the audit log.
+// before and after are nil for creations and deletions respectively.
+type change struct {
+	action   models.AuditAction
+	target   models.AuditTarget
+	targetID int
+	ownerID  models.UserID
+	before   interface{}
+	after    interface{}
+}
+
+// event returns the audit event recording c made by the actor.
+func (a *Actor) event(c change) (*models.AuditEvent, error) {
+	e, err := models.NewAuditEvent(c.action, c.target, c.targetID, c.before, c.after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build audit event: %v", err)
+	}
+	e.ActorID = a.UserID
+	if e.ActorID == 0 {
+		e.ActorID = c.ownerID
+	}
+	e.OwnerID = c.ownerID
+	e.RequestID = a.RequestID
+
+	return e, nil
+}
+
+// record appends the audit events of changes made by the actor using ex,
+// which should be the transaction making the changes, so that they are
+// rolled back together if recording fails. A nil actor records nothing.
+func (a *Actor) record(ex execer, changes ...change) error {
+	if a == nil {
+		return nil
+	}
+
+	for _, c := range changes {
+		e, err := a.event(c)
+		if err != nil {
+			return err
+		}
+		if _, err := addEvent(ex, e); err != nil {
+			return fmt.Errorf("failed to record audit event: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// audited calls apply with handle if actor is nil. Otherwise, apply is
+// called in a transaction, and the changes it returns are recorded in the
+// audit log in the same transaction, which is only committed if both succeed.
+func audited(handle *sql.DB, actor *Actor, apply func(ex execer) ([]change, error)) error {
+	if actor == nil {
+		_, err := apply(handle)
+		return err
+	}
+
+	tx, err := handle.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	changes, err := apply(tx)
+	if err != nil {
+		return err
+	}
+	if err := actor.record(tx, changes...); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// Events implements the AuditDB interface method for querying the audit log.
+// Events matching every non-zero field of the filter are returned in the
+// order they were recorded. An empty slice is a valid result.
+func (ad *auditDB) Events(f models.AuditFilter) ([]*models.AuditEvent, error) {
+	defer metrics.ObserveQuery("Events")()
+	query, args := buildAuditQuery(f)
+	rows, err := ad.handle.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing SQL query: %v", err)
+	}
+	defer rows.Close()
+
+	events := make([]*models.AuditEvent, 0, 10)
+	for rows.Next() {
+		e := &models.AuditEvent{}
+		var before, after, timestamp string
+		if err := rows.Scan(&e.ID, &e.ActorID, &e.OwnerID, &e.Action, &e.TargetType, &e.TargetID,
+			&before, &after, &e.RequestID, &timestamp); err != nil {
+			return nil, fmt.Errorf("encountered error scanning row: %v", err)
+		}
+
+		if before != "" {
+			e.Before = []byte(before)
+		}
+		if after != "" {
+			e.After = []byte(after)
+		}
+		if e.Timestamp, err = time.Parse(auditTimeLayout, timestamp); err != nil {
+			return nil, fmt.Errorf("encountered error parsing timestamp %q: %v", timestamp, err)
+		}
+
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("encountered error after scanning rows: %v", err)
+	}
+
+	return events, nil
+}
+
+// buildAuditQuery appends a where clause for each non-zero field of the filter
+// to the base audit select statement, returning the query and its arguments.
+func buildAuditQuery(f models.AuditFilter) (string, []interface{}) {
+	var clauses []string
+	var args []interface{}
+
+	if f.ActorID != 0 {
+		clauses = append(clauses, "actorid=?")
+		args = append(args, f.ActorID)
+	}
+	if f.OwnerID != 0 {
+		clauses = append(clauses, "ownerid=?")
+		args = append(args, f.OwnerID)
+	}
+	if f.Action != "" {
+		clauses = append(clauses, "action=?")
+		args = append(args, f.Action)
+	}
+	if f.TargetType != "" {
+		clauses = append(clauses, "targettype=?")
+		args = append(args, f.TargetType)
+	}
+	if f.TargetID != 0 {
+		clauses = append(clauses, "targetid=?")
+		args = append(args, f.TargetID)
+	}
+	if !f.Since.IsZero() {
+		clauses = append(clauses, "timestamp>=?")
+		args = append(args, f.Since.UTC().Format(auditTimeLayout))
+	}
+	if !f.Until.IsZero() {
+		clauses = append(clauses, "timestamp<=?")
+		args = append(args, f.Until.UTC().Format(auditTimeLayout))
+	}
+
+	query := selectAuditEvents
+	if len(clauses) > 0 {
+		query += " WHERE " + strings.Join(clauses, " AND ")
+	}
+	query += " ORDER BY id"
+	if f.Limit > 0 || f.Offset > 0 {
+		// sqlite treats a negative limit as no limit
+		limit := -1
+		if f.Limit > 0 {
+			limit = f.Limit
+		}
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, limit, f.Offset)
+	}
+
+	return query + ";", args
+}
+
+// Close calls close on the underlying sql.DB
+func (ad *auditDB) Close() error {
+	return ad.handle.Close()
+}
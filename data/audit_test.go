@@ -0,0 +1,267 @@
+package data
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// TestAddAndQueryEvents adds events to the audit db and checks that Events
+// returns exactly those matching the filter, in the order they were added.
+func TestAddAndQueryEvents(t *testing.T) {
+	ad := setupTestAuditDB()
+	defer teardownTestAuditDB(ad)
+
+	start := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	events := []*models.AuditEvent{
+		{ActorID: 1, OwnerID: 1, Action: models.AuditCreate, TargetType: models.AuditTargetSet, TargetID: 1,
+			After: []byte(`{"movement":"Squat"}`), RequestID: "a", Timestamp: start},
+		{ActorID: 2, OwnerID: 1, Action: models.AuditUpdate, TargetType: models.AuditTargetSet, TargetID: 1,
+			Before: []byte(`{"movement":"Squat"}`), After: []byte(`{"movement":"Front Squat"}`), RequestID: "b", Timestamp: start.Add(time.Hour)},
+		{ActorID: 2, OwnerID: 2, Action: models.AuditCreate, TargetType: models.AuditTargetUser, TargetID: 2,
+			After: []byte(`{"name":"Coach"}`), RequestID: "c", Timestamp: start.Add(2 * time.Hour)},
+		{ActorID: 1, OwnerID: 1, Action: models.AuditDelete, TargetType: models.AuditTargetSet, TargetID: 1,
+			Before: []byte(`{"movement":"Front Squat"}`), RequestID: "d", Timestamp: start.Add(3 * time.Hour)},
+	}
+	for _, e := range events {
+		id, err := ad.AddEvent(e)
+		if err != nil {
+			t.Fatalf("Encountered unexpected error: %v", err)
+		}
+		if id <= 0 {
+			t.Fatalf("Invalid event id: %v", id)
+		}
+		e.ID = id
+	}
+
+	testCases := []struct {
+		name   string
+		filter models.AuditFilter
+		want   []*models.AuditEvent
+	}{
+		{
+			name:   "Empty filter returns all events",
+			filter: models.AuditFilter{},
+			want:   events,
+		},
+		{
+			name:   "Target filter returns history of a set",
+			filter: models.AuditFilter{TargetType: models.AuditTargetSet, TargetID: 1},
+			want:   []*models.AuditEvent{events[0], events[1], events[3]},
+		},
+		{
+			name:   "Actor and action filters are combined",
+			filter: models.AuditFilter{ActorID: 2, Action: models.AuditUpdate},
+			want:   []*models.AuditEvent{events[1]},
+		},
+		{
+			name:   "Time range is inclusive",
+			filter: models.AuditFilter{Since: start.Add(time.Hour), Until: start.Add(2 * time.Hour)},
+			want:   []*models.AuditEvent{events[1], events[2]},
+		},
+		{
+			name:   "Limit and offset paginate results",
+			filter: models.AuditFilter{Limit: 2, Offset: 1},
+			want:   []*models.AuditEvent{events[1], events[2]},
+		},
+		{
+			name:   "Unmatched filter returns empty slice",
+			filter: models.AuditFilter{OwnerID: 3},
+			want:   []*models.AuditEvent{},
+		},
+	}
+
+	for _, v := range testCases {
+		got, err := ad.Events(v.filter)
+		if err != nil {
+			t.Fatalf("%s: Encountered unexpected error: %v", v.name, err)
+		}
+		if len(got) != len(v.want) {
+			t.Fatalf("%s: Wanted %v events but got %v", v.name, len(v.want), len(got))
+		}
+		for i := range got {
+			if msg := compareEvents(v.want[i], got[i]); msg != "" {
+				t.Fatalf("%s: %s", v.name, msg)
+			}
+		}
+	}
+}
+
+// TestEventsAreAppendOnly checks that recorded audit events cannot be
+// modified or removed.
+func TestEventsAreAppendOnly(t *testing.T) {
+	ad := setupTestAuditDB()
+	defer teardownTestAuditDB(ad)
+
+	id, err := ad.AddEvent(&models.AuditEvent{ActorID: 1, OwnerID: 1, Action: models.AuditCreate, TargetType: models.AuditTargetSet, TargetID: 1})
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+
+	if _, err := ad.handle.Exec(`UPDATE audit_events SET actorid=2 WHERE id=?;`, id); err == nil {
+		t.Fatalf("Expected error updating audit event")
+	}
+	if _, err := ad.handle.Exec(`DELETE FROM audit_events WHERE id=?;`, id); err == nil {
+		t.Fatalf("Expected error deleting audit event")
+	}
+}
+
+// TestAuditedChanges checks that changes made through an audited set or user
+// db are recorded in the audit log as made by the actor, and that changes
+// made without an actor are not.
+func TestAuditedChanges(t *testing.T) {
+	ad := setupTestAuditDB()
+	defer teardownTestAuditDB(ad)
+	sd, err := newSetDB(ad.handle)
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	ud, err := newUserDB(ad.handle)
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+
+	// signups have no actor, so the new user is recorded as the actor
+	userID, err := ud.AuditedBy(Actor{RequestID: "a"}).AddUser(&models.User{Name: "Hubie", Password: "hash"})
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	sets := sd.AuditedBy(Actor{UserID: userID, RequestID: "b"})
+	setID, err := sets.AddSet(&models.Set{UID: userID, Movement: "Squat", Volume: 5, Intensity: 80})
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if err := sets.UpdateSetForUser(setID, userID, &models.Set{Movement: "Squat", Volume: 3, Intensity: 90}); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if err := sets.DeleteSetForUser(setID, userID); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if _, err := sd.AddSet(&models.Set{UID: userID, Movement: "Bench", Volume: 5, Intensity: 70}); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+
+	got, err := ad.Events(models.AuditFilter{})
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	want := []struct {
+		action    models.AuditAction
+		target    models.AuditTarget
+		targetID  int
+		requestID string
+		before    bool
+		after     bool
+	}{
+		{models.AuditCreate, models.AuditTargetUser, int(userID), "a", false, true},
+		{models.AuditCreate, models.AuditTargetSet, int(setID), "b", false, true},
+		{models.AuditUpdate, models.AuditTargetSet, int(setID), "b", true, true},
+		{models.AuditDelete, models.AuditTargetSet, int(setID), "b", true, false},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Wanted %v events\nGot events: %+v", len(want), got)
+	}
+	for i, w := range want {
+		e := got[i]
+		if e.Action != w.action || e.TargetType != w.target || e.TargetID != w.targetID || e.RequestID != w.requestID ||
+			e.ActorID != userID || e.OwnerID != userID || (e.Before != nil) != w.before || (e.After != nil) != w.after {
+			t.Fatalf("Wanted event %v: %+v by user %v\nGot event: %+v", i, w, userID, e)
+		}
+	}
+}
+
+// TestFailedAuditRollsBack checks that changes are not made if their audit
+// events cannot be recorded.
+func TestFailedAuditRollsBack(t *testing.T) {
+	ad := setupTestAuditDB()
+	defer teardownTestAuditDB(ad)
+	sd, err := newSetDB(ad.handle)
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	ud, err := newUserDB(ad.handle)
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	userID, err := ud.AddUser(&models.User{Name: "Hubie", Password: "hash"})
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	setID, err := sd.AddSet(&models.Set{UID: userID, Movement: "Squat", Volume: 5, Intensity: 80})
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+
+	if _, err := ad.handle.Exec(`DROP TABLE audit_events;`); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	actor := Actor{UserID: userID, RequestID: "a"}
+	sets := sd.AuditedBy(actor)
+
+	if _, err := sets.AddSet(&models.Set{UID: userID, Movement: "Bench", Volume: 5, Intensity: 70}); err == nil {
+		t.Fatalf("Expected error adding set")
+	}
+	if err := sets.UpdateSetForUser(setID, userID, &models.Set{Movement: "Squat", Volume: 3, Intensity: 90}); err == nil {
+		t.Fatalf("Expected error updating set")
+	}
+	batch := []*models.SetOperation{{Op: models.SetOpDelete, SetID: setID}}
+	if err := sets.BatchForUser(userID, batch); err == nil {
+		t.Fatalf("Expected error applying batch")
+	}
+	if err := ud.AuditedBy(actor).UpdateUser(userID, &models.User{Name: "Hubert"}); err == nil {
+		t.Fatalf("Expected error updating user")
+	}
+
+	stored, err := sd.SetsByUserID(userID)
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if len(stored) != 1 || stored[0].Volume != 5 || stored[0].Version != 1 {
+		t.Fatalf("Wanted the set to be unchanged\nGot sets: %+v", stored)
+	}
+	user, err := ud.UserByID(userID)
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if user.Name != "Hubie" {
+		t.Fatalf("Wanted the user to be unchanged\nGot user: %+v", user)
+	}
+}
+
+// compareEvents returns a description of the difference between two events,
+// or an empty string if they are equal.
+func compareEvents(want, got *models.AuditEvent) string {
+	if want.ID != got.ID || want.ActorID != got.ActorID || want.OwnerID != got.OwnerID ||
+		want.Action != got.Action || want.TargetType != got.TargetType || want.TargetID != got.TargetID ||
+		string(want.Before) != string(got.Before) || string(want.After) != string(got.After) ||
+		want.RequestID != got.RequestID || !want.Timestamp.Equal(got.Timestamp) {
+		return fmt.Sprintf("not equal, want event:\n%+v\ngot event:\n%+v", want, got)
+	}
+	return ""
+}
+
+const testAuditDB = "testAuditDB.sqlite"
+
+func setupTestAuditDB() *auditDB {
+	db, err := SqliteDB(testAuditDB)
+	if err != nil {
+		msg := fmt.Sprintf("failed to setup test db: %v, err: %v", testAuditDB, err)
+		panic(msg)
+	}
+
+	ad, err := newAuditDB(db)
+	if err != nil {
+		msg := fmt.Sprintf("failed to setup test db: %v, err: %v", testAuditDB, err)
+		panic(msg)
+	}
+
+	return ad
+}
+
+func teardownTestAuditDB(ad *auditDB) {
+	ad.handle.Close()
+	os.Remove(testAuditDB)
+}
@@ -2,11 +2,20 @@ package data
 
 import (
 	"database/sql"
+	"fmt"
 	"os"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// execer is implemented by both *sql.DB and *sql.Tx, so that statements can be
+// shared between standalone and transactional operations.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 // LoadSqliteFile creates a sql.DB handle using the sqlite3 driver and the filename.
 // If the file doesn't exist, it is created
 func SqliteDB(f string) (*sql.DB, error) {
@@ -27,3 +36,113 @@ func SqliteDB(f string) (*sql.DB, error) {
 
 	return db, nil
 }
+
+// placeholders returns n comma separated query placeholders, for statements
+// with an in clause over a variable number of values.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// addColumn adds a column to an existing table if it is not already present,
+// so that databases created by older versions of the server are upgraded in place.
+func addColumn(db *sql.DB, table, column, definition string) error {
+	columns, err := tableColumns(db, table)
+	if err != nil {
+		return err
+	}
+	if columns[column] {
+		return nil
+	}
+
+	stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, column, definition)
+	if _, err := db.Exec(stmt); err != nil {
+		return fmt.Errorf("couldn't prepare SQL statement:\n%s\nerr: %v", stmt, err)
+	}
+
+	return nil
+}
+
+// tableColumns returns the set of column names in the given table, which is
+// empty if the table does not exist.
+func tableColumns(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s);", table))
+	if err != nil {
+		return nil, fmt.Errorf("error reading columns of table %s: %v", table, err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var cid int
+		var name, colType string
+		var notNull, pk int
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return nil, fmt.Errorf("encountered error scanning row: %v", err)
+		}
+		columns[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("encountered error after scanning rows: %v", err)
+	}
+
+	return columns, nil
+}
+
+// column is a column added to a table after the table was first created.
+type column struct {
+	name       string
+	definition string
+}
+
+// table is a table the server requires, and the columns added to it by later
+// versions of the server.
+type table struct {
+	name  string
+	added []column
+}
+
+// schema lists the tables the server requires, in the order they were
+// registered with requireTable.
+var schema []table
+
+// requireTable registers a table the server requires, with the columns added
+// to it after it was first created, so that CheckSchema checks it.
+func requireTable(name string, added ...column) table {
+	t := table{name: name, added: added}
+	schema = append(schema, t)
+	return t
+}
+
+// upgrade adds the columns added by later versions of the server to the
+// table, so that tables created by older versions are upgraded in place.
+func (t table) upgrade(db *sql.DB) error {
+	for _, c := range t.added {
+		if err := addColumn(db, t.name, c.name, c.definition); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CheckSchema returns an error if any table the server requires is missing,
+// or has not been upgraded with the columns added by later versions.
+func CheckSchema(db *sql.DB) error {
+	for _, t := range schema {
+		columns, err := tableColumns(db, t.name)
+		if err != nil {
+			return err
+		}
+		if len(columns) == 0 {
+			return fmt.Errorf("table %s does not exist", t.name)
+		}
+		for _, c := range t.added {
+			if !columns[c.name] {
+				return fmt.Errorf("table %s is missing column %s", t.name, c.name)
+			}
+		}
+	}
+
+	return nil
+}
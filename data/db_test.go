@@ -0,0 +1,105 @@
+package data
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+)
+
+const testSchemaDB = "testSchemaDB.sqlite"
+
+// TestCheckSchema checks that a database is only reported ready once every
+// table has been created and upgraded.
+func TestCheckSchema(t *testing.T) {
+	testCases := []struct {
+		name    string
+		setup   func(db *sql.DB) error
+		wantErr bool
+	}{
+		{
+			name:    "Empty database is not ready",
+			setup:   func(db *sql.DB) error { return nil },
+			wantErr: true,
+		},
+		{
+			name: "Legacy users table is not ready",
+			setup: func(db *sql.DB) error {
+				if err := createAllTables(db); err != nil {
+					return err
+				}
+				_, err := db.Exec(`DROP TABLE users;
+					CREATE TABLE users (id integer NOT NULL PRIMARY KEY AUTOINCREMENT, name TEXT, password TEXT);`)
+				return err
+			},
+			wantErr: true,
+		},
+		{
+			name:    "Missing webhook deliveries table is not ready",
+			setup:   dropTable("webhook_deliveries"),
+			wantErr: true,
+		},
+		{
+			name:    "Missing webhooks table is not ready",
+			setup:   dropTable("webhooks"),
+			wantErr: true,
+		},
+		{
+			name:    "Missing set sync table is not ready",
+			setup:   dropTable("set_sync"),
+			wantErr: true,
+		},
+		{
+			name:    "Created tables are ready",
+			setup:   createAllTables,
+			wantErr: false,
+		},
+	}
+
+	for _, v := range testCases {
+		db, err := SqliteDB(testSchemaDB)
+		if err != nil {
+			t.Fatalf("Failed to setup test db: %v", err)
+		}
+		if err := v.setup(db); err != nil {
+			t.Fatalf("%s: failed to setup tables: %v", v.name, err)
+		}
+
+		err = CheckSchema(db)
+		db.Close()
+		os.Remove(testSchemaDB)
+
+		if (err != nil) != v.wantErr {
+			t.Fatalf("%s: wanted error: %v, got: %v", v.name, v.wantErr, err)
+		}
+	}
+}
+
+// createAllTables creates every table the server requires.
+func createAllTables(db *sql.DB) error {
+	if _, err := newSetDB(db); err != nil {
+		return err
+	}
+	if _, err := newUserDB(db); err != nil {
+		return err
+	}
+	if _, err := NewAuditDB(db); err != nil {
+		return err
+	}
+	if _, err := newIdempotencyDB(db); err != nil {
+		return err
+	}
+	_, err := newWebhookDB(db)
+	return err
+}
+
+// dropTable returns a setup that creates every table the server requires and
+// then drops the given one.
+func dropTable(table string) func(db *sql.DB) error {
+	return func(db *sql.DB) error {
+		if err := createAllTables(db); err != nil {
+			return err
+		}
+		_, err := db.Exec(`DROP TABLE ` + table)
+		return err
+	}
+}
@@ -1,6 +1,32 @@
 package data
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 // ErrNotFound should be returned when the resource does not exist.
 var ErrNotFound = errors.New("resource not found")
+
+// ErrVersionMismatch should be returned when a change was conditional on a
+// version of the resource that is no longer current.
+var ErrVersionMismatch = errors.New("resource version mismatch")
+
+// ErrInvalidSyncToken should be returned when a sync resumes from a token
+// that was never handed out.
+var ErrInvalidSyncToken = errors.New("invalid sync token")
+
+// BatchError is returned when an operation in a batch fails, and identifies the
+// failed operation by its index. None of the operations in the batch are applied.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch operation %v failed: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
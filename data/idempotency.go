@@ -0,0 +1,166 @@
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hrand1005/training-notebook/metrics"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+const (
+	createIdempotencyTable = `
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+		scope TEXT NOT NULL,
+		key TEXT NOT NULL,
+		fingerprint TEXT,
+		status INT,
+		header TEXT,
+		body BLOB,
+		created TEXT,
+		PRIMARY KEY (scope, key)
+	);
+	CREATE INDEX IF NOT EXISTS idempotency_keys_created ON idempotency_keys(created);
+	`
+	insertIdempotencyKey  = `INSERT OR IGNORE INTO idempotency_keys(scope, key, fingerprint, status, header, body, created) VALUES (?, ?, ?, 0, '', NULL, ?);`
+	selectIdempotencyKey  = `SELECT fingerprint, status, header, body, created FROM idempotency_keys WHERE scope=? AND key=?;`
+	completeIdempotency   = `UPDATE idempotency_keys SET status=?, header=?, body=? WHERE scope=? AND key=?;`
+	deleteIdempotencyKey  = `DELETE FROM idempotency_keys WHERE scope=? AND key=?;`
+	deleteExpiredKeys     = `DELETE FROM idempotency_keys WHERE created<?;`
+	idempotencyTimeLayout = auditTimeLayout
+)
+
+func init() {
+	requireTable("idempotency_keys")
+}
+
+// IdempotencyDB defines the interface for storing the responses to requests
+// made with idempotency keys.
+type IdempotencyDB interface {
+	Reserve(scope, key, fingerprint string, expiresBefore time.Time) (*models.IdempotencyRecord, bool, error)
+	Complete(rec *models.IdempotencyRecord) error
+	Release(scope, key string) error
+	Close() error
+}
+
+// idempotencyDB contains a handle to the underlying sql database, and implements IdempotencyDB
+type idempotencyDB struct {
+	handle *sql.DB
+}
+
+// NewIdempotencyDB prepares the idempotency key table on the given sql db
+// handle and returns an IdempotencyDB interface or error
+func NewIdempotencyDB(db *sql.DB) (IdempotencyDB, error) {
+	return newIdempotencyDB(db)
+}
+
+// newIdempotencyDB returns the underlying idempotencyDB and error created from the given sql db handle.
+func newIdempotencyDB(db *sql.DB) (*idempotencyDB, error) {
+	_, err := db.Exec(createIdempotencyTable)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't prepare SQL statement:\n%s\nerr: %v", createIdempotencyTable, err)
+	}
+
+	return &idempotencyDB{
+		handle: db,
+	}, nil
+}
+
+// Reserve implements the IdempotencyDB interface method for claiming an idempotency key.
+// Records created before expiresBefore are discarded first. If the key is unclaimed
+// within scope, a new in progress record is stored and returned with reserved true.
+// Otherwise the existing record is returned with reserved false.
+func (id *idempotencyDB) Reserve(scope, key, fingerprint string, expiresBefore time.Time) (*models.IdempotencyRecord, bool, error) {
+	defer metrics.ObserveQuery("Reserve")()
+	tx, err := id.handle.Begin()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(deleteExpiredKeys, expiresBefore.UTC().Format(idempotencyTimeLayout)); err != nil {
+		return nil, false, fmt.Errorf("error executing SQL statement: %v", err)
+	}
+
+	now := time.Now().UTC()
+	result, err := tx.Exec(insertIdempotencyKey, scope, key, fingerprint, now.Format(idempotencyTimeLayout))
+	if err != nil {
+		return nil, false, fmt.Errorf("error executing SQL statement: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, false, fmt.Errorf("encountered error checking rows affected: %v", err)
+	}
+
+	if rowsAffected == 1 {
+		if err := tx.Commit(); err != nil {
+			return nil, false, fmt.Errorf("failed to commit transaction: %v", err)
+		}
+		return &models.IdempotencyRecord{
+			Scope:       scope,
+			Key:         key,
+			Fingerprint: fingerprint,
+			CreatedAt:   now,
+		}, true, nil
+	}
+
+	rec := &models.IdempotencyRecord{Scope: scope, Key: key}
+	var header, created string
+	err = tx.QueryRow(selectIdempotencyKey, scope, key).Scan(&rec.Fingerprint, &rec.Status, &header, &rec.Body, &created)
+	if err != nil {
+		return nil, false, fmt.Errorf("error executing SQL query: %v", err)
+	}
+	if header != "" {
+		if err := json.Unmarshal([]byte(header), &rec.Header); err != nil {
+			return nil, false, fmt.Errorf("encountered error decoding stored header: %v", err)
+		}
+	}
+	if rec.CreatedAt, err = time.Parse(idempotencyTimeLayout, created); err != nil {
+		return nil, false, fmt.Errorf("encountered error parsing timestamp %q: %v", created, err)
+	}
+
+	return rec, false, nil
+}
+
+// Complete implements the IdempotencyDB interface method for storing the
+// response to a request whose key was reserved.
+// If the key is not reserved, returns ErrNotFound.
+func (id *idempotencyDB) Complete(rec *models.IdempotencyRecord) error {
+	defer metrics.ObserveQuery("Complete")()
+	header, err := json.Marshal(rec.Header)
+	if err != nil {
+		return fmt.Errorf("encountered error encoding header: %v", err)
+	}
+
+	result, err := id.handle.Exec(completeIdempotency, rec.Status, string(header), rec.Body, rec.Scope, rec.Key)
+	if err != nil {
+		return fmt.Errorf("error executing SQL statement: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("encountered error checking rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Release implements the IdempotencyDB interface method for giving up a reserved
+// key without storing a response, so the request may be retried.
+func (id *idempotencyDB) Release(scope, key string) error {
+	defer metrics.ObserveQuery("Release")()
+	if _, err := id.handle.Exec(deleteIdempotencyKey, scope, key); err != nil {
+		return fmt.Errorf("error executing SQL statement: %v", err)
+	}
+	return nil
+}
+
+// Close calls close on the underlying sql.DB
+func (id *idempotencyDB) Close() error {
+	return id.handle.Close()
+}
@@ -0,0 +1,109 @@
+package data
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// TestReserveAndComplete reserves idempotency keys, stores responses for them
+// and checks that later reservations return the stored records.
+func TestReserveAndComplete(t *testing.T) {
+	id := setupTestIdempotencyDB()
+	defer teardownTestIdempotencyDB(id)
+
+	longAgo := time.Now().Add(-time.Hour)
+
+	rec, reserved, err := id.Reserve("alice", "key-1", "fp-1", longAgo)
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if !reserved || rec.Status != 0 || rec.Fingerprint != "fp-1" {
+		t.Fatalf("Wanted new reservation, got reserved %v and record %+v", reserved, rec)
+	}
+
+	// a second reservation sees the request in progress
+	rec, reserved, err = id.Reserve("alice", "key-1", "fp-1", longAgo)
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if reserved || rec.Status != 0 {
+		t.Fatalf("Wanted in progress record, got reserved %v and record %+v", reserved, rec)
+	}
+
+	rec.Status = 201
+	rec.Header = map[string]string{"Content-Type": "application/json"}
+	rec.Body = []byte(`{"set-id":1}`)
+	if err := id.Complete(rec); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+
+	got, reserved, err := id.Reserve("alice", "key-1", "fp-2", longAgo)
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if reserved || got.Fingerprint != "fp-1" || got.Status != rec.Status ||
+		!reflect.DeepEqual(got.Header, rec.Header) || string(got.Body) != string(rec.Body) {
+		t.Fatalf("Wanted stored record:\n%+v\ngot reserved %v and record:\n%+v", rec, reserved, got)
+	}
+
+	// keys are scoped to the caller
+	if _, reserved, err := id.Reserve("bob", "key-1", "fp-1", longAgo); err != nil || !reserved {
+		t.Fatalf("Wanted new reservation in another scope, got reserved %v, err %v", reserved, err)
+	}
+
+	if err := id.Complete(&models.IdempotencyRecord{Scope: "carol", Key: "key-1", Status: 200}); err != ErrNotFound {
+		t.Fatalf("Wanted ErrNotFound completing unreserved key, got %v", err)
+	}
+}
+
+// TestReserveExpiredAndReleased checks that expired and released keys can be
+// reserved again.
+func TestReserveExpiredAndReleased(t *testing.T) {
+	id := setupTestIdempotencyDB()
+	defer teardownTestIdempotencyDB(id)
+
+	longAgo := time.Now().Add(-time.Hour)
+
+	if _, _, err := id.Reserve("alice", "key-1", "fp-1", longAgo); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if err := id.Release("alice", "key-1"); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if _, reserved, err := id.Reserve("alice", "key-1", "fp-2", longAgo); err != nil || !reserved {
+		t.Fatalf("Wanted reservation of released key, got reserved %v, err %v", reserved, err)
+	}
+
+	// every record created before the cutoff has expired
+	if _, reserved, err := id.Reserve("alice", "key-1", "fp-3", time.Now().Add(time.Minute)); err != nil || !reserved {
+		t.Fatalf("Wanted reservation of expired key, got reserved %v, err %v", reserved, err)
+	}
+}
+
+const testIdempotencyDB = "testIdempotencyDB.sqlite"
+
+func setupTestIdempotencyDB() *idempotencyDB {
+	db, err := SqliteDB(testIdempotencyDB)
+	if err != nil {
+		msg := fmt.Sprintf("failed to setup test db: %v, err: %v", testIdempotencyDB, err)
+		panic(msg)
+	}
+
+	id, err := newIdempotencyDB(db)
+	if err != nil {
+		msg := fmt.Sprintf("failed to setup test db: %v, err: %v", testIdempotencyDB, err)
+		panic(msg)
+	}
+
+	return id
+}
+
+func teardownTestIdempotencyDB(id *idempotencyDB) {
+	id.handle.Close()
+	os.Remove(testIdempotencyDB)
+}
@@ -0,0 +1,22 @@
+package data
+
+import "github.com/hrand1005/training-notebook/models"
+
+// MockAuditDB is a manually implemented mock of the AuditDB interface for testing
+type MockAuditDB struct {
+	AddEventStub func(e *models.AuditEvent) (int64, error)
+	EventsStub   func(f models.AuditFilter) ([]*models.AuditEvent, error)
+	CloseStub    func() error
+}
+
+func (m *MockAuditDB) AddEvent(e *models.AuditEvent) (int64, error) {
+	return m.AddEventStub(e)
+}
+
+func (m *MockAuditDB) Events(f models.AuditFilter) ([]*models.AuditEvent, error) {
+	return m.EventsStub(f)
+}
+
+func (m *MockAuditDB) Close() error {
+	return m.CloseStub()
+}
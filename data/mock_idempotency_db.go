@@ -0,0 +1,31 @@
+package data
+
+import (
+	"time"
+
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// MockIdempotencyDB is a manually implemented mock of the IdempotencyDB interface for testing
+type MockIdempotencyDB struct {
+	ReserveStub  func(scope, key, fingerprint string, expiresBefore time.Time) (*models.IdempotencyRecord, bool, error)
+	CompleteStub func(rec *models.IdempotencyRecord) error
+	ReleaseStub  func(scope, key string) error
+	CloseStub    func() error
+}
+
+func (m *MockIdempotencyDB) Reserve(scope, key, fingerprint string, expiresBefore time.Time) (*models.IdempotencyRecord, bool, error) {
+	return m.ReserveStub(scope, key, fingerprint, expiresBefore)
+}
+
+func (m *MockIdempotencyDB) Complete(rec *models.IdempotencyRecord) error {
+	return m.CompleteStub(rec)
+}
+
+func (m *MockIdempotencyDB) Release(scope, key string) error {
+	return m.ReleaseStub(scope, key)
+}
+
+func (m *MockIdempotencyDB) Close() error {
+	return m.CloseStub()
+}
@@ -4,16 +4,21 @@ import "github.com/hrand1005/training-notebook/models"
 
 // MockSetDB is my crack at manually implementing a Mock interface for testing
 type MockSetDB struct {
-	AddSetStub           func(s *models.Set) (models.SetID, error)
-	SetsStub             func() ([]*models.Set, error)
-	SetsByUserIDStub     func(models.UserID) ([]*models.Set, error)
-	SetByIDStub          func(id models.SetID) (*models.Set, error)
-	SetByIDForUserStub   func(models.SetID, models.UserID) (*models.Set, error)
-	UpdateSetStub        func(id models.SetID, s *models.Set) error
-	UpdateSetForUserStub func(setID models.SetID, userID models.UserID, s *models.Set) error
-	DeleteSetStub        func(id models.SetID) error
-	DeleteSetForUserStub func(setID models.SetID, userID models.UserID) error
-	CloseStub            func() error
+	AddSetStub                    func(s *models.Set) (models.SetID, error)
+	SetsStub                      func() ([]*models.Set, error)
+	SetsByUserIDStub              func(models.UserID) ([]*models.Set, error)
+	SetsByUserIDsStub             func([]models.UserID) ([]*models.Set, error)
+	SetByIDStub                   func(id models.SetID) (*models.Set, error)
+	SetByIDForUserStub            func(models.SetID, models.UserID) (*models.Set, error)
+	UpdateSetStub                 func(id models.SetID, s *models.Set) error
+	UpdateSetForUserStub          func(setID models.SetID, userID models.UserID, s *models.Set) error
+	DeleteSetStub                 func(id models.SetID) error
+	DeleteSetForUserStub          func(setID models.SetID, userID models.UserID) error
+	DeleteSetForUserAtVersionStub func(setID models.SetID, userID models.UserID, version int) error
+	BatchForUserStub              func(userID models.UserID, ops []*models.SetOperation) error
+	SyncForUserStub               func(userID models.UserID, since int64, changes []*models.SyncChange, limit int) (*models.SyncResult, error)
+	AuditedByStub                 func(actor Actor) SetDB
+	CloseStub                     func() error
 }
 
 func (m *MockSetDB) AddSet(s *models.Set) (models.SetID, error) {
@@ -28,6 +33,10 @@ func (m *MockSetDB) SetsByUserID(id models.UserID) ([]*models.Set, error) {
 	return m.SetsByUserIDStub(id)
 }
 
+func (m *MockSetDB) SetsByUserIDs(ids []models.UserID) ([]*models.Set, error) {
+	return m.SetsByUserIDsStub(ids)
+}
+
 func (m *MockSetDB) SetByID(id models.SetID) (*models.Set, error) {
 	return m.SetByIDStub(id)
 }
@@ -52,6 +61,26 @@ func (m *MockSetDB) DeleteSetForUser(setID models.SetID, userID models.UserID) e
 	return m.DeleteSetForUserStub(setID, userID)
 }
 
+func (m *MockSetDB) DeleteSetForUserAtVersion(setID models.SetID, userID models.UserID, version int) error {
+	return m.DeleteSetForUserAtVersionStub(setID, userID, version)
+}
+
+func (m *MockSetDB) BatchForUser(userID models.UserID, ops []*models.SetOperation) error {
+	return m.BatchForUserStub(userID, ops)
+}
+
+func (m *MockSetDB) SyncForUser(userID models.UserID, since int64, changes []*models.SyncChange, limit int) (*models.SyncResult, error) {
+	return m.SyncForUserStub(userID, since, changes, limit)
+}
+
+// AuditedBy returns the result of AuditedByStub, or the mock itself if it is nil.
+func (m *MockSetDB) AuditedBy(actor Actor) SetDB {
+	if m.AuditedByStub == nil {
+		return m
+	}
+	return m.AuditedByStub(actor)
+}
+
 func (m *MockSetDB) Close() error {
 	return m.CloseStub()
 }
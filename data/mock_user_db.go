@@ -4,12 +4,15 @@ import "github.com/hrand1005/training-notebook/models"
 
 // MockUserDB is my crack at manually implementing a Mock interface for testing
 type MockUserDB struct {
-	AddUserStub    func(*models.User) (models.UserID, error)
-	UsersStub      func() ([]*models.User, error)
-	UserByIDStub   func(models.UserID) (*models.User, error)
-	UpdateUserStub func(models.UserID, *models.User) error
-	DeleteUserStub func(models.UserID) error
-	CloseStub      func() error
+	AddUserStub        func(*models.User) (models.UserID, error)
+	UsersStub          func() ([]*models.User, error)
+	UserByIDStub       func(models.UserID) (*models.User, error)
+	UsersByIDsStub     func([]models.UserID) ([]*models.User, error)
+	UpdateUserStub     func(models.UserID, *models.User) error
+	UpdatePasswordStub func(models.UserID, string) error
+	DeleteUserStub     func(models.UserID) error
+	AuditedByStub      func(actor Actor) UserDB
+	CloseStub          func() error
 }
 
 func (m *MockUserDB) AddUser(s *models.User) (models.UserID, error) {
@@ -24,14 +27,30 @@ func (m *MockUserDB) UserByID(id models.UserID) (*models.User, error) {
 	return m.UserByIDStub(id)
 }
 
+func (m *MockUserDB) UsersByIDs(ids []models.UserID) ([]*models.User, error) {
+	return m.UsersByIDsStub(ids)
+}
+
 func (m *MockUserDB) UpdateUser(id models.UserID, u *models.User) error {
 	return m.UpdateUserStub(id, u)
 }
 
+func (m *MockUserDB) UpdatePassword(id models.UserID, hash string) error {
+	return m.UpdatePasswordStub(id, hash)
+}
+
 func (m *MockUserDB) DeleteUser(id models.UserID) error {
 	return m.DeleteUserStub(id)
 }
 
+// AuditedBy returns the result of AuditedByStub, or the mock itself if it is nil.
+func (m *MockUserDB) AuditedBy(actor Actor) UserDB {
+	if m.AuditedByStub == nil {
+		return m
+	}
+	return m.AuditedByStub(actor)
+}
+
 func (m *MockUserDB) Close() error {
 	return m.CloseStub()
 }
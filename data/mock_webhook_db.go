@@ -0,0 +1,61 @@
+package data
+
+import (
+	"time"
+
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// MockWebhookDB is a manually implemented mock of the WebhookDB interface for testing
+type MockWebhookDB struct {
+	AddWebhookStub      func(w *models.Webhook) (models.WebhookID, error)
+	WebhookByIDStub     func(id models.WebhookID) (*models.Webhook, error)
+	WebhooksByOwnerStub func(ownerID models.UserID) ([]*models.Webhook, error)
+	WebhooksForStub     func(eventType string, ownerID models.UserID) ([]*models.Webhook, error)
+	DeleteWebhookStub   func(id models.WebhookID) error
+	AddDeliveryStub     func(d *models.WebhookDelivery) (int64, error)
+	DueDeliveriesStub   func(now time.Time, limit int) ([]*models.WebhookDelivery, error)
+	UpdateDeliveryStub  func(d *models.WebhookDelivery) error
+	DeliveriesStub      func(webhookID models.WebhookID, limit int) ([]*models.WebhookDelivery, error)
+	CloseStub           func() error
+}
+
+func (m *MockWebhookDB) AddWebhook(w *models.Webhook) (models.WebhookID, error) {
+	return m.AddWebhookStub(w)
+}
+
+func (m *MockWebhookDB) WebhookByID(id models.WebhookID) (*models.Webhook, error) {
+	return m.WebhookByIDStub(id)
+}
+
+func (m *MockWebhookDB) WebhooksByOwner(ownerID models.UserID) ([]*models.Webhook, error) {
+	return m.WebhooksByOwnerStub(ownerID)
+}
+
+func (m *MockWebhookDB) WebhooksFor(eventType string, ownerID models.UserID) ([]*models.Webhook, error) {
+	return m.WebhooksForStub(eventType, ownerID)
+}
+
+func (m *MockWebhookDB) DeleteWebhook(id models.WebhookID) error {
+	return m.DeleteWebhookStub(id)
+}
+
+func (m *MockWebhookDB) AddDelivery(d *models.WebhookDelivery) (int64, error) {
+	return m.AddDeliveryStub(d)
+}
+
+func (m *MockWebhookDB) DueDeliveries(now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	return m.DueDeliveriesStub(now, limit)
+}
+
+func (m *MockWebhookDB) UpdateDelivery(d *models.WebhookDelivery) error {
+	return m.UpdateDeliveryStub(d)
+}
+
+func (m *MockWebhookDB) Deliveries(webhookID models.WebhookID, limit int) ([]*models.WebhookDelivery, error) {
+	return m.DeliveriesStub(webhookID, limit)
+}
+
+func (m *MockWebhookDB) Close() error {
+	return m.CloseStub()
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 
+	"github.com/hrand1005/training-notebook/metrics"
 	"github.com/hrand1005/training-notebook/models"
 )
 
@@ -17,20 +18,35 @@ const (
 		userid INT,
 		movement TEXT,
 		volume FLOAT,
-		intensity FLOAT
+		intensity FLOAT,
+		version INTEGER NOT NULL DEFAULT 1
 	);
 	`
 	insertSet              = `INSERT OR IGNORE INTO sets(userid, movement, volume, intensity) VALUES (?, ?, ?, ?);`
-	selectSetByID          = `SELECT userid, movement, volume, intensity FROM sets WHERE id=?;`
-	selectSetByIDAndUserID = `SELECT movement, volume, intensity FROM sets WHERE id=? AND userid=?;`
-	selectAllSets          = `SELECT id, userid, movement, volume, intensity FROM sets;`
-	selectSetsByUserID     = `SELECT id, movement, volume, intensity FROM sets WHERE userid=?;`
-	updateSetByID          = `UPDATE sets SET movement=?, volume=?, intensity=? WHERE id=?;`
-	updateSetByIDAndUserID = `UPDATE sets SET movement=?, volume=?, intensity=? WHERE id=? AND userid=?;`
-	deleteSetByID          = `DELETE FROM sets WHERE id=?;`
-	deleteSetByIDAndUserID = `DELETE FROM sets WHERE id=? AND userid=?;`
+	selectSetByID          = `SELECT userid, movement, volume, intensity, version FROM sets WHERE id=?;`
+	selectSetByIDAndUserID = `SELECT movement, volume, intensity, version FROM sets WHERE id=? AND userid=?;`
+	selectAllSets          = `SELECT id, userid, movement, volume, intensity, version FROM sets;`
+	selectSetsByUserID     = `SELECT id, movement, volume, intensity, version FROM sets WHERE userid=?;`
+	selectSetVersion       = `SELECT version FROM sets WHERE id=? AND userid=?;`
+	selectAnySetVersion    = `SELECT version FROM sets WHERE id=?;`
+	// updates and deletes only apply at the given version, unless it is zero.
+	// The statements for a user always bind userid, so that a missing user id
+	// never matches the sets of every user; only the admin paths use the
+	// unscoped statements.
+	updateSetStmt = `UPDATE sets SET movement=?, volume=?, intensity=?, version=version+1
+		WHERE id=? AND userid=? AND (?=0 OR version=?) RETURNING version;`
+	updateAnySetStmt = `UPDATE sets SET movement=?, volume=?, intensity=?, version=version+1
+		WHERE id=? AND (?=0 OR version=?) RETURNING version;`
+	deleteSetStmt    = `DELETE FROM sets WHERE id=? AND userid=? AND (?=0 OR version=?);`
+	deleteAnySetStmt = `DELETE FROM sets WHERE id=? AND (?=0 OR version=?);`
+	// the placeholders of the in clause are filled in for the number of users
+	selectSetsByUserIDs = `SELECT id, userid, movement, volume, intensity, version FROM sets WHERE userid IN (%s) ORDER BY id;`
 )
 
+// setsTable holds the sets. Tables created before versioning existed lack
+// the version column.
+var setsTable = requireTable("sets", column{"version", "INTEGER NOT NULL DEFAULT 1"})
+
 // SetDB defines the interface for accessing/manipulating set data
 // Naive implementation duplicates logic for slightly different where clauses
 // TODO: smarter filtering to reduce duplicate code
@@ -38,18 +54,25 @@ type SetDB interface {
 	AddSet(s *models.Set) (models.SetID, error)
 	Sets() ([]*models.Set, error)
 	SetsByUserID(models.UserID) ([]*models.Set, error)
+	SetsByUserIDs([]models.UserID) ([]*models.Set, error)
 	SetByID(id models.SetID) (*models.Set, error)
 	SetByIDForUser(models.SetID, models.UserID) (*models.Set, error)
 	UpdateSet(id models.SetID, s *models.Set) error
 	UpdateSetForUser(setID models.SetID, userID models.UserID, s *models.Set) error
 	DeleteSet(id models.SetID) error
 	DeleteSetForUser(setID models.SetID, userID models.UserID) error
+	DeleteSetForUserAtVersion(setID models.SetID, userID models.UserID, version int) error
+	BatchForUser(userID models.UserID, ops []*models.SetOperation) error
+	SyncForUser(userID models.UserID, since int64, changes []*models.SyncChange, limit int) (*models.SyncResult, error)
+	AuditedBy(actor Actor) SetDB
 	Close() error
 }
 
-// setDB contains a handle to the underlying sql database, and implements SetDB
+// setDB contains a handle to the underlying sql database, and implements SetDB.
+// Changes are recorded in the audit log if actor is set.
 type setDB struct {
 	handle *sql.DB
+	actor  *Actor
 }
 
 // NewSetDB loads the data from the given sql db handle and returns a SetDB interface or error
@@ -64,6 +87,14 @@ func newSetDB(db *sql.DB) (*setDB, error) {
 		return nil, fmt.Errorf("couldn't prepare SQL statement:\n%s\nerr: %v", createSetTable, err)
 	}
 
+	if err := setsTable.upgrade(db); err != nil {
+		return nil, err
+	}
+
+	if err := createSyncTracking(db); err != nil {
+		return nil, err
+	}
+
 	return &setDB{
 		handle: db,
 	}, nil
@@ -74,7 +105,27 @@ func newSetDB(db *sql.DB) (*setDB, error) {
 // Returns the assigned id upon successfully inserting the provided set, and nil error.
 // If an error occurs, returns -1 for the id and the error value.
 func (sd *setDB) AddSet(s *models.Set) (models.SetID, error) {
-	result, err := sd.handle.Exec(insertSet, s.UID, s.Movement, s.Volume, s.Intensity)
+	defer metrics.ObserveQuery("AddSet")()
+	id := InvalidSetID
+	err := audited(sd.handle, sd.actor, func(ex execer) ([]change, error) {
+		var err error
+		if id, err = addSet(ex, s); err != nil {
+			return nil, err
+		}
+		created := *s
+		created.ID = id
+		return []change{setChange(models.AuditCreate, id, s.UID, nil, &created)}, nil
+	})
+	if err != nil {
+		return InvalidSetID, err
+	}
+
+	return id, nil
+}
+
+// addSet performs AddSet using the given execer.
+func addSet(ex execer, s *models.Set) (models.SetID, error) {
+	result, err := ex.Exec(insertSet, s.UID, s.Movement, s.Volume, s.Intensity)
 	if err != nil {
 		return InvalidSetID, fmt.Errorf("encountered error executing SQL statement: %v", err)
 	}
@@ -84,12 +135,15 @@ func (sd *setDB) AddSet(s *models.Set) (models.SetID, error) {
 		return InvalidSetID, fmt.Errorf("encountered error retrieving last inserted id: %v", err)
 	}
 
+	s.Version = 1
+
 	return models.SetID(setID), nil
 }
 
 // Sets implements the SetDB interface method for retrieving all sets from the database.
 // An empty slice of sets is considered a valid result of the database query.
 func (sd *setDB) Sets() ([]*models.Set, error) {
+	defer metrics.ObserveQuery("Sets")()
 	rows, err := sd.handle.Query(selectAllSets)
 	if err != nil {
 		return nil, fmt.Errorf("error executing SQL query: %v", err)
@@ -103,7 +157,8 @@ func (sd *setDB) Sets() ([]*models.Set, error) {
 		var movement string
 		var volume float64
 		var intensity float64
-		if err := rows.Scan(&id, &userID, &movement, &volume, &intensity); err != nil {
+		var version int
+		if err := rows.Scan(&id, &userID, &movement, &volume, &intensity, &version); err != nil {
 			return nil, fmt.Errorf("encountered error scanning row: %v", err)
 		}
 
@@ -113,6 +168,7 @@ func (sd *setDB) Sets() ([]*models.Set, error) {
 			Movement:  movement,
 			Volume:    volume,
 			Intensity: intensity,
+			Version:   version,
 		})
 	}
 
@@ -127,6 +183,7 @@ func (sd *setDB) Sets() ([]*models.Set, error) {
 // Returns sets with the matching UserID from the database.
 // An empty slice of sets is considered a valid result of the database query.
 func (sd *setDB) SetsByUserID(userID models.UserID) ([]*models.Set, error) {
+	defer metrics.ObserveQuery("SetsByUserID")()
 	rows, err := sd.handle.Query(selectSetsByUserID, userID)
 	if err != nil {
 		return nil, fmt.Errorf("error executing SQL query: %v", err)
@@ -139,7 +196,8 @@ func (sd *setDB) SetsByUserID(userID models.UserID) ([]*models.Set, error) {
 		var movement string
 		var volume float64
 		var intensity float64
-		if err := rows.Scan(&id, &movement, &volume, &intensity); err != nil {
+		var version int
+		if err := rows.Scan(&id, &movement, &volume, &intensity, &version); err != nil {
 			return nil, fmt.Errorf("encountered error scanning row: %v", err)
 		}
 
@@ -149,21 +207,65 @@ func (sd *setDB) SetsByUserID(userID models.UserID) ([]*models.Set, error) {
 			Movement:  movement,
 			Volume:    volume,
 			Intensity: intensity,
+			Version:   version,
 		})
 	}
 
 	return sets, nil
 }
 
+// SetsByUserIDs implements the SetDB interface method for retrieving the sets of many users
+// in a single query. Returns the sets owned by any of the given users, ordered by id.
+// An empty slice of sets is considered a valid result of the database query.
+func (sd *setDB) SetsByUserIDs(userIDs []models.UserID) ([]*models.Set, error) {
+	defer metrics.ObserveQuery("SetsByUserIDs")()
+	sets := make([]*models.Set, 0, 10)
+	if len(userIDs) == 0 {
+		return sets, nil
+	}
+
+	args := make([]interface{}, len(userIDs))
+	for i, id := range userIDs {
+		args[i] = id
+	}
+	query := fmt.Sprintf(selectSetsByUserIDs, placeholders(len(userIDs)))
+	rows, err := sd.handle.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing SQL query: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s := &models.Set{}
+		if err := rows.Scan(&s.ID, &s.UID, &s.Movement, &s.Volume, &s.Intensity, &s.Version); err != nil {
+			return nil, fmt.Errorf("encountered error scanning row: %v", err)
+		}
+		sets = append(sets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("encountered error after scanning rows: %v", err)
+	}
+
+	return sets, nil
+}
+
 // SetByID implements the SetDB interface method for finding a particular set in the database.
 // Returns a set matching the given ID in the database.
 // If no set with the given id is found, returns ErrNotFound.
 func (sd *setDB) SetByID(id models.SetID) (*models.Set, error) {
+	defer metrics.ObserveQuery("SetByID")()
+	return setByID(sd.handle, id)
+}
+
+// setByID performs SetByID using the given execer.
+func setByID(ex execer, id models.SetID) (*models.Set, error) {
 	var userID int
 	var movement string
 	var volume float64
 	var intensity float64
-	err := sd.handle.QueryRow(selectSetByID, id).Scan(&userID, &movement, &volume, &intensity)
+	var version int
+	err := ex.QueryRow(selectSetByID, id).Scan(&userID, &movement, &volume, &intensity, &version)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
@@ -177,6 +279,7 @@ func (sd *setDB) SetByID(id models.SetID) (*models.Set, error) {
 		Movement:  movement,
 		Volume:    volume,
 		Intensity: intensity,
+		Version:   version,
 	}, nil
 }
 
@@ -184,10 +287,17 @@ func (sd *setDB) SetByID(id models.SetID) (*models.Set, error) {
 // Returns a set matching the given ID in the database.
 // If no set with the given id is found, returns ErrNotFound.
 func (sd *setDB) SetByIDForUser(setID models.SetID, userID models.UserID) (*models.Set, error) {
+	defer metrics.ObserveQuery("SetByIDForUser")()
+	return setByIDForUser(sd.handle, setID, userID)
+}
+
+// setByIDForUser performs SetByIDForUser using the given execer.
+func setByIDForUser(ex execer, setID models.SetID, userID models.UserID) (*models.Set, error) {
 	var movement string
 	var volume float64
 	var intensity float64
-	err := sd.handle.QueryRow(selectSetByIDAndUserID, setID, userID).Scan(&movement, &volume, &intensity)
+	var version int
+	err := ex.QueryRow(selectSetByIDAndUserID, setID, userID).Scan(&movement, &volume, &intensity, &version)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
@@ -201,53 +311,70 @@ func (sd *setDB) SetByIDForUser(setID models.SetID, userID models.UserID) (*mode
 		Movement:  movement,
 		Volume:    volume,
 		Intensity: intensity,
+		Version:   version,
 	}, nil
 }
 
 // UpdateSet implements the SetDB interface method for updating a particular set in the database.
 // Updates the columns of the set matching the given id with the fields of the given set.
+// If s.Version is non-zero, the update only applies if the stored set is at that version,
+// and otherwise returns ErrVersionMismatch. On success, s.Version is set to the new version.
 // If no set with the given id is found, returns ErrNotFound.
 func (sd *setDB) UpdateSet(id models.SetID, s *models.Set) error {
-	result, err := sd.handle.Exec(updateSetByID, s.Movement, s.Volume, s.Intensity, id)
-	if err != nil {
-		return fmt.Errorf("failed to update set: %v", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get RowsAffected by update: %v", err)
-	}
-
-	if rowsAffected != 1 {
-		if rowsAffected == 0 {
-			return ErrNotFound
+	defer metrics.ObserveQuery("UpdateSet")()
+	return audited(sd.handle, sd.actor, func(ex execer) ([]change, error) {
+		before, err := setByID(ex, id)
+		if err != nil {
+			return nil, err
 		}
-		return fmt.Errorf("unexpected number of affected rows: %v", rowsAffected)
-	}
-
-	return nil
+		row := ex.QueryRow(updateAnySetStmt, s.Movement, s.Volume, s.Intensity, id, s.Version, s.Version)
+		err = scanUpdatedVersion(row, s, func() error {
+			return missingSetError(ex, selectAnySetVersion, id)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return []change{setUpdated(before, s)}, nil
+	})
 }
 
 // UpdateSetForUser implements the SetDB interface method for updating a particular set in the database.
 // UpdateSetForUser performs UpdateSet where userid equals the userID param.
 func (sd *setDB) UpdateSetForUser(setID models.SetID, userID models.UserID, s *models.Set) error {
-	result, err := sd.handle.Exec(updateSetByIDAndUserID, s.Movement, s.Volume, s.Intensity, setID, userID)
-	if err != nil {
-		return fmt.Errorf("failed to update set: %v", err)
-	}
+	defer metrics.ObserveQuery("UpdateSetForUser")()
+	return audited(sd.handle, sd.actor, func(ex execer) ([]change, error) {
+		before, err := setByIDForUser(ex, setID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if err := updateSet(ex, setID, userID, s); err != nil {
+			return nil, err
+		}
+		return []change{setUpdated(before, s)}, nil
+	})
+}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get RowsAffected by update: %v", err)
-	}
+// updateSet performs UpdateSetForUser using the given execer.
+func updateSet(ex execer, setID models.SetID, userID models.UserID, s *models.Set) error {
+	row := ex.QueryRow(updateSetStmt, s.Movement, s.Volume, s.Intensity, setID, userID, s.Version, s.Version)
+	return scanUpdatedVersion(row, s, func() error {
+		return missingSetError(ex, selectSetVersion, setID, userID)
+	})
+}
 
-	if rowsAffected != 1 {
-		if rowsAffected == 0 {
-			return ErrNotFound
+// scanUpdatedVersion sets s.Version to the version returned by an update, or
+// returns the error of missing if the update did not apply.
+func scanUpdatedVersion(row *sql.Row, s *models.Set, missing func() error) error {
+	var version int
+	if err := row.Scan(&version); err != nil {
+		if err == sql.ErrNoRows {
+			return missing()
 		}
-		return fmt.Errorf("unexpected number of affected rows: %v", rowsAffected)
+		return fmt.Errorf("failed to update set: %v", err)
 	}
 
+	s.Version = version
+
 	return nil
 }
 
@@ -255,7 +382,63 @@ func (sd *setDB) UpdateSetForUser(setID models.SetID, userID models.UserID, s *m
 // Deletes the record of the set matching the given id.
 // If no set with the given id is found, returns ErrNotFound.
 func (sd *setDB) DeleteSet(id models.SetID) error {
-	result, err := sd.handle.Exec(deleteSetByID, id)
+	defer metrics.ObserveQuery("DeleteSet")()
+	return audited(sd.handle, sd.actor, func(ex execer) ([]change, error) {
+		before, err := setByID(ex, id)
+		if err != nil {
+			return nil, err
+		}
+		result, err := ex.Exec(deleteAnySetStmt, id, 0, 0)
+		err = checkDeleted(result, err, func() error {
+			return missingSetError(ex, selectAnySetVersion, id)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return []change{setChange(models.AuditDelete, id, before.UID, before, nil)}, nil
+	})
+}
+
+// DeleteSetForUser performs DeleteSet where userid equals userID param
+func (sd *setDB) DeleteSetForUser(setID models.SetID, userID models.UserID) error {
+	defer metrics.ObserveQuery("DeleteSetForUser")()
+	return sd.deleteForUser(setID, userID, 0)
+}
+
+// DeleteSetForUserAtVersion performs DeleteSetForUser only if the stored set is
+// at the given version, and otherwise returns ErrVersionMismatch.
+func (sd *setDB) DeleteSetForUserAtVersion(setID models.SetID, userID models.UserID, version int) error {
+	defer metrics.ObserveQuery("DeleteSetForUserAtVersion")()
+	return sd.deleteForUser(setID, userID, version)
+}
+
+// deleteForUser performs DeleteSetForUserAtVersion, at any version if version
+// is zero.
+func (sd *setDB) deleteForUser(setID models.SetID, userID models.UserID, version int) error {
+	return audited(sd.handle, sd.actor, func(ex execer) ([]change, error) {
+		before, err := setByIDForUser(ex, setID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if err := deleteSet(ex, setID, userID, version); err != nil {
+			return nil, err
+		}
+		return []change{setChange(models.AuditDelete, setID, userID, before, nil)}, nil
+	})
+}
+
+// deleteSet performs DeleteSetForUserAtVersion using the given execer, at any
+// version if version is zero.
+func deleteSet(ex execer, setID models.SetID, userID models.UserID, version int) error {
+	result, err := ex.Exec(deleteSetStmt, setID, userID, version, version)
+	return checkDeleted(result, err, func() error {
+		return missingSetError(ex, selectSetVersion, setID, userID)
+	})
+}
+
+// checkDeleted checks that the delete statement with the given result deleted
+// exactly one set, and returns the error of missing if it deleted none.
+func checkDeleted(result sql.Result, err error, missing func() error) error {
 	if err != nil {
 		return fmt.Errorf("error executing SQL statement: %v", err)
 	}
@@ -266,7 +449,7 @@ func (sd *setDB) DeleteSet(id models.SetID) error {
 	}
 	if rowsAffected != 1 {
 		if rowsAffected == 0 {
-			return ErrNotFound
+			return missing()
 		}
 		return fmt.Errorf("unexpected number of affected rows: %v", rowsAffected)
 	}
@@ -274,27 +457,140 @@ func (sd *setDB) DeleteSet(id models.SetID) error {
 	return nil
 }
 
-// DeleteSetForUser performs DeleteSet where userid equals userID param
-func (sd *setDB) DeleteSetForUser(setID models.SetID, userID models.UserID) error {
-	result, err := sd.handle.Exec(deleteSetByIDAndUserID, setID, userID)
+// missingSetError explains why a versioned statement did not affect a set:
+// either the set does not exist, or it exists at a different version. query
+// selects the version of the set with the given args.
+func missingSetError(ex execer, query string, args ...interface{}) error {
+	var version int
+	err := ex.QueryRow(query, args...).Scan(&version)
 	if err != nil {
-		return fmt.Errorf("error executing SQL statement: %v", err)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		return fmt.Errorf("error executing SQL query: %v", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	return ErrVersionMismatch
+}
+
+// BatchForUser implements the SetDB interface method for applying several operations
+// on the sets of a user in a single transaction. Either every operation is applied,
+// or none are and a *BatchError identifying the failed operation is returned.
+// Created and updated sets are written back to each operation's Set, and the state
+// of updated or deleted sets before the batch is set in each operation's Previous.
+func (sd *setDB) BatchForUser(userID models.UserID, ops []*models.SetOperation) error {
+	defer metrics.ObserveQuery("BatchForUser")()
+	tx, err := sd.handle.Begin()
 	if err != nil {
-		return fmt.Errorf("encountered error checking rows affected: %v", err)
+		return fmt.Errorf("failed to begin transaction: %v", err)
 	}
-	if rowsAffected != 1 {
-		if rowsAffected == 0 {
-			return ErrNotFound
+
+	for i, op := range ops {
+		if err := applySetOperation(tx, userID, op); err != nil {
+			tx.Rollback()
+			return &BatchError{Index: i, Err: err}
 		}
-		return fmt.Errorf("unexpected number of affected rows: %v", rowsAffected)
+	}
+
+	if err := sd.actor.record(tx, operationChanges(ops)...); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
 	return nil
 }
 
+// applySetOperation applies a single batch operation using the given execer.
+func applySetOperation(ex execer, userID models.UserID, op *models.SetOperation) error {
+	switch op.Op {
+	case models.SetOpCreate:
+		op.Set.UID = userID
+		id, err := addSet(ex, op.Set)
+		if err != nil {
+			return err
+		}
+		op.Set.ID = id
+		op.SetID = id
+		return nil
+
+	case models.SetOpUpdate:
+		previous, err := setByIDForUser(ex, op.SetID, userID)
+		if err != nil {
+			return err
+		}
+		op.Set.Version = op.Version
+		if err := updateSet(ex, op.SetID, userID, op.Set); err != nil {
+			return err
+		}
+		op.Previous = previous
+		op.Set.ID = op.SetID
+		op.Set.UID = userID
+		return nil
+
+	case models.SetOpDelete:
+		previous, err := setByIDForUser(ex, op.SetID, userID)
+		if err != nil {
+			return err
+		}
+		if err := deleteSet(ex, op.SetID, userID, op.Version); err != nil {
+			return err
+		}
+		op.Previous = previous
+		return nil
+	}
+
+	return fmt.Errorf("unknown set operation %q", op.Op)
+}
+
+// operationChanges returns the changes made by the applied set operations.
+func operationChanges(ops []*models.SetOperation) []change {
+	changes := make([]change, 0, len(ops))
+	for _, op := range ops {
+		switch op.Op {
+		case models.SetOpCreate:
+			changes = append(changes, setChange(models.AuditCreate, op.SetID, op.Set.UID, nil, op.Set))
+		case models.SetOpUpdate:
+			changes = append(changes, setChange(models.AuditUpdate, op.SetID, op.Previous.UID, op.Previous, op.Set))
+		case models.SetOpDelete:
+			changes = append(changes, setChange(models.AuditDelete, op.SetID, op.Previous.UID, op.Previous, nil))
+		}
+	}
+	return changes
+}
+
+// setChange returns the change to the set with the given id owned by ownerID
+// from before to after, either of which is nil for creations and deletions.
+func setChange(action models.AuditAction, setID models.SetID, ownerID models.UserID, before, after *models.Set) change {
+	c := change{action: action, target: models.AuditTargetSet, targetID: int(setID), ownerID: ownerID}
+	if before != nil {
+		c.before = before
+	}
+	if after != nil {
+		c.after = after
+	}
+	return c
+}
+
+// setUpdated returns the change of the stored set before to s.
+func setUpdated(before, s *models.Set) change {
+	after := *s
+	after.ID, after.UID = before.ID, before.UID
+	return setChange(models.AuditUpdate, before.ID, before.UID, before, &after)
+}
+
+// AuditedBy implements the SetDB interface method for recording changes in
+// the audit log. Changes made through the returned SetDB are recorded as made
+// by actor, in the same transaction as the change, so that neither is kept if
+// the other fails. The audit log must have been prepared on the same sql db
+// handle with NewAuditDB.
+func (sd *setDB) AuditedBy(actor Actor) SetDB {
+	return &setDB{handle: sd.handle, actor: &actor}
+}
+
 // Close calls close on the underlying sql.DB
 func (sd *setDB) Close() error {
 	return sd.handle.Close()
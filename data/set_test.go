@@ -311,8 +311,220 @@ func TestDeleteSet(t *testing.T) {
 	}
 }
 
+// TestSetVersions checks that updates increment a set's version, and that
+// versioned updates and deletes only apply at the current version.
+func TestSetVersions(t *testing.T) {
+	sd := setupTestSetDB()
+	defer teardownTestSetDB(sd)
+
+	s := &models.Set{UID: 1, Movement: "Squat", Volume: 5, Intensity: 80}
+	id, _ := sd.AddSet(s)
+	if s.Version != 1 {
+		t.Fatalf("Wanted version 1 after add but got %v", s.Version)
+	}
+
+	// unconditional update increments the version
+	update := &models.Set{Movement: "Squat", Volume: 5, Intensity: 85}
+	if err := sd.UpdateSetForUser(id, 1, update); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if update.Version != 2 {
+		t.Fatalf("Wanted version 2 after update but got %v", update.Version)
+	}
+
+	// update at a stale version is rejected
+	stale := &models.Set{Movement: "Squat", Volume: 3, Intensity: 90, Version: 1}
+	if err := sd.UpdateSetForUser(id, 1, stale); err != ErrVersionMismatch {
+		t.Fatalf("Got error: %v\nWanted error: %v", err, ErrVersionMismatch)
+	}
+
+	// update at the current version applies
+	current := &models.Set{Movement: "Squat", Volume: 3, Intensity: 90, Version: 2}
+	if err := sd.UpdateSetForUser(id, 1, current); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	got, _ := sd.SetByIDForUser(id, 1)
+	if got.Version != 3 || got.Intensity != 90 {
+		t.Fatalf("Unexpected set after versioned update: %+v", got)
+	}
+
+	// versioned update of another user's set is not found
+	if err := sd.UpdateSetForUser(id, 2, &models.Set{Version: 3}); err != ErrNotFound {
+		t.Fatalf("Got error: %v\nWanted error: %v", err, ErrNotFound)
+	}
+
+	// delete at a stale version is rejected, and at the current version applies
+	if err := sd.DeleteSetForUserAtVersion(id, 1, 2); err != ErrVersionMismatch {
+		t.Fatalf("Got error: %v\nWanted error: %v", err, ErrVersionMismatch)
+	}
+	if err := sd.DeleteSetForUserAtVersion(id, 1, 3); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if err := sd.DeleteSetForUserAtVersion(id, 1, 3); err != ErrNotFound {
+		t.Fatalf("Got error: %v\nWanted error: %v", err, ErrNotFound)
+	}
+}
+
+// TestSetOwnership checks that the ForUser methods only change sets owned by
+// the given user, even if its id is zero, while the unscoped methods change
+// the set of any user.
+func TestSetOwnership(t *testing.T) {
+	sd := setupTestSetDB()
+	defer teardownTestSetDB(sd)
+
+	id, _ := sd.AddSet(&models.Set{UID: 1, Movement: "Squat", Volume: 5, Intensity: 80})
+
+	for _, userID := range []models.UserID{0, 2} {
+		if err := sd.UpdateSetForUser(id, userID, &models.Set{Movement: "Squat", Volume: 1, Intensity: 99}); err != ErrNotFound {
+			t.Fatalf("Update by user %v: Got error: %v\nWanted error: %v", userID, err, ErrNotFound)
+		}
+		if err := sd.DeleteSetForUser(id, userID); err != ErrNotFound {
+			t.Fatalf("Delete by user %v: Got error: %v\nWanted error: %v", userID, err, ErrNotFound)
+		}
+		if err := sd.DeleteSetForUserAtVersion(id, userID, 1); err != ErrNotFound {
+			t.Fatalf("Versioned delete by user %v: Got error: %v\nWanted error: %v", userID, err, ErrNotFound)
+		}
+	}
+	if got, _ := sd.SetByID(id); got == nil || got.Intensity != 80 {
+		t.Fatalf("Wanted the set unchanged\nGot set: %+v", got)
+	}
+
+	update := &models.Set{UID: 1, Movement: "Squat", Volume: 5, Intensity: 85, Version: 1}
+	if err := sd.UpdateSet(id, update); err != nil || update.Version != 2 {
+		t.Fatalf("Wanted version 2 after update\nGot version: %v, err: %v", update.Version, err)
+	}
+	if err := sd.UpdateSet(id, &models.Set{Version: 1}); err != ErrVersionMismatch {
+		t.Fatalf("Got error: %v\nWanted error: %v", err, ErrVersionMismatch)
+	}
+	if err := sd.DeleteSet(id); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if err := sd.DeleteSet(id); err != ErrNotFound {
+		t.Fatalf("Got error: %v\nWanted error: %v", err, ErrNotFound)
+	}
+}
+
+// TestBatchForUser checks that a batch of operations is applied atomically:
+// either every operation applies, or none do and the failed operation is identified.
+func TestBatchForUser(t *testing.T) {
+	sd := setupTestSetDB()
+	defer teardownTestSetDB(sd)
+
+	existingID, _ := sd.AddSet(&models.Set{UID: 1, Movement: "Squat", Volume: 5, Intensity: 80})
+	deletedID, _ := sd.AddSet(&models.Set{UID: 1, Movement: "Press", Volume: 5, Intensity: 70})
+
+	ops := []*models.SetOperation{
+		{Op: models.SetOpCreate, Set: &models.Set{Movement: "Deadlift", Volume: 3, Intensity: 90}},
+		{Op: models.SetOpUpdate, SetID: existingID, Version: 1, Set: &models.Set{Movement: "Squat", Volume: 5, Intensity: 85}},
+		{Op: models.SetOpDelete, SetID: deletedID},
+	}
+	if err := sd.BatchForUser(1, ops); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+
+	created, err := sd.SetByIDForUser(ops[0].SetID, 1)
+	if err != nil || created.Movement != "Deadlift" {
+		t.Fatalf("Created set not found: %+v, err: %v", created, err)
+	}
+	updated, _ := sd.SetByIDForUser(existingID, 1)
+	if updated.Intensity != 85 || updated.Version != 2 || ops[1].Previous.Intensity != 80 {
+		t.Fatalf("Unexpected update: %+v, previous: %+v", updated, ops[1].Previous)
+	}
+	if _, err := sd.SetByIDForUser(deletedID, 1); err != ErrNotFound {
+		t.Fatalf("Got error: %v\nWanted error: %v", err, ErrNotFound)
+	}
+
+	// a failing operation rolls back the operations before it
+	failing := []*models.SetOperation{
+		{Op: models.SetOpCreate, Set: &models.Set{Movement: "Row", Volume: 8, Intensity: 60}},
+		{Op: models.SetOpUpdate, SetID: existingID, Version: 1, Set: &models.Set{Movement: "Squat", Volume: 1, Intensity: 95}},
+	}
+	err = sd.BatchForUser(1, failing)
+	batchErr, ok := err.(*BatchError)
+	if !ok || batchErr.Index != 1 || batchErr.Err != ErrVersionMismatch {
+		t.Fatalf("Got error: %v\nWanted batch error at index 1 with %v", err, ErrVersionMismatch)
+	}
+
+	sets, _ := sd.SetsByUserID(1)
+	if len(sets) != 2 {
+		t.Fatalf("Wanted 2 sets after rolled back batch but got %v", len(sets))
+	}
+	if current, _ := sd.SetByIDForUser(existingID, 1); current.Intensity != 85 {
+		t.Fatalf("Rolled back update was applied: %+v", current)
+	}
+
+	// sets of other users cannot be changed in a batch
+	err = sd.BatchForUser(2, []*models.SetOperation{{Op: models.SetOpDelete, SetID: existingID}})
+	if batchErr, ok := err.(*BatchError); !ok || batchErr.Err != ErrNotFound {
+		t.Fatalf("Got error: %v\nWanted batch error with %v", err, ErrNotFound)
+	}
+}
+
 const testSetDB = "testSetDB.sqlite"
 
+// TestSetsByUserIDs calls the SetsByUserIDs method on a db, and checks that
+// the sets of exactly the given users are returned in a single call.
+func TestSetsByUserIDs(t *testing.T) {
+	addSets := []*models.Set{
+		{UID: 1, Movement: "Squat", Volume: 5, Intensity: 80},
+		{UID: 2, Movement: "Bench", Volume: 8, Intensity: 70},
+		{UID: 1, Movement: "Deadlift", Volume: 3, Intensity: 90},
+		{UID: 3, Movement: "Curl", Volume: 12, Intensity: 50},
+	}
+
+	testCases := []struct {
+		name     string
+		userIDs  []models.UserID
+		wantSets []*models.Set
+	}{
+		{
+			name: "No users returns empty slice",
+		},
+		{
+			name:     "Sets of a single user",
+			userIDs:  []models.UserID{2},
+			wantSets: []*models.Set{addSets[1]},
+		},
+		{
+			name:     "Sets of many users in order of id",
+			userIDs:  []models.UserID{3, 1},
+			wantSets: []*models.Set{addSets[0], addSets[2], addSets[3]},
+		},
+		{
+			name:    "Users without sets are left out",
+			userIDs: []models.UserID{4, 5},
+		},
+	}
+
+	sd := setupTestSetDB()
+	defer teardownTestSetDB(sd)
+	for _, s := range addSets {
+		if _, err := sd.AddSet(s); err != nil {
+			t.Fatalf("Encountered unexpected error: %v", err)
+		}
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			sets, err := sd.SetsByUserIDs(v.userIDs)
+			if err != nil {
+				t.Fatalf("Encountered unexpected error: %v", err)
+			}
+			if sets == nil || len(sets) != len(v.wantSets) {
+				t.Fatalf("Wanted %v sets but got %+v", len(v.wantSets), sets)
+			}
+			for i, wantSet := range v.wantSets {
+				if !models.SetsEqual(sets[i], wantSet) {
+					t.Fatalf("Wanted set %v to be %+v but got %+v", i, wantSet, sets[i])
+				}
+				if i > 0 && sets[i].ID <= sets[i-1].ID {
+					t.Fatalf("Sets are not ordered by id: %+v", sets)
+				}
+			}
+		})
+	}
+}
+
 func setupTestSetDB() *setDB {
 	db, err := SqliteDB(testSetDB)
 	if err != nil {
@@ -343,7 +555,8 @@ func checkSetInDB(sd *setDB, s *models.Set) (bool, string) {
 	var movement string
 	var volume float64
 	var intensity float64
-	err := sd.handle.QueryRow(selectSetByID, s.ID).Scan(&userID, &movement, &volume, &intensity)
+	var version int
+	err := sd.handle.QueryRow(selectSetByID, s.ID).Scan(&userID, &movement, &volume, &intensity, &version)
 	if err != nil {
 		return false, fmt.Sprintf("error querying for set: %v", err)
 	}
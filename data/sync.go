@@ -0,0 +1,364 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hrand1005/training-notebook/metrics"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+const (
+	// set_sync keeps, for every set ever created, the sequence number of its
+	// latest change, when each of its fields was last changed in unix
+	// milliseconds, and whether it was deleted. Triggers on sets keep it up
+	// to date for changes made through any api, and deleted sets are kept as
+	// tombstones, so that clients can be sent every change since their last
+	// sync.
+	createSyncTable = `
+	CREATE TABLE IF NOT EXISTS set_sync (
+		setid INTEGER NOT NULL PRIMARY KEY,
+		userid INT,
+		clientid TEXT,
+		seq INTEGER NOT NULL,
+		deleted INT NOT NULL DEFAULT 0,
+		movementat INTEGER NOT NULL DEFAULT 0,
+		volumeat INTEGER NOT NULL DEFAULT 0,
+		intensityat INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS set_sync_client ON set_sync(userid, clientid);
+	CREATE INDEX IF NOT EXISTS set_sync_seq ON set_sync(seq);
+	CREATE INDEX IF NOT EXISTS set_sync_feed ON set_sync(userid, seq);
+	`
+	// syncNow is the current time in unix milliseconds
+	syncNow = `CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)`
+	// syncNextSeq is the sequence number of the next change
+	syncNextSeq        = `(SELECT COALESCE(MAX(seq), 0) + 1 FROM set_sync)`
+	createSyncTriggers = `
+	CREATE TRIGGER IF NOT EXISTS set_sync_insert AFTER INSERT ON sets BEGIN
+		INSERT OR REPLACE INTO set_sync(setid, userid, seq, movementat, volumeat, intensityat)
+		VALUES (NEW.id, NEW.userid, ` + syncNextSeq + `, ` + syncNow + `, ` + syncNow + `, ` + syncNow + `);
+	END;
+	CREATE TRIGGER IF NOT EXISTS set_sync_update AFTER UPDATE ON sets BEGIN
+		UPDATE set_sync SET seq = ` + syncNextSeq + `,
+			movementat = CASE WHEN NEW.movement IS NOT OLD.movement THEN ` + syncNow + ` ELSE movementat END,
+			volumeat = CASE WHEN NEW.volume IS NOT OLD.volume THEN ` + syncNow + ` ELSE volumeat END,
+			intensityat = CASE WHEN NEW.intensity IS NOT OLD.intensity THEN ` + syncNow + ` ELSE intensityat END
+		WHERE setid = NEW.id;
+	END;
+	CREATE TRIGGER IF NOT EXISTS set_sync_delete AFTER DELETE ON sets BEGIN
+		UPDATE set_sync SET seq = ` + syncNextSeq + `, deleted = 1 WHERE setid = OLD.id;
+	END;
+	`
+	selectSyncTracked = `SELECT EXISTS (SELECT 1 FROM set_sync);`
+	// sets created before set_sync existed are tracked as unchanged since the
+	// epoch, so that any change of a client wins over them
+	backfillSyncTable    = `INSERT INTO set_sync(setid, userid, seq) SELECT id, userid, id FROM sets;`
+	selectSyncBySetID    = `SELECT setid, clientid, deleted, movementat, volumeat, intensityat FROM set_sync WHERE setid=? AND userid=?;`
+	selectSyncByClientID = `SELECT setid, clientid, deleted, movementat, volumeat, intensityat FROM set_sync WHERE clientid=? AND userid=?;`
+	updateSyncCreated    = `UPDATE set_sync SET clientid=?, movementat=?, volumeat=?, intensityat=? WHERE setid=?;`
+	updateSyncTimes      = `UPDATE set_sync SET movementat=?, volumeat=?, intensityat=? WHERE setid=?;`
+	selectSyncLatest     = `SELECT COALESCE(MAX(seq), 0) FROM set_sync;`
+	selectSyncFeed       = `SELECT ss.setid, ss.clientid, ss.deleted, ss.seq, s.movement, s.volume, s.intensity, s.version
+		FROM set_sync ss LEFT JOIN sets s ON s.id = ss.setid
+		WHERE ss.userid=? AND ss.seq>? ORDER BY ss.seq LIMIT ?;`
+)
+
+func init() {
+	requireTable("set_sync")
+}
+
+// syncState is the sync metadata of a set, with the times its fields were
+// last changed in unix milliseconds.
+type syncState struct {
+	setID       models.SetID
+	clientID    string
+	deleted     bool
+	movementAt  int64
+	volumeAt    int64
+	intensityAt int64
+}
+
+// lastChanged returns when any field of the set was last changed.
+func (s *syncState) lastChanged() int64 {
+	latest := s.movementAt
+	if s.volumeAt > latest {
+		latest = s.volumeAt
+	}
+	if s.intensityAt > latest {
+		latest = s.intensityAt
+	}
+	return latest
+}
+
+// createSyncTracking creates the set_sync table and its triggers, tracking
+// any sets that already exist.
+func createSyncTracking(db *sql.DB) error {
+	if _, err := db.Exec(createSyncTable); err != nil {
+		return fmt.Errorf("couldn't prepare SQL statement:\n%s\nerr: %v", createSyncTable, err)
+	}
+
+	var tracked bool
+	if err := db.QueryRow(selectSyncTracked).Scan(&tracked); err != nil {
+		return fmt.Errorf("error executing SQL query: %v", err)
+	}
+	if !tracked {
+		if _, err := db.Exec(backfillSyncTable); err != nil {
+			return fmt.Errorf("failed to track existing sets: %v", err)
+		}
+	}
+
+	if _, err := db.Exec(createSyncTriggers); err != nil {
+		return fmt.Errorf("couldn't prepare SQL statement:\n%s\nerr: %v", createSyncTriggers, err)
+	}
+	return nil
+}
+
+// SyncForUser implements the SetDB interface method for syncing the sets of a user
+// with a client. The client's changes are applied in order in a single transaction,
+// each field taking the value of whichever change to it was made last, on the client
+// or the server. Changes made on the client later than the current time are treated
+// as made now. Changes that are discarded are reported as conflicts. The result holds
+// up to limit changes to the user's sets after since, which is zero for the first sync
+// and the token of the previous result otherwise. Returns ErrInvalidSyncToken if since
+// was never handed out.
+func (sd *setDB) SyncForUser(userID models.UserID, since int64, changes []*models.SyncChange, limit int) (*models.SyncResult, error) {
+	defer metrics.ObserveQuery("SyncForUser")()
+	tx, err := sd.handle.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var latest int64
+	if err := tx.QueryRow(selectSyncLatest).Scan(&latest); err != nil {
+		return nil, fmt.Errorf("error executing SQL query: %v", err)
+	}
+	if since < 0 || since > latest {
+		return nil, ErrInvalidSyncToken
+	}
+
+	result := &models.SyncResult{
+		Changes:   []*models.SyncedSet{},
+		Conflicts: []*models.SyncConflict{},
+	}
+	now := time.Now()
+	for _, change := range changes {
+		if err := applySyncChange(tx, userID, change, now, result); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := sd.actor.record(tx, operationChanges(result.Applied)...); err != nil {
+		return nil, err
+	}
+
+	if err := readSyncFeed(tx, userID, since, limit, result); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return result, nil
+}
+
+// applySyncChange applies a change of a client to the sets of userID, and
+// records the outcome in result.
+func applySyncChange(tx *sql.Tx, userID models.UserID, change *models.SyncChange, now time.Time, result *models.SyncResult) error {
+	at := change.ModifiedAt
+	// clients with clocks ahead of the server cannot win every conflict
+	if at.After(now) {
+		at = now
+	}
+	changedAt := at.UnixMilli()
+	f := change.Fields
+	clientID := change.ClientID
+
+	conflict := func(setID models.SetID, field string, reason models.ConflictReason, clientValue, serverValue interface{}) {
+		result.Conflicts = append(result.Conflicts, &models.SyncConflict{
+			ClientID:    clientID,
+			SetID:       setID,
+			Field:       field,
+			Reason:      reason,
+			ClientValue: clientValue,
+			ServerValue: serverValue,
+		})
+	}
+
+	state, err := syncStateFor(tx, userID, change)
+	if err == ErrNotFound {
+		switch {
+		case change.SetID != 0 || (!change.Deleted && !f.Complete()):
+			conflict(change.SetID, "", models.ConflictNotFound, nil, nil)
+		case change.Deleted:
+			// the set was created and deleted before it was ever synced
+		default:
+			s := &models.Set{UID: userID, Movement: *f.Movement, Volume: *f.Volume, Intensity: *f.Intensity}
+			id, err := addSet(tx, s)
+			if err != nil {
+				return err
+			}
+			s.ID = id
+			if _, err := tx.Exec(updateSyncCreated, change.ClientID, changedAt, changedAt, changedAt, id); err != nil {
+				return fmt.Errorf("error executing SQL statement: %v", err)
+			}
+			result.Applied = append(result.Applied, &models.SetOperation{Op: models.SetOpCreate, SetID: id, Set: s})
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// sets synced before are known to the client by both ids
+	if state.clientID != "" {
+		clientID = state.clientID
+	}
+
+	if state.deleted {
+		// deleting a deleted set is not a conflict
+		if !change.Deleted {
+			conflict(state.setID, "", models.ConflictDeleted, nil, nil)
+		}
+		return nil
+	}
+
+	current, err := setByIDForUser(tx, state.setID, userID)
+	if err != nil {
+		return err
+	}
+
+	if change.Deleted {
+		if changedAt <= state.lastChanged() {
+			conflict(state.setID, "deleted", models.ConflictModified, true, false)
+			return nil
+		}
+		if err := deleteSet(tx, state.setID, userID, 0); err != nil {
+			return err
+		}
+		result.Applied = append(result.Applied, &models.SetOperation{Op: models.SetOpDelete, SetID: state.setID, Previous: current})
+		return nil
+	}
+
+	// each field takes the value of its latest change, and changes to a
+	// value the field already has are not conflicts
+	updated := *current
+	if f.Movement != nil {
+		if changedAt > state.movementAt {
+			updated.Movement, state.movementAt = *f.Movement, changedAt
+		} else if *f.Movement != current.Movement {
+			conflict(state.setID, "movement", models.ConflictServerNewer, *f.Movement, current.Movement)
+		}
+	}
+	if f.Volume != nil {
+		if changedAt > state.volumeAt {
+			updated.Volume, state.volumeAt = *f.Volume, changedAt
+		} else if *f.Volume != current.Volume {
+			conflict(state.setID, "volume", models.ConflictServerNewer, *f.Volume, current.Volume)
+		}
+	}
+	if f.Intensity != nil {
+		if changedAt > state.intensityAt {
+			updated.Intensity, state.intensityAt = *f.Intensity, changedAt
+		} else if *f.Intensity != current.Intensity {
+			conflict(state.setID, "intensity", models.ConflictServerNewer, *f.Intensity, current.Intensity)
+		}
+	}
+
+	if !models.SetsEqual(&updated, current) {
+		updated.Version = 0
+		if err := updateSet(tx, state.setID, userID, &updated); err != nil {
+			return err
+		}
+		result.Applied = append(result.Applied, &models.SetOperation{Op: models.SetOpUpdate, SetID: state.setID, Set: &updated, Previous: current})
+	}
+	// the update trigger stamped changed fields with the current time
+	if _, err := tx.Exec(updateSyncTimes, state.movementAt, state.volumeAt, state.intensityAt, state.setID); err != nil {
+		return fmt.Errorf("error executing SQL statement: %v", err)
+	}
+
+	return nil
+}
+
+// syncStateFor returns the sync metadata of the set of userID targeted by
+// change, which is identified by its set id if it has one, and otherwise by
+// the id generated by the client. Returns ErrNotFound if there is no such set.
+func syncStateFor(tx *sql.Tx, userID models.UserID, change *models.SyncChange) (*syncState, error) {
+	var row *sql.Row
+	if change.SetID != 0 {
+		row = tx.QueryRow(selectSyncBySetID, change.SetID, userID)
+	} else {
+		row = tx.QueryRow(selectSyncByClientID, change.ClientID, userID)
+	}
+
+	s := &syncState{}
+	var clientID sql.NullString
+	err := row.Scan(&s.setID, &clientID, &s.deleted, &s.movementAt, &s.volumeAt, &s.intensityAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error executing SQL query: %v", err)
+	}
+	s.clientID = clientID.String
+
+	return s, nil
+}
+
+// readSyncFeed reads up to limit changes to the sets of userID after since
+// into result, and sets its token to resume after them.
+func readSyncFeed(tx *sql.Tx, userID models.UserID, since int64, limit int, result *models.SyncResult) error {
+	// one more change than the limit is read to tell if there are more
+	rows, err := tx.Query(selectSyncFeed, userID, since, limit+1)
+	if err != nil {
+		return fmt.Errorf("error executing SQL query: %v", err)
+	}
+	defer rows.Close()
+
+	result.Token = since
+	for rows.Next() {
+		if len(result.Changes) == limit {
+			result.More = true
+			break
+		}
+
+		synced := &models.SyncedSet{}
+		var seq int64
+		var clientID, movement sql.NullString
+		var volume, intensity sql.NullFloat64
+		var version sql.NullInt64
+		if err := rows.Scan(&synced.SetID, &clientID, &synced.Deleted, &seq, &movement, &volume, &intensity, &version); err != nil {
+			return fmt.Errorf("encountered error scanning row: %v", err)
+		}
+		synced.ClientID = clientID.String
+		if !synced.Deleted && movement.Valid {
+			synced.Set = &models.Set{
+				ID:        synced.SetID,
+				UID:       userID,
+				Movement:  movement.String,
+				Volume:    volume.Float64,
+				Intensity: intensity.Float64,
+				Version:   int(version.Int64),
+			}
+		}
+		synced.Deleted = synced.Set == nil
+
+		result.Changes = append(result.Changes, synced)
+		result.Token = seq
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("encountered error after scanning rows: %v", err)
+	}
+
+	// every later change to the user's sets was read, so the token can skip
+	// the changes to other users' sets
+	if !result.More {
+		if err := tx.QueryRow(selectSyncLatest).Scan(&result.Token); err != nil {
+			return fmt.Errorf("error executing SQL query: %v", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// TestSyncForUser calls the SyncForUser method on a db, and checks that the
+// changes of a client are merged field by field with the latest change
+// winning, and that discarded changes are reported as conflicts.
+func TestSyncForUser(t *testing.T) {
+	sd := setupTestSetDB()
+	defer teardownTestSetDB(sd)
+
+	now := time.Now()
+	created := now.Add(-2 * time.Hour)
+	older := now.Add(-3 * time.Hour)
+	newer := now.Add(-time.Hour)
+
+	result, err := sd.SyncForUser(1, 0, []*models.SyncChange{
+		{ClientID: "a", ModifiedAt: created, Fields: fields("Squat", 5, 80)},
+		{ClientID: "b", ModifiedAt: created, Fields: fields("Press", 5, 70)},
+		{ClientID: "c", ModifiedAt: created, Fields: fields("Row", 8, 60)},
+		{ClientID: "d", ModifiedAt: created, Fields: fields("Curl", 10, 50)},
+		// created and deleted before it was synced
+		{ClientID: "e", Deleted: true, ModifiedAt: created},
+	}, 100)
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if len(result.Applied) != 4 || len(result.Changes) != 4 || len(result.Conflicts) != 0 {
+		t.Fatalf("Wanted 4 created sets without conflicts\nGot result: %+v", result)
+	}
+	ids := map[string]models.SetID{}
+	for _, c := range result.Changes {
+		if c.Deleted || c.Set == nil || c.Set.UID != 1 {
+			t.Fatalf("Wanted a set of user 1\nGot change: %+v", c)
+		}
+		ids[c.ClientID] = c.SetID
+	}
+
+	// sets changed on the server are stamped with the time of the change
+	if err := sd.UpdateSetForUser(ids["b"], 1, &models.Set{Movement: "Press", Volume: 3, Intensity: 70}); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if err := sd.DeleteSetForUser(ids["d"], 1); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	otherID, _ := sd.AddSet(&models.Set{UID: 2, Movement: "Squat", Volume: 1, Intensity: 90})
+
+	intensity := 90.0
+	volume := 8.0
+	result, err = sd.SyncForUser(1, result.Token, []*models.SyncChange{
+		// a later change wins, an earlier one loses
+		{ClientID: "a", ModifiedAt: newer, Fields: models.SetFields{Intensity: &intensity}},
+		{ClientID: "a", ModifiedAt: older, Fields: fields("Bench", 5, 80)},
+		// fields changed on the server later are kept, and others are changed
+		{SetID: ids["b"], ModifiedAt: newer, Fields: fields("Press", 8, 75)},
+		// deletions lose to later changes on the server
+		{SetID: ids["b"], Deleted: true, ModifiedAt: newer},
+		// and win over earlier ones
+		{ClientID: "c", Deleted: true, ModifiedAt: newer},
+		// deleted sets stay deleted
+		{ClientID: "d", ModifiedAt: newer, Fields: models.SetFields{Volume: &volume}},
+		{ClientID: "d", Deleted: true, ModifiedAt: newer},
+		// sets of other users are not found
+		{SetID: otherID, ModifiedAt: newer, Fields: models.SetFields{Volume: &volume}},
+		{ClientID: "f", ModifiedAt: newer, Fields: models.SetFields{Volume: &volume}},
+	}, 100)
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+
+	wantConflicts := []models.SyncConflict{
+		{ClientID: "a", SetID: ids["a"], Field: "movement", Reason: models.ConflictServerNewer, ClientValue: "Bench", ServerValue: "Squat"},
+		{ClientID: "a", SetID: ids["a"], Field: "intensity", Reason: models.ConflictServerNewer, ClientValue: 80.0, ServerValue: 90.0},
+		{ClientID: "b", SetID: ids["b"], Field: "volume", Reason: models.ConflictServerNewer, ClientValue: 8.0, ServerValue: 3.0},
+		{ClientID: "b", SetID: ids["b"], Field: "deleted", Reason: models.ConflictModified, ClientValue: true, ServerValue: false},
+		{ClientID: "d", SetID: ids["d"], Reason: models.ConflictDeleted},
+		{SetID: otherID, Reason: models.ConflictNotFound},
+		{ClientID: "f", Reason: models.ConflictNotFound},
+	}
+	if len(result.Conflicts) != len(wantConflicts) {
+		t.Fatalf("Wanted conflicts: %+v\nGot conflicts: %+v", wantConflicts, result.Conflicts)
+	}
+	for i, want := range wantConflicts {
+		if *result.Conflicts[i] != want {
+			t.Fatalf("Wanted conflict: %+v\nGot conflict: %+v", want, *result.Conflicts[i])
+		}
+	}
+
+	wantSets := map[models.SetID]*models.Set{
+		ids["a"]: {UID: 1, Movement: "Squat", Volume: 5, Intensity: 90},
+		ids["b"]: {UID: 1, Movement: "Press", Volume: 3, Intensity: 75},
+	}
+	for id, want := range wantSets {
+		got, err := sd.SetByIDForUser(id, 1)
+		if err != nil || !models.SetsEqual(got, want) {
+			t.Fatalf("Wanted set: %+v\nGot set: %+v, err: %v", want, got, err)
+		}
+	}
+	if _, err := sd.SetByIDForUser(ids["c"], 1); err != ErrNotFound {
+		t.Fatalf("Got error: %v\nWanted error: %v", err, ErrNotFound)
+	}
+	if len(result.Applied) != 3 {
+		t.Fatalf("Wanted 3 applied changes\nGot applied: %v", len(result.Applied))
+	}
+
+	// the feed holds the latest state of every set changed since the token,
+	// including tombstones, but not the sets of other users
+	wantChanges := map[string]bool{"a": false, "b": false, "c": true, "d": true}
+	if len(result.Changes) != len(wantChanges) {
+		t.Fatalf("Wanted changes to %v sets\nGot changes: %v", len(wantChanges), len(result.Changes))
+	}
+	for _, c := range result.Changes {
+		deleted, ok := wantChanges[c.ClientID]
+		if !ok || c.Deleted != deleted || (c.Set == nil) != deleted {
+			t.Fatalf("Wanted change to %q with deleted %v\nGot change: %+v", c.ClientID, deleted, c)
+		}
+	}
+}
+
+// TestSyncFeed checks that the changes since a token are paged in the order
+// they were made, and that tokens that were never handed out are rejected.
+func TestSyncFeed(t *testing.T) {
+	sd := setupTestSetDB()
+	defer teardownTestSetDB(sd)
+
+	// sets created before sync tracking existed are tracked when it is set up
+	drop := `DROP TRIGGER set_sync_insert; DROP TRIGGER set_sync_update; DROP TRIGGER set_sync_delete; DROP TABLE set_sync;`
+	if _, err := sd.handle.Exec(drop); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		sd.AddSet(&models.Set{UID: 1, Movement: "Squat", Volume: 5, Intensity: 80})
+	}
+	if err := createSyncTracking(sd.handle); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		sd.AddSet(&models.Set{UID: 1, Movement: "Press", Volume: 5, Intensity: 70})
+	}
+	sd.AddSet(&models.Set{UID: 2, Movement: "Row", Volume: 5, Intensity: 70})
+
+	var got []models.SetID
+	var token int64
+	for page := 1; ; page++ {
+		result, err := sd.SyncForUser(1, token, nil, 2)
+		if err != nil {
+			t.Fatalf("Encountered unexpected error: %v", err)
+		}
+		for _, c := range result.Changes {
+			got = append(got, c.SetID)
+		}
+		token = result.Token
+		if !result.More {
+			break
+		}
+		if page > 3 {
+			t.Fatalf("Wanted 3 pages\nGot result: %+v", result)
+		}
+	}
+	want := []models.SetID{1, 2, 3, 4, 5}
+	if len(got) != len(want) {
+		t.Fatalf("Wanted sets: %v\nGot sets: %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Wanted sets: %v\nGot sets: %v", want, got)
+		}
+	}
+
+	// a set changed after the last sync is the only change
+	sd.UpdateSetForUser(2, 1, &models.Set{Movement: "Squat", Volume: 3, Intensity: 80})
+	result, err := sd.SyncForUser(1, token, nil, 2)
+	if err != nil || len(result.Changes) != 1 || result.Changes[0].SetID != 2 || result.Changes[0].Set.Version != 2 {
+		t.Fatalf("Wanted the updated set\nGot result: %+v, err: %v", result, err)
+	}
+
+	for _, since := range []int64{-1, result.Token + 1} {
+		if _, err := sd.SyncForUser(1, since, nil, 2); err != ErrInvalidSyncToken {
+			t.Fatalf("Got error: %v\nWanted error: %v", err, ErrInvalidSyncToken)
+		}
+	}
+}
+
+func fields(movement string, volume, intensity float64) models.SetFields {
+	return models.SetFields{Movement: &movement, Volume: &volume, Intensity: &intensity}
+}
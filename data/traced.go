@@ -0,0 +1,181 @@
+package data
+
+import (
+	"context"
+
+	"github.com/hrand1005/training-notebook/models"
+	"github.com/hrand1005/training-notebook/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startSpan starts a span for the named database call as a child of any span
+// in ctx.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) trace.Span {
+	attrs = append(attrs, semconv.DBSystemSqlite, semconv.DBOperationName(name))
+	_, span := tracing.Start(ctx, name, attrs...)
+	return span
+}
+
+// TraceSetDB returns a SetDB that records a span, as a child of any span in
+// ctx, for each call made to db. Wrap the SetDB per request so that database
+// spans belong to the request's trace.
+func TraceSetDB(ctx context.Context, db SetDB) SetDB {
+	return &tracedSetDB{ctx: ctx, db: db}
+}
+
+// tracedSetDB implements SetDB by recording spans around calls to db
+type tracedSetDB struct {
+	ctx context.Context
+	db  SetDB
+}
+
+func (t *tracedSetDB) AddSet(s *models.Set) (id models.SetID, err error) {
+	span := startSpan(t.ctx, "SetDB.AddSet")
+	defer func() { tracing.End(span, err) }()
+	return t.db.AddSet(s)
+}
+
+func (t *tracedSetDB) Sets() (sets []*models.Set, err error) {
+	span := startSpan(t.ctx, "SetDB.Sets")
+	defer func() { tracing.End(span, err) }()
+	return t.db.Sets()
+}
+
+func (t *tracedSetDB) SetsByUserID(userID models.UserID) (sets []*models.Set, err error) {
+	span := startSpan(t.ctx, "SetDB.SetsByUserID", attribute.Int("user.id", int(userID)))
+	defer func() { tracing.End(span, err) }()
+	return t.db.SetsByUserID(userID)
+}
+
+func (t *tracedSetDB) SetsByUserIDs(userIDs []models.UserID) (sets []*models.Set, err error) {
+	span := startSpan(t.ctx, "SetDB.SetsByUserIDs", attribute.Int("user.count", len(userIDs)))
+	defer func() { tracing.End(span, err) }()
+	return t.db.SetsByUserIDs(userIDs)
+}
+
+func (t *tracedSetDB) SetByID(id models.SetID) (s *models.Set, err error) {
+	span := startSpan(t.ctx, "SetDB.SetByID", attribute.Int("set.id", int(id)))
+	defer func() { tracing.End(span, err) }()
+	return t.db.SetByID(id)
+}
+
+func (t *tracedSetDB) SetByIDForUser(setID models.SetID, userID models.UserID) (s *models.Set, err error) {
+	span := startSpan(t.ctx, "SetDB.SetByIDForUser", attribute.Int("set.id", int(setID)), attribute.Int("user.id", int(userID)))
+	defer func() { tracing.End(span, err) }()
+	return t.db.SetByIDForUser(setID, userID)
+}
+
+func (t *tracedSetDB) UpdateSet(id models.SetID, s *models.Set) (err error) {
+	span := startSpan(t.ctx, "SetDB.UpdateSet", attribute.Int("set.id", int(id)))
+	defer func() { tracing.End(span, err) }()
+	return t.db.UpdateSet(id, s)
+}
+
+func (t *tracedSetDB) UpdateSetForUser(setID models.SetID, userID models.UserID, s *models.Set) (err error) {
+	span := startSpan(t.ctx, "SetDB.UpdateSetForUser", attribute.Int("set.id", int(setID)), attribute.Int("user.id", int(userID)))
+	defer func() { tracing.End(span, err) }()
+	return t.db.UpdateSetForUser(setID, userID, s)
+}
+
+func (t *tracedSetDB) DeleteSet(id models.SetID) (err error) {
+	span := startSpan(t.ctx, "SetDB.DeleteSet", attribute.Int("set.id", int(id)))
+	defer func() { tracing.End(span, err) }()
+	return t.db.DeleteSet(id)
+}
+
+func (t *tracedSetDB) DeleteSetForUser(setID models.SetID, userID models.UserID) (err error) {
+	span := startSpan(t.ctx, "SetDB.DeleteSetForUser", attribute.Int("set.id", int(setID)), attribute.Int("user.id", int(userID)))
+	defer func() { tracing.End(span, err) }()
+	return t.db.DeleteSetForUser(setID, userID)
+}
+
+func (t *tracedSetDB) DeleteSetForUserAtVersion(setID models.SetID, userID models.UserID, version int) (err error) {
+	span := startSpan(t.ctx, "SetDB.DeleteSetForUserAtVersion", attribute.Int("set.id", int(setID)), attribute.Int("user.id", int(userID)), attribute.Int("set.version", version))
+	defer func() { tracing.End(span, err) }()
+	return t.db.DeleteSetForUserAtVersion(setID, userID, version)
+}
+
+func (t *tracedSetDB) BatchForUser(userID models.UserID, ops []*models.SetOperation) (err error) {
+	span := startSpan(t.ctx, "SetDB.BatchForUser", attribute.Int("user.id", int(userID)), attribute.Int("batch.size", len(ops)))
+	defer func() { tracing.End(span, err) }()
+	return t.db.BatchForUser(userID, ops)
+}
+
+func (t *tracedSetDB) SyncForUser(userID models.UserID, since int64, changes []*models.SyncChange, limit int) (result *models.SyncResult, err error) {
+	span := startSpan(t.ctx, "SetDB.SyncForUser", attribute.Int("user.id", int(userID)), attribute.Int("sync.changes", len(changes)))
+	defer func() { tracing.End(span, err) }()
+	return t.db.SyncForUser(userID, since, changes, limit)
+}
+
+func (t *tracedSetDB) AuditedBy(actor Actor) SetDB {
+	return &tracedSetDB{ctx: t.ctx, db: t.db.AuditedBy(actor)}
+}
+
+func (t *tracedSetDB) Close() error {
+	return t.db.Close()
+}
+
+// TraceUserDB returns a UserDB that records a span, as a child of any span in
+// ctx, for each call made to db. Wrap the UserDB per request so that database
+// spans belong to the request's trace.
+func TraceUserDB(ctx context.Context, db UserDB) UserDB {
+	return &tracedUserDB{ctx: ctx, db: db}
+}
+
+// tracedUserDB implements UserDB by recording spans around calls to db
+type tracedUserDB struct {
+	ctx context.Context
+	db  UserDB
+}
+
+func (t *tracedUserDB) AddUser(u *models.User) (id models.UserID, err error) {
+	span := startSpan(t.ctx, "UserDB.AddUser")
+	defer func() { tracing.End(span, err) }()
+	return t.db.AddUser(u)
+}
+
+func (t *tracedUserDB) Users() (users []*models.User, err error) {
+	span := startSpan(t.ctx, "UserDB.Users")
+	defer func() { tracing.End(span, err) }()
+	return t.db.Users()
+}
+
+func (t *tracedUserDB) UserByID(id models.UserID) (u *models.User, err error) {
+	span := startSpan(t.ctx, "UserDB.UserByID", attribute.Int("user.id", int(id)))
+	defer func() { tracing.End(span, err) }()
+	return t.db.UserByID(id)
+}
+
+func (t *tracedUserDB) UsersByIDs(ids []models.UserID) (users []*models.User, err error) {
+	span := startSpan(t.ctx, "UserDB.UsersByIDs", attribute.Int("user.count", len(ids)))
+	defer func() { tracing.End(span, err) }()
+	return t.db.UsersByIDs(ids)
+}
+
+func (t *tracedUserDB) UpdateUser(id models.UserID, u *models.User) (err error) {
+	span := startSpan(t.ctx, "UserDB.UpdateUser", attribute.Int("user.id", int(id)))
+	defer func() { tracing.End(span, err) }()
+	return t.db.UpdateUser(id, u)
+}
+
+func (t *tracedUserDB) UpdatePassword(id models.UserID, hash string) (err error) {
+	span := startSpan(t.ctx, "UserDB.UpdatePassword", attribute.Int("user.id", int(id)))
+	defer func() { tracing.End(span, err) }()
+	return t.db.UpdatePassword(id, hash)
+}
+
+func (t *tracedUserDB) DeleteUser(id models.UserID) (err error) {
+	span := startSpan(t.ctx, "UserDB.DeleteUser", attribute.Int("user.id", int(id)))
+	defer func() { tracing.End(span, err) }()
+	return t.db.DeleteUser(id)
+}
+
+func (t *tracedUserDB) AuditedBy(actor Actor) UserDB {
+	return &tracedUserDB{ctx: t.ctx, db: t.db.AuditedBy(actor)}
+}
+
+func (t *tracedUserDB) Close() error {
+	return t.db.Close()
+}
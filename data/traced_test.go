@@ -0,0 +1,76 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hrand1005/training-notebook/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// TestTraceSetDB checks that calls through a traced SetDB record a span named
+// after the method, as a child of the span in the given context, and that
+// errors mark the span as failed.
+func TestTraceSetDB(t *testing.T) {
+	testCases := []struct {
+		name       string
+		call       func(SetDB) error
+		wantSpan   string
+		wantStatus codes.Code
+	}{
+		{
+			name: "Successful call records span",
+			call: func(db SetDB) error {
+				_, err := db.SetByIDForUser(1, 1)
+				return err
+			},
+			wantSpan:   "SetDB.SetByIDForUser",
+			wantStatus: codes.Unset,
+		},
+		{
+			name: "Failed call records error",
+			call: func(db SetDB) error {
+				return db.DeleteSetForUser(1, 1)
+			},
+			wantSpan:   "SetDB.DeleteSetForUser",
+			wantStatus: codes.Error,
+		},
+	}
+
+	mock := &MockSetDB{
+		SetByIDForUserStub: func(models.SetID, models.UserID) (*models.Set, error) {
+			return &models.Set{}, nil
+		},
+		DeleteSetForUserStub: func(models.SetID, models.UserID) error {
+			return fmt.Errorf("expected error")
+		},
+	}
+
+	for _, v := range testCases {
+		recorder := tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+
+		v.call(TraceSetDB(ctx, mock))
+		parent.End()
+
+		spans := recorder.Ended()
+		if len(spans) != 2 {
+			t.Fatalf("%s: wanted 2 spans, got %v", v.name, len(spans))
+		}
+		got := spans[0]
+		if got.Name() != v.wantSpan {
+			t.Errorf("%s: wanted span %q, got %q", v.name, v.wantSpan, got.Name())
+		}
+		if got.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("%s: span is not a child of the span in the context", v.name)
+		}
+		if got.Status().Code != v.wantStatus {
+			t.Errorf("%s: wanted status %v, got %v", v.name, v.wantStatus, got.Status().Code)
+		}
+	}
+}
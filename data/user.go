@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 
+	"github.com/hrand1005/training-notebook/metrics"
 	"github.com/hrand1005/training-notebook/models"
 )
 
@@ -13,27 +14,41 @@ const (
 	CREATE TABLE IF NOT EXISTS users (
 		id integer NOT NULL PRIMARY KEY AUTOINCREMENT,
 		name TEXT,
-		password TEXT
+		password TEXT,
+		admin INTEGER NOT NULL DEFAULT 0
 	);
 	`
 	insertUser     = `INSERT OR IGNORE INTO users(name, password) VALUES (?, ?);`
-	selectUserByID = `SELECT name, password FROM users WHERE id=?;`
-	selectAllUsers = `SELECT * FROM users;`
-	updateUserByID = `UPDATE users SET name=?, password=? WHERE id=?;`
+	selectUserByID = `SELECT name, password, admin FROM users WHERE id=?;`
+	selectAllUsers = `SELECT id, name, password, admin FROM users;`
+	updateUserByID = `UPDATE users SET name=? WHERE id=?;`
+	updatePassword = `UPDATE users SET password=? WHERE id=?;`
 	deleteUserByID = `DELETE FROM users WHERE id=?;`
+	// the placeholders of the in clause are filled in for the number of ids
+	selectUsersByIDs = `SELECT id, name, password, admin FROM users WHERE id IN (%s);`
 )
 
+// usersTable holds the users. Tables created before admin accounts existed
+// lack the admin column.
+var usersTable = requireTable("users", column{"admin", "INTEGER NOT NULL DEFAULT 0"})
+
 type UserDB interface {
 	AddUser(*models.User) (models.UserID, error)
 	Users() ([]*models.User, error)
 	UserByID(id models.UserID) (*models.User, error)
+	UsersByIDs(ids []models.UserID) ([]*models.User, error)
 	UpdateUser(models.UserID, *models.User) error
+	UpdatePassword(id models.UserID, hash string) error
 	DeleteUser(id models.UserID) error
+	AuditedBy(actor Actor) UserDB
 	Close() error
 }
 
+// userDB contains a handle to the underlying sql database, and implements UserDB.
+// Changes are recorded in the audit log if actor is set.
 type userDB struct {
 	handle *sql.DB
+	actor  *Actor
 }
 
 // NewUserDB loads the data from the given file and returns a UserDB interface or error
@@ -48,6 +63,10 @@ func newUserDB(db *sql.DB) (*userDB, error) {
 		return nil, fmt.Errorf("couldn't prepare SQL statement:\n%s\nerr: %v", createUserTable, err)
 	}
 
+	if err := usersTable.upgrade(db); err != nil {
+		return nil, err
+	}
+
 	return &userDB{
 		handle: db,
 	}, nil
@@ -58,22 +77,35 @@ func newUserDB(db *sql.DB) (*userDB, error) {
 // Returns the assigned id upon successfully inserting the provided user, and nil error.
 // If an error occurs, returns -1 for the id and the error value.
 func (ud *userDB) AddUser(u *models.User) (models.UserID, error) {
-	result, err := ud.handle.Exec(insertUser, u.Name, u.Password)
-	if err != nil {
-		return InvalidUserID, fmt.Errorf("encountered error executing SQL statement: %v", err)
-	}
+	defer metrics.ObserveQuery("AddUser")()
+	id := InvalidUserID
+	err := audited(ud.handle, ud.actor, func(ex execer) ([]change, error) {
+		result, err := ex.Exec(insertUser, u.Name, u.Password)
+		if err != nil {
+			return nil, fmt.Errorf("encountered error executing SQL statement: %v", err)
+		}
+
+		userID, err := result.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("encountered error retrieving last inserted id: %v", err)
+		}
 
-	userID, err := result.LastInsertId()
+		id = models.UserID(userID)
+		created := u.Sanitized()
+		created.ID = id
+		return []change{userChange(models.AuditCreate, id, nil, created)}, nil
+	})
 	if err != nil {
-		return InvalidUserID, fmt.Errorf("encountered error retrieving last inserted id: %v", err)
+		return InvalidUserID, err
 	}
 
-	return models.UserID(userID), nil
+	return id, nil
 }
 
 // Users implements the UserDB interface method for retrieving all users from the database.
 // An empty slice of users is considered a valid result of the database query.
 func (ud *userDB) Users() ([]*models.User, error) {
+	defer metrics.ObserveQuery("Users")()
 	rows, err := ud.handle.Query(selectAllUsers)
 	if err != nil {
 		return nil, fmt.Errorf("error executing SQL query: %v", err)
@@ -85,7 +117,8 @@ func (ud *userDB) Users() ([]*models.User, error) {
 		var id models.UserID
 		var name string
 		var password string
-		if err := rows.Scan(&id, &name, &password); err != nil {
+		var admin bool
+		if err := rows.Scan(&id, &name, &password, &admin); err != nil {
 			return nil, fmt.Errorf("encountered error scanning row: %v", err)
 		}
 
@@ -93,6 +126,7 @@ func (ud *userDB) Users() ([]*models.User, error) {
 			ID:       id,
 			Name:     name,
 			Password: password,
+			Admin:    admin,
 		})
 	}
 
@@ -107,9 +141,16 @@ func (ud *userDB) Users() ([]*models.User, error) {
 // Returns a user matching the given ID in the database.
 // If no user with the given id is found, returns ErrNotFound.
 func (ud *userDB) UserByID(id models.UserID) (*models.User, error) {
+	defer metrics.ObserveQuery("UserByID")()
+	return userByID(ud.handle, id)
+}
+
+// userByID performs UserByID using the given execer.
+func userByID(ex execer, id models.UserID) (*models.User, error) {
 	var name string
 	var password string
-	err := ud.handle.QueryRow(selectUserByID, id).Scan(&name, &password)
+	var admin bool
+	err := ex.QueryRow(selectUserByID, id).Scan(&name, &password, &admin)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
@@ -121,18 +162,97 @@ func (ud *userDB) UserByID(id models.UserID) (*models.User, error) {
 		ID:       id,
 		Name:     name,
 		Password: password,
+		Admin:    admin,
 	}, nil
 }
 
+// UsersByIDs implements the UserDB interface method for finding many users in a single query.
+// Returns the users matching any of the given ids, in no particular order. Ids without a
+// user are left out, so an empty slice of users is a valid result of the database query.
+func (ud *userDB) UsersByIDs(ids []models.UserID) ([]*models.User, error) {
+	defer metrics.ObserveQuery("UsersByIDs")()
+	users := make([]*models.User, 0, len(ids))
+	if len(ids) == 0 {
+		return users, nil
+	}
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := ud.handle.Query(fmt.Sprintf(selectUsersByIDs, placeholders(len(ids))), args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing SQL query: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		u := &models.User{}
+		if err := rows.Scan(&u.ID, &u.Name, &u.Password, &u.Admin); err != nil {
+			return nil, fmt.Errorf("encountered error scanning row: %v", err)
+		}
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("encountered error after scanning rows: %v", err)
+	}
+
+	return users, nil
+}
+
 // UpdateUser implements the UserDB interface method for updating a particular user in the database.
 // Updates the columns of the user matching the given id with the fields of the given user.
+// The password is never changed by UpdateUser, use UpdatePassword instead.
 // If no user with the given id is found, returns ErrNotFound.
 func (ud *userDB) UpdateUser(id models.UserID, u *models.User) error {
-	result, err := ud.handle.Exec(updateUserByID, u.Name, u.Password, id)
-	if err != nil {
-		return fmt.Errorf("failed to update user: %v", err)
-	}
+	defer metrics.ObserveQuery("UpdateUser")()
+	return audited(ud.handle, ud.actor, func(ex execer) ([]change, error) {
+		before, err := userByID(ex, id)
+		if err != nil {
+			return nil, err
+		}
 
+		result, err := ex.Exec(updateUserByID, u.Name, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update user: %v", err)
+		}
+		if err := checkUserUpdated(result); err != nil {
+			return nil, err
+		}
+
+		after := before.Sanitized()
+		after.Name = u.Name
+		return []change{userChange(models.AuditUpdate, id, before.Sanitized(), after)}, nil
+	})
+}
+
+// UpdatePassword implements the UserDB interface method for changing a user's password.
+// The hash of the new password is stored for the user matching the given id.
+// If no user with the given id is found, returns ErrNotFound.
+func (ud *userDB) UpdatePassword(id models.UserID, hash string) error {
+	defer metrics.ObserveQuery("UpdatePassword")()
+	return audited(ud.handle, ud.actor, func(ex execer) ([]change, error) {
+		before, err := userByID(ex, id)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := ex.Exec(updatePassword, hash, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update password: %v", err)
+		}
+		if err := checkUserUpdated(result); err != nil {
+			return nil, err
+		}
+
+		// passwords are never recorded, so before and after are the same
+		return []change{userChange(models.AuditUpdate, id, before.Sanitized(), before.Sanitized())}, nil
+	})
+}
+
+// checkUserUpdated checks that exactly one user was affected by an update.
+func checkUserUpdated(result sql.Result) error {
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("failed to get RowsAffected by update: %v", err)
@@ -152,23 +272,54 @@ func (ud *userDB) UpdateUser(id models.UserID, u *models.User) error {
 // Deletes the record of the user matching the given id.
 // If no user with the given id is found, returns ErrNotFound.
 func (sd *userDB) DeleteUser(id models.UserID) error {
-	result, err := sd.handle.Exec(deleteUserByID, id)
-	if err != nil {
-		return fmt.Errorf("error executing SQL statement: %v", err)
-	}
+	defer metrics.ObserveQuery("DeleteUser")()
+	return audited(sd.handle, sd.actor, func(ex execer) ([]change, error) {
+		before, err := userByID(ex, id)
+		if err != nil {
+			return nil, err
+		}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("encountered error checking rows affected: %v", err)
-	}
-	if rowsAffected != 1 {
-		if rowsAffected == 0 {
-			return ErrNotFound
+		result, err := ex.Exec(deleteUserByID, id)
+		if err != nil {
+			return nil, fmt.Errorf("error executing SQL statement: %v", err)
 		}
-		return fmt.Errorf("unexpected number of affected rows: %v", rowsAffected)
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("encountered error checking rows affected: %v", err)
+		}
+		if rowsAffected != 1 {
+			if rowsAffected == 0 {
+				return nil, ErrNotFound
+			}
+			return nil, fmt.Errorf("unexpected number of affected rows: %v", rowsAffected)
+		}
+
+		return []change{userChange(models.AuditDelete, id, before.Sanitized(), nil)}, nil
+	})
+}
+
+// userChange returns the change to the user with the given id from before to
+// after, either of which is nil for creations and deletions. Users own their
+// own records.
+func userChange(action models.AuditAction, id models.UserID, before, after *models.User) change {
+	c := change{action: action, target: models.AuditTargetUser, targetID: int(id), ownerID: id}
+	if before != nil {
+		c.before = before
+	}
+	if after != nil {
+		c.after = after
 	}
+	return c
+}
 
-	return nil
+// AuditedBy implements the UserDB interface method for recording changes in
+// the audit log. Changes made through the returned UserDB are recorded as made
+// by actor, in the same transaction as the change, so that neither is kept if
+// the other fails. The audit log must have been prepared on the same sql db
+// handle with NewAuditDB.
+func (ud *userDB) AuditedBy(actor Actor) UserDB {
+	return &userDB{handle: ud.handle, actor: &actor}
 }
 
 // Close calls close on the underlying sql.DB
@@ -175,6 +175,60 @@ func TestUserByID(t *testing.T) {
 	}
 }
 
+// TestUsersByIDs calls userDB's UsersByIDs method and checks that the users
+// with the given ids are returned, leaving out ids without a user.
+func TestUsersByIDs(t *testing.T) {
+	ud := setupTestUserDB()
+	defer teardownTestUserDB(ud)
+
+	names := []string{"Hubie", "Dewey", "Louie"}
+	ids := make([]models.UserID, len(names))
+	for i, name := range names {
+		id, err := ud.AddUser(&models.User{Name: name})
+		if err != nil {
+			t.Fatalf("Encountered unexpected error: %v", err)
+		}
+		ids[i] = id
+	}
+
+	testCases := []struct {
+		name      string
+		ids       []models.UserID
+		wantNames map[models.UserID]string
+	}{
+		{
+			name:      "No ids returns empty slice",
+			wantNames: map[models.UserID]string{},
+		},
+		{
+			name:      "Users with the given ids",
+			ids:       []models.UserID{ids[2], ids[0]},
+			wantNames: map[models.UserID]string{ids[0]: "Hubie", ids[2]: "Louie"},
+		},
+		{
+			name:      "Ids without a user are left out",
+			ids:       []models.UserID{ids[1], InvalidUserID},
+			wantNames: map[models.UserID]string{ids[1]: "Dewey"},
+		},
+	}
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			users, err := ud.UsersByIDs(v.ids)
+			if err != nil {
+				t.Fatalf("Encountered unexpected error: %v", err)
+			}
+			if users == nil || len(users) != len(v.wantNames) {
+				t.Fatalf("Wanted users %v but got %+v", v.wantNames, users)
+			}
+			for _, u := range users {
+				if v.wantNames[u.ID] != u.Name {
+					t.Fatalf("Wanted users %v but got %+v", v.wantNames, u)
+				}
+			}
+		})
+	}
+}
+
 // TestUpdateUser calls userDB's UpdateUser method and checks that the expected
 // values are user in the DB, or that the expected error value is returned.
 func TestUpdateUser(t *testing.T) {
@@ -230,6 +284,34 @@ func TestUpdateUser(t *testing.T) {
 	}
 }
 
+// TestUpdatePassword checks that passwords are only changed by UpdatePassword,
+// and never by UpdateUser.
+func TestUpdatePassword(t *testing.T) {
+	ud := setupTestUserDB()
+	defer teardownTestUserDB(ud)
+
+	id, _ := ud.AddUser(&models.User{Name: "Herb", Password: "old-hash"})
+
+	// updating the user leaves the password untouched
+	if err := ud.UpdateUser(id, &models.User{Name: "Herbert", Password: ""}); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if exists, msg := checkUserInDB(ud, id, &models.User{Name: "Herbert", Password: "old-hash"}); !exists {
+		t.Fatalf("Failed db check: %v", msg)
+	}
+
+	if err := ud.UpdatePassword(id, "new-hash"); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if exists, msg := checkUserInDB(ud, id, &models.User{Name: "Herbert", Password: "new-hash"}); !exists {
+		t.Fatalf("Failed db check: %v", msg)
+	}
+
+	if err := ud.UpdatePassword(InvalidUserID, "new-hash"); err != ErrNotFound {
+		t.Fatalf("Got error: %v\nWanted error: %v", err, ErrNotFound)
+	}
+}
+
 func TestDeleteUser(t *testing.T) {
 	testCases := []struct {
 		name    string
@@ -289,7 +371,8 @@ func teardownTestUserDB(ud *userDB) {
 func checkUserInDB(ud *userDB, id models.UserID, u *models.User) (bool, string) {
 	var name string
 	var password string
-	err := ud.handle.QueryRow(selectUserByID, id).Scan(&name, &password)
+	var admin bool
+	err := ud.handle.QueryRow(selectUserByID, id).Scan(&name, &password, &admin)
 	if err != nil {
 		return false, fmt.Sprintf("error querying for user: %v", err)
 	}
@@ -318,3 +401,36 @@ func setupTestUserDB() *userDB {
 
 	return ud
 }
+
+// TestUserTableUpgrade checks that a users table created before the admin
+// column existed is upgraded in place without losing users.
+func TestUserTableUpgrade(t *testing.T) {
+	db, err := SqliteDB(testUserDB)
+	if err != nil {
+		t.Fatalf("Failed to setup test db: %v", err)
+	}
+	_, err = db.Exec(`CREATE TABLE users (id integer NOT NULL PRIMARY KEY AUTOINCREMENT, name TEXT, password TEXT);
+		INSERT INTO users(name, password) VALUES ('Legacy', 'hash');`)
+	if err != nil {
+		t.Fatalf("Failed to create legacy table: %v", err)
+	}
+
+	ud, err := newUserDB(db)
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	defer teardownTestUserDB(ud)
+
+	u, err := ud.UserByID(1)
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if u.Name != "Legacy" || u.Admin {
+		t.Fatalf("Unexpected user after upgrade: %+v", u)
+	}
+
+	// upgrading an already upgraded table is a no-op
+	if _, err := newUserDB(db); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+}
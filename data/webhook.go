@@ -0,0 +1,342 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hrand1005/training-notebook/metrics"
+	"github.com/hrand1005/training-notebook/models"
+)
+
+const (
+	createWebhookTables = `
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+		ownerid INT,
+		url TEXT,
+		secret TEXT,
+		events TEXT,
+		allusers INT,
+		created TEXT
+	);
+	CREATE INDEX IF NOT EXISTS webhooks_owner ON webhooks(ownerid);
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+		webhookid INT,
+		event TEXT,
+		payload TEXT,
+		status TEXT,
+		attempts INT,
+		responsecode INT,
+		error TEXT,
+		created TEXT,
+		lastattempt TEXT,
+		nextattempt TEXT
+	);
+	CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook ON webhook_deliveries(webhookid, id);
+	CREATE INDEX IF NOT EXISTS webhook_deliveries_due ON webhook_deliveries(status, nextattempt);
+	`
+	insertWebhook           = `INSERT INTO webhooks(ownerid, url, secret, events, allusers, created) VALUES (?, ?, ?, ?, ?, ?);`
+	selectWebhooks          = `SELECT id, ownerid, url, secret, events, allusers, created FROM webhooks`
+	deleteWebhook           = `DELETE FROM webhooks WHERE id=?;`
+	deleteWebhookDeliveries = `DELETE FROM webhook_deliveries WHERE webhookid=?;`
+	insertDelivery          = `INSERT INTO webhook_deliveries(webhookid, event, payload, status, attempts, responsecode, error, created, lastattempt, nextattempt) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	selectDeliveries        = `SELECT id, webhookid, event, payload, status, attempts, responsecode, error, created, lastattempt, nextattempt FROM webhook_deliveries`
+	updateDelivery          = `UPDATE webhook_deliveries SET status=?, attempts=?, responsecode=?, error=?, lastattempt=?, nextattempt=? WHERE id=?;`
+	webhookTimeLayout       = auditTimeLayout
+)
+
+func init() {
+	requireTable("webhooks")
+	requireTable("webhook_deliveries")
+}
+
+// WebhookDB defines the interface for storing webhooks and the queue of
+// their deliveries.
+type WebhookDB interface {
+	AddWebhook(w *models.Webhook) (models.WebhookID, error)
+	WebhookByID(id models.WebhookID) (*models.Webhook, error)
+	WebhooksByOwner(ownerID models.UserID) ([]*models.Webhook, error)
+	WebhooksFor(eventType string, ownerID models.UserID) ([]*models.Webhook, error)
+	DeleteWebhook(id models.WebhookID) error
+	AddDelivery(d *models.WebhookDelivery) (int64, error)
+	DueDeliveries(now time.Time, limit int) ([]*models.WebhookDelivery, error)
+	UpdateDelivery(d *models.WebhookDelivery) error
+	Deliveries(webhookID models.WebhookID, limit int) ([]*models.WebhookDelivery, error)
+	Close() error
+}
+
+// webhookDB contains a handle to the underlying sql database, and implements WebhookDB
+type webhookDB struct {
+	handle *sql.DB
+}
+
+// NewWebhookDB prepares the webhook tables on the given sql db handle and
+// returns a WebhookDB interface or error
+func NewWebhookDB(db *sql.DB) (WebhookDB, error) {
+	return newWebhookDB(db)
+}
+
+// newWebhookDB returns the underlying webhookDB and error created from the given sql db handle.
+func newWebhookDB(db *sql.DB) (*webhookDB, error) {
+	_, err := db.Exec(createWebhookTables)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't prepare SQL statement:\n%s\nerr: %v", createWebhookTables, err)
+	}
+
+	return &webhookDB{
+		handle: db,
+	}, nil
+}
+
+// AddWebhook implements the WebhookDB interface method for adding a webhook.
+// If the webhook has no creation time, the current time is used.
+func (wd *webhookDB) AddWebhook(w *models.Webhook) (models.WebhookID, error) {
+	defer metrics.ObserveQuery("AddWebhook")()
+	if w.CreatedAt.IsZero() {
+		w.CreatedAt = time.Now().UTC()
+	}
+
+	result, err := wd.handle.Exec(insertWebhook, w.OwnerID, w.URL, w.Secret, strings.Join(w.Events, ","),
+		w.AllUsers, w.CreatedAt.UTC().Format(webhookTimeLayout))
+	if err != nil {
+		return 0, fmt.Errorf("encountered error executing SQL statement: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("encountered error retrieving last inserted id: %v", err)
+	}
+
+	return models.WebhookID(id), nil
+}
+
+// WebhookByID implements the WebhookDB interface method for reading a
+// webhook. Returns ErrNotFound if there is no webhook with the given id.
+func (wd *webhookDB) WebhookByID(id models.WebhookID) (*models.Webhook, error) {
+	defer metrics.ObserveQuery("WebhookByID")()
+	webhooks, err := wd.queryWebhooks(selectWebhooks+" WHERE id=?;", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(webhooks) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return webhooks[0], nil
+}
+
+// WebhooksByOwner implements the WebhookDB interface method for reading the
+// webhooks of a user, in the order they were added. An empty slice is a
+// valid result.
+func (wd *webhookDB) WebhooksByOwner(ownerID models.UserID) ([]*models.Webhook, error) {
+	defer metrics.ObserveQuery("WebhooksByOwner")()
+	return wd.queryWebhooks(selectWebhooks+" WHERE ownerid=? ORDER BY id;", ownerID)
+}
+
+// WebhooksFor implements the WebhookDB interface method for finding the
+// webhooks to deliver an event of the given type to, for a change owned by
+// ownerID. These are the owner's webhooks, and those receiving changes of
+// every user, that subscribe to the event type.
+func (wd *webhookDB) WebhooksFor(eventType string, ownerID models.UserID) ([]*models.Webhook, error) {
+	defer metrics.ObserveQuery("WebhooksFor")()
+	candidates, err := wd.queryWebhooks(selectWebhooks+" WHERE ownerid=? OR allusers=1 ORDER BY id;", ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks := make([]*models.Webhook, 0, len(candidates))
+	for _, w := range candidates {
+		if w.Subscribes(eventType) {
+			webhooks = append(webhooks, w)
+		}
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook implements the WebhookDB interface method for deleting a
+// webhook, along with its deliveries. Returns ErrNotFound if there is no
+// webhook with the given id.
+func (wd *webhookDB) DeleteWebhook(id models.WebhookID) error {
+	defer metrics.ObserveQuery("DeleteWebhook")()
+	tx, err := wd.handle.Begin()
+	if err != nil {
+		return fmt.Errorf("encountered error beginning transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(deleteWebhook, id)
+	if err != nil {
+		return fmt.Errorf("encountered error executing SQL statement: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("encountered error retrieving rows affected: %v", err)
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.Exec(deleteWebhookDeliveries, id); err != nil {
+		return fmt.Errorf("encountered error executing SQL statement: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("encountered error committing transaction: %v", err)
+	}
+	return nil
+}
+
+// AddDelivery implements the WebhookDB interface method for queueing a
+// delivery. Returns the id assigned to the delivery. If the delivery has no
+// creation time, the current time is used.
+func (wd *webhookDB) AddDelivery(d *models.WebhookDelivery) (int64, error) {
+	defer metrics.ObserveQuery("AddDelivery")()
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = time.Now().UTC()
+	}
+
+	result, err := wd.handle.Exec(insertDelivery, d.WebhookID, d.Event, string(d.Payload), d.Status, d.Attempts,
+		d.ResponseCode, d.Error, d.CreatedAt.UTC().Format(webhookTimeLayout), formatOptionalTime(d.LastAttemptAt),
+		formatOptionalTime(d.NextAttemptAt))
+	if err != nil {
+		return 0, fmt.Errorf("encountered error executing SQL statement: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("encountered error retrieving last inserted id: %v", err)
+	}
+
+	return id, nil
+}
+
+// DueDeliveries implements the WebhookDB interface method for reading up to
+// limit pending deliveries whose next attempt is due by now, the longest
+// overdue first.
+func (wd *webhookDB) DueDeliveries(now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	defer metrics.ObserveQuery("DueDeliveries")()
+	query := selectDeliveries + " WHERE status=? AND nextattempt<=? ORDER BY nextattempt, id LIMIT ?;"
+	return wd.queryDeliveries(query, models.DeliveryPending, now.UTC().Format(webhookTimeLayout), limit)
+}
+
+// UpdateDelivery implements the WebhookDB interface method for recording
+// the outcome of an attempt to deliver d. Returns ErrNotFound if the
+// delivery no longer exists, e.g. because its webhook was deleted.
+func (wd *webhookDB) UpdateDelivery(d *models.WebhookDelivery) error {
+	defer metrics.ObserveQuery("UpdateDelivery")()
+	result, err := wd.handle.Exec(updateDelivery, d.Status, d.Attempts, d.ResponseCode, d.Error,
+		formatOptionalTime(d.LastAttemptAt), formatOptionalTime(d.NextAttemptAt), d.ID)
+	if err != nil {
+		return fmt.Errorf("encountered error executing SQL statement: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("encountered error retrieving rows affected: %v", err)
+	} else if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Deliveries implements the WebhookDB interface method for reading the
+// delivery log of a webhook, up to limit deliveries, the latest first. An
+// empty slice is a valid result.
+func (wd *webhookDB) Deliveries(webhookID models.WebhookID, limit int) ([]*models.WebhookDelivery, error) {
+	defer metrics.ObserveQuery("Deliveries")()
+	return wd.queryDeliveries(selectDeliveries+" WHERE webhookid=? ORDER BY id DESC LIMIT ?;", webhookID, limit)
+}
+
+// queryWebhooks returns the webhooks selected by query.
+func (wd *webhookDB) queryWebhooks(query string, args ...interface{}) ([]*models.Webhook, error) {
+	rows, err := wd.handle.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing SQL query: %v", err)
+	}
+	defer rows.Close()
+
+	webhooks := make([]*models.Webhook, 0, 10)
+	for rows.Next() {
+		w := &models.Webhook{}
+		var events, created string
+		if err := rows.Scan(&w.ID, &w.OwnerID, &w.URL, &w.Secret, &events, &w.AllUsers, &created); err != nil {
+			return nil, fmt.Errorf("encountered error scanning row: %v", err)
+		}
+
+		w.Events = strings.Split(events, ",")
+		if w.CreatedAt, err = time.Parse(webhookTimeLayout, created); err != nil {
+			return nil, fmt.Errorf("encountered error parsing timestamp %q: %v", created, err)
+		}
+
+		webhooks = append(webhooks, w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("encountered error after scanning rows: %v", err)
+	}
+
+	return webhooks, nil
+}
+
+// queryDeliveries returns the deliveries selected by query.
+func (wd *webhookDB) queryDeliveries(query string, args ...interface{}) ([]*models.WebhookDelivery, error) {
+	rows, err := wd.handle.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing SQL query: %v", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]*models.WebhookDelivery, 0, 10)
+	for rows.Next() {
+		d := &models.WebhookDelivery{}
+		var payload, created, lastAttempt, nextAttempt string
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &payload, &d.Status, &d.Attempts, &d.ResponseCode,
+			&d.Error, &created, &lastAttempt, &nextAttempt); err != nil {
+			return nil, fmt.Errorf("encountered error scanning row: %v", err)
+		}
+
+		d.Payload = []byte(payload)
+		if d.CreatedAt, err = time.Parse(webhookTimeLayout, created); err != nil {
+			return nil, fmt.Errorf("encountered error parsing timestamp %q: %v", created, err)
+		}
+		if d.LastAttemptAt, err = parseOptionalTime(lastAttempt); err != nil {
+			return nil, err
+		}
+		if d.NextAttemptAt, err = parseOptionalTime(nextAttempt); err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("encountered error after scanning rows: %v", err)
+	}
+
+	return deliveries, nil
+}
+
+// formatOptionalTime formats t for storage, as an empty string if it is nil.
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(webhookTimeLayout)
+}
+
+// parseOptionalTime parses a time formatted by formatOptionalTime.
+func parseOptionalTime(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(webhookTimeLayout, s)
+	if err != nil {
+		return nil, fmt.Errorf("encountered error parsing timestamp %q: %v", s, err)
+	}
+	return &t, nil
+}
+
+// Close calls close on the underlying sql.DB
+func (wd *webhookDB) Close() error {
+	return wd.handle.Close()
+}
@@ -0,0 +1,183 @@
+package data
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hrand1005/training-notebook/models"
+)
+
+// TestWebhooksFor adds webhooks and checks that WebhooksFor returns those of
+// the owner, and those receiving every user's changes, subscribed to the
+// event type.
+func TestWebhooksFor(t *testing.T) {
+	wd := setupTestWebhookDB()
+	defer teardownTestWebhookDB(wd)
+
+	webhooks := []*models.Webhook{
+		{OwnerID: 1, URL: "http://a.example", Secret: "a", Events: []string{"set.created", "set.deleted"}},
+		{OwnerID: 2, URL: "http://b.example", Secret: "b", Events: []string{"set.created"}},
+		{OwnerID: 3, URL: "http://c.example", Secret: "c", Events: []string{"set.created", "user.updated"}, AllUsers: true},
+		{OwnerID: 1, URL: "http://d.example", Secret: "d", Events: []string{"user.updated"}},
+	}
+	for _, w := range webhooks {
+		id, err := wd.AddWebhook(w)
+		if err != nil {
+			t.Fatalf("Encountered unexpected error: %v", err)
+		}
+		w.ID = id
+	}
+
+	testCases := []struct {
+		name      string
+		eventType string
+		ownerID   models.UserID
+		want      []models.WebhookID
+	}{
+		{
+			name:      "Owner's and every user's webhooks are returned",
+			eventType: "set.created",
+			ownerID:   1,
+			want:      []models.WebhookID{webhooks[0].ID, webhooks[2].ID},
+		},
+		{
+			name:      "Webhooks not subscribed to the event are not returned",
+			eventType: "set.deleted",
+			ownerID:   1,
+			want:      []models.WebhookID{webhooks[0].ID},
+		},
+		{
+			name:      "Other users' webhooks are not returned",
+			eventType: "user.updated",
+			ownerID:   2,
+			want:      []models.WebhookID{webhooks[2].ID},
+		},
+		{
+			name:      "Unsubscribed event returns empty slice",
+			eventType: "user.deleted",
+			ownerID:   1,
+			want:      []models.WebhookID{},
+		},
+	}
+
+	for _, v := range testCases {
+		got, err := wd.WebhooksFor(v.eventType, v.ownerID)
+		if err != nil {
+			t.Fatalf("%s: Encountered unexpected error: %v", v.name, err)
+		}
+		if len(got) != len(v.want) {
+			t.Fatalf("%s: Wanted webhooks %v\nGot %v webhooks", v.name, v.want, len(got))
+		}
+		for i := range got {
+			if got[i].ID != v.want[i] {
+				t.Fatalf("%s: Wanted webhooks %v\nGot webhook %+v at %v", v.name, v.want, got[i], i)
+			}
+		}
+	}
+
+	got, err := wd.WebhookByID(webhooks[2].ID)
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if got.URL != webhooks[2].URL || got.Secret != "c" || !got.AllUsers || len(got.Events) != 2 || !got.CreatedAt.Equal(webhooks[2].CreatedAt) {
+		t.Fatalf("Wanted webhook:\n%+v\nGot webhook:\n%+v", webhooks[2], got)
+	}
+}
+
+// TestDeliveryQueue checks that deliveries are due once their next attempt
+// is, that attempts are recorded, and that deleting a webhook removes its
+// deliveries.
+func TestDeliveryQueue(t *testing.T) {
+	wd := setupTestWebhookDB()
+	defer teardownTestWebhookDB(wd)
+
+	webhookID, err := wd.AddWebhook(&models.Webhook{OwnerID: 1, URL: "http://a.example", Events: []string{"set.created"}})
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+
+	start := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	later := start.Add(time.Minute)
+	deliveries := []*models.WebhookDelivery{
+		{WebhookID: webhookID, Event: "set.created", Payload: []byte(`{"n":1}`), Status: models.DeliveryPending, NextAttemptAt: &later},
+		{WebhookID: webhookID, Event: "set.created", Payload: []byte(`{"n":2}`), Status: models.DeliveryPending, NextAttemptAt: &start},
+		{WebhookID: webhookID, Event: "set.created", Payload: []byte(`{"n":3}`), Status: models.DeliveryDelivered, Attempts: 1, LastAttemptAt: &start},
+	}
+	for _, d := range deliveries {
+		if d.ID, err = wd.AddDelivery(d); err != nil {
+			t.Fatalf("Encountered unexpected error: %v", err)
+		}
+	}
+
+	due, err := wd.DueDeliveries(start, 10)
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if len(due) != 1 || due[0].ID != deliveries[1].ID || string(due[0].Payload) != `{"n":2}` {
+		t.Fatalf("Wanted delivery %v to be due\nGot deliveries: %+v", deliveries[1].ID, due)
+	}
+
+	// the overdue delivery is attempted and fails, the other comes due
+	d := due[0]
+	next := start.Add(2 * time.Minute)
+	d.Attempts, d.ResponseCode, d.Error, d.LastAttemptAt, d.NextAttemptAt = 1, 500, "receiver responded with 500", &start, &next
+	if err := wd.UpdateDelivery(d); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	due, err = wd.DueDeliveries(later, 10)
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if len(due) != 1 || due[0].ID != deliveries[0].ID {
+		t.Fatalf("Wanted delivery %v to be due\nGot deliveries: %+v", deliveries[0].ID, due)
+	}
+
+	log, err := wd.Deliveries(webhookID, 2)
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if len(log) != 2 || log[0].ID != deliveries[2].ID || log[1].ID != d.ID {
+		t.Fatalf("Wanted the latest 2 deliveries\nGot deliveries: %+v", log)
+	}
+	if log[1].Attempts != 1 || log[1].ResponseCode != 500 || log[1].Error == "" || !log[1].NextAttemptAt.Equal(next) {
+		t.Fatalf("Wanted the failed attempt to be recorded\nGot delivery: %+v", log[1])
+	}
+
+	if err := wd.DeleteWebhook(webhookID); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if err := wd.DeleteWebhook(webhookID); err != ErrNotFound {
+		t.Fatalf("Wanted error: %v\nGot error: %v", ErrNotFound, err)
+	}
+	if log, err = wd.Deliveries(webhookID, 10); err != nil || len(log) != 0 {
+		t.Fatalf("Wanted no deliveries\nGot deliveries: %+v, error: %v", log, err)
+	}
+	if err := wd.UpdateDelivery(d); err != ErrNotFound {
+		t.Fatalf("Wanted error: %v\nGot error: %v", ErrNotFound, err)
+	}
+}
+
+const testWebhookDB = "testWebhookDB.sqlite"
+
+func setupTestWebhookDB() *webhookDB {
+	db, err := SqliteDB(testWebhookDB)
+	if err != nil {
+		msg := fmt.Sprintf("failed to setup test db: %v, err: %v", testWebhookDB, err)
+		panic(msg)
+	}
+
+	wd, err := newWebhookDB(db)
+	if err != nil {
+		msg := fmt.Sprintf("failed to setup test db: %v, err: %v", testWebhookDB, err)
+		panic(msg)
+	}
+
+	return wd
+}
+
+func teardownTestWebhookDB(wd *webhookDB) {
+	wd.handle.Close()
+	os.Remove(testWebhookDB)
+}
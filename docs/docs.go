@@ -0,0 +1,26 @@
+// Package docs holds the api's swagger specs, one per api version, so that
+// they can be served without shipping the docs directory. The version 1 spec
+// is generated by scripts/swagger.sh.
+package docs
+
+import (
+	"embed"
+	"fmt"
+)
+
+// SwaggerFile is the name of the version 1 swagger spec in FS
+const SwaggerFile = "swagger.yaml"
+
+// FS holds the swagger specs
+//
+//go:embed swagger*.yaml
+var FS embed.FS
+
+// SwaggerFileFor returns the name of the swagger spec of an api version, in
+// FS or in the directory of SwaggerFile on disk.
+func SwaggerFileFor(version int) string {
+	if version == 1 {
+		return SwaggerFile
+	}
+	return fmt.Sprintf("swagger.v%d.yaml", version)
+}
@@ -0,0 +1,4 @@
+// Package frontend holds the production build of the React frontend. The
+// build is only compiled into the binary with the embed build tag, after
+// running yarn build, since go:embed fails if the build directory is missing.
+package frontend
@@ -0,0 +1,22 @@
+//go:build embed
+
+package frontend
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// build holds the output of yarn build, including precompressed variants
+//
+//go:embed all:build
+var build embed.FS
+
+// FS returns the files of the production build.
+func FS() (fs.FS, bool) {
+	files, err := fs.Sub(build, "build")
+	if err != nil {
+		panic(err)
+	}
+	return files, true
+}
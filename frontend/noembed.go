@@ -0,0 +1,11 @@
+//go:build !embed
+
+package frontend
+
+import "io/fs"
+
+// FS returns the files of the production build, and false if the binary was
+// built without them.
+func FS() (fs.FS, bool) {
+	return nil, false
+}
@@ -3,70 +3,197 @@ package main
 import (
 	"context"
 	"flag"
-	"log"
+	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
+	"syscall"
 
+	"github.com/hrand1005/training-notebook/api/version"
 	"github.com/hrand1005/training-notebook/server"
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
-var prodMode = flag.Bool("prod", false, "Run in production mode and serve static files")
-var configFile = flag.String("config", "", "Path to file containing server configs")
+// Exit codes reported by the server process
+const (
+	// exitOK is returned after a clean shutdown
+	exitOK = 0
+	// exitError is returned if the server failed while running, or did not
+	// shut down cleanly
+	exitError = 1
+	// exitConfig is returned if the server configs could not be loaded
+	exitConfig = 2
+	// exitBuild is returned if the server could not be built from its configs
+	exitBuild = 3
+)
 
 func main() {
-	flag.Parse()
-	if err := godotenv.Load(); err != nil {
-		log.Printf("failed to load env variables for signing key: %v\n", err)
-		os.Setenv("SIGNING_KEY", "development")
+	os.Exit(run(os.Args[1:]))
+}
+
+// run starts the server and blocks until it has shut down, returning the
+// process exit code. "config print" prints the effective configs instead.
+func run(args []string) int {
+	printConfig := len(args) >= 2 && args[0] == "config" && args[1] == "print"
+	if printConfig {
+		args = args[2:]
+	}
+
+	fs := flag.NewFlagSet("training-notebook", flag.ContinueOnError)
+	configFile := fs.String("config", "", "Path to file containing server configs")
+	prodMode := fs.Bool("prod", false, "Deprecated: use --mode=prod")
+	overrides := registerConfigFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitConfig
+	}
+	if *prodMode {
+		if _, ok := overrides["mode"]; !ok {
+			overrides["mode"] = string(ModeProd)
+		}
+	}
+
+	// .env may set environment variables, such as the signing key
+	if err := godotenv.Load(); err != nil && !os.IsNotExist(err) {
+		slog.Warn("failed to load .env", "error", err)
 	}
 
 	// load server configs
-	srvConf, err := loadConfig(*prodMode, *configFile)
+	srvConf, err := loadConfig(*configFile, os.LookupEnv, overrides)
 	if err != nil {
-		log.Fatalf("failed to load server configs from %v: %v", *configFile, err)
+		slog.Error("failed to load server configs", "file", *configFile, "error", err)
+		return exitConfig
+	}
+
+	if printConfig {
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(srvConf.Redact()); err != nil {
+			slog.Error("failed to print server configs", "error", err)
+			return exitError
+		}
+		return exitOK
 	}
 
 	// build server with desired configuration
 	server, err := ConstructHTTPServer(srvConf)
 	if err != nil {
-		log.Fatalf("failed to build server: %v", err)
+		slog.Error("failed to build server", "error", err)
+		return exitBuild
 	}
 
-	// define shutdown conditions
+	// define shutdown conditions. Containers are stopped with SIGTERM, and
+	// os.Kill cannot be caught.
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt)
-	signal.Notify(sigChan, os.Kill)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
 	// define cancel context for server
 	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	// await kill signal
+	// await shutdown signal
 	go func() {
 		sig := <-sigChan
-		log.Printf("Recieved kill signal: %+v\n", sig)
+		slog.Info("received shutdown signal", "signal", sig.String())
 
+		// restore default handling, so that a second signal terminates the
+		// process without waiting for requests to drain
+		signal.Stop(sigChan)
 		cancel()
 	}()
 
-	// start server with cancel context
-	server.Start(ctx)
+	// reload configs while the server runs when they change or on SIGHUP
+	r := &reloader{
+		configPath: *configFile,
+		env:        os.LookupEnv,
+		overrides:  overrides,
+		current:    srvConf,
+		server:     server,
+	}
+	go r.watch(ctx)
+
+	// start server with cancel context. The log file is closed by the time
+	// Start returns, so errors are reported on stderr.
+	if err := server.Start(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "server did not shut down cleanly: %v\n", err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+// registerConfigFlags registers a flag for each setting, named by its key,
+// e.g. --log.level. Returns the values of the flags that are set, keyed by
+// setting, once fs is parsed.
+func registerConfigFlags(fs *flag.FlagSet) map[string]string {
+	overrides := make(map[string]string)
+	visitSettings(defaultConfig(), func(s setting) {
+		key := s.key
+		usage := fmt.Sprintf("Sets %v, overriding %v", key, s.env())
+		set := func(value string) error {
+			overrides[key] = value
+			return nil
+		}
+		if s.isBool() {
+			fs.BoolFunc(key, usage, set)
+		} else {
+			fs.Func(key, usage, set)
+		}
+	})
+	return overrides
 }
 
 // ConstructHTTPServer directs the construction of the server using a serverBuilder
 func ConstructHTTPServer(conf *Config) (server.Server, error) {
 	serverBuilder := server.NewBuilder()
 
-	serverBuilder.RegisterSwaggerDocs(conf.SwaggerSpec)
-	serverBuilder.RegisterFileLogger(conf.LogFile)
-	if conf.Prod {
-		serverBuilder.RegisterFrontend(conf.Frontend)
+	if conf.EmbedAssets {
+		serverBuilder.RegisterEmbeddedSwaggerDocs()
+	} else {
+		serverBuilder.RegisterSwaggerDocs(conf.SwaggerSpec)
+	}
+	serverBuilder.RegisterFileLogger(conf.Log.File)
+	serverBuilder.SetLogLevel(conf.Log.Level)
+	serverBuilder.SetLogFormat(conf.Log.Format)
+	serverBuilder.SetLogRotation(conf.Log.MaxSizeMB, conf.Log.MaxBackups, conf.Log.MaxAgeDays, conf.Log.Compress)
+	if conf.Mode == ModeProd {
+		if conf.EmbedAssets {
+			serverBuilder.RegisterEmbeddedFrontend()
+		} else {
+			serverBuilder.RegisterFrontend(conf.Frontend)
+		}
 	}
+	serverBuilder.SetSigningKey(conf.Auth.SigningKey)
+	serverBuilder.SetCookieSameSite(conf.Auth.CookieSameSite)
 	serverBuilder.SetDB(conf.Database.Path)
 	serverBuilder.SetServerAddr(conf.Server.Port)
 	serverBuilder.SetIdleTimeout(conf.Server.IdleTimeout)
 	serverBuilder.SetReadTimeout(conf.Server.ReadTimeout)
 	serverBuilder.SetWriteTimeout(conf.Server.WriteTimeout)
+	serverBuilder.SetShutdownGracePeriod(conf.Server.ShutdownGracePeriod)
+	serverBuilder.SetShutdownDrainDelay(conf.Server.ShutdownDrainDelay)
+	serverBuilder.SetIdempotencyWindow(conf.Server.IdempotencyWindow)
+	serverBuilder.SetRateLimits(conf.RateLimit.policies())
+	serverBuilder.SetTrustedProxies(conf.Server.TrustedProxies)
+	serverBuilder.SetCORS(conf.CORS.AllowedOrigins, conf.CORS.AllowCredentials, conf.CORS.MaxAge)
+	serverBuilder.SetSpecValidation(conf.ValidateRequests, conf.ValidateRequests && conf.Mode == ModeTest)
+	serverBuilder.SetAdminAddr(conf.Server.AdminPort)
+	serverBuilder.SetGRPCAddr(conf.Server.GRPCPort)
+	serverBuilder.SetWebhookPrivateNetworks(conf.Webhooks.AllowPrivateNetworks)
+	if deprecated, sunset, ok, _ := conf.APIVersions.v1(); ok {
+		serverBuilder.SetVersionDeprecation(version.V1, deprecated, sunset)
+	}
+	if conf.Server.TLS.Enabled() {
+		serverBuilder.SetTLS(conf.Server.TLS.CertFile, conf.Server.TLS.KeyFile)
+		serverBuilder.SetTLSPolicy(conf.Server.TLS.MinVersion, conf.Server.TLS.CipherPolicy)
+		serverBuilder.SetRedirectAddr(conf.Server.TLS.RedirectPort)
+	}
+	serverBuilder.SetTraceExporter(conf.Tracing.Exporter, conf.Tracing.File, conf.Tracing.OTLPEndpoint, conf.Tracing.OTLPInsecure)
+	serverBuilder.SetTraceSampleRatio(conf.Tracing.SampleRatio)
+	serverBuilder.SetServiceName(conf.Tracing.ServiceName)
 
 	return serverBuilder.Construct()
 }
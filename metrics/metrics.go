@@ -0,0 +1,163 @@
+// Package metrics defines the Prometheus metrics exported by the server. The
+// metrics are registered on Registry, which is served by Handler.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes the names of all exported metrics
+const Namespace = "training_notebook"
+
+// Login results used to label LoginAttempts
+const (
+	LoginSuccess = "success"
+	LoginFailure = "failure"
+)
+
+// Registry holds all metrics exported by the server, including Go runtime
+// and process metrics.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+var (
+	// HTTPRequests counts handled requests by method, route template and status
+	HTTPRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests handled, by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration observes request latency by method, route template and status
+	HTTPRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of HTTP requests, by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// GRPCRequests counts handled gRPC calls by full method name and status code
+	GRPCRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "grpc",
+		Name:      "requests_total",
+		Help:      "Number of gRPC calls handled, by method and code.",
+	}, []string{"method", "code"})
+
+	// GRPCRequestDuration observes gRPC call latency by full method name and status code
+	GRPCRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "grpc",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of gRPC calls, by method and code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "code"})
+
+	// LoginAttempts counts logins by result
+	LoginAttempts = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "auth",
+		Name:      "login_attempts_total",
+		Help:      "Number of login attempts, by result.",
+	}, []string{"result"})
+
+	// DBQueryDuration observes the duration of database operations by name
+	DBQueryDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Duration of SQLite operations, by operation.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation"})
+
+	// Sessions tracks users holding an unexpired login token
+	Sessions = newSessionTracker(time.Now)
+)
+
+func init() {
+	factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "auth",
+		Name:      "active_sessions",
+		Help:      "Number of users holding an unexpired login token issued by this server.",
+	}, func() float64 {
+		return float64(Sessions.Active())
+	})
+}
+
+// ObserveQuery starts timing the named database operation. Call the returned
+// function when the operation completes.
+func ObserveQuery(operation string) func() {
+	start := time.Now()
+	return func() {
+		DBQueryDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RegisterDBStats exports the connection pool statistics of db.
+func RegisterDBStats(db *sql.DB) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, "sqlite"))
+}
+
+// Handler serves the metrics in Registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// sessionTracker records when each user's most recent login token expires.
+// Tokens are stateless, so a session is considered active until its token expires.
+type sessionTracker struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	expires map[int]time.Time
+}
+
+func newSessionTracker(now func() time.Time) *sessionTracker {
+	return &sessionTracker{
+		now:     now,
+		expires: make(map[int]time.Time),
+	}
+}
+
+// Start records a login by userID whose token expires at expires.
+func (s *sessionTracker) Start(userID int, expires time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if expires.After(s.expires[userID]) {
+		s.expires[userID] = expires
+	}
+}
+
+// Active returns the number of users with an unexpired token, and forgets
+// expired tokens.
+func (s *sessionTracker) Active() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for userID, expires := range s.expires {
+		if !expires.After(now) {
+			delete(s.expires, userID)
+		}
+	}
+	return len(s.expires)
+}
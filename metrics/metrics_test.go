@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSessionTracker checks that sessions are active until their token expires,
+// and that each user is counted once.
+func TestSessionTracker(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := newSessionTracker(func() time.Time { return now })
+
+	s.Start(1, now.Add(time.Hour))
+	s.Start(1, now.Add(30*time.Minute))
+	s.Start(2, now.Add(2*time.Hour))
+	s.Start(3, now.Add(-time.Minute))
+
+	steps := []struct {
+		name    string
+		elapsed time.Duration
+		want    int
+	}{
+		{name: "Expired tokens are not counted", elapsed: 0, want: 2},
+		{name: "Earlier login does not shorten a session", elapsed: 45 * time.Minute, want: 2},
+		{name: "Session ends when its latest token expires", elapsed: time.Hour, want: 1},
+		{name: "All sessions expire", elapsed: 2 * time.Hour, want: 0},
+	}
+
+	start := now
+	for _, v := range steps {
+		now = start.Add(v.elapsed)
+		if got := s.Active(); got != v.want {
+			t.Fatalf("%s: Wanted %v active sessions, got %v", v.name, v.want, got)
+		}
+	}
+}
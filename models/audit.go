@@ -0,0 +1,79 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditAction describes the kind of change recorded by an AuditEvent
+type AuditAction string
+
+const (
+	AuditCreate AuditAction = "create"
+	AuditUpdate AuditAction = "update"
+	AuditDelete AuditAction = "delete"
+)
+
+// AuditTarget describes the type of resource changed by an AuditEvent
+type AuditTarget string
+
+const (
+	AuditTargetSet  AuditTarget = "set"
+	AuditTargetUser AuditTarget = "user"
+)
+
+// AuditEvent is an append-only record of a single change to a resource.
+// Before and After hold the JSON representation of the resource on either
+// side of the change, and are empty for creates and deletes respectively.
+// swagger:model
+type AuditEvent struct {
+	ID         int64           `json:"event-id"`
+	ActorID    UserID          `json:"actor-id"`
+	OwnerID    UserID          `json:"owner-id"`
+	Action     AuditAction     `json:"action"`
+	TargetType AuditTarget     `json:"target-type"`
+	TargetID   int             `json:"target-id"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	RequestID  string          `json:"request-id,omitempty"`
+	Timestamp  time.Time       `json:"timestamp"`
+}
+
+// AuditFilter narrows the audit events returned from a query. Zero valued
+// fields are ignored, so an empty AuditFilter matches every event.
+type AuditFilter struct {
+	ActorID    UserID
+	OwnerID    UserID
+	Action     AuditAction
+	TargetType AuditTarget
+	TargetID   int
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+	Offset     int
+}
+
+// NewAuditEvent builds an AuditEvent with the JSON encodings of before and
+// after. Either may be nil, in which case the corresponding field is left empty.
+func NewAuditEvent(action AuditAction, target AuditTarget, targetID int, before, after interface{}) (*AuditEvent, error) {
+	e := &AuditEvent{
+		Action:     action,
+		TargetType: target,
+		TargetID:   targetID,
+		Timestamp:  time.Now().UTC(),
+	}
+
+	var err error
+	if before != nil {
+		if e.Before, err = json.Marshal(before); err != nil {
+			return nil, err
+		}
+	}
+	if after != nil {
+		if e.After, err = json.Marshal(after); err != nil {
+			return nil, err
+		}
+	}
+
+	return e, nil
+}
@@ -0,0 +1,83 @@
+package models
+
+// SetOpType is the kind of operation in a batch of set operations
+type SetOpType string
+
+const (
+	SetOpCreate SetOpType = "create"
+	SetOpUpdate SetOpType = "update"
+	SetOpDelete SetOpType = "delete"
+)
+
+// SetOperation is a single create, update or delete in a batch of operations
+// on sets. Creates and updates require a Set, while updates and deletes require
+// the SetID of an existing set. If Version is non-zero, the update or delete
+// only applies at that version of the set.
+// swagger:model
+type SetOperation struct {
+	Op      SetOpType `json:"op"`
+	SetID   SetID     `json:"set-id,omitempty"`
+	Version int       `json:"version,omitempty"`
+	Set     *Set      `json:"set,omitempty"`
+	// Previous is the state of an updated or deleted set before the operation
+	Previous *Set `json:"-"`
+}
+
+// SetOperationResult describes the outcome of a single operation in a batch,
+// identified by its index in the request.
+// swagger:model
+type SetOperationResult struct {
+	Index   int       `json:"index"`
+	Op      SetOpType `json:"op"`
+	Status  int       `json:"status"`
+	Set     *Set      `json:"set,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+// SetOperationV2 is the representation of a SetOperation in version 2 of the
+// api, where the set operated on is identified by an id field.
+// swagger:model
+type SetOperationV2 struct {
+	Op      SetOpType `json:"op"`
+	ID      SetID     `json:"id,omitempty"`
+	Version int       `json:"version,omitempty"`
+	Set     *SetV2    `json:"set,omitempty"`
+}
+
+// SetOperation returns the operation represented by op.
+func (op *SetOperationV2) SetOperation() *SetOperation {
+	o := &SetOperation{
+		Op:      op.Op,
+		SetID:   op.ID,
+		Version: op.Version,
+	}
+	if op.Set != nil {
+		o.Set = op.Set.Set()
+	}
+	return o
+}
+
+// SetOperationResultV2 is the representation of a SetOperationResult in
+// version 2 of the api.
+// swagger:model
+type SetOperationResultV2 struct {
+	Index   int       `json:"index"`
+	Op      SetOpType `json:"op"`
+	Status  int       `json:"status"`
+	Set     *SetV2    `json:"set,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+// V2 returns the version 2 representation of the result.
+func (r *SetOperationResult) V2() *SetOperationResultV2 {
+	v2 := &SetOperationResultV2{
+		Index:   r.Index,
+		Op:      r.Op,
+		Status:  r.Status,
+		Message: r.Message,
+	}
+	if r.Set != nil {
+		v2.Set = r.Set.V2()
+	}
+	return v2
+}
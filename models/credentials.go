@@ -4,3 +4,10 @@ type Credentails struct {
 	UID      UserID `json:"user-id"`
 	Password string `json:"password,omitempty"`
 }
+
+// PasswordChange defines the request to change a user's password. The current
+// password must be provided to verify the request.
+type PasswordChange struct {
+	CurrentPassword string `json:"current-password" binding:"required"`
+	NewPassword     string `json:"new-password" binding:"required"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// IdempotencyRecord stores the response to a request made with an
+// Idempotency-Key, so that retries of the request can be answered with the
+// original response. A zero Status means the original request is still in progress.
+type IdempotencyRecord struct {
+	Scope       string
+	Key         string
+	Fingerprint string
+	Status      int
+	Header      map[string]string
+	Body        []byte
+	CreatedAt   time.Time
+}
@@ -14,10 +14,7 @@ type SetID int
 // Set is the model representation of a sets resource
 // swagger:model
 type Set struct {
-	// the id for this set
-	//
-	// required: true
-	// min: 1
+	// the id for this set, assigned by the server
 	ID            SetID   `json:"set-id"`
 	UID           UserID  `json:"user-id,omitempty"`
 	Movement      string  `json:"movement" binding:"movement"`
@@ -25,6 +22,43 @@ type Set struct {
 	Intensity     float64 `json:"intensity" binding:"gt=0,lte=100"`
 	CreatedOn     string  `json:"-"`
 	LastUpdatedOn string  `json:"-"`
+	// Version is incremented on every update, and is exposed to clients as an ETag
+	Version int `json:"-"`
+}
+
+// SetV2 is the representation of a set in version 2 of the api, where every
+// resource is identified by an id field
+// swagger:model
+type SetV2 struct {
+	// the id for this set, assigned by the server
+	ID SetID `json:"id"`
+	// the id of the user owning this set, assigned by the server
+	OwnerID   UserID  `json:"owner-id,omitempty"`
+	Movement  string  `json:"movement" binding:"movement"`
+	Volume    float64 `json:"volume" binding:"gt=0"`
+	Intensity float64 `json:"intensity" binding:"gt=0,lte=100"`
+}
+
+// V2 returns the version 2 representation of the set.
+func (s *Set) V2() *SetV2 {
+	return &SetV2{
+		ID:        s.ID,
+		OwnerID:   s.UID,
+		Movement:  s.Movement,
+		Volume:    s.Volume,
+		Intensity: s.Intensity,
+	}
+}
+
+// Set returns the set represented by s.
+func (s *SetV2) Set() *Set {
+	return &Set{
+		ID:        s.ID,
+		UID:       s.OwnerID,
+		Movement:  s.Movement,
+		Volume:    s.Volume,
+		Intensity: s.Intensity,
+	}
 }
 
 // MovementValidator validates the movement field in a Set.
@@ -66,11 +100,8 @@ func ContainsSet(sets []*Set, s *Set) bool {
 // a readable message on the field's constraints.
 func BindingErrorToMessage(err error) string {
 	var msg string
-	var ve validator.ValidationErrors
-	if errors.As(err, &ve) {
-		for _, v := range ve {
-			msg += fmt.Sprintf("'%s' field %v.", v.Field(), fieldErrorToMessage(v))
-		}
+	for _, v := range BindingErrorToFieldErrors(err) {
+		msg += v.Message
 	}
 	if msg == "" {
 		msg = "Malformed JSON"
@@ -78,15 +109,45 @@ func BindingErrorToMessage(err error) string {
 	return msg
 }
 
+// FieldError describes a single field that failed validation.
+type FieldError struct {
+	Field   string `json:"field"`
+	Tag     string `json:"code"`
+	Message string `json:"message"`
+}
+
+// BindingErrorToFieldErrors turns a binding error into a readable message for
+// each field that failed validation. Returns nil if err is not a validation
+// error, for example if the JSON was malformed.
+func BindingErrorToFieldErrors(err error) []FieldError {
+	var ve validator.ValidationErrors
+	if !errors.As(err, &ve) {
+		return nil
+	}
+
+	fieldErrors := make([]FieldError, 0, len(ve))
+	for _, v := range ve {
+		fieldErrors = append(fieldErrors, FieldError{
+			Field:   v.Field(),
+			Tag:     v.Tag(),
+			Message: fmt.Sprintf("'%s' field %v.", v.Field(), fieldErrorToMessage(v)),
+		})
+	}
+	return fieldErrors
+}
+
 // customTagToMessage gets condition for custom validators
 var customTagToMessage = map[string]string{
 	"movement": "must use unicode characters",
+	"required": "is required",
 }
 
 // standardTagToMessage gets conditions for standard validators
 var standardTagToMessage = map[string]string{
-	"gt":  "must be greater than",
-	"lte": "must be no more than",
+	"gt":    "must be greater than",
+	"gte":   "must be at least",
+	"lte":   "must be no more than",
+	"oneof": "must be one of",
 }
 
 // fieldErrorToMessage gets either standard or custom error messages
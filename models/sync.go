@@ -0,0 +1,91 @@
+package models
+
+import "time"
+
+// SyncChange is a change made to a set by a client, possibly while offline.
+// Sets created on the client are identified by the id it generated until they
+// are synced and assigned a set id. Each of the given fields is only changed
+// if the change was made after the field was last changed on the server.
+// swagger:model
+type SyncChange struct {
+	// the id the client generated for the set, e.g. a UUID. Required unless
+	// set-id is given.
+	ClientID string `json:"client-id,omitempty" binding:"required_without=SetID,max=64"`
+	// the id of a set the client has synced before
+	SetID SetID `json:"set-id,omitempty" binding:"gte=0"`
+	// deletes the set
+	Deleted bool `json:"deleted,omitempty"`
+	// when the change was made on the client
+	ModifiedAt time.Time `json:"modified-at" binding:"required"`
+	// the fields changed, all of which are required to create a set
+	Fields SetFields `json:"fields"`
+}
+
+// SetFields are the fields of a set changed by a SyncChange. Fields that are
+// not set are left unchanged.
+type SetFields struct {
+	Movement  *string  `json:"movement,omitempty" binding:"omitempty,movement"`
+	Volume    *float64 `json:"volume,omitempty" binding:"omitempty,gt=0"`
+	Intensity *float64 `json:"intensity,omitempty" binding:"omitempty,gt=0,lte=100"`
+}
+
+// Complete reports whether every field is set, as required to create a set.
+func (f SetFields) Complete() bool {
+	return f.Movement != nil && f.Volume != nil && f.Intensity != nil
+}
+
+// SyncedSet is the latest state of a set changed since a sync. Deleted sets
+// are tombstones without a Set, so that clients delete them too.
+type SyncedSet struct {
+	SetID    SetID
+	ClientID string
+	Deleted  bool
+	Set      *Set
+}
+
+// ConflictReason explains why a change of a client was discarded
+type ConflictReason string
+
+const (
+	// ConflictServerNewer changes lost to a later change to the field on
+	// the server, whose value was kept
+	ConflictServerNewer ConflictReason = "server-newer"
+	// ConflictDeleted changes were made to a set deleted on the server
+	ConflictDeleted ConflictReason = "deleted"
+	// ConflictModified deletions lost to a later change to the set on the
+	// server, which was kept
+	ConflictModified ConflictReason = "modified"
+	// ConflictNotFound changes were made to a set that does not exist
+	ConflictNotFound ConflictReason = "not-found"
+)
+
+// SyncConflict reports a change of a client that was discarded, in whole or
+// for one field.
+// swagger:model
+type SyncConflict struct {
+	ClientID string `json:"client-id,omitempty"`
+	SetID    SetID  `json:"set-id,omitempty"`
+	// the field whose change was discarded, or deleted for deletions
+	Field  string         `json:"field,omitempty"`
+	Reason ConflictReason `json:"reason"`
+	// the discarded value
+	ClientValue interface{} `json:"client-value,omitempty"`
+	// the value kept on the server
+	ServerValue interface{} `json:"server-value,omitempty"`
+}
+
+// SyncResult is the outcome of a sync
+type SyncResult struct {
+	// Changes are the changes to the user's sets since the sync token, in
+	// the order they were made, including the client's changes
+	Changes []*SyncedSet
+	// Conflicts report the client's changes that were discarded
+	Conflicts []*SyncConflict
+	// Applied are the client's changes that were applied, with the state of
+	// each set before and after
+	Applied []*SetOperation
+	// Token resumes the changes after the last of Changes
+	Token int64
+	// More is set if there are changes after the last of Changes
+	More bool
+}
@@ -8,6 +8,43 @@ type User struct {
 	ID       UserID `json:"user-id"`
 	Name     string `json:"name"`
 	Password string `json:"password,omitempty"`
+	// Admin is never bound from requests, and may only be granted directly in the database
+	Admin bool `json:"-"`
+}
+
+// UserV2 is the representation of a user in version 2 of the api, where every
+// resource is identified by an id field
+type UserV2 struct {
+	ID       UserID `json:"id"`
+	Name     string `json:"name"`
+	Password string `json:"password,omitempty"`
+}
+
+// V2 returns the version 2 representation of the user, without sensitive
+// data.
+func (u *User) V2() *UserV2 {
+	return &UserV2{
+		ID:   u.ID,
+		Name: u.Name,
+	}
+}
+
+// User returns the user represented by u.
+func (u *UserV2) User() *User {
+	return &User{
+		ID:       u.ID,
+		Name:     u.Name,
+		Password: u.Password,
+	}
+}
+
+// Sanitized returns a copy of the user without sensitive data, suitable for
+// responses and records that outlive the request.
+func (u *User) Sanitized() *User {
+	return &User{
+		ID:   u.ID,
+		Name: u.Name,
+	}
 }
 
 // UsersEqual returns true if all non-id fields of the user are equal, and false otherwise.
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebhookID is the unique identifier of a webhook
+type WebhookID int
+
+// Webhook delivers the events it subscribes to as signed POST requests to
+// URL. Webhooks receive changes owned by their owner, or by every user if
+// AllUsers is set, which only administrators may do.
+// swagger:model
+type Webhook struct {
+	ID       WebhookID `json:"webhook-id"`
+	OwnerID  UserID    `json:"owner-id"`
+	URL      string    `json:"url"`
+	Events   []string  `json:"events"`
+	AllUsers bool      `json:"all-users"`
+	// Secret signs deliveries. It is only returned when the webhook is
+	// created.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created-at"`
+}
+
+// Subscribes reports whether w is subscribed to events of the given type.
+func (w *Webhook) Subscribes(eventType string) bool {
+	for _, e := range w.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliveryStatus is the state of a WebhookDelivery
+type DeliveryStatus string
+
+const (
+	// DeliveryPending deliveries are waiting for their next attempt
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliveryDelivered deliveries were accepted by the receiver
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryFailed deliveries ran out of attempts
+	DeliveryFailed DeliveryStatus = "failed"
+)
+
+// WebhookDelivery is an event queued for delivery to a webhook. Failed
+// attempts are retried until the delivery succeeds or runs out of attempts,
+// and the outcome of the latest attempt is kept.
+// swagger:model
+type WebhookDelivery struct {
+	ID        int64           `json:"delivery-id"`
+	WebhookID WebhookID       `json:"webhook-id"`
+	Event     string          `json:"event"`
+	Payload   json.RawMessage `json:"payload"`
+	Status    DeliveryStatus  `json:"status"`
+	Attempts  int             `json:"attempts"`
+	// the status of the receiver's response to the latest attempt, or zero
+	// if it did not respond
+	ResponseCode int `json:"response-code,omitempty"`
+	// why the latest attempt failed
+	Error         string     `json:"error,omitempty"`
+	CreatedAt     time.Time  `json:"created-at"`
+	LastAttemptAt *time.Time `json:"last-attempt-at,omitempty"`
+	// when the delivery is next attempted, while it is pending
+	NextAttemptAt *time.Time `json:"next-attempt-at,omitempty"`
+}
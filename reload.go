@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/hrand1005/training-notebook/server"
+)
+
+// reloadDebounce groups the several events editors emit when saving a file
+// into a single reload
+const reloadDebounce = 250 * time.Millisecond
+
+// reloader reloads configs from the same sources they were first loaded
+// from, and applies reloadable settings to a running server
+type reloader struct {
+	configPath string
+	env        func(string) (string, bool)
+	overrides  map[string]string
+	current    *Config
+	server     server.Server
+}
+
+// settings returns the settings of conf that can be applied while the
+// server is running.
+func settings(conf *Config) server.Settings {
+	return server.Settings{
+		LogLevel:          conf.Log.Level,
+		IdempotencyWindow: conf.Server.IdempotencyWindow,
+		RateLimits:        conf.RateLimit.policies(),
+		CORS:              conf.CORS.options(),
+	}
+}
+
+// reload loads the configs again and applies any changed reloadable
+// settings. Invalid configs are rejected, keeping the current configs, and
+// changes to settings that require a restart are reported but not applied.
+func (r *reloader) reload(trigger string) {
+	candidate, err := layerConfig(r.configPath, r.env, r.overrides)
+	if err != nil {
+		slog.Error("rejected config reload, keeping current configs", "trigger", trigger, "error", err)
+		return
+	}
+
+	changes := diffConfig(r.current, candidate)
+	diff := make([]string, 0, len(changes))
+	for _, c := range changes {
+		diff = append(diff, c.String())
+	}
+
+	if err := candidate.Validate(); err != nil {
+		slog.Error("rejected config reload, keeping current configs", "trigger", trigger, "error", err, "diff", diff)
+		return
+	}
+	if len(changes) == 0 {
+		slog.Info("configs reloaded without changes", "trigger", trigger)
+		return
+	}
+
+	// settings that require a restart keep their current values, so that the
+	// current configs describe what the server is running with
+	applied := r.current.withReloadable(candidate)
+	var restart []string
+	for _, c := range changes {
+		if !c.reloadable {
+			restart = append(restart, c.key)
+		}
+	}
+
+	if err := r.server.Reload(settings(applied)); err != nil {
+		slog.Error("rejected config reload, keeping current configs", "trigger", trigger, "error", err, "diff", diff)
+		return
+	}
+	r.current = applied
+
+	slog.Info("configs reloaded", "trigger", trigger, "diff", diff)
+	if len(restart) > 0 {
+		slog.Warn("changed settings require a restart to take effect", "settings", restart)
+	}
+}
+
+// watch reloads configs whenever the config file changes or the process
+// receives SIGHUP, until ctx is done. Reloads are run one at a time.
+func (r *reloader) watch(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var events chan fsnotify.Event
+	var watchErrs chan error
+	if r.configPath != "" {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			slog.Warn("failed to watch config file, reload with SIGHUP", "error", err)
+		} else {
+			defer watcher.Close()
+			// watch the directory, since editors and orchestrators replace the
+			// file rather than writing to it
+			if err := watcher.Add(filepath.Dir(r.configPath)); err != nil {
+				slog.Warn("failed to watch config file, reload with SIGHUP", "file", r.configPath, "error", err)
+			} else {
+				events, watchErrs = watcher.Events, watcher.Errors
+			}
+		}
+	}
+
+	configFile := filepath.Clean(r.configPath)
+	debounce := time.NewTimer(reloadDebounce)
+	debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.reload("SIGHUP")
+		case e := <-events:
+			if filepath.Clean(e.Name) != configFile || e.Op == fsnotify.Chmod {
+				continue
+			}
+			debounce.Reset(reloadDebounce)
+		case err := <-watchErrs:
+			slog.Warn("error watching config file", "file", r.configPath, "error", err)
+		case <-debounce.C:
+			r.reload("file changed")
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hrand1005/training-notebook/api/cors"
+	"github.com/hrand1005/training-notebook/server"
+)
+
+// mockServer records the settings applied by reloads
+type mockServer struct {
+	reloads []server.Settings
+}
+
+func (m *mockServer) Start(context.Context) error {
+	return nil
+}
+
+func (m *mockServer) Reload(s server.Settings) error {
+	m.reloads = append(m.reloads, s)
+	return nil
+}
+
+// TestReload checks that reloadable settings are applied, settings that
+// require a restart are kept, and invalid configs are rejected.
+func TestReload(t *testing.T) {
+	const initial = `
+log:
+  level: "info"
+server-settings:
+  port: ":8080"
+  idempotency-window: 24h
+`
+	tests := []struct {
+		name        string
+		file        string
+		wantReload  *server.Settings
+		wantLevel   string
+		wantPort    string
+		wantChanges []string
+	}{
+		{
+			name: "Reloadable settings are applied",
+			file: `
+log:
+  level: "debug"
+server-settings:
+  port: ":8080"
+  idempotency-window: 1h
+`,
+			wantReload:  &server.Settings{LogLevel: "debug", IdempotencyWindow: time.Hour, CORS: cors.Options{MaxAge: 10 * time.Minute}},
+			wantLevel:   "debug",
+			wantPort:    ":8080",
+			wantChanges: []string{"log.level", "server-settings.idempotency-window"},
+		},
+		{
+			name: "Settings requiring restart are kept",
+			file: `
+log:
+  level: "warn"
+server-settings:
+  port: ":9000"
+  idempotency-window: 24h
+`,
+			wantReload:  &server.Settings{LogLevel: "warn", IdempotencyWindow: 24 * time.Hour, CORS: cors.Options{MaxAge: 10 * time.Minute}},
+			wantLevel:   "warn",
+			wantPort:    ":8080",
+			wantChanges: []string{"log.level", "server-settings.port"},
+		},
+		{
+			name: "Changed CORS origins are applied",
+			file: `
+cors:
+  allowed-origins: ["http://localhost:3000"]
+  allow-credentials: true
+log:
+  level: "info"
+server-settings:
+  port: ":8080"
+  idempotency-window: 24h
+`,
+			wantReload: &server.Settings{
+				LogLevel:          "info",
+				IdempotencyWindow: 24 * time.Hour,
+				CORS:              cors.Options{AllowedOrigins: []string{"http://localhost:3000"}, AllowCredentials: true, MaxAge: 10 * time.Minute},
+			},
+			wantLevel:   "info",
+			wantPort:    ":8080",
+			wantChanges: []string{"cors.allowed-origins", "cors.allow-credentials"},
+		},
+		{
+			name: "Invalid configs are rejected",
+			file: `
+log:
+  level: "loud"
+server-settings:
+  port: ":8080"
+  idempotency-window: 1h
+`,
+			wantLevel:   "info",
+			wantPort:    ":8080",
+			wantChanges: []string{"log.level", "server-settings.idempotency-window"},
+		},
+		{
+			name:      "Unparseable configs are rejected",
+			file:      "log: [",
+			wantLevel: "info",
+			wantPort:  ":8080",
+		},
+	}
+
+	for _, v := range tests {
+		path := writeConfigFile(t, initial)
+		conf, err := loadConfig(path, lookup(nil), nil)
+		if err != nil {
+			t.Fatalf("%s: Encountered unexpected error: %v", v.name, err)
+		}
+
+		if err := os.WriteFile(path, []byte(v.file), 0644); err != nil {
+			t.Fatalf("%s: failed to write config file: %v", v.name, err)
+		}
+		if candidate, err := layerConfig(path, lookup(nil), nil); err == nil {
+			changes := diffConfig(conf, candidate)
+			if len(changes) != len(v.wantChanges) {
+				t.Fatalf("%s: wanted changes %v, got %v", v.name, v.wantChanges, changes)
+			}
+			for i, c := range changes {
+				if c.key != v.wantChanges[i] {
+					t.Fatalf("%s: wanted changes %v, got %v", v.name, v.wantChanges, changes)
+				}
+			}
+		}
+
+		srv := &mockServer{}
+		r := &reloader{configPath: path, env: lookup(nil), current: conf, server: srv}
+		r.reload("test")
+
+		if v.wantReload == nil && len(srv.reloads) != 0 {
+			t.Fatalf("%s: wanted no reload, got %+v", v.name, srv.reloads)
+		}
+		if v.wantReload != nil && (len(srv.reloads) != 1 || !reflect.DeepEqual(srv.reloads[0], *v.wantReload)) {
+			t.Fatalf("%s: wanted reload %+v, got %+v", v.name, *v.wantReload, srv.reloads)
+		}
+		if r.current.Log.Level != v.wantLevel || r.current.Server.Port != v.wantPort {
+			t.Fatalf("%s: unexpected current configs: %+v", v.name, r.current)
+		}
+	}
+}
+
+// TestDiffConfigRedactsSecrets checks that changed secrets are reported
+// without their values.
+func TestDiffConfigRedactsSecrets(t *testing.T) {
+	from, to := defaultConfig(), defaultConfig()
+	from.Auth.SigningKey = "old"
+	to.Auth.SigningKey = "new"
+
+	changes := diffConfig(from, to)
+	if len(changes) != 1 || changes[0].key != "auth.signing-key" {
+		t.Fatalf("wanted signing key change, got %v", changes)
+	}
+	if changes[0].from != Redacted || changes[0].to != Redacted || changes[0].reloadable {
+		t.Fatalf("wanted redacted change requiring restart, got %+v", changes[0])
+	}
+}
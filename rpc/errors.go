@@ -0,0 +1,43 @@
+package rpc
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/hrand1005/training-notebook/api/problem"
+	"github.com/hrand1005/training-notebook/models"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// internalError logs err server side and returns a generic internal error, so
+// that details of the failure are never exposed to the client.
+func internalError(ctx context.Context, err error) error {
+	method, _ := grpc.Method(ctx)
+	slog.Error("internal error", "request_id", RequestIDFromContext(ctx), "method", method, "error", err)
+	return status.Error(codes.Internal, problem.InternalDetail)
+}
+
+// invalidArgument returns an error describing the fields of a request that
+// failed validation, with a BadRequest detail listing each of them.
+func invalidArgument(err error) error {
+	st := status.New(codes.InvalidArgument, models.BindingErrorToMessage(err))
+
+	fieldErrors := models.BindingErrorToFieldErrors(err)
+	if len(fieldErrors) == 0 {
+		return st.Err()
+	}
+	details := &errdetails.BadRequest{}
+	for _, fe := range fieldErrors {
+		details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       fe.Field,
+			Description: fe.Message,
+		})
+	}
+	if withDetails, err := st.WithDetails(details); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
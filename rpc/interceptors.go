@@ -0,0 +1,346 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hrand1005/training-notebook/api/ratelimit"
+	"github.com/hrand1005/training-notebook/api/requestid"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/metrics"
+	"github.com/hrand1005/training-notebook/models"
+	"github.com/hrand1005/training-notebook/rpc/notebookpb"
+	"github.com/hrand1005/training-notebook/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// requestIDKey is the metadata key carrying the request id in calls and
+// their response headers
+var requestIDKey = strings.ToLower(requestid.Header)
+
+// publicMethods may be called without a login token. Health checks and
+// server reflection are public too.
+var publicMethods = map[string]bool{
+	notebookpb.UserService_Signup_FullMethodName: true,
+	notebookpb.UserService_Login_FullMethodName:  true,
+}
+
+// publicServices may be called without a login token, by method name prefix
+var publicServices = []string{
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.",
+}
+
+// limitedRoutes are the REST routes whose rate limits are shared by methods,
+// so that clients cannot get around the limits by switching api
+var limitedRoutes = map[string]struct{ route, method string }{
+	notebookpb.UserService_Signup_FullMethodName: {"/api/signup", http.MethodPost},
+	notebookpb.UserService_Login_FullMethodName:  {"/api/login", http.MethodPost},
+}
+
+// retryAfterKey is the metadata key telling rate limited clients how many
+// seconds to wait before retrying
+const retryAfterKey = "retry-after"
+
+// call holds the values the interceptors set for a call. It is shared by
+// pointer, so that values set by later interceptors are seen by earlier ones
+// once the call completes.
+type call struct {
+	requestID string
+	userID    models.UserID
+	loggedIn  bool
+}
+
+type callKey struct{}
+
+// callFromContext returns the values set for the call, which are empty if
+// the call was not intercepted.
+func callFromContext(ctx context.Context) *call {
+	if c, ok := ctx.Value(callKey{}).(*call); ok {
+		return c
+	}
+	return &call{}
+}
+
+// UserIDFromContext returns the id of the user logged in for the call, set
+// by the authentication interceptors.
+func UserIDFromContext(ctx context.Context) (models.UserID, bool) {
+	c := callFromContext(ctx)
+	return c.userID, c.loggedIn
+}
+
+// loggedIn returns the id of the user logged in for the call, or an error if
+// there is none.
+func loggedIn(ctx context.Context) (models.UserID, error) {
+	userID, ok := UserIDFromContext(ctx)
+	if !ok {
+		return data.InvalidUserID, status.Error(codes.Unauthenticated, "you must be logged in to perform this action")
+	}
+	return userID, nil
+}
+
+// RequestIDFromContext returns the request id of the call, or an empty string
+// if it has none.
+func RequestIDFromContext(ctx context.Context) string {
+	return callFromContext(ctx).requestID
+}
+
+// actorFromContext returns who makes the changes in the call, for the audit
+// log. Signups have no logged in user, so the actor is left unset and the
+// new user is recorded as having signed themselves up.
+func actorFromContext(ctx context.Context) data.Actor {
+	c := callFromContext(ctx)
+	actor := data.Actor{RequestID: c.requestID}
+	if c.loggedIn {
+		actor.UserID = c.userID
+	}
+	return actor
+}
+
+// observeUnary wraps unary calls with observe.
+func observeUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, done := observe(ctx, info.FullMethod)
+	// the request id is echoed in the response headers
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, RequestIDFromContext(ctx)))
+
+	resp, err := handler(ctx, req)
+	done(err)
+	return resp, err
+}
+
+// observeStream wraps streaming calls with observe.
+func observeStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, done := observe(ss.Context(), info.FullMethod)
+	ss.SetHeader(metadata.Pairs(requestIDKey, RequestIDFromContext(ctx)))
+
+	err := handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	done(err)
+	return err
+}
+
+// observe assigns a call a request id, reusing the one sent by the client in
+// the x-request-id metadata if it is valid, and starts a server span for it,
+// continuing any trace propagated in the metadata. Call the returned function
+// with the error the call ended with to log it, record its metrics and end
+// the span. Calls failing with a server error are logged at error level, and
+// others failing at warn level.
+func observe(ctx context.Context, method string) (context.Context, func(error)) {
+	start := time.Now()
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	id := firstValue(md, requestIDKey)
+	if !requestid.Valid(id) {
+		id = requestid.New()
+	}
+	ctx = context.WithValue(ctx, callKey{}, &call{requestID: id})
+
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	ctx, span := tracing.Tracer().Start(ctx, strings.TrimPrefix(method, "/"),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.method", method),
+			attribute.String("request.id", id),
+		),
+	)
+
+	return ctx, func(err error) {
+		code := status.Code(err)
+		span.SetAttributes(attribute.String("rpc.grpc.status_code", code.String()))
+		if serverError(code) {
+			tracing.End(span, err)
+		} else {
+			span.End()
+		}
+
+		metrics.GRPCRequests.WithLabelValues(method, code.String()).Inc()
+		metrics.GRPCRequestDuration.WithLabelValues(method, code.String()).Observe(time.Since(start).Seconds())
+
+		level := slog.LevelInfo
+		switch {
+		case serverError(code):
+			level = slog.LevelError
+		case code != codes.OK:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("request_id", id),
+			slog.String("method", method),
+			slog.String("code", code.String()),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+		}
+		if userID, ok := UserIDFromContext(ctx); ok {
+			attrs = append(attrs, slog.Int("user_id", int(userID)))
+		}
+		if sc := span.SpanContext(); sc.IsValid() {
+			attrs = append(attrs, slog.String("trace_id", sc.TraceID().String()))
+		}
+
+		slog.LogAttrs(ctx, level, "rpc handled", attrs...)
+	}
+}
+
+// authenticateUnary wraps unary calls with authenticate.
+func authenticateUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authenticateStream wraps streaming calls with authenticate.
+func authenticateStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticate checks that calls to methods other than public ones carry an
+// unexpired login token in the authorization metadata, as "Bearer <token>",
+// and sets the logged in user in the returned context. The token is the same
+// as the one used by the REST api, and expires with its login cookie.
+func authenticate(ctx context.Context, method string) (context.Context, error) {
+	if public(method) {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	scheme, token, ok := strings.Cut(firstValue(md, "authorization"), " ")
+	token = strings.TrimSpace(token)
+	if !ok || !strings.EqualFold(scheme, users.BearerScheme) || token == "" {
+		return nil, status.Error(codes.Unauthenticated, "you must be logged in to perform this action")
+	}
+
+	_, span := tracing.Start(ctx, "rpc.authenticate")
+	userID, err := users.UserIDFromToken(token)
+	tracing.End(span, err)
+	if err != nil || userID == data.InvalidUserID {
+		return nil, status.Error(codes.Unauthenticated, "your login is invalid or has expired, log in again")
+	}
+
+	c, ok := ctx.Value(callKey{}).(*call)
+	if !ok {
+		c = &call{}
+		ctx = context.WithValue(ctx, callKey{}, c)
+	}
+	c.userID, c.loggedIn = userID, true
+	return ctx, nil
+}
+
+// rateLimitUnary returns an interceptor limiting calls to methods sharing a
+// REST route by the policies of limiter, which are applied as to requests to
+// the route. Calls made once a client has used its allowance fail with
+// ResourceExhausted. Clients are identified by their logged in user, or by
+// their address. Calls are not limited if limiter is nil.
+func rateLimitUnary(limiter *ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		limited, ok := limitedRoutes[info.FullMethod]
+		if limiter == nil || !ok {
+			return handler(ctx, req)
+		}
+
+		client := ratelimit.ClientAddress(peerAddress(ctx))
+		if userID, ok := UserIDFromContext(ctx); ok {
+			client = ratelimit.ClientUser(userID)
+		}
+		if allowed, retryAfter := limiter.Allow(ctx, limited.route, limited.method, client); !allowed {
+			seconds := ratelimit.CeilSeconds(retryAfter)
+			grpc.SetHeader(ctx, metadata.Pairs(retryAfterKey, strconv.Itoa(seconds)))
+			return nil, status.Error(codes.ResourceExhausted, fmt.Sprintf(ratelimit.ErrRateLimited, seconds))
+		}
+		return handler(ctx, req)
+	}
+}
+
+// peerAddress returns the address of the client making the call, without its
+// port.
+func peerAddress(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
+
+// public reports whether method may be called without a login token.
+func public(method string) bool {
+	if publicMethods[method] {
+		return true
+	}
+	for _, prefix := range publicServices {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// serverError reports whether code reports a failure of the server, rather
+// than of the call made by the client.
+func serverError(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.Internal, codes.Unavailable, codes.DataLoss, codes.Unimplemented:
+		return true
+	}
+	return false
+}
+
+// firstValue returns the first value of key in md, or an empty string.
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// contextStream replaces the context of a server stream, so that values set
+// by interceptors reach streaming handlers.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// metadataCarrier propagates trace context in gRPC metadata, whose keys are
+// lower case.
+type metadataCarrier metadata.MD
+
+func (m metadataCarrier) Get(key string) string {
+	return firstValue(metadata.MD(m), key)
+}
+
+func (m metadataCarrier) Set(key, value string) {
+	metadata.MD(m).Set(key, value)
+}
+
+func (m metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
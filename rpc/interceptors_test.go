@@ -0,0 +1,275 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/hrand1005/training-notebook/api/ratelimit"
+	"github.com/hrand1005/training-notebook/api/requestid"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+	"github.com/hrand1005/training-notebook/rpc/notebookpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// testSigningKey signs login tokens in tests that forge them
+var testSigningKey = []byte("test-signing-key")
+
+// TestAuthenticate checks that calls are authenticated by the login token in
+// their authorization metadata, except for public methods.
+func TestAuthenticate(t *testing.T) {
+	var gotUserID models.UserID
+	setDB := &data.MockSetDB{
+		SetByIDForUserStub: func(setID models.SetID, userID models.UserID) (*models.Set, error) {
+			gotUserID = userID
+			return &models.Set{ID: setID, UID: userID, Movement: "Squat", Volume: 5, Intensity: 80, Version: 1}, nil
+		},
+		SetsByUserIDStub: func(userID models.UserID) ([]*models.Set, error) {
+			gotUserID = userID
+			return []*models.Set{{ID: 1, UID: userID, Movement: "Squat", Volume: 5, Intensity: 80, Version: 1}}, nil
+		},
+	}
+	conn := testServer(t, newServer(setDB, &data.MockUserDB{}, nil, nil, nil))
+	sets := notebookpb.NewSetServiceClient(conn)
+	health := healthpb.NewHealthClient(conn)
+
+	// tokens are signed with a known key, so that an expired one can be forged
+	users.SetSigningKey(testSigningKey)
+	t.Cleanup(func() { users.SetSigningKey(nil) })
+	expired, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &users.Claims{
+		UserID:           3,
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute))},
+	}).SignedString(testSigningKey)
+	if err != nil {
+		t.Fatalf("failed to build login token: %v", err)
+	}
+
+	loggedIn := loginContext(t, 3)
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		call       func(context.Context) error
+		wantCode   codes.Code
+		wantUserID models.UserID
+	}{
+		{
+			name: "Call with a login token is authenticated",
+			ctx:  loggedIn,
+			call: func(ctx context.Context) error {
+				_, err := sets.GetSet(ctx, &notebookpb.GetSetRequest{Id: 1})
+				return err
+			},
+			wantCode:   codes.OK,
+			wantUserID: 3,
+		},
+		{
+			name: "Streaming call with a login token is authenticated",
+			ctx:  loggedIn,
+			call: func(ctx context.Context) error {
+				stream, err := sets.ListSets(ctx, &notebookpb.ListSetsRequest{})
+				if err != nil {
+					return err
+				}
+				_, err = stream.Recv()
+				return err
+			},
+			wantCode:   codes.OK,
+			wantUserID: 3,
+		},
+		{
+			name: "Call without a login token is rejected",
+			ctx:  context.Background(),
+			call: func(ctx context.Context) error {
+				_, err := sets.GetSet(ctx, &notebookpb.GetSetRequest{Id: 1})
+				return err
+			},
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name: "Streaming call without a login token is rejected",
+			ctx:  context.Background(),
+			call: func(ctx context.Context) error {
+				stream, err := sets.ListSets(ctx, &notebookpb.ListSetsRequest{})
+				if err != nil {
+					return err
+				}
+				_, err = stream.Recv()
+				return err
+			},
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name: "Call with another authorization scheme is rejected",
+			ctx:  metadata.AppendToOutgoingContext(context.Background(), "authorization", "Basic dXNlcjpwYXNz"),
+			call: func(ctx context.Context) error {
+				_, err := sets.GetSet(ctx, &notebookpb.GetSetRequest{Id: 1})
+				return err
+			},
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name: "Call with an invalid login token is rejected",
+			ctx:  metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer not-a-token"),
+			call: func(ctx context.Context) error {
+				_, err := sets.GetSet(ctx, &notebookpb.GetSetRequest{Id: 1})
+				return err
+			},
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name: "Call with an expired login token is rejected",
+			ctx:  metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+expired),
+			call: func(ctx context.Context) error {
+				_, err := sets.GetSet(ctx, &notebookpb.GetSetRequest{Id: 1})
+				return err
+			},
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name: "Health checks do not require a login token",
+			ctx:  context.Background(),
+			call: func(ctx context.Context) error {
+				_, err := health.Check(ctx, &healthpb.HealthCheckRequest{})
+				return err
+			},
+			wantCode: codes.OK,
+		},
+	}
+
+	for _, v := range tests {
+		gotUserID = 0
+		err := v.call(v.ctx)
+		if status.Code(err) != v.wantCode {
+			t.Fatalf("%s: Wanted code: %v\nGot error: %v", v.name, v.wantCode, err)
+		}
+		if gotUserID != v.wantUserID {
+			t.Fatalf("%s: Wanted user id: %v\nGot user id: %v", v.name, v.wantUserID, gotUserID)
+		}
+	}
+}
+
+// TestRequestID checks that calls reuse valid request ids sent by the client,
+// and are otherwise assigned one, which is echoed in the response headers.
+func TestRequestID(t *testing.T) {
+	client := healthpb.NewHealthClient(testServer(t, newServer(&data.MockSetDB{}, &data.MockUserDB{}, nil, nil, nil)))
+
+	tests := []struct {
+		name   string
+		sent   string
+		wantID string
+	}{
+		{
+			name:   "Valid request id is reused",
+			sent:   "mobile-7f3a",
+			wantID: "mobile-7f3a",
+		},
+		{
+			name: "Missing request id is generated",
+		},
+		{
+			name: "Invalid request id is replaced",
+			sent: "bad id!",
+		},
+	}
+
+	for _, v := range tests {
+		ctx := context.Background()
+		if v.sent != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, requestIDKey, v.sent)
+		}
+
+		var header metadata.MD
+		if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Header(&header)); err != nil {
+			t.Fatalf("%s: Encountered unexpected error: %v", v.name, err)
+		}
+
+		got := firstValue(header, requestIDKey)
+		if !requestid.Valid(got) {
+			t.Fatalf("%s: Wanted a valid request id\nGot request id: %q", v.name, got)
+		}
+		if v.wantID != "" && got != v.wantID {
+			t.Fatalf("%s: Wanted request id: %q\nGot request id: %q", v.name, v.wantID, got)
+		}
+		if v.sent != "" && v.wantID == "" && got == v.sent {
+			t.Fatalf("%s: Wanted request id %q to be replaced", v.name, v.sent)
+		}
+	}
+}
+
+// TestRateLimit checks that logins and signups share the rate limits of their
+// REST routes, and that limited calls report when to retry.
+func TestRateLimit(t *testing.T) {
+	userDB := &data.MockUserDB{
+		UserByIDStub: func(id models.UserID) (*models.User, error) {
+			return nil, data.ErrNotFound
+		},
+		AddUserStub: func(u *models.User) (models.UserID, error) {
+			return 1, nil
+		},
+	}
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), []ratelimit.Policy{
+		{Route: "/api/login", Method: http.MethodPost, Requests: 2, Per: time.Minute},
+		{Route: "/api/v1/signup", Method: http.MethodPost, Requests: 1, Per: time.Hour},
+	})
+	client := notebookpb.NewUserServiceClient(testServer(t, newServer(&data.MockSetDB{}, userDB, nil, nil, limiter)))
+
+	tests := []struct {
+		name     string
+		call     func(...grpc.CallOption) error
+		wantCode codes.Code
+	}{
+		{
+			name: "Login within the limit is handled",
+			call: func(opts ...grpc.CallOption) error {
+				_, err := client.Login(context.Background(), &notebookpb.LoginRequest{UserId: 1, Password: testPassword}, opts...)
+				return err
+			},
+			wantCode: codes.NotFound,
+		},
+		{
+			name: "Login at the limit is handled",
+			call: func(opts ...grpc.CallOption) error {
+				_, err := client.Login(context.Background(), &notebookpb.LoginRequest{UserId: 1, Password: testPassword}, opts...)
+				return err
+			},
+			wantCode: codes.NotFound,
+		},
+		{
+			name: "Login over the limit is rejected",
+			call: func(opts ...grpc.CallOption) error {
+				_, err := client.Login(context.Background(), &notebookpb.LoginRequest{UserId: 1, Password: testPassword}, opts...)
+				return err
+			},
+			wantCode: codes.ResourceExhausted,
+		},
+		{
+			name: "Signup over the limit of a versioned route is rejected",
+			call: func(opts ...grpc.CallOption) error {
+				client.Signup(context.Background(), &notebookpb.SignupRequest{Name: "name", Password: testPassword})
+				_, err := client.Signup(context.Background(), &notebookpb.SignupRequest{Name: "name", Password: testPassword}, opts...)
+				return err
+			},
+			wantCode: codes.ResourceExhausted,
+		},
+	}
+
+	for _, v := range tests {
+		var header metadata.MD
+		err := v.call(grpc.Header(&header))
+		if status.Code(err) != v.wantCode {
+			t.Fatalf("%s: Wanted code: %v\nGot error: %v", v.name, v.wantCode, err)
+		}
+		retryAfter := firstValue(header, retryAfterKey)
+		if (v.wantCode == codes.ResourceExhausted) != (retryAfter != "") {
+			t.Fatalf("%s: Wanted %v to be set only when limited\nGot %v: %q", v.name, retryAfterKey, retryAfterKey, retryAfter)
+		}
+	}
+}
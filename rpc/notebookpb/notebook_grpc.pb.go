@@ -0,0 +1,487 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: notebook/v1/notebook.proto
+
+package notebookpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	SetService_CreateSet_FullMethodName = "/notebook.v1.SetService/CreateSet"
+	SetService_GetSet_FullMethodName    = "/notebook.v1.SetService/GetSet"
+	SetService_ListSets_FullMethodName  = "/notebook.v1.SetService/ListSets"
+	SetService_UpdateSet_FullMethodName = "/notebook.v1.SetService/UpdateSet"
+	SetService_DeleteSet_FullMethodName = "/notebook.v1.SetService/DeleteSet"
+)
+
+// SetServiceClient is the client API for SetService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// SetService manages the sets of the logged in user. Every method requires a
+// login token, sent in the authorization metadata as "Bearer <token>".
+type SetServiceClient interface {
+	// CreateSet creates a set owned by the logged in user.
+	CreateSet(ctx context.Context, in *CreateSetRequest, opts ...grpc.CallOption) (*CreateSetResponse, error)
+	// GetSet returns a set owned by the logged in user.
+	GetSet(ctx context.Context, in *GetSetRequest, opts ...grpc.CallOption) (*GetSetResponse, error)
+	// ListSets streams every set owned by the logged in user, in order of id.
+	ListSets(ctx context.Context, in *ListSetsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ListSetsResponse], error)
+	// UpdateSet replaces the movement, volume and intensity of a set owned by
+	// the logged in user.
+	UpdateSet(ctx context.Context, in *UpdateSetRequest, opts ...grpc.CallOption) (*UpdateSetResponse, error)
+	// DeleteSet deletes a set owned by the logged in user.
+	DeleteSet(ctx context.Context, in *DeleteSetRequest, opts ...grpc.CallOption) (*DeleteSetResponse, error)
+}
+
+type setServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSetServiceClient(cc grpc.ClientConnInterface) SetServiceClient {
+	return &setServiceClient{cc}
+}
+
+func (c *setServiceClient) CreateSet(ctx context.Context, in *CreateSetRequest, opts ...grpc.CallOption) (*CreateSetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateSetResponse)
+	err := c.cc.Invoke(ctx, SetService_CreateSet_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *setServiceClient) GetSet(ctx context.Context, in *GetSetRequest, opts ...grpc.CallOption) (*GetSetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetSetResponse)
+	err := c.cc.Invoke(ctx, SetService_GetSet_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *setServiceClient) ListSets(ctx context.Context, in *ListSetsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ListSetsResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SetService_ServiceDesc.Streams[0], SetService_ListSets_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ListSetsRequest, ListSetsResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SetService_ListSetsClient = grpc.ServerStreamingClient[ListSetsResponse]
+
+func (c *setServiceClient) UpdateSet(ctx context.Context, in *UpdateSetRequest, opts ...grpc.CallOption) (*UpdateSetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateSetResponse)
+	err := c.cc.Invoke(ctx, SetService_UpdateSet_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *setServiceClient) DeleteSet(ctx context.Context, in *DeleteSetRequest, opts ...grpc.CallOption) (*DeleteSetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteSetResponse)
+	err := c.cc.Invoke(ctx, SetService_DeleteSet_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SetServiceServer is the server API for SetService service.
+// All implementations must embed UnimplementedSetServiceServer
+// for forward compatibility.
+//
+// SetService manages the sets of the logged in user. Every method requires a
+// login token, sent in the authorization metadata as "Bearer <token>".
+type SetServiceServer interface {
+	// CreateSet creates a set owned by the logged in user.
+	CreateSet(context.Context, *CreateSetRequest) (*CreateSetResponse, error)
+	// GetSet returns a set owned by the logged in user.
+	GetSet(context.Context, *GetSetRequest) (*GetSetResponse, error)
+	// ListSets streams every set owned by the logged in user, in order of id.
+	ListSets(*ListSetsRequest, grpc.ServerStreamingServer[ListSetsResponse]) error
+	// UpdateSet replaces the movement, volume and intensity of a set owned by
+	// the logged in user.
+	UpdateSet(context.Context, *UpdateSetRequest) (*UpdateSetResponse, error)
+	// DeleteSet deletes a set owned by the logged in user.
+	DeleteSet(context.Context, *DeleteSetRequest) (*DeleteSetResponse, error)
+	mustEmbedUnimplementedSetServiceServer()
+}
+
+// UnimplementedSetServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSetServiceServer struct{}
+
+func (UnimplementedSetServiceServer) CreateSet(context.Context, *CreateSetRequest) (*CreateSetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSet not implemented")
+}
+func (UnimplementedSetServiceServer) GetSet(context.Context, *GetSetRequest) (*GetSetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSet not implemented")
+}
+func (UnimplementedSetServiceServer) ListSets(*ListSetsRequest, grpc.ServerStreamingServer[ListSetsResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ListSets not implemented")
+}
+func (UnimplementedSetServiceServer) UpdateSet(context.Context, *UpdateSetRequest) (*UpdateSetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateSet not implemented")
+}
+func (UnimplementedSetServiceServer) DeleteSet(context.Context, *DeleteSetRequest) (*DeleteSetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSet not implemented")
+}
+func (UnimplementedSetServiceServer) mustEmbedUnimplementedSetServiceServer() {}
+func (UnimplementedSetServiceServer) testEmbeddedByValue()                    {}
+
+// UnsafeSetServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SetServiceServer will
+// result in compilation errors.
+type UnsafeSetServiceServer interface {
+	mustEmbedUnimplementedSetServiceServer()
+}
+
+func RegisterSetServiceServer(s grpc.ServiceRegistrar, srv SetServiceServer) {
+	// If the following call pancis, it indicates UnimplementedSetServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&SetService_ServiceDesc, srv)
+}
+
+func _SetService_CreateSet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SetServiceServer).CreateSet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SetService_CreateSet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SetServiceServer).CreateSet(ctx, req.(*CreateSetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SetService_GetSet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SetServiceServer).GetSet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SetService_GetSet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SetServiceServer).GetSet(ctx, req.(*GetSetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SetService_ListSets_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListSetsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SetServiceServer).ListSets(m, &grpc.GenericServerStream[ListSetsRequest, ListSetsResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SetService_ListSetsServer = grpc.ServerStreamingServer[ListSetsResponse]
+
+func _SetService_UpdateSet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateSetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SetServiceServer).UpdateSet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SetService_UpdateSet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SetServiceServer).UpdateSet(ctx, req.(*UpdateSetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SetService_DeleteSet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteSetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SetServiceServer).DeleteSet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SetService_DeleteSet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SetServiceServer).DeleteSet(ctx, req.(*DeleteSetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SetService_ServiceDesc is the grpc.ServiceDesc for SetService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SetService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "notebook.v1.SetService",
+	HandlerType: (*SetServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateSet",
+			Handler:    _SetService_CreateSet_Handler,
+		},
+		{
+			MethodName: "GetSet",
+			Handler:    _SetService_GetSet_Handler,
+		},
+		{
+			MethodName: "UpdateSet",
+			Handler:    _SetService_UpdateSet_Handler,
+		},
+		{
+			MethodName: "DeleteSet",
+			Handler:    _SetService_DeleteSet_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListSets",
+			Handler:       _SetService_ListSets_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "notebook/v1/notebook.proto",
+}
+
+const (
+	UserService_Signup_FullMethodName  = "/notebook.v1.UserService/Signup"
+	UserService_Login_FullMethodName   = "/notebook.v1.UserService/Login"
+	UserService_GetUser_FullMethodName = "/notebook.v1.UserService/GetUser"
+)
+
+// UserServiceClient is the client API for UserService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// UserService signs up and logs in users. Signup and Login may be called
+// without a login token, and every other method requires one.
+type UserServiceClient interface {
+	// Signup creates a user.
+	Signup(ctx context.Context, in *SignupRequest, opts ...grpc.CallOption) (*SignupResponse, error)
+	// Login checks the credentials of a user, and returns a login token to
+	// send in the authorization metadata of later calls.
+	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error)
+	// GetUser returns a user.
+	GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*GetUserResponse, error)
+}
+
+type userServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewUserServiceClient(cc grpc.ClientConnInterface) UserServiceClient {
+	return &userServiceClient{cc}
+}
+
+func (c *userServiceClient) Signup(ctx context.Context, in *SignupRequest, opts ...grpc.CallOption) (*SignupResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SignupResponse)
+	err := c.cc.Invoke(ctx, UserService_Signup_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LoginResponse)
+	err := c.cc.Invoke(ctx, UserService_Login_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*GetUserResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetUserResponse)
+	err := c.cc.Invoke(ctx, UserService_GetUser_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UserServiceServer is the server API for UserService service.
+// All implementations must embed UnimplementedUserServiceServer
+// for forward compatibility.
+//
+// UserService signs up and logs in users. Signup and Login may be called
+// without a login token, and every other method requires one.
+type UserServiceServer interface {
+	// Signup creates a user.
+	Signup(context.Context, *SignupRequest) (*SignupResponse, error)
+	// Login checks the credentials of a user, and returns a login token to
+	// send in the authorization metadata of later calls.
+	Login(context.Context, *LoginRequest) (*LoginResponse, error)
+	// GetUser returns a user.
+	GetUser(context.Context, *GetUserRequest) (*GetUserResponse, error)
+	mustEmbedUnimplementedUserServiceServer()
+}
+
+// UnimplementedUserServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedUserServiceServer struct{}
+
+func (UnimplementedUserServiceServer) Signup(context.Context, *SignupRequest) (*SignupResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Signup not implemented")
+}
+func (UnimplementedUserServiceServer) Login(context.Context, *LoginRequest) (*LoginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
+func (UnimplementedUserServiceServer) GetUser(context.Context, *GetUserRequest) (*GetUserResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUser not implemented")
+}
+func (UnimplementedUserServiceServer) mustEmbedUnimplementedUserServiceServer() {}
+func (UnimplementedUserServiceServer) testEmbeddedByValue()                     {}
+
+// UnsafeUserServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to UserServiceServer will
+// result in compilation errors.
+type UnsafeUserServiceServer interface {
+	mustEmbedUnimplementedUserServiceServer()
+}
+
+func RegisterUserServiceServer(s grpc.ServiceRegistrar, srv UserServiceServer) {
+	// If the following call pancis, it indicates UnimplementedUserServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&UserService_ServiceDesc, srv)
+}
+
+func _UserService_Signup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).Signup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_Signup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).Signup(ctx, req.(*SignupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_Login_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).Login(ctx, req.(*LoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_GetUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).GetUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_GetUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).GetUser(ctx, req.(*GetUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// UserService_ServiceDesc is the grpc.ServiceDesc for UserService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var UserService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "notebook.v1.UserService",
+	HandlerType: (*UserServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Signup",
+			Handler:    _UserService_Signup_Handler,
+		},
+		{
+			MethodName: "Login",
+			Handler:    _UserService_Login_Handler,
+		},
+		{
+			MethodName: "GetUser",
+			Handler:    _UserService_GetUser_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "notebook/v1/notebook.proto",
+}
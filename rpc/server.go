@@ -0,0 +1,117 @@
+// Package rpc serves the sets and users of the notebook over gRPC, for native
+// clients that want a typed, streaming api. It shares the data layer and login
+// tokens of the REST api, so the two can be used interchangeably.
+//
+// The services are defined in proto/notebook/v1/notebook.proto, and the code
+// in notebookpb is generated from it by running buf generate in this
+// directory.
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"errors"
+	"net"
+
+	"github.com/hrand1005/training-notebook/api/events"
+	"github.com/hrand1005/training-notebook/api/ratelimit"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/rpc/notebookpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// Server serves the notebook services, gRPC health checks and server
+// reflection.
+type Server struct {
+	grpc   *grpc.Server
+	health *health.Server
+}
+
+// New returns a gRPC server for the sets and users stored in db, served over
+// TLS if tlsConfig is not nil. Changes are recorded in the audit trail shared
+// with the REST api and published to hub, and signups and logins share its
+// rate limits in limiter.
+func New(db *sql.DB, tlsConfig *tls.Config, hub *events.Hub, limiter *ratelimit.Limiter) (*Server, error) {
+	auditDB, err := data.NewAuditDB(db)
+	if err != nil {
+		return nil, err
+	}
+	setDB, err := data.NewSetDB(db)
+	if err != nil {
+		return nil, err
+	}
+	userDB, err := data.NewUserDB(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []grpc.ServerOption
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	return newServer(setDB, userDB, auditDB, hub, limiter, opts...), nil
+}
+
+// newServer returns a gRPC server for the sets and users in setDB and userDB.
+// Changes are recorded in auditDB and published to hub, either of which may
+// be nil to disable them, and calls are rate limited by limiter, which may be
+// nil to disable limits.
+func newServer(setDB data.SetDB, userDB data.UserDB, auditDB data.AuditDB, hub *events.Hub, limiter *ratelimit.Limiter, opts ...grpc.ServerOption) *Server {
+	// calls are observed before they are authenticated, so that rejected
+	// calls are logged and counted too, and limited once the user is known
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(observeUnary, authenticateUnary, rateLimitUnary(limiter)),
+		grpc.ChainStreamInterceptor(observeStream, authenticateStream),
+	)
+	s := grpc.NewServer(opts...)
+
+	notebookpb.RegisterSetServiceServer(s, &setService{db: setDB, audit: auditDB, events: hub})
+	notebookpb.RegisterUserServiceServer(s, &userService{db: userDB, audit: auditDB, events: hub})
+
+	// the overall status, named "", is serving from the start, and each
+	// service reports its own status too
+	h := health.NewServer()
+	for name := range s.GetServiceInfo() {
+		h.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+	}
+	healthpb.RegisterHealthServer(s, h)
+	reflection.Register(s)
+
+	return &Server{grpc: s, health: h}
+}
+
+// Serve accepts connections on ln until the server is shut down. Returns nil
+// once the server is shut down, and any other error that stops it serving.
+func (s *Server) Serve(ln net.Listener) error {
+	if err := s.grpc.Serve(ln); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown reports every service as not serving to health checks, stops
+// accepting calls, and waits for in-flight calls to complete. Calls still
+// running when ctx is done are cut off, and the context's error is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.health.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.grpc.Stop()
+		<-stopped
+		return ctx.Err()
+	}
+}
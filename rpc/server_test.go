@@ -0,0 +1,153 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const testPassword = "password"
+
+// testPasswordHash is the hash of testPassword, which is slow to compute
+var testPasswordHash = sync.OnceValues(func() (string, error) {
+	return users.HashPassword(testPassword)
+})
+
+// testServer serves s on an in-memory listener, and returns a connection to
+// it. The server is stopped when the test ends.
+func testServer(t *testing.T, s *Server) *grpc.ClientConn {
+	t.Helper()
+
+	ln := bufconn.Listen(1 << 20)
+	go s.Serve(ln)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ln.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to connect to test server: %v", err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		s.grpc.Stop()
+	})
+	return conn
+}
+
+// loginContext returns a context carrying a login token for the user with
+// the given id.
+func loginContext(t *testing.T, id models.UserID) context.Context {
+	t.Helper()
+
+	hash, err := testPasswordHash()
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	token, err := users.AuthenticateUser(&models.User{ID: id, Password: hash}, models.Credentails{UID: id, Password: testPassword})
+	if err != nil {
+		t.Fatalf("failed to build login token: %v", err)
+	}
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+// TestHealth checks that every service reports serving until the server is
+// shut down.
+func TestHealth(t *testing.T) {
+	s := newServer(&data.MockSetDB{}, &data.MockUserDB{}, nil, nil, nil)
+	client := healthpb.NewHealthClient(testServer(t, s))
+
+	services := []string{"", "notebook.v1.SetService", "notebook.v1.UserService"}
+	for _, name := range services {
+		resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: name})
+		if err != nil {
+			t.Fatalf("service %q: Encountered unexpected error: %v", name, err)
+		}
+		if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			t.Fatalf("service %q: Wanted status: %v\nGot status: %v", name, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+		}
+	}
+
+	// watch before shutting down, since shutdown stops new calls
+	watch, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("failed to watch health: %v", err)
+	}
+	if resp, err := watch.Recv(); err != nil || resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("Wanted status: %v\nGot status: %v, error: %v", healthpb.HealthCheckResponse_SERVING, resp.GetStatus(), err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	// the open watch keeps the server from stopping gracefully, so shutdown
+	// cuts it off once ctx is done
+	if err := s.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Wanted error: %v\nGot error: %v", context.DeadlineExceeded, err)
+	}
+	if resp, err := watch.Recv(); err != nil || resp.GetStatus() != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("Wanted status: %v\nGot status: %v, error: %v", healthpb.HealthCheckResponse_NOT_SERVING, resp.GetStatus(), err)
+	}
+}
+
+// TestReflection checks that the notebook services can be discovered
+// without logging in.
+func TestReflection(t *testing.T) {
+	client := reflectionpb.NewServerReflectionClient(testServer(t, newServer(&data.MockSetDB{}, &data.MockUserDB{}, nil, nil, nil)))
+
+	stream, err := client.ServerReflectionInfo(context.Background())
+	if err != nil {
+		t.Fatalf("failed to open reflection stream: %v", err)
+	}
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		t.Fatalf("failed to send reflection request: %v", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("failed to receive reflection response: %v", err)
+	}
+
+	got := make(map[string]bool)
+	for _, s := range resp.GetListServicesResponse().GetService() {
+		got[s.GetName()] = true
+	}
+	for _, want := range []string{"notebook.v1.SetService", "notebook.v1.UserService", "grpc.health.v1.Health"} {
+		if !got[want] {
+			t.Fatalf("Wanted service %q to be listed\nGot services: %v", want, got)
+		}
+	}
+}
+
+// TestShutdown checks that calls are refused once the server has shut down.
+func TestShutdown(t *testing.T) {
+	s := newServer(&data.MockSetDB{}, &data.MockUserDB{}, nil, nil, nil)
+	client := healthpb.NewHealthClient(testServer(t, s))
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("Wanted code: %v\nGot error: %v", codes.Unavailable, err)
+	}
+}
@@ -0,0 +1,223 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/hrand1005/training-notebook/api/events"
+	"github.com/hrand1005/training-notebook/api/sets"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+	"github.com/hrand1005/training-notebook/rpc/notebookpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// validate checks sets against the binding tags of models.Set, which the
+// REST api validates requests with.
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	v.RegisterValidation("movement", models.MovementValidator)
+	return v
+}
+
+// setService implements notebookpb.SetServiceServer over a SetDB. Changes to
+// sets are recorded in audit, which may be nil to disable auditing, and
+// published to events, which may be nil to disable live events.
+type setService struct {
+	notebookpb.UnimplementedSetServiceServer
+	db     data.SetDB
+	audit  data.AuditDB
+	events *events.Hub
+}
+
+// dbFor returns the set db, recording changes in the audit log as made by the
+// actor of the call in ctx, and a span for each call in the trace of the
+// call.
+func (s *setService) dbFor(ctx context.Context) data.SetDB {
+	db := s.db
+	if s.audit != nil {
+		db = db.AuditedBy(actorFromContext(ctx))
+	}
+	return data.TraceSetDB(ctx, db)
+}
+
+// CreateSet creates a set owned by the logged in user.
+func (s *setService) CreateSet(ctx context.Context, req *notebookpb.CreateSetRequest) (*notebookpb.CreateSetResponse, error) {
+	userID, err := loggedIn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	newSet := models.Set{
+		UID:       userID,
+		Movement:  req.GetMovement(),
+		Volume:    req.GetVolume(),
+		Intensity: req.GetIntensity(),
+	}
+	if err := validate.Struct(&newSet); err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	id, err := s.dbFor(ctx).AddSet(&newSet)
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+
+	newSet.ID = id
+	s.publish(events.SetCreated, userID, &newSet)
+	return &notebookpb.CreateSetResponse{Set: setMessage(&newSet)}, nil
+}
+
+// GetSet returns a set owned by the logged in user.
+func (s *setService) GetSet(ctx context.Context, req *notebookpb.GetSetRequest) (*notebookpb.GetSetResponse, error) {
+	userID, err := loggedIn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	setID, err := setIDFromRequest(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := s.dbFor(ctx).SetByIDForUser(setID, userID)
+	if err != nil {
+		return nil, setError(ctx, setID, err)
+	}
+
+	return &notebookpb.GetSetResponse{Set: setMessage(set)}, nil
+}
+
+// ListSets streams every set owned by the logged in user.
+func (s *setService) ListSets(req *notebookpb.ListSetsRequest, stream notebookpb.SetService_ListSetsServer) error {
+	ctx := stream.Context()
+	userID, err := loggedIn(ctx)
+	if err != nil {
+		return err
+	}
+
+	userSets, err := s.dbFor(ctx).SetsByUserID(userID)
+	if err != nil {
+		return internalError(ctx, err)
+	}
+
+	for _, set := range userSets {
+		if err := stream.Send(&notebookpb.ListSetsResponse{Set: setMessage(set)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateSet replaces a set owned by the logged in user. If a version is
+// given, the update only applies if it is the version of the stored set.
+func (s *setService) UpdateSet(ctx context.Context, req *notebookpb.UpdateSetRequest) (*notebookpb.UpdateSetResponse, error) {
+	userID, err := loggedIn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	setID, err := setIDFromRequest(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	newSet := models.Set{
+		Movement:  req.GetMovement(),
+		Volume:    req.GetVolume(),
+		Intensity: req.GetIntensity(),
+		// the zero version updates the set whatever its version
+		Version: int(req.GetVersion()),
+	}
+	if err := validate.Struct(&newSet); err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	if err := s.dbFor(ctx).UpdateSetForUser(setID, userID, &newSet); err != nil {
+		return nil, setError(ctx, setID, err)
+	}
+
+	newSet.ID = setID
+	newSet.UID = userID
+	s.publish(events.SetUpdated, userID, &newSet)
+	return &notebookpb.UpdateSetResponse{Set: setMessage(&newSet)}, nil
+}
+
+// DeleteSet deletes a set owned by the logged in user. If a version is given,
+// the delete only applies if it is the version of the stored set.
+func (s *setService) DeleteSet(ctx context.Context, req *notebookpb.DeleteSetRequest) (*notebookpb.DeleteSetResponse, error) {
+	userID, err := loggedIn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	setID, err := setIDFromRequest(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	// the current state of the set is published as the deleted set
+	var current *models.Set
+	if s.events != nil {
+		current, err = s.dbFor(ctx).SetByIDForUser(setID, userID)
+		if err != nil {
+			return nil, setError(ctx, setID, err)
+		}
+	}
+
+	if version := int(req.GetVersion()); version != 0 {
+		err = s.dbFor(ctx).DeleteSetForUserAtVersion(setID, userID, version)
+	} else {
+		err = s.dbFor(ctx).DeleteSetForUser(setID, userID)
+	}
+	if err != nil {
+		return nil, setError(ctx, setID, err)
+	}
+
+	s.publish(events.SetDeleted, userID, current)
+	return &notebookpb.DeleteSetResponse{}, nil
+}
+
+// publish sends a change to a set owned by ownerID to live event
+// subscribers, such as the event stream, rooms and webhooks of the REST api.
+func (s *setService) publish(eventType string, ownerID models.UserID, changed *models.Set) {
+	if s.events == nil || changed == nil {
+		return
+	}
+	s.events.Publish(events.Event{Type: eventType, OwnerID: ownerID, Set: *changed})
+}
+
+// setIDFromRequest returns the set id of a request, or an error if it is
+// invalid.
+func setIDFromRequest(id int64) (models.SetID, error) {
+	if id < 0 {
+		return data.InvalidSetID, status.Error(codes.InvalidArgument, sets.ErrInvalidSetID)
+	}
+	return models.SetID(id), nil
+}
+
+// setError returns the error reported for err, returned by the set db for the
+// set with the given id.
+func setError(ctx context.Context, setID models.SetID, err error) error {
+	switch err {
+	case data.ErrNotFound:
+		return status.Error(codes.NotFound, fmt.Sprintf("no such set with id %v", setID))
+	case data.ErrVersionMismatch:
+		return status.Error(codes.FailedPrecondition, sets.ErrSetModified)
+	}
+	return internalError(ctx, err)
+}
+
+// setMessage returns the message representing a set.
+func setMessage(s *models.Set) *notebookpb.Set {
+	return &notebookpb.Set{
+		Id:        int64(s.ID),
+		OwnerId:   int64(s.UID),
+		Movement:  s.Movement,
+		Volume:    s.Volume,
+		Intensity: s.Intensity,
+		Version:   int64(s.Version),
+	}
+}
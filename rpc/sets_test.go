@@ -0,0 +1,362 @@
+package rpc
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/hrand1005/training-notebook/api/events"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+	"github.com/hrand1005/training-notebook/rpc/notebookpb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// TestCreateSet checks that sets are validated, created for the logged in
+// user and audited.
+func TestCreateSet(t *testing.T) {
+	tests := []struct {
+		name           string
+		req            *notebookpb.CreateSetRequest
+		dbErr          error
+		wantCode       codes.Code
+		wantSet        *notebookpb.Set
+		wantViolations []string
+	}{
+		{
+			name:     "Valid set is created",
+			req:      &notebookpb.CreateSetRequest{Movement: "Squat", Volume: 5, Intensity: 80},
+			wantCode: codes.OK,
+			wantSet:  &notebookpb.Set{Id: 7, OwnerId: 3, Movement: "Squat", Volume: 5, Intensity: 80, Version: 1},
+		},
+		{
+			name:           "Invalid fields are reported",
+			req:            &notebookpb.CreateSetRequest{Movement: "Squat!", Volume: 0, Intensity: 120},
+			wantCode:       codes.InvalidArgument,
+			wantViolations: []string{"Movement", "Volume", "Intensity"},
+		},
+		{
+			name:     "Database errors are hidden",
+			req:      &notebookpb.CreateSetRequest{Movement: "Squat", Volume: 5, Intensity: 80},
+			dbErr:    fmt.Errorf("Expected error"),
+			wantCode: codes.Internal,
+		},
+	}
+
+	for _, v := range tests {
+		setDB := &data.MockSetDB{
+			AddSetStub: func(s *models.Set) (models.SetID, error) {
+				if v.dbErr != nil {
+					return data.InvalidSetID, v.dbErr
+				}
+				s.Version = 1
+				return 7, nil
+			},
+		}
+		var actor *data.Actor
+		setDB.AuditedByStub = func(a data.Actor) data.SetDB {
+			actor = &a
+			return setDB
+		}
+		client := notebookpb.NewSetServiceClient(testServer(t, newServer(setDB, &data.MockUserDB{}, &data.MockAuditDB{}, nil, nil)))
+
+		resp, err := client.CreateSet(loginContext(t, 3), v.req)
+		if status.Code(err) != v.wantCode {
+			t.Fatalf("%s: Wanted code: %v\nGot error: %v", v.name, v.wantCode, err)
+		}
+		if v.wantCode != codes.OK {
+			if got := violations(err); fmt.Sprint(got) != fmt.Sprint(v.wantViolations) {
+				t.Fatalf("%s: Wanted violations: %v\nGot violations: %v", v.name, v.wantViolations, got)
+			}
+			continue
+		}
+
+		if !proto.Equal(resp.GetSet(), v.wantSet) {
+			t.Fatalf("%s: Wanted set: %v\nGot set: %v", v.name, v.wantSet, resp.GetSet())
+		}
+		if actor == nil || actor.UserID != 3 || actor.RequestID == "" {
+			t.Fatalf("%s: Wanted the set to be audited as created by user 3\nGot actor: %+v", v.name, actor)
+		}
+	}
+}
+
+// TestListSets checks that every set of the logged in user is streamed.
+func TestListSets(t *testing.T) {
+	stored := []*models.Set{
+		{ID: 1, UID: 3, Movement: "Squat", Volume: 5, Intensity: 80, Version: 1},
+		{ID: 4, UID: 3, Movement: "Bench", Volume: 8, Intensity: 70, Version: 2},
+	}
+	setDB := &data.MockSetDB{
+		SetsByUserIDStub: func(userID models.UserID) ([]*models.Set, error) {
+			if userID != 3 {
+				return nil, nil
+			}
+			return stored, nil
+		},
+	}
+	client := notebookpb.NewSetServiceClient(testServer(t, newServer(setDB, &data.MockUserDB{}, nil, nil, nil)))
+
+	stream, err := client.ListSets(loginContext(t, 3), &notebookpb.ListSetsRequest{})
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+
+	var got []*notebookpb.Set
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Encountered unexpected error: %v", err)
+		}
+		got = append(got, resp.GetSet())
+	}
+
+	if len(got) != len(stored) {
+		t.Fatalf("Wanted %v sets\nGot sets: %v", len(stored), got)
+	}
+	for i, s := range stored {
+		if !proto.Equal(got[i], setMessage(s)) {
+			t.Fatalf("Wanted set: %v\nGot set: %v", setMessage(s), got[i])
+		}
+	}
+}
+
+// TestUpdateSet checks that sets of the logged in user are replaced, at the
+// version given if there is one.
+func TestUpdateSet(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         *notebookpb.UpdateSetRequest
+		wantCode    codes.Code
+		wantSet     *notebookpb.Set
+		wantVersion int
+	}{
+		{
+			name:        "Set is updated whatever its version",
+			req:         &notebookpb.UpdateSetRequest{Id: 1, Movement: "Deadlift", Volume: 3, Intensity: 90},
+			wantCode:    codes.OK,
+			wantSet:     &notebookpb.Set{Id: 1, OwnerId: 3, Movement: "Deadlift", Volume: 3, Intensity: 90, Version: 3},
+			wantVersion: 0,
+		},
+		{
+			name:        "Set is updated at its current version",
+			req:         &notebookpb.UpdateSetRequest{Id: 1, Movement: "Deadlift", Volume: 3, Intensity: 90, Version: 2},
+			wantCode:    codes.OK,
+			wantSet:     &notebookpb.Set{Id: 1, OwnerId: 3, Movement: "Deadlift", Volume: 3, Intensity: 90, Version: 3},
+			wantVersion: 2,
+		},
+		{
+			name:        "Set modified since the given version is not updated",
+			req:         &notebookpb.UpdateSetRequest{Id: 1, Movement: "Deadlift", Volume: 3, Intensity: 90, Version: 1},
+			wantCode:    codes.FailedPrecondition,
+			wantVersion: 1,
+		},
+		{
+			name:     "Set of another user is not found",
+			req:      &notebookpb.UpdateSetRequest{Id: 2, Movement: "Deadlift", Volume: 3, Intensity: 90},
+			wantCode: codes.NotFound,
+		},
+		{
+			name:     "Invalid set id is rejected",
+			req:      &notebookpb.UpdateSetRequest{Id: -1, Movement: "Deadlift", Volume: 3, Intensity: 90},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "Invalid set is rejected",
+			req:      &notebookpb.UpdateSetRequest{Id: 1, Movement: "", Volume: 3, Intensity: 90},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, v := range tests {
+		gotVersion := -1
+		setDB := &data.MockSetDB{
+			SetByIDForUserStub: func(setID models.SetID, userID models.UserID) (*models.Set, error) {
+				if setID != 1 || userID != 3 {
+					return nil, data.ErrNotFound
+				}
+				return &models.Set{ID: 1, UID: 3, Movement: "Squat", Volume: 5, Intensity: 80, Version: 2}, nil
+			},
+			UpdateSetForUserStub: func(setID models.SetID, userID models.UserID, s *models.Set) error {
+				gotVersion = s.Version
+				if setID != 1 || userID != 3 {
+					return data.ErrNotFound
+				}
+				if s.Version != 0 && s.Version != 2 {
+					return data.ErrVersionMismatch
+				}
+				s.Version = 3
+				return nil
+			},
+		}
+		var actor *data.Actor
+		setDB.AuditedByStub = func(a data.Actor) data.SetDB {
+			actor = &a
+			return setDB
+		}
+		client := notebookpb.NewSetServiceClient(testServer(t, newServer(setDB, &data.MockUserDB{}, &data.MockAuditDB{}, nil, nil)))
+
+		resp, err := client.UpdateSet(loginContext(t, 3), v.req)
+		if status.Code(err) != v.wantCode {
+			t.Fatalf("%s: Wanted code: %v\nGot error: %v", v.name, v.wantCode, err)
+		}
+		if v.wantCode == codes.OK || v.wantCode == codes.FailedPrecondition {
+			if gotVersion != v.wantVersion {
+				t.Fatalf("%s: Wanted update at version: %v\nGot version: %v", v.name, v.wantVersion, gotVersion)
+			}
+		}
+		if v.wantCode != codes.OK {
+			continue
+		}
+
+		if !proto.Equal(resp.GetSet(), v.wantSet) {
+			t.Fatalf("%s: Wanted set: %v\nGot set: %v", v.name, v.wantSet, resp.GetSet())
+		}
+		if actor == nil || actor.UserID != 3 {
+			t.Fatalf("%s: Wanted the set to be audited as updated by user 3\nGot actor: %+v", v.name, actor)
+		}
+	}
+}
+
+// TestDeleteSet checks that sets of the logged in user are deleted, at the
+// version given if there is one.
+func TestDeleteSet(t *testing.T) {
+	tests := []struct {
+		name          string
+		req           *notebookpb.DeleteSetRequest
+		wantCode      codes.Code
+		wantAtVersion bool
+	}{
+		{
+			name:     "Set is deleted whatever its version",
+			req:      &notebookpb.DeleteSetRequest{Id: 1},
+			wantCode: codes.OK,
+		},
+		{
+			name:          "Set is deleted at its current version",
+			req:           &notebookpb.DeleteSetRequest{Id: 1, Version: 2},
+			wantCode:      codes.OK,
+			wantAtVersion: true,
+		},
+		{
+			name:          "Set modified since the given version is not deleted",
+			req:           &notebookpb.DeleteSetRequest{Id: 1, Version: 1},
+			wantCode:      codes.FailedPrecondition,
+			wantAtVersion: true,
+		},
+		{
+			name:     "Set of another user is not found",
+			req:      &notebookpb.DeleteSetRequest{Id: 2},
+			wantCode: codes.NotFound,
+		},
+	}
+
+	for _, v := range tests {
+		var gotAtVersion bool
+		setDB := &data.MockSetDB{
+			SetByIDForUserStub: func(setID models.SetID, userID models.UserID) (*models.Set, error) {
+				if setID != 1 || userID != 3 {
+					return nil, data.ErrNotFound
+				}
+				return &models.Set{ID: 1, UID: 3, Movement: "Squat", Volume: 5, Intensity: 80, Version: 2}, nil
+			},
+			DeleteSetForUserStub: func(setID models.SetID, userID models.UserID) error {
+				if setID != 1 || userID != 3 {
+					return data.ErrNotFound
+				}
+				return nil
+			},
+			DeleteSetForUserAtVersionStub: func(setID models.SetID, userID models.UserID, version int) error {
+				gotAtVersion = true
+				if version != 2 {
+					return data.ErrVersionMismatch
+				}
+				return nil
+			},
+		}
+		var actor *data.Actor
+		setDB.AuditedByStub = func(a data.Actor) data.SetDB {
+			actor = &a
+			return setDB
+		}
+		client := notebookpb.NewSetServiceClient(testServer(t, newServer(setDB, &data.MockUserDB{}, &data.MockAuditDB{}, nil, nil)))
+
+		_, err := client.DeleteSet(loginContext(t, 3), v.req)
+		if status.Code(err) != v.wantCode {
+			t.Fatalf("%s: Wanted code: %v\nGot error: %v", v.name, v.wantCode, err)
+		}
+		if gotAtVersion != v.wantAtVersion {
+			t.Fatalf("%s: Wanted delete at version: %v\nGot: %v", v.name, v.wantAtVersion, gotAtVersion)
+		}
+
+		if actor == nil || actor.UserID != 3 {
+			t.Fatalf("%s: Wanted changes to be audited as made by user 3\nGot actor: %+v", v.name, actor)
+		}
+	}
+}
+
+// violations returns the fields reported in the BadRequest details of err.
+func violations(err error) []string {
+	var fields []string
+	for _, d := range status.Convert(err).Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, fv := range br.GetFieldViolations() {
+				fields = append(fields, fv.GetField())
+			}
+		}
+	}
+	return fields
+}
+
+// TestPublishSetChanges checks that changes to sets are published to live
+// event subscribers, as they are by the REST api.
+func TestPublishSetChanges(t *testing.T) {
+	stored := &models.Set{ID: 7, UID: 3, Movement: "Squat", Volume: 5, Intensity: 80, Version: 1}
+	setDB := &data.MockSetDB{
+		AddSetStub: func(s *models.Set) (models.SetID, error) {
+			s.Version = 1
+			return 7, nil
+		},
+		SetByIDForUserStub: func(setID models.SetID, userID models.UserID) (*models.Set, error) {
+			return stored, nil
+		},
+		UpdateSetForUserStub: func(setID models.SetID, userID models.UserID, s *models.Set) error {
+			s.Version = 2
+			return nil
+		},
+		DeleteSetForUserStub: func(setID models.SetID, userID models.UserID) error {
+			return nil
+		},
+	}
+	hub := events.NewHub(events.DefaultLogSize, events.DefaultBufferSize)
+	sub, _, _ := hub.Subscribe(0, func(events.Event) bool { return true })
+	client := notebookpb.NewSetServiceClient(testServer(t, newServer(setDB, &data.MockUserDB{}, nil, hub, nil)))
+
+	ctx := loginContext(t, 3)
+	if _, err := client.CreateSet(ctx, &notebookpb.CreateSetRequest{Movement: "Squat", Volume: 5, Intensity: 80}); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if _, err := client.UpdateSet(ctx, &notebookpb.UpdateSetRequest{Id: 7, Movement: "Squat", Volume: 3, Intensity: 80}); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if _, err := client.DeleteSet(ctx, &notebookpb.DeleteSetRequest{Id: 7}); err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+
+	want := []events.Event{
+		{Type: events.SetCreated, OwnerID: 3, Set: models.Set{ID: 7, UID: 3, Movement: "Squat", Volume: 5, Intensity: 80, Version: 1}},
+		{Type: events.SetUpdated, OwnerID: 3, Set: models.Set{ID: 7, UID: 3, Movement: "Squat", Volume: 3, Intensity: 80, Version: 2}},
+		{Type: events.SetDeleted, OwnerID: 3, Set: *stored},
+	}
+	for _, w := range want {
+		got := <-sub.Events
+		if got.Type != w.Type || got.OwnerID != w.OwnerID || got.Set != w.Set {
+			t.Fatalf("Wanted event: %+v\nGot event: %+v", w, got)
+		}
+	}
+}
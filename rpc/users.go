@@ -0,0 +1,119 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hrand1005/training-notebook/api/events"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/metrics"
+	"github.com/hrand1005/training-notebook/models"
+	"github.com/hrand1005/training-notebook/rpc/notebookpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// userService implements notebookpb.UserServiceServer over a UserDB. Changes
+// to users are recorded in audit, which may be nil to disable auditing, and
+// published to events, which may be nil to disable live events.
+type userService struct {
+	notebookpb.UnimplementedUserServiceServer
+	db     data.UserDB
+	audit  data.AuditDB
+	events *events.Hub
+}
+
+// dbFor returns the user db, recording changes in the audit log as made by
+// the actor of the call in ctx, and a span for each call in the trace of
+// the call.
+func (u *userService) dbFor(ctx context.Context) data.UserDB {
+	db := u.db
+	if u.audit != nil {
+		db = db.AuditedBy(actorFromContext(ctx))
+	}
+	return data.TraceUserDB(ctx, db)
+}
+
+// Signup creates a user with the given name and password.
+func (u *userService) Signup(ctx context.Context, req *notebookpb.SignupRequest) (*notebookpb.SignupResponse, error) {
+	if err := users.CheckPasswordRequirements(req.GetPassword()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// hash the password so that user information is protected
+	hashedPassword, err := users.HashPassword(req.GetPassword())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid password")
+	}
+
+	newUser := &models.User{Name: req.GetName(), Password: hashedPassword}
+	id, err := u.dbFor(ctx).AddUser(newUser)
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+
+	newUser.ID = id
+	if u.events != nil {
+		u.events.Publish(events.UserEvent(models.AuditCreate, newUser))
+	}
+	return &notebookpb.SignupResponse{User: userMessage(newUser)}, nil
+}
+
+// Login checks the credentials of a user, and returns a login token for
+// them.
+func (u *userService) Login(ctx context.Context, req *notebookpb.LoginRequest) (*notebookpb.LoginResponse, error) {
+	userID := models.UserID(req.GetUserId())
+	user, err := u.dbFor(ctx).UserByID(userID)
+	if err != nil {
+		if err == data.ErrNotFound {
+			metrics.LoginAttempts.WithLabelValues(metrics.LoginFailure).Inc()
+			return nil, status.Error(codes.NotFound, fmt.Sprintf("no such user with id %v", userID))
+		}
+		return nil, internalError(ctx, err)
+	}
+
+	token, err := users.AuthenticateUser(user, models.Credentails{UID: userID, Password: req.GetPassword()})
+	if err != nil {
+		if err == users.ErrIncorrectPassword {
+			metrics.LoginAttempts.WithLabelValues(metrics.LoginFailure).Inc()
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return nil, internalError(ctx, err)
+	}
+
+	metrics.LoginAttempts.WithLabelValues(metrics.LoginSuccess).Inc()
+	metrics.Sessions.Start(int(user.ID), time.Now().Add(users.TokenLifetime))
+	return &notebookpb.LoginResponse{Token: token}, nil
+}
+
+// GetUser returns a user, without sensitive data.
+func (u *userService) GetUser(ctx context.Context, req *notebookpb.GetUserRequest) (*notebookpb.GetUserResponse, error) {
+	if _, err := loggedIn(ctx); err != nil {
+		return nil, err
+	}
+	if req.GetId() < 0 {
+		return nil, status.Error(codes.InvalidArgument, users.ErrInvalidUserID)
+	}
+
+	userID := models.UserID(req.GetId())
+	user, err := u.dbFor(ctx).UserByID(userID)
+	if err != nil {
+		if err == data.ErrNotFound {
+			return nil, status.Error(codes.NotFound, fmt.Sprintf("no such user with id %v", userID))
+		}
+		return nil, internalError(ctx, err)
+	}
+
+	return &notebookpb.GetUserResponse{User: userMessage(user)}, nil
+}
+
+// userMessage returns the message representing a user, without sensitive
+// data.
+func userMessage(u *models.User) *notebookpb.User {
+	return &notebookpb.User{
+		Id:   int64(u.ID),
+		Name: u.Name,
+	}
+}
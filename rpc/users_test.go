@@ -0,0 +1,198 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/models"
+	"github.com/hrand1005/training-notebook/rpc/notebookpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// TestSignup checks that users are created with a hashed password, without
+// logging in.
+func TestSignup(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      *notebookpb.SignupRequest
+		dbErr    error
+		wantCode codes.Code
+		wantUser *notebookpb.User
+	}{
+		{
+			name:     "Valid user is created",
+			req:      &notebookpb.SignupRequest{Name: "Hubie", Password: "cookies"},
+			wantCode: codes.OK,
+			wantUser: &notebookpb.User{Id: 5, Name: "Hubie"},
+		},
+		{
+			name:     "Short password is rejected",
+			req:      &notebookpb.SignupRequest{Name: "Hubie", Password: "abc"},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "Database errors are hidden",
+			req:      &notebookpb.SignupRequest{Name: "Hubie", Password: "cookies"},
+			dbErr:    fmt.Errorf("Expected error"),
+			wantCode: codes.Internal,
+		},
+	}
+
+	for _, v := range tests {
+		var added *models.User
+		userDB := &data.MockUserDB{
+			AddUserStub: func(u *models.User) (models.UserID, error) {
+				added = u
+				if v.dbErr != nil {
+					return data.InvalidUserID, v.dbErr
+				}
+				return 5, nil
+			},
+		}
+		var actor *data.Actor
+		userDB.AuditedByStub = func(a data.Actor) data.UserDB {
+			actor = &a
+			return userDB
+		}
+		client := notebookpb.NewUserServiceClient(testServer(t, newServer(&data.MockSetDB{}, userDB, &data.MockAuditDB{}, nil, nil)))
+
+		resp, err := client.Signup(context.Background(), v.req)
+		if status.Code(err) != v.wantCode {
+			t.Fatalf("%s: Wanted code: %v\nGot error: %v", v.name, v.wantCode, err)
+		}
+		if v.wantCode != codes.OK {
+			continue
+		}
+
+		if !proto.Equal(resp.GetUser(), v.wantUser) {
+			t.Fatalf("%s: Wanted user: %v\nGot user: %v", v.name, v.wantUser, resp.GetUser())
+		}
+		if added.Password == v.req.Password {
+			t.Fatalf("%s: Wanted the password to be hashed before it is stored", v.name)
+		}
+		// signups have no logged in user, so the new user is recorded as the actor
+		if actor == nil || actor.UserID != 0 || actor.RequestID == "" {
+			t.Fatalf("%s: Wanted the user to be audited as signing themselves up\nGot actor: %+v", v.name, actor)
+		}
+	}
+}
+
+// TestLogin checks that logins return a token accepted by the REST api.
+func TestLogin(t *testing.T) {
+	hash, err := testPasswordHash()
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	userDB := &data.MockUserDB{
+		UserByIDStub: func(id models.UserID) (*models.User, error) {
+			if id != 3 {
+				return nil, data.ErrNotFound
+			}
+			return &models.User{ID: 3, Name: "Louie", Password: hash}, nil
+		},
+	}
+	client := notebookpb.NewUserServiceClient(testServer(t, newServer(&data.MockSetDB{}, userDB, nil, nil, nil)))
+
+	tests := []struct {
+		name     string
+		req      *notebookpb.LoginRequest
+		wantCode codes.Code
+	}{
+		{
+			name:     "Correct password logs in",
+			req:      &notebookpb.LoginRequest{UserId: 3, Password: testPassword},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "Incorrect password is rejected",
+			req:      &notebookpb.LoginRequest{UserId: 3, Password: "wrong"},
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:     "Missing user is not found",
+			req:      &notebookpb.LoginRequest{UserId: 9, Password: testPassword},
+			wantCode: codes.NotFound,
+		},
+	}
+
+	for _, v := range tests {
+		resp, err := client.Login(context.Background(), v.req)
+		if status.Code(err) != v.wantCode {
+			t.Fatalf("%s: Wanted code: %v\nGot error: %v", v.name, v.wantCode, err)
+		}
+		if v.wantCode != codes.OK {
+			continue
+		}
+
+		// tokens without an expiry are rejected, so this also checks that
+		// the token expires
+		userID, err := users.UserIDFromToken(resp.GetToken())
+		if err != nil || userID != 3 {
+			t.Fatalf("%s: Wanted a token for user 3\nGot user %v, error: %v", v.name, userID, err)
+		}
+	}
+}
+
+// TestGetUser checks that users are read without sensitive data.
+func TestGetUser(t *testing.T) {
+	userDB := &data.MockUserDB{
+		UserByIDStub: func(id models.UserID) (*models.User, error) {
+			switch id {
+			case 3:
+				return &models.User{ID: 3, Name: "Louie", Password: "hash"}, nil
+			case 4:
+				return nil, fmt.Errorf("Expected error")
+			}
+			return nil, data.ErrNotFound
+		},
+	}
+	client := notebookpb.NewUserServiceClient(testServer(t, newServer(&data.MockSetDB{}, userDB, nil, nil, nil)))
+
+	tests := []struct {
+		name     string
+		id       int64
+		wantCode codes.Code
+		wantUser *notebookpb.User
+	}{
+		{
+			name:     "User is read",
+			id:       3,
+			wantCode: codes.OK,
+			wantUser: &notebookpb.User{Id: 3, Name: "Louie"},
+		},
+		{
+			name:     "Missing user is not found",
+			id:       9,
+			wantCode: codes.NotFound,
+		},
+		{
+			name:     "Invalid user id is rejected",
+			id:       -1,
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "Database errors are hidden",
+			id:       4,
+			wantCode: codes.Internal,
+		},
+	}
+
+	ctx := loginContext(t, 1)
+	for _, v := range tests {
+		resp, err := client.GetUser(ctx, &notebookpb.GetUserRequest{Id: v.id})
+		if status.Code(err) != v.wantCode {
+			t.Fatalf("%s: Wanted code: %v\nGot error: %v", v.name, v.wantCode, err)
+		}
+		if v.wantCode == codes.Internal && status.Convert(err).Message() == "Expected error" {
+			t.Fatalf("%s: Wanted the cause of the error to be hidden", v.name)
+		}
+		if v.wantCode == codes.OK && !proto.Equal(resp.GetUser(), v.wantUser) {
+			t.Fatalf("%s: Wanted user: %v\nGot user: %v", v.name, v.wantUser, resp.GetUser())
+		}
+	}
+}
@@ -1,7 +1,8 @@
 # runs the webapp in development mode, launching client and server separately
 go build
 
-./training-notebook --config=${1}
+# webhooks may be delivered to receivers on localhost in development
+./training-notebook --config=${1} --webhooks.allow-private-networks
 
 # Command for running front end and backend together in development mode:
-# sh -c './training-notebook --config='${1}' | tee server.log > /dev/null & npm start --prefix frontend/ | tee frontend.log > /dev/null & wait'
+# sh -c './training-notebook --config='${1}' --webhooks.allow-private-networks | tee server.log > /dev/null & npm start --prefix frontend/ | tee frontend.log > /dev/null & wait'
@@ -1,5 +1,12 @@
 # starts training-notebook webapp in prod mode
-# perhaps build things first... go build, yarn build --prefix frontend/
-go build
-yarn --cwd frontend/ build 
-./training-notebook --config=$1 --prod=true
\ No newline at end of file
+# builds the frontend, precompresses it, and embeds it in the binary with the
+# swagger spec, so the binary can be deployed on its own
+yarn --cwd frontend/ build
+find frontend/build -type f \( -name '*.js' -o -name '*.css' -o -name '*.html' -o -name '*.json' -o -name '*.svg' -o -name '*.map' \) \
+  -exec gzip -k -9 -f {} \;
+if command -v brotli > /dev/null; then
+  find frontend/build -type f \( -name '*.js' -o -name '*.css' -o -name '*.html' -o -name '*.json' -o -name '*.svg' -o -name '*.map' \) \
+    -exec brotli -k -f {} \;
+fi
+go build -tags embed -ldflags "-X github.com/hrand1005/training-notebook/buildinfo.Commit=$(git rev-parse HEAD) -X github.com/hrand1005/training-notebook/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+./training-notebook --config=$1 --mode=prod --embed-assets
\ No newline at end of file
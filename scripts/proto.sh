@@ -0,0 +1,6 @@
+# regenerates the gRPC code in rpc/notebookpb from the protobuf definitions in
+# rpc/proto, using buf with the protoc-gen-go and protoc-gen-go-grpc plugins:
+# go install google.golang.org/protobuf/cmd/protoc-gen-go@v1.34.2
+# go install google.golang.org/grpc/cmd/protoc-gen-go-grpc@v1.5.1
+cd "$(dirname "$0")/../rpc" || exit 1
+buf lint && buf generate
@@ -1 +1,2 @@
+# swagger.v2.yaml is maintained by hand alongside the generated version 1 spec
 swagger generate spec -o ../docs/swagger.yaml --scan-models
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/problem"
+)
+
+// indexFile is served for paths that are not files, so that client-side
+// routes load the app when visited directly
+const indexFile = "index.html"
+
+// Cache-Control values for frontend files
+const (
+	// cacheImmutable lets browsers keep files with a content hash in their
+	// name, since a changed file is served under a new name
+	cacheImmutable = "public, max-age=31536000, immutable"
+	// cacheRevalidate makes browsers check every other file for changes, so
+	// that a new deployment is picked up on the next load
+	cacheRevalidate = "no-cache"
+)
+
+// hashedName matches file names containing a content hash, such as
+// main.1f2e3d4c.js in a React build
+var hashedName = regexp.MustCompile(`\.[0-9a-f]{8,}\.`)
+
+// precompressed are the encodings of precompressed variants served in place
+// of a file, in order of preference, by the extension appended to its name
+var precompressed = []struct {
+	encoding, ext string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// assets serves a single page app's files from a file system, such as the
+// frontend build on disk or built into the binary
+type assets struct {
+	fsys fs.FS
+	// etags caches the tags of files without a modification time, such as
+	// embedded files, by name
+	etags sync.Map
+}
+
+func newAssets(fsys fs.FS) *assets {
+	return &assets{fsys: fsys}
+}
+
+// NoRoute serves requests matching no route. Api paths are answered with a
+// not found problem, and other GET and HEAD requests with the file at their
+// path. Paths that are neither files nor look like one are answered with
+// index.html, leaving them to the app's client-side router.
+func (a *assets) NoRoute(c *gin.Context) {
+	p := path.Clean("/" + c.Request.URL.Path)
+	if p == "/api" || strings.HasPrefix(p, "/api/") ||
+		(c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead) {
+		problem.Abort(c, problem.Newf(problem.CodeNotFound, "no such endpoint %v %v", c.Request.Method, p))
+		return
+	}
+
+	name := strings.TrimPrefix(p, "/")
+	if info, err := fs.Stat(a.fsys, name); err != nil || info.IsDir() {
+		if path.Ext(name) != "" {
+			// a missing asset, which should not be answered with html
+			c.Status(http.StatusNotFound)
+			return
+		}
+		name = indexFile
+	}
+
+	a.serve(c, name)
+}
+
+// serve writes the file name, or its precompressed variant if the client
+// accepts it, with cache headers suited to the file.
+func (a *assets) serve(c *gin.Context, name string) {
+	served := name
+	c.Writer.Header().Add("Vary", "Accept-Encoding")
+	for _, v := range precompressed {
+		if !acceptsEncoding(c.GetHeader("Accept-Encoding"), v.encoding) {
+			continue
+		}
+		if info, err := fs.Stat(a.fsys, name+v.ext); err == nil && !info.IsDir() {
+			served = name + v.ext
+			c.Header("Content-Encoding", v.encoding)
+			break
+		}
+	}
+
+	info, err := fs.Stat(a.fsys, served)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	content, err := fs.ReadFile(a.fsys, served)
+	if err != nil {
+		problem.Internal(c, err)
+		return
+	}
+
+	// the type is that of the original file, rather than of its compressed
+	// variant
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	if hashedName.MatchString(path.Base(name)) {
+		c.Header("Cache-Control", cacheImmutable)
+	} else {
+		c.Header("Cache-Control", cacheRevalidate)
+	}
+	if info.ModTime().IsZero() {
+		c.Header("ETag", a.etag(served, content))
+	}
+
+	http.ServeContent(c.Writer, c.Request, served, info.ModTime(), bytes.NewReader(content))
+}
+
+// etag returns the entity tag of the file name with the given content.
+func (a *assets) etag(name string, content []byte) string {
+	if tag, ok := a.etags.Load(name); ok {
+		return tag.(string)
+	}
+	sum := sha256.Sum256(content)
+	tag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	a.etags.Store(name, tag)
+	return tag
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header accepts
+// encoding.
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+		q := strings.ReplaceAll(params, " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
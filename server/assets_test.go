@@ -0,0 +1,169 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestAssetsNoRoute checks that files are served with cache headers suited
+// to them, precompressed when accepted, and that other paths fall back to
+// index.html unless they belong to the api or look like a file.
+func TestAssetsNoRoute(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":                      {Data: []byte("<html>app</html>")},
+		"favicon.ico":                     {Data: []byte("icon")},
+		"static/js/main.1f2e3d4c.js":      {Data: []byte("console.log('app')")},
+		"static/js/main.1f2e3d4c.js.br":   {Data: []byte("brotli")},
+		"static/js/main.1f2e3d4c.js.gz":   {Data: []byte("gzip")},
+		"static/css/main.0a1b2c3d.css":    {Data: []byte("body{}")},
+		"static/css/main.0a1b2c3d.css.gz": {Data: []byte("gzip css")},
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		acceptEncoding string
+		wantCode       int
+		wantBody       string
+		wantType       string
+		wantEncoding   string
+		wantCache      string
+	}{
+		{
+			name:      "Root serves index",
+			path:      "/",
+			wantCode:  http.StatusOK,
+			wantBody:  "<html>app</html>",
+			wantType:  "text/html; charset=utf-8",
+			wantCache: cacheRevalidate,
+		},
+		{
+			name:      "Client-side route falls back to index",
+			path:      "/sets/3",
+			wantCode:  http.StatusOK,
+			wantBody:  "<html>app</html>",
+			wantCache: cacheRevalidate,
+		},
+		{
+			name:      "Unhashed file must be revalidated",
+			path:      "/favicon.ico",
+			wantCode:  http.StatusOK,
+			wantBody:  "icon",
+			wantCache: cacheRevalidate,
+		},
+		{
+			name:      "Hashed file is immutable",
+			path:      "/static/js/main.1f2e3d4c.js",
+			wantCode:  http.StatusOK,
+			wantBody:  "console.log('app')",
+			wantType:  "text/javascript; charset=utf-8",
+			wantCache: cacheImmutable,
+		},
+		{
+			name:           "Brotli is preferred",
+			path:           "/static/js/main.1f2e3d4c.js",
+			acceptEncoding: "gzip, deflate, br",
+			wantCode:       http.StatusOK,
+			wantBody:       "brotli",
+			wantType:       "text/javascript; charset=utf-8",
+			wantEncoding:   "br",
+			wantCache:      cacheImmutable,
+		},
+		{
+			name:           "Gzip is served without brotli variant",
+			path:           "/static/css/main.0a1b2c3d.css",
+			acceptEncoding: "br, gzip",
+			wantCode:       http.StatusOK,
+			wantBody:       "gzip css",
+			wantType:       "text/css; charset=utf-8",
+			wantEncoding:   "gzip",
+		},
+		{
+			name:           "Refused encoding is not served",
+			path:           "/static/js/main.1f2e3d4c.js",
+			acceptEncoding: "br;q=0, gzip",
+			wantCode:       http.StatusOK,
+			wantBody:       "gzip",
+			wantEncoding:   "gzip",
+		},
+		{
+			name:     "Missing asset is not found",
+			path:     "/static/js/missing.js",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Unknown api path is not found",
+			path:     "/api/unknown",
+			wantCode: http.StatusNotFound,
+			wantType: "application/problem+json",
+		},
+		{
+			name:     "Mutating request is not found",
+			method:   http.MethodPost,
+			path:     "/sets/3",
+			wantCode: http.StatusNotFound,
+			wantType: "application/problem+json",
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.NoRoute(newAssets(fsys).NoRoute)
+
+	for _, v := range tests {
+		method := v.method
+		if method == "" {
+			method = http.MethodGet
+		}
+		req, _ := http.NewRequest(method, v.path, nil)
+		if v.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", v.acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != v.wantCode {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v", v.name, v.wantCode, w.Code)
+		}
+		if v.wantBody != "" && w.Body.String() != v.wantBody {
+			t.Fatalf("%s: Wanted body: %q\nGot body: %q", v.name, v.wantBody, w.Body.String())
+		}
+		if v.wantType != "" && w.Header().Get("Content-Type") != v.wantType {
+			t.Fatalf("%s: Wanted content type: %q\nGot content type: %q", v.name, v.wantType, w.Header().Get("Content-Type"))
+		}
+		if got := w.Header().Get("Content-Encoding"); got != v.wantEncoding {
+			t.Fatalf("%s: Wanted content encoding: %q\nGot content encoding: %q", v.name, v.wantEncoding, got)
+		}
+		if v.wantCache != "" && w.Header().Get("Cache-Control") != v.wantCache {
+			t.Fatalf("%s: Wanted cache control: %q\nGot cache control: %q", v.name, v.wantCache, w.Header().Get("Cache-Control"))
+		}
+	}
+}
+
+// TestAssetsETag checks that files without a modification time, such as
+// embedded files, can be revalidated by their entity tag.
+func TestAssetsETag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.NoRoute(newAssets(fstest.MapFS{"index.html": {Data: []byte("app")}}).NoRoute)
+
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("Wanted ETag header, got %v", w.Header())
+	}
+
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("Wanted code: %v\nGot code: %v", http.StatusNotModified, w.Code)
+	}
+}
@@ -1,23 +1,49 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
 	"database/sql"
 	"fmt"
 	"io"
-	"log"
+	"io/fs"
+	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"time"
 
-	"github.com/gin-gonic/contrib/static"
 	"github.com/gin-gonic/gin"
 	"github.com/go-openapi/runtime/middleware"
 	"github.com/hrand1005/training-notebook/api"
+	"github.com/hrand1005/training-notebook/api/cors"
+	"github.com/hrand1005/training-notebook/api/csrf"
+	"github.com/hrand1005/training-notebook/api/events"
+	"github.com/hrand1005/training-notebook/api/health"
+	"github.com/hrand1005/training-notebook/api/idempotency"
+	"github.com/hrand1005/training-notebook/api/openapi"
+	"github.com/hrand1005/training-notebook/api/ratelimit"
+	"github.com/hrand1005/training-notebook/api/requestid"
+	"github.com/hrand1005/training-notebook/api/rooms"
+	"github.com/hrand1005/training-notebook/api/users"
+	"github.com/hrand1005/training-notebook/api/version"
+	"github.com/hrand1005/training-notebook/api/webhooks"
 	"github.com/hrand1005/training-notebook/data"
+	"github.com/hrand1005/training-notebook/docs"
+	"github.com/hrand1005/training-notebook/frontend"
+	"github.com/hrand1005/training-notebook/metrics"
+	"github.com/hrand1005/training-notebook/rpc"
+	"github.com/hrand1005/training-notebook/tracing"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// backgroundStopTimeout bounds how long shutdown waits for background work to
+// stop before closing the database
+const backgroundStopTimeout = 5 * time.Second
+
 // NewBuilder returns a builder with sensible server defaults.
 func NewBuilder() *builder {
 	return &builder{
@@ -30,12 +56,36 @@ func NewBuilder() *builder {
 // builder contains an interface for building a server.
 // Call it's methods to configure a server, and call 'Construct' to instantiate it.
 type builder struct {
-	db              *sql.DB
-	frontendPath    string
-	logFile         string
-	httpServer      http.Server
-	swaggerSpecPath string
-	err             error
+	db                *sql.DB
+	frontendPath      string
+	embedFrontend     bool
+	logFile           string
+	logLevel          string
+	logFormat         string
+	logRotation       logRotation
+	httpServer        http.Server
+	swaggerSpecPath   string
+	embedSwagger      bool
+	idempotencyWindow time.Duration
+	adminAddr         string
+	grpcAddr          string
+	gracePeriod       time.Duration
+	drainDelay        time.Duration
+	signingKey        string
+	tls               tlsSettings
+	redirectAddr      string
+	tracing           tracing.Options
+	rateLimits        []ratelimit.Policy
+	trustedProxies    []string
+	cors              cors.Options
+	cookieSameSite    string
+	validateRequests  bool
+	validateResponses bool
+	// privateWebhooks allows webhooks to be delivered to private addresses
+	privateWebhooks bool
+	// deprecations are the deprecation and sunset dates of api versions
+	deprecations map[version.Version][2]time.Time
+	err          error
 }
 
 func (b *builder) SetDB(dbPath string) {
@@ -52,14 +102,46 @@ func (b *builder) RegisterSwaggerDocs(specPath string) {
 	b.swaggerSpecPath = specPath
 }
 
+// RegisterEmbeddedSwaggerDocs serves the swagger spec built into the binary
+// in place of a spec on disk.
+func (b *builder) RegisterEmbeddedSwaggerDocs() {
+	b.embedSwagger = true
+}
+
+// RegisterFrontend serves the single page app built in frontendPath on every
+// path outside the api.
 func (b *builder) RegisterFrontend(frontendPath string) {
 	b.frontendPath = frontendPath
 }
 
+// RegisterEmbeddedFrontend serves the single page app built into the binary
+// in place of a build on disk. The app is only built in with the embed build
+// tag.
+func (b *builder) RegisterEmbeddedFrontend() {
+	b.embedFrontend = true
+}
+
 func (b *builder) RegisterFileLogger(logFile string) {
 	b.logFile = logFile
 }
 
+func (b *builder) SetLogLevel(level string) {
+	b.logLevel = level
+}
+
+func (b *builder) SetLogFormat(format string) {
+	b.logFormat = format
+}
+
+func (b *builder) SetLogRotation(maxSizeMB, maxBackups, maxAgeDays int, compress bool) {
+	b.logRotation = logRotation{
+		maxSizeMB:  maxSizeMB,
+		maxBackups: maxBackups,
+		maxAgeDays: maxAgeDays,
+		compress:   compress,
+	}
+}
+
 func (b *builder) SetServerAddr(port string) {
 	b.httpServer.Addr = port
 }
@@ -76,57 +158,366 @@ func (b *builder) SetWriteTimeout(timeout time.Duration) {
 	b.httpServer.WriteTimeout = timeout
 }
 
+// SetGRPCAddr serves the gRPC api on a separate address, over TLS if the
+// server is. The gRPC api is not served if addr is empty.
+func (b *builder) SetGRPCAddr(addr string) {
+	b.grpcAddr = addr
+}
+
+// SetAdminAddr serves operational endpoints, such as metrics, on a separate
+// address instead of the main router.
+func (b *builder) SetAdminAddr(addr string) {
+	b.adminAddr = addr
+}
+
+// SetShutdownGracePeriod bounds how long in-flight requests are given to
+// complete once shutdown begins.
+func (b *builder) SetShutdownGracePeriod(period time.Duration) {
+	b.gracePeriod = period
+}
+
+// SetShutdownDrainDelay sets how long the server keeps serving after it
+// starts reporting itself as not ready, before it stops accepting connections.
+func (b *builder) SetShutdownDrainDelay(delay time.Duration) {
+	b.drainDelay = delay
+}
+
+// tlsSettings configure TLS, which is enabled if a certificate file is set
+type tlsSettings struct {
+	certFile, keyFile        string
+	minVersion, cipherPolicy string
+}
+
+// SetTLS serves HTTPS with the certificate and key in the given files, which
+// are reloaded when they change. Login cookies are marked secure when TLS is
+// enabled.
+func (b *builder) SetTLS(certFile, keyFile string) {
+	b.tls.certFile = certFile
+	b.tls.keyFile = keyFile
+}
+
+// SetTLSPolicy sets the minimum TLS version, "1.2" or "1.3", and the cipher
+// policy, "default" or "modern".
+func (b *builder) SetTLSPolicy(minVersion, cipherPolicy string) {
+	b.tls.minVersion = minVersion
+	b.tls.cipherPolicy = cipherPolicy
+}
+
+// SetRedirectAddr serves plain HTTP on addr, redirecting every request to
+// HTTPS. It has no effect unless TLS is enabled.
+func (b *builder) SetRedirectAddr(addr string) {
+	b.redirectAddr = addr
+}
+
+// SetCORS allows browsers to call the api from origins, sending cookies if
+// allowCredentials is set, and to cache preflight responses for maxAge.
+// Cross-origin requests are not allowed if no origins are set.
+func (b *builder) SetCORS(origins []string, allowCredentials bool, maxAge time.Duration) {
+	b.cors = cors.Options{
+		AllowedOrigins:   origins,
+		AllowCredentials: allowCredentials,
+		MaxAge:           maxAge,
+	}
+}
+
+// SetCookieSameSite sets the SameSite attribute of login and CSRF cookies,
+// one of lax, strict or none. Defaults to lax.
+func (b *builder) SetCookieSameSite(mode string) {
+	b.cookieSameSite = mode
+}
+
+// SetWebhookPrivateNetworks sets whether webhooks may be delivered to
+// loopback, private and link-local addresses. Defaults to false.
+func (b *builder) SetWebhookPrivateNetworks(allow bool) {
+	b.privateWebhooks = allow
+}
+
+// SetVersionDeprecation schedules the deprecation of api version v, which
+// remains served until sunset. Versions are current by default.
+func (b *builder) SetVersionDeprecation(v version.Version, deprecated, sunset time.Time) {
+	if b.deprecations == nil {
+		b.deprecations = make(map[version.Version][2]time.Time)
+	}
+	b.deprecations[v] = [2]time.Time{deprecated, sunset}
+}
+
+// SetSigningKey sets the key used to sign and verify login tokens.
+func (b *builder) SetSigningKey(key string) {
+	b.signingKey = key
+}
+
+// SetRateLimits limits requests to the api according to policies, per user
+// or per client address. Requests to routes without a policy are not limited.
+func (b *builder) SetRateLimits(policies []ratelimit.Policy) {
+	b.rateLimits = policies
+}
+
+// SetTrustedProxies sets the addresses or CIDR ranges of proxies whose
+// forwarding headers are trusted to report the client address. Forwarding
+// headers are ignored if none are set.
+func (b *builder) SetTrustedProxies(proxies []string) {
+	b.trustedProxies = proxies
+}
+
+// SetSpecValidation validates api requests against the swagger spec, and
+// responses too if validateResponses is set. Requires swagger docs to be
+// registered.
+func (b *builder) SetSpecValidation(validateRequests, validateResponses bool) {
+	b.validateRequests = validateRequests
+	b.validateResponses = validateResponses
+}
+
+func (b *builder) SetIdempotencyWindow(window time.Duration) {
+	b.idempotencyWindow = window
+}
+
+// SetTraceExporter exports spans with exporter, one of none, stdout, file or
+// otlp. file is the path written by the file exporter, and endpoint the
+// host:port of the OTLP/HTTP collector, which is connected to without TLS if
+// insecure is set.
+func (b *builder) SetTraceExporter(exporter, file, endpoint string, insecure bool) {
+	b.tracing.Exporter = exporter
+	b.tracing.File = file
+	b.tracing.Endpoint = endpoint
+	b.tracing.Insecure = insecure
+}
+
+func (b *builder) SetTraceSampleRatio(ratio float64) {
+	b.tracing.SampleRatio = ratio
+}
+
+func (b *builder) SetServiceName(name string) {
+	b.tracing.ServiceName = name
+}
+
 func (b *builder) Construct() (Server, error) {
 	if b.err != nil {
 		return nil, b.err
 	}
 
 	router := gin.New()
+	if err := router.SetTrustedProxies(b.trustedProxies); err != nil {
+		return nil, fmt.Errorf("configuring trusted proxies: %v", err)
+	}
+	// unversioned api requests are handled again as requests to the
+	// negotiated version, so negotiation must come before any other middleware
+	router.Use(version.Negotiate(router, "/api"))
+	router.Use(requestid.Middleware())
+	// preflight requests match no route, so CORS applies to the whole router.
+	// The policy is applied even without origins, so that they can be added
+	// by a reload.
+	if err := b.cors.Validate(); err != nil {
+		return nil, fmt.Errorf("configuring CORS: %v", err)
+	}
+	corsPolicy := cors.New(b.cors)
+	router.Use(corsPolicy.Middleware())
 	apiGroup := router.Group("/api")
 
-	// add outstanding resources to closers, and the server will call close
-	// on them before gracefully shutting down
-	closers := []io.Closer{b.db}
+	// add outstanding resources to closers in the order they are opened, and
+	// the server will close them in reverse once requests have drained. The
+	// log file comes first so that it is closed last.
+	var closers []io.Closer
 
-	// check if logger is set, if so use it with the router
+	// log to the configured file if set, otherwise to stderr
+	var logOutput io.Writer = os.Stderr
 	if b.logFile != "" {
-		f, err := os.Create(b.logFile)
+		f, err := openLogFile(b.logFile, b.logRotation)
 		if err != nil {
-			log.Printf("Failed to create log file: %v\nProceeding without logger...", err)
+			slog.Warn("failed to open log file, logging to stderr", "file", b.logFile, "error", err)
 		} else {
-			logger := log.New(f, "", log.LstdFlags)
-			apiGroup.Use(api.LatencyLogger(logger))
+			logOutput = f
 			closers = append(closers, f)
 		}
 	}
+	logger, logLevel, err := newLogger(logOutput, b.logLevel, b.logFormat)
+	if err != nil {
+		return nil, err
+	}
+	// all packages log through the default logger
+	slog.SetDefault(logger)
+	closers = append(closers, b.db)
+
+	shutdownTracing, err := tracing.Setup(b.tracing)
+	if err != nil {
+		return nil, fmt.Errorf("configuring tracing: %v", err)
+	}
+	closers = append(closers, closerFunc(func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return shutdownTracing(ctx)
+	}))
+
+	// the tracing middleware runs first so the request logger can report
+	// the trace id
+	apiGroup.Use(tracing.Middleware(), api.RequestLogger(logger), api.Metrics())
 
 	if b.db == nil {
 		// TODO: initialize test mode db
 		return nil, fmt.Errorf("no db found, test mode not yet implemented")
 	}
 
-	if err := api.RegisterAll(b.db, apiGroup); err != nil {
+	var tlsConfig *tls.Config
+	if b.tls.certFile != "" {
+		tlsConfig, err = newTLSConfig(b.tls.certFile, b.tls.keyFile, b.tls.minVersion, b.tls.cipherPolicy)
+		if err != nil {
+			return nil, fmt.Errorf("configuring TLS: %v", err)
+		}
+	}
+
+	sameSite, err := parseSameSite(b.cookieSameSite)
+	if err != nil {
+		return nil, err
+	}
+
+	// middleware must be added to the group before endpoints are registered.
+	// Requests are limited before they reach idempotency keys, so that
+	// replayed requests count towards the limit too, and forged requests are
+	// rejected before their keys are reserved.
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), b.rateLimits)
+	apiGroup.Use(users.Authenticate(), limiter.Middleware(), csrf.Middleware(tlsConfig != nil, sameSite))
+
+	if b.validateRequests || b.validateResponses {
+		// requests are only validated once they are known to be allowed, and
+		// before idempotency keys are reserved, so that rejected requests can
+		// be corrected and retried with the same key. Each spec only validates
+		// routes under its version's base path.
+		for _, v := range version.Supported {
+			spec, err := b.loadSpec(v)
+			if err != nil {
+				return nil, fmt.Errorf("loading %v api spec: %v", v, err)
+			}
+			apiGroup.Use(spec.Middleware(b.validateResponses))
+		}
+	}
+
+	idempotencyDB, err := data.NewIdempotencyDB(b.db)
+	if err != nil {
+		return nil, fmt.Errorf("preparing idempotency keys: %v", err)
+	}
+	idempotencyWindow := &atomic.Int64{}
+	idempotencyWindow.Store(int64(b.idempotencyWindow))
+	apiGroup.Use(idempotency.Middleware(idempotencyDB, func() time.Duration {
+		return time.Duration(idempotencyWindow.Load())
+	}))
+
+	users.SetSigningKey([]byte(b.signingKey))
+	// cookies are only sent over HTTPS when it is available
+	users.SetSecureCookies(tlsConfig != nil)
+	users.SetCookieSameSite(sameSite)
+	webhooks.AllowPrivateNetworks(b.privateWebhooks)
+	rooms.SetAllowedOrigins(corsPolicy)
+	for v, dates := range b.deprecations {
+		version.Deprecate(v, dates[0], dates[1])
+	}
+	// live events outlast requests, so they are ended when the server shuts
+	// down rather than holding it open for the grace period
+	hub := events.NewHub(events.DefaultLogSize, events.DefaultBufferSize)
+	b.httpServer.RegisterOnShutdown(hub.Close)
+	stopped, err := api.RegisterAll(b.db, apiGroup, hub)
+	if err != nil {
 		return nil, fmt.Errorf("registering api endpoints: %v", err)
 	}
+	// background work such as webhook deliveries stops once the hub is
+	// closed, and is given time to do so before the database is closed
+	closers = append(closers, closerFunc(func() error {
+		hub.Close()
+		select {
+		case <-stopped:
+			return nil
+		case <-time.After(backgroundStopTimeout):
+			return fmt.Errorf("background work did not stop within %v", backgroundStopTimeout)
+		}
+	}))
 
-	if b.swaggerSpecPath != "" {
-		// set redoc options for swagger spec and create handler
-		docOptions := middleware.RedocOpts{SpecURL: "/swagger.yaml"}
-		docHandler := gin.WrapH(middleware.Redoc(docOptions, nil))
+	if b.swaggerSpecPath != "" || b.embedSwagger {
+		// each version serves its own docs and spec, e.g. /api/v2/docs
+		for _, v := range version.Supported {
+			base := "/api" + v.Path()
+			docOptions := middleware.RedocOpts{BasePath: base, SpecURL: base + "/swagger.yaml"}
+			docHandler := gin.WrapH(middleware.Redoc(docOptions, nil))
 
-		// create docs endpoint, register api documentation with SwaggerSpec
-		apiGroup.GET("/docs", docHandler)
-		apiGroup.StaticFile("/swagger.yaml", b.swaggerSpecPath)
+			apiGroup.GET(v.Path()+"/docs", docHandler)
+			if b.embedSwagger {
+				apiGroup.StaticFileFS(v.Path()+"/swagger.yaml", docs.SwaggerFileFor(int(v)), http.FS(docs.FS))
+			} else {
+				apiGroup.StaticFile(v.Path()+"/swagger.yaml", b.specPath(v))
+			}
+		}
 	}
 
-	if b.frontendPath != "" {
-		router.Use(static.Serve("/", static.LocalFile(b.frontendPath, true)))
+	var frontendFS fs.FS
+	if b.embedFrontend {
+		var ok bool
+		if frontendFS, ok = frontend.FS(); !ok {
+			return nil, fmt.Errorf("serving embedded frontend: binary was built without the embed tag")
+		}
+	} else if b.frontendPath != "" {
+		frontendFS = os.DirFS(b.frontendPath)
+	}
+	if frontendFS != nil {
+		router.NoRoute(newAssets(frontendFS).NoRoute)
 	}
 
-	b.httpServer.Handler = router
+	if err := metrics.RegisterDBStats(b.db); err != nil {
+		return nil, fmt.Errorf("registering db metrics: %v", err)
+	}
+
+	// serve metrics on the admin address if set, otherwise alongside the api
+	var admin *http.Server
+	if b.adminAddr != "" {
+		adminRouter := gin.New()
+		adminRouter.GET("/metrics", gin.WrapH(metrics.Handler()))
+		admin = &http.Server{
+			Addr:    b.adminAddr,
+			Handler: adminRouter,
+		}
+	} else {
+		router.GET("/metrics", gin.WrapH(metrics.Handler()))
+	}
+
+	// probes are served on the main router, which is what orchestrators
+	// route traffic to
+	checker := health.New(b.db)
+	checker.RegisterHandlers(router)
+
+	// the gRPC api shares the db and login tokens of the REST api
+	var rpcServer *rpc.Server
+	if b.grpcAddr != "" {
+		rpcServer, err = rpc.New(b.db, tlsConfig, hub, limiter)
+		if err != nil {
+			return nil, fmt.Errorf("registering gRPC services: %v", err)
+		}
+	}
+
+	b.httpServer.Handler = events.WithResponseController(router)
+	b.httpServer.TLSConfig = tlsConfig
+
+	var redirect *http.Server
+	if tlsConfig != nil && b.redirectAddr != "" {
+		redirect = &http.Server{
+			Addr:        b.redirectAddr,
+			Handler:     redirectHandler(b.httpServer.Addr),
+			ReadTimeout: b.httpServer.ReadTimeout,
+			IdleTimeout: b.httpServer.IdleTimeout,
+		}
+	}
 
 	return &server{
-		h: &b.httpServer,
+		h:           &b.httpServer,
+		admin:       admin,
+		redirect:    redirect,
+		rpc:         rpcServer,
+		rpcAddr:     b.grpcAddr,
+		rpcTLS:      tlsConfig != nil,
+		health:      checker,
+		drainDelay:  b.drainDelay,
+		gracePeriod: b.gracePeriod,
+		settings: runtimeSettings{
+			logLevel:          logLevel,
+			idempotencyWindow: idempotencyWindow,
+			limiter:           limiter,
+			cors:              corsPolicy,
+		},
 		c: closers,
 	}, nil
 }
@@ -134,3 +525,50 @@ func (b *builder) Construct() (Server, error) {
 func (b *builder) Error() error {
 	return b.err
 }
+
+// parseSameSite parses a cookie SameSite attribute, defaulting to lax.
+func parseSameSite(mode string) (http.SameSite, error) {
+	switch strings.ToLower(mode) {
+	case "", "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	}
+	return 0, fmt.Errorf("invalid cookie SameSite mode %q, must be lax, strict or none", mode)
+}
+
+// specPath returns the path of the swagger spec of v on disk. Specs of later
+// versions are read from the directory of the version 1 spec.
+func (b *builder) specPath(v version.Version) string {
+	if v == version.V1 {
+		return b.swaggerSpecPath
+	}
+	return filepath.Join(filepath.Dir(b.swaggerSpecPath), docs.SwaggerFileFor(int(v)))
+}
+
+// loadSpec loads the swagger spec served by version v of the api.
+func (b *builder) loadSpec(v version.Version) (*openapi.Spec, error) {
+	var spec []byte
+	var err error
+	switch {
+	case b.embedSwagger:
+		spec, err = fs.ReadFile(docs.FS, docs.SwaggerFileFor(int(v)))
+	case b.swaggerSpecPath != "":
+		spec, err = os.ReadFile(b.specPath(v))
+	default:
+		return nil, fmt.Errorf("no swagger docs registered")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return openapi.Load(spec)
+}
+
+// closerFunc adapts a function to the io.Closer interface.
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// logRotation configures when log files are rotated. Rotation is disabled
+// when maxSizeMB is zero.
+type logRotation struct {
+	maxSizeMB  int
+	maxBackups int
+	maxAgeDays int
+	compress   bool
+}
+
+// openLogFile opens the log file at path for appending, creating it if needed,
+// so that logs from previous runs are kept. If rotation is enabled, the file
+// is rotated once it grows beyond the configured size.
+func openLogFile(path string, rotation logRotation) (io.WriteCloser, error) {
+	if rotation.maxSizeMB > 0 {
+		return &lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    rotation.maxSizeMB,
+			MaxBackups: rotation.maxBackups,
+			MaxAge:     rotation.maxAgeDays,
+			Compress:   rotation.compress,
+		}, nil
+	}
+
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+}
+
+// newLogger returns a logger writing records of at least the given level to w,
+// formatted as either "json" or "text". Empty level and format default to
+// "info" and "json". The level may be changed while the logger is in use
+// through the returned LevelVar.
+func newLogger(w io.Writer, level, format string) (*slog.Logger, *slog.LevelVar, error) {
+	lvl, err := parseLevel(level)
+	if err != nil {
+		return nil, nil, err
+	}
+	levelVar := &slog.LevelVar{}
+	levelVar.Set(lvl)
+	opts := &slog.HandlerOptions{Level: levelVar}
+
+	switch strings.ToLower(format) {
+	case "", "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), levelVar, nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), levelVar, nil
+	default:
+		return nil, nil, fmt.Errorf("invalid log format %q, must be json or text", format)
+	}
+}
+
+// parseLevel parses a log level such as "debug" or "warn". An empty level is
+// parsed as info.
+func parseLevel(level string) (slog.Level, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return lvl, fmt.Errorf("invalid log level %q: %v", level, err)
+		}
+	}
+	return lvl, nil
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestOpenLogFileAppends checks that reopening a log file keeps the logs
+// written by previous runs.
+func TestOpenLogFileAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+
+	for _, line := range []string{"first\n", "second\n"} {
+		f, err := openLogFile(path, logRotation{})
+		if err != nil {
+			t.Fatalf("Encountered unexpected error: %v", err)
+		}
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Encountered unexpected error: %v", err)
+		}
+		f.Close()
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if string(got) != "first\nsecond\n" {
+		t.Fatalf("Wanted both runs in log file, got %q", got)
+	}
+}
+
+// TestNewLogger checks that log level and format are applied, and that
+// invalid settings are rejected.
+func TestNewLogger(t *testing.T) {
+	tests := []struct {
+		name       string
+		level      string
+		format     string
+		wantErr    bool
+		wantOutput string
+	}{
+		{
+			name:       "Defaults log info as json",
+			wantOutput: `"msg":"info"`,
+		},
+		{
+			name:       "Text format at warn level",
+			level:      "warn",
+			format:     "text",
+			wantOutput: "level=WARN msg=warn",
+		},
+		{
+			name:    "Invalid level is rejected",
+			level:   "loud",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid format is rejected",
+			format:  "xml",
+			wantErr: true,
+		},
+	}
+
+	for _, v := range tests {
+		var buf bytes.Buffer
+		logger, _, err := newLogger(&buf, v.level, v.format)
+		if v.wantErr {
+			if err == nil {
+				t.Fatalf("%s: Expected error", v.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: Encountered unexpected error: %v", v.name, err)
+		}
+
+		logger.Debug("debug")
+		logger.Info("info")
+		logger.Warn("warn")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if !strings.Contains(lines[0], v.wantOutput) {
+			t.Fatalf("%s: Wanted first record to contain %q, got %q", v.name, v.wantOutput, buf.String())
+		}
+	}
+}
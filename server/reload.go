@@ -0,0 +1,58 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hrand1005/training-notebook/api/cors"
+	"github.com/hrand1005/training-notebook/api/ratelimit"
+)
+
+// Settings are the settings that can be changed with Reload while the server
+// is running. Every other setting only takes effect when the server is built.
+type Settings struct {
+	LogLevel          string
+	IdempotencyWindow time.Duration
+	RateLimits        []ratelimit.Policy
+	CORS              cors.Options
+}
+
+// runtimeSettings holds the values read by the server's components on each
+// use, so that they can be replaced while the server is running
+type runtimeSettings struct {
+	// mu serializes reloads
+	mu                sync.Mutex
+	logLevel          *slog.LevelVar
+	idempotencyWindow *atomic.Int64
+	limiter           *ratelimit.Limiter
+	cors              *cors.Policy
+}
+
+// Reload validates settings, then applies them all.
+func (s *server) Reload(settings Settings) error {
+	level, err := parseLevel(settings.LogLevel)
+	if err != nil {
+		return err
+	}
+	for i, p := range settings.RateLimits {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("rate limit policy %d: %v", i, err)
+		}
+	}
+	if err := settings.CORS.Validate(); err != nil {
+		return fmt.Errorf("CORS: %v", err)
+	}
+
+	s.settings.mu.Lock()
+	defer s.settings.mu.Unlock()
+
+	s.settings.logLevel.Set(level)
+	s.settings.idempotencyWindow.Store(int64(settings.IdempotencyWindow))
+	s.settings.limiter.SetPolicies(settings.RateLimits)
+	s.settings.cors.SetOptions(settings.CORS)
+
+	return nil
+}
@@ -2,49 +2,215 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"time"
+
+	"github.com/hrand1005/training-notebook/api/health"
+	"github.com/hrand1005/training-notebook/rpc"
 )
 
+// DefaultShutdownGracePeriod is how long in-flight requests are given to
+// complete after shutdown begins, if no grace period is configured
+const DefaultShutdownGracePeriod = 30 * time.Second
+
 // Server interface contains start method
 type Server interface {
-	Start(context.Context)
+	// Start serves requests until ctx is done or serving fails, then shuts
+	// down gracefully. Returns any error that caused or occurred during
+	// shutdown.
+	Start(context.Context) error
+	// Reload applies settings while the server is running. Either every
+	// setting is applied, or none are and an error is returned.
+	Reload(Settings) error
 }
 
 type server struct {
 	h *http.Server
+	// admin serves operational endpoints such as metrics on a separate
+	// address, and is nil if no admin address is configured
+	admin *http.Server
+	// redirect redirects plain HTTP requests to h, and is nil unless h
+	// serves TLS and a redirect address is configured
+	redirect *http.Server
+	// rpc serves the gRPC api on rpcAddr, and is nil if no gRPC address is
+	// configured
+	rpc     *rpc.Server
+	rpcAddr string
+	// rpcTLS reports whether rpc is served over TLS
+	rpcTLS bool
+	// health reports the server as not ready once shutdown begins
+	health *health.Checker
+	// drainDelay is how long the server keeps accepting connections while
+	// reporting itself as not ready, so that load balancers polling the
+	// readiness probe stop routing to it before connections are refused
+	drainDelay time.Duration
+	// gracePeriod bounds how long in-flight requests are drained for
+	gracePeriod time.Duration
+	// settings may be changed with Reload while the server is running
+	settings runtimeSettings
+	// c are closed in reverse order once requests have drained, so resources
+	// should be added in the order they are opened
 	c []io.Closer
 }
 
-// Start the server and await done signal for graceful shutdown
-func (s *server) Start(ctx context.Context) {
-	go func() {
-		if err := s.h.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("server encountered error while listening: %v\n", err)
+// Start the server and await done signal for graceful shutdown. Shutdown stops
+// accepting connections and drains in-flight requests before closing the
+// server's resources, so that requests never see a closed database.
+func (s *server) Start(ctx context.Context) error {
+	servers := s.servers()
+
+	// bind before reporting the server as started, so that the address is
+	// accepting connections by the time it is logged
+	listeners := make([]net.Listener, 0, len(servers)+1)
+	listen := func(addr string) (net.Listener, error) {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, errors.Join(fmt.Errorf("listening on %v: %w", addr, err), s.close())
 		}
-	}()
+		listeners = append(listeners, ln)
+		return ln, nil
+	}
+	for _, h := range servers {
+		if _, err := listen(h.Addr); err != nil {
+			return err
+		}
+	}
+	var rpcListener net.Listener
+	if s.rpc != nil {
+		var err error
+		if rpcListener, err = listen(s.rpcAddr); err != nil {
+			return err
+		}
+	}
 
-	log.Println("Server started")
+	serveErr := make(chan error, len(servers)+1)
+	for i, h := range servers {
+		go func(h *http.Server, ln net.Listener) {
+			var err error
+			if h.TLSConfig != nil {
+				// certificates are provided by the TLS config
+				err = h.ServeTLS(ln, "", "")
+			} else {
+				err = h.Serve(ln)
+			}
+			if err != nil && err != http.ErrServerClosed {
+				serveErr <- fmt.Errorf("serving on %v: %w", ln.Addr(), err)
+			}
+		}(h, listeners[i])
+	}
+	if s.rpc != nil {
+		go func() {
+			if err := s.rpc.Serve(rpcListener); err != nil {
+				serveErr <- fmt.Errorf("serving gRPC on %v: %w", rpcListener.Addr(), err)
+			}
+		}()
+	}
 
-	<-ctx.Done()
+	for i, h := range servers {
+		msg := "server started"
+		switch h {
+		case s.admin:
+			msg = "admin server started"
+		case s.redirect:
+			msg = "HTTPS redirect server started"
+		}
+		slog.Info(msg, "addr", listeners[i].Addr().String(), "tls", h.TLSConfig != nil)
+	}
+	if s.rpc != nil {
+		slog.Info("gRPC server started", "addr", rpcListener.Addr().String(), "tls", s.rpcTLS)
+	}
 
-	log.Println("Shutting down server")
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-serveErr:
+		slog.Error("server failed, shutting down", "error", err)
+	}
 
-	// close outstanding server resources
-	for _, v := range s.c {
-		err := v.Close()
-		if err != nil {
-			log.Printf("Error closing server resource: %v", err)
-		}
+	return errors.Join(err, s.shutdown())
+}
+
+// shutdown marks the server as not ready, keeps serving for the drain delay,
+// drains in-flight requests within the grace period, then closes the
+// server's resources.
+func (s *server) shutdown() error {
+	gracePeriod := s.gracePeriod
+	if gracePeriod <= 0 {
+		gracePeriod = DefaultShutdownGracePeriod
 	}
+	slog.Info("shutting down server", "drain_delay", s.drainDelay.String(), "grace_period", gracePeriod.String())
 
-	// shutdown gracefully with timeout context
-	timeout, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	if s.health != nil {
+		s.health.ShuttingDown()
+	}
+	if s.drainDelay > 0 {
+		time.Sleep(s.drainDelay)
+	}
+
+	timeout, cancel := context.WithTimeout(context.Background(), gracePeriod)
 	defer cancel()
 
-	if err := s.h.Shutdown(timeout); err != nil {
-		log.Fatalf("Failed to shutdown correctly: %v\n", err)
+	// gRPC calls are drained alongside http requests, so that both report
+	// the server as not serving as soon as shutdown begins
+	rpcDrained := make(chan error, 1)
+	if s.rpc != nil {
+		go func() { rpcDrained <- s.rpc.Shutdown(timeout) }()
+	} else {
+		rpcDrained <- nil
+	}
+
+	var errs []error
+	for _, h := range s.servers() {
+		if err := h.Shutdown(timeout); err != nil {
+			// requests still running after the grace period are cut off
+			h.Close()
+			err = fmt.Errorf("draining requests on %v: %w", h.Addr, err)
+			slog.Error("failed to drain requests", "error", err)
+			errs = append(errs, err)
+		}
+	}
+	if err := <-rpcDrained; err != nil {
+		err = fmt.Errorf("draining calls on %v: %w", s.rpcAddr, err)
+		slog.Error("failed to drain calls", "error", err)
+		errs = append(errs, err)
+	}
+
+	// the log file may be among the resources, so errors closing them are
+	// only returned
+	slog.Info("closing server resources")
+	errs = append(errs, s.close())
+
+	return errors.Join(errs...)
+}
+
+// servers returns the http servers to start, with the main server first.
+func (s *server) servers() []*http.Server {
+	servers := []*http.Server{s.h}
+	if s.admin != nil {
+		servers = append(servers, s.admin)
+	}
+	if s.redirect != nil {
+		servers = append(servers, s.redirect)
+	}
+	return servers
+}
+
+// close closes the server's resources in the reverse of the order they were
+// opened, and returns every error encountered.
+func (s *server) close() error {
+	var errs []error
+	for i := len(s.c) - 1; i >= 0; i-- {
+		if err := s.c[i].Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing server resource: %w", err))
+		}
 	}
+	return errors.Join(errs...)
 }
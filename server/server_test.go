@@ -0,0 +1,198 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/health"
+	"github.com/hrand1005/training-notebook/data"
+)
+
+// recorder records the order in which requests complete and resources close
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) closer(name string) closerFunc {
+	return func() error {
+		r.record(name)
+		return nil
+	}
+}
+
+// TestStartShutdown checks that shutdown drains in-flight requests before
+// closing resources in reverse order, and reports requests that outlast the
+// grace period.
+func TestStartShutdown(t *testing.T) {
+	tests := []struct {
+		name        string
+		gracePeriod time.Duration
+		wantErr     bool
+		wantEvents  []string
+	}{
+		{
+			name:        "In-flight request completes before resources close",
+			gracePeriod: time.Second,
+			wantEvents:  []string{"request", "second", "first"},
+		},
+		{
+			name:        "Request outlasting grace period is reported",
+			gracePeriod: 10 * time.Millisecond,
+			wantErr:     true,
+			wantEvents:  []string{"second", "first"},
+		},
+	}
+
+	for _, v := range tests {
+		rec := &recorder{}
+		started := make(chan struct{})
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			time.Sleep(200 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+			rec.record("request")
+		})
+
+		addr := freeAddr(t)
+		s := &server{
+			h:           &http.Server{Addr: addr, Handler: handler},
+			gracePeriod: v.gracePeriod,
+			c:           []io.Closer{rec.closer("first"), rec.closer("second")},
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- s.Start(ctx) }()
+
+		go func() {
+			resp, err := getWithRetry("http://" + addr)
+			if err == nil {
+				resp.Body.Close()
+			}
+		}()
+
+		<-started
+		cancel()
+		err := <-done
+
+		if (err != nil) != v.wantErr {
+			t.Fatalf("%s: wanted error: %v, got: %v", v.name, v.wantErr, err)
+		}
+
+		// a request cut off by the grace period may still finish afterwards
+		rec.mu.Lock()
+		events := rec.events[:len(v.wantEvents)]
+		rec.mu.Unlock()
+		if !reflect.DeepEqual(events, v.wantEvents) {
+			t.Fatalf("%s: wanted events %v, got %v", v.name, v.wantEvents, events)
+		}
+	}
+}
+
+// TestDrainDelay checks that the server keeps serving for the drain delay
+// once shutdown begins, so that the failing readiness probe can be observed
+// before connections are refused.
+func TestDrainDelay(t *testing.T) {
+	db, err := data.SqliteDB(filepath.Join(t.TempDir(), "drain.sqlite"))
+	if err != nil {
+		t.Fatalf("failed to open test db: %v", err)
+	}
+	checker := health.New(db)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	checker.RegisterHandlers(router)
+
+	addr := freeAddr(t)
+	s := &server{
+		h:          &http.Server{Addr: addr, Handler: router},
+		health:     checker,
+		drainDelay: 500 * time.Millisecond,
+		c:          []io.Closer{db},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Start(ctx) }()
+
+	resp, err := getWithRetry("http://" + addr + "/healthz")
+	if err != nil {
+		t.Fatalf("server did not start: %v", err)
+	}
+	resp.Body.Close()
+
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+	resp, err = http.Get("http://" + addr + "/readyz")
+	if err != nil {
+		t.Fatalf("wanted the server to serve during the drain delay, got: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("wanted readiness code %v during the drain delay, got %v", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("wanted no error, got: %v", err)
+	}
+}
+
+// TestStartListenError checks that Start returns an error, after closing the
+// server's resources, if the address cannot be bound.
+func TestStartListenError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	rec := &recorder{}
+	s := &server{
+		h: &http.Server{Addr: ln.Addr().String()},
+		c: []io.Closer{rec.closer("first")},
+	}
+
+	if err := s.Start(context.Background()); err == nil {
+		t.Fatalf("wanted error listening on a bound address")
+	}
+	if !reflect.DeepEqual(rec.events, []string{"first"}) {
+		t.Fatalf("wanted resources closed, got %v", rec.events)
+	}
+}
+
+// freeAddr returns a local address that is not in use.
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// getWithRetry sends a GET request to url, retrying until the server is up.
+func getWithRetry(url string) (*http.Response, error) {
+	var err error
+	for i := 0; i < 50; i++ {
+		var resp *http.Response
+		if resp, err = http.Get(url); err == nil {
+			return resp, nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil, err
+}
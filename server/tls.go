@@ -0,0 +1,180 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// certCheckInterval is how often the certificate files are checked for
+// changes, at most, when a TLS handshake needs the certificate
+const certCheckInterval = 10 * time.Second
+
+// Cipher policies accepted by newTLSConfig
+const (
+	// CipherPolicyDefault uses the cipher suites chosen by the Go runtime
+	CipherPolicyDefault = "default"
+	// CipherPolicyModern only allows forward secret AEAD cipher suites
+	CipherPolicyModern = "modern"
+)
+
+// modernCipherSuites are the TLS 1.2 cipher suites allowed by the modern
+// policy. TLS 1.3 suites are not configurable, and are all allowed.
+var modernCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// newTLSConfig returns a TLS config serving the certificate and key in the
+// given files, which are reloaded when they change. minVersion is "1.2" or
+// "1.3", and defaults to 1.2 if empty.
+func newTLSConfig(certFile, keyFile, minVersion, cipherPolicy string) (*tls.Config, error) {
+	conf := &tls.Config{}
+
+	switch minVersion {
+	case "", "1.2":
+		conf.MinVersion = tls.VersionTLS12
+	case "1.3":
+		conf.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("invalid minimum TLS version %q, must be 1.2 or 1.3", minVersion)
+	}
+
+	switch cipherPolicy {
+	case "", CipherPolicyDefault:
+	case CipherPolicyModern:
+		conf.CipherSuites = modernCipherSuites
+	default:
+		return nil, fmt.Errorf("invalid cipher policy %q, must be %v or %v", cipherPolicy, CipherPolicyDefault, CipherPolicyModern)
+	}
+
+	certs, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	conf.GetCertificate = certs.GetCertificate
+
+	return conf, nil
+}
+
+// certReloader serves a certificate loaded from files, and loads it again
+// when the files are modified, so that renewed certificates are served
+// without a restart
+type certReloader struct {
+	certFile, keyFile string
+
+	mu   sync.Mutex
+	cert *tls.Certificate
+	// modified is the latest modification time of the files when the
+	// certificate was loaded
+	modified time.Time
+	checked  time.Time
+}
+
+// newCertReloader loads the certificate and key in the given files, and
+// returns an error if they cannot be loaded.
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load reads the certificate and key files.
+func (r *certReloader) load() error {
+	modified, err := r.lastModified()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading TLS certificate: %v", err)
+	}
+
+	r.cert = &cert
+	r.modified = modified
+	return nil
+}
+
+// lastModified returns the latest modification time of the certificate and
+// key files.
+func (r *certReloader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, f := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(f)
+		if err != nil {
+			return latest, fmt.Errorf("reading TLS certificate: %v", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate, returning the current
+// certificate. If the files have changed since they were loaded, the
+// certificate is loaded again. Failing to load a changed certificate is
+// logged, and the previous certificate is served.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checked) < certCheckInterval {
+		return r.cert, nil
+	}
+	r.checked = time.Now()
+
+	modified, err := r.lastModified()
+	if err != nil {
+		slog.Error("failed to check TLS certificate, serving previous certificate", "error", err)
+		return r.cert, nil
+	}
+	if !modified.After(r.modified) {
+		return r.cert, nil
+	}
+
+	if err := r.load(); err != nil {
+		slog.Error("failed to reload TLS certificate, serving previous certificate", "error", err)
+		return r.cert, nil
+	}
+	slog.Info("reloaded TLS certificate", "file", r.certFile)
+
+	return r.cert, nil
+}
+
+// redirectHandler redirects plain HTTP requests to the same host and path
+// served over HTTPS on httpsAddr's port.
+func redirectHandler(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		// permanent redirects that preserve the method and body, except for
+		// reads which older clients handle better as 301
+		status := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			status = http.StatusMovedPermanently
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), status)
+	})
+}
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestNewTLSConfig checks that TLS versions and cipher policies are applied,
+// and that invalid settings are rejected.
+func TestNewTLSConfig(t *testing.T) {
+	certFile, keyFile := writeTestCert(t, t.TempDir(), "first")
+
+	tests := []struct {
+		name         string
+		minVersion   string
+		cipherPolicy string
+		wantVersion  uint16
+		wantSuites   []uint16
+		wantErr      bool
+	}{
+		{
+			name:        "Defaults to TLS 1.2 with default ciphers",
+			wantVersion: tls.VersionTLS12,
+		},
+		{
+			name:         "Modern policy restricts cipher suites",
+			minVersion:   "1.3",
+			cipherPolicy: CipherPolicyModern,
+			wantVersion:  tls.VersionTLS13,
+			wantSuites:   modernCipherSuites,
+		},
+		{
+			name:       "Invalid version is rejected",
+			minVersion: "1.0",
+			wantErr:    true,
+		},
+		{
+			name:         "Invalid cipher policy is rejected",
+			cipherPolicy: "legacy",
+			wantErr:      true,
+		},
+	}
+
+	for _, v := range tests {
+		conf, err := newTLSConfig(certFile, keyFile, v.minVersion, v.cipherPolicy)
+		if v.wantErr {
+			if err == nil {
+				t.Fatalf("%s: Expected error", v.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: Encountered unexpected error: %v", v.name, err)
+		}
+		if conf.MinVersion != v.wantVersion {
+			t.Fatalf("%s: Wanted version %x, got %x", v.name, v.wantVersion, conf.MinVersion)
+		}
+		if len(conf.CipherSuites) != len(v.wantSuites) {
+			t.Fatalf("%s: Wanted cipher suites %v, got %v", v.name, v.wantSuites, conf.CipherSuites)
+		}
+	}
+}
+
+// TestCertReloader checks that a changed certificate is served once the
+// files are modified, and that the previous certificate is kept if the new
+// files cannot be loaded.
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "first")
+
+	r, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	if got := servedName(t, r); got != "first" {
+		t.Fatalf("Wanted certificate %q, got %q", "first", got)
+	}
+
+	// replace the certificate, and allow it to be checked immediately
+	writeTestCert(t, dir, "second")
+	touch(t, time.Now().Add(time.Minute), certFile, keyFile)
+	r.checked = time.Time{}
+	if got := servedName(t, r); got != "second" {
+		t.Fatalf("Wanted reloaded certificate %q, got %q", "second", got)
+	}
+
+	// a broken certificate keeps the previous one
+	if err := os.WriteFile(certFile, []byte("not a certificate"), 0600); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	touch(t, time.Now().Add(2*time.Minute), certFile)
+	r.checked = time.Time{}
+	if got := servedName(t, r); got != "second" {
+		t.Fatalf("Wanted previous certificate %q, got %q", "second", got)
+	}
+}
+
+// TestRedirectHandler checks that plain HTTP requests are redirected to the
+// same host and path over HTTPS.
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		httpsAddr string
+		method    string
+		target    string
+		wantCode  int
+		wantURL   string
+	}{
+		{
+			name:      "Standard port is omitted",
+			httpsAddr: ":443",
+			method:    http.MethodGet,
+			target:    "http://example.com/api/sets/?page=2",
+			wantCode:  http.StatusMovedPermanently,
+			wantURL:   "https://example.com/api/sets/?page=2",
+		},
+		{
+			name:      "Other ports are kept",
+			httpsAddr: ":8443",
+			method:    http.MethodGet,
+			target:    "http://example.com:8080/healthz",
+			wantCode:  http.StatusMovedPermanently,
+			wantURL:   "https://example.com:8443/healthz",
+		},
+		{
+			name:      "Writes keep their method",
+			httpsAddr: ":443",
+			method:    http.MethodPost,
+			target:    "http://example.com/api/login",
+			wantCode:  http.StatusPermanentRedirect,
+			wantURL:   "https://example.com/api/login",
+		},
+	}
+
+	for _, v := range tests {
+		w := httptest.NewRecorder()
+		redirectHandler(v.httpsAddr).ServeHTTP(w, httptest.NewRequest(v.method, v.target, nil))
+
+		if w.Code != v.wantCode {
+			t.Fatalf("%s: Wanted code: %v\nGot code: %v", v.name, v.wantCode, w.Code)
+		}
+		if got := w.Header().Get("Location"); got != v.wantURL {
+			t.Fatalf("%s: Wanted location %q, got %q", v.name, v.wantURL, got)
+		}
+	}
+}
+
+// servedName returns the common name of the certificate served by r.
+func servedName(t *testing.T, r *certReloader) string {
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("Encountered unexpected error: %v", err)
+	}
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	return parsed.Subject.CommonName
+}
+
+// touch sets the modification time of files.
+func touch(t *testing.T, modified time.Time, files ...string) {
+	for _, f := range files {
+		if err := os.Chtimes(f, modified, modified); err != nil {
+			t.Fatalf("failed to touch %v: %v", f, err)
+		}
+	}
+}
+
+// writeTestCert writes a self-signed certificate for commonName, and its key,
+// to cert.pem and key.pem in dir.
+func writeTestCert(t *testing.T, dir, commonName string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	return certFile, keyFile
+}
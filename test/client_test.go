@@ -527,6 +527,23 @@ func DecodeJSON(source io.Reader, target interface{}) error {
 func newHTTPClientWithCookieJar() *http.Client {
 	jar, _ := cookiejar.New(nil)
 	return &http.Client{
-		Jar: jar,
+		Jar:       jar,
+		Transport: &csrfTransport{jar: jar},
 	}
 }
+
+// csrfTransport echoes the CSRF token cookie in the X-CSRF-Token header, as
+// the frontend does, so that cookie authenticated requests are accepted
+type csrfTransport struct {
+	jar http.CookieJar
+}
+
+func (t *csrfTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for _, cookie := range t.jar.Cookies(req.URL) {
+		if cookie.Name == "csrf_token" {
+			req = req.Clone(req.Context())
+			req.Header.Set("X-CSRF-Token", cookie.Value)
+		}
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
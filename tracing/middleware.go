@@ -0,0 +1,99 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrand1005/training-notebook/api/requestid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware starts a server span for each request, continuing any trace
+// propagated in the request headers. The span is named after the matched
+// route template, and the request context carries it to later handlers.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath()
+		name := c.Request.Method
+		if route != "" {
+			name += " " + route
+		}
+
+		ctx, span := Tracer().Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(c.Request.URL.Path),
+				semconv.ClientAddress(c.ClientIP()),
+				attribute.String("request.id", requestid.FromContext(c)),
+			),
+		)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, fmt.Sprintf("status %d", status))
+		}
+	}
+}
+
+// HandlerSpan starts a span around the rest of the handler chain, named after
+// the final handler. It should be the last middleware registered on a group,
+// so that the span covers only the handler itself.
+func HandlerSpan() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, span := Start(c.Request.Context(), handlerSpanName(c.HandlerName()))
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
+
+// MiddlewareSpan starts a span named name for the work done by a middleware
+// before it calls c.Next, and returns a context carrying the span. Call the
+// returned function with any error to end the span.
+func MiddlewareSpan(c *gin.Context, name string, attrs ...attribute.KeyValue) (context.Context, func(error)) {
+	ctx, span := Start(Context(c), name, attrs...)
+	return ctx, func(err error) { End(span, err) }
+}
+
+// Context returns the context of the request being handled, which carries
+// the current span, or a background context if there is no request.
+func Context(c *gin.Context) context.Context {
+	if c.Request == nil {
+		return context.Background()
+	}
+	return c.Request.Context()
+}
+
+// handlerSpanName shortens a handler's function name, such as
+// "github.com/x/api/sets.(*set).Read-fm", to "sets.Read".
+func handlerSpanName(handler string) string {
+	name := handler[strings.LastIndex(handler, "/")+1:]
+	name = strings.TrimSuffix(name, "-fm")
+	if i := strings.Index(name, "."); i >= 0 {
+		pkg, rest := name[:i], name[i+1:]
+		if j := strings.LastIndex(rest, "."); j >= 0 {
+			rest = rest[j+1:]
+		}
+		name = pkg + "." + rest
+	}
+	return name
+}
@@ -0,0 +1,139 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func readSet(c *gin.Context) {
+	c.Status(http.StatusOK)
+}
+
+func failSet(c *gin.Context) {
+	c.Status(http.StatusInternalServerError)
+}
+
+// TestMiddleware checks that requests record a server span named after the
+// route, continue a trace propagated in the traceparent header, and that the
+// handler span is a child of the server span.
+func TestMiddleware(t *testing.T) {
+	const (
+		traceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parentID = "00f067aa0ba902b7"
+	)
+
+	testCases := []struct {
+		name        string
+		path        string
+		traceparent string
+		wantStatus  codes.Code
+		wantHandler string
+	}{
+		{
+			name:        "Propagated trace is continued",
+			path:        "/sets/1",
+			traceparent: "00-" + traceID + "-" + parentID + "-01",
+			wantStatus:  codes.Unset,
+			wantHandler: "tracing.readSet",
+		},
+		{
+			name:        "New trace is started without traceparent",
+			path:        "/sets/1",
+			wantStatus:  codes.Unset,
+			wantHandler: "tracing.readSet",
+		},
+		{
+			name:        "Server errors mark span as failed",
+			path:        "/fail",
+			wantStatus:  codes.Error,
+			wantHandler: "tracing.failSet",
+		},
+	}
+
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	gin.SetMode(gin.TestMode)
+
+	for _, v := range testCases {
+		recorder := tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+		router := gin.New()
+		router.Use(Middleware(), HandlerSpan())
+		router.GET("/sets/:id", readSet)
+		router.GET("/fail", failSet)
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, v.path, nil)
+		if v.traceparent != "" {
+			req.Header.Set("traceparent", v.traceparent)
+		}
+		router.ServeHTTP(w, req)
+
+		spans := recorder.Ended()
+		if len(spans) != 2 {
+			t.Fatalf("%s: wanted 2 spans, got %v", v.name, len(spans))
+		}
+		handler, server := spans[0], spans[1]
+
+		if handler.Name() != v.wantHandler {
+			t.Errorf("%s: wanted handler span %q, got %q", v.name, v.wantHandler, handler.Name())
+		}
+		if handler.Parent().SpanID() != server.SpanContext().SpanID() {
+			t.Errorf("%s: handler span is not a child of the server span", v.name)
+		}
+		if server.SpanKind() != trace.SpanKindServer {
+			t.Errorf("%s: wanted server span kind, got %v", v.name, server.SpanKind())
+		}
+		if server.Status().Code != v.wantStatus {
+			t.Errorf("%s: wanted status %v, got %v", v.name, v.wantStatus, server.Status().Code)
+		}
+
+		if v.traceparent != "" {
+			if server.SpanContext().TraceID().String() != traceID {
+				t.Errorf("%s: wanted trace id %v, got %v", v.name, traceID, server.SpanContext().TraceID())
+			}
+			if server.Parent().SpanID().String() != parentID {
+				t.Errorf("%s: wanted parent span %v, got %v", v.name, parentID, server.Parent().SpanID())
+			}
+		} else if server.Parent().IsValid() {
+			t.Errorf("%s: wanted root span, got parent %v", v.name, server.Parent().SpanID())
+		}
+	}
+}
+
+// TestHandlerSpanName checks that handler function names are shortened to
+// their package and method.
+func TestHandlerSpanName(t *testing.T) {
+	testCases := []struct {
+		handler string
+		want    string
+	}{
+		{
+			handler: "github.com/hrand1005/training-notebook/api/sets.(*set).Read-fm",
+			want:    "sets.Read",
+		},
+		{
+			handler: "github.com/hrand1005/training-notebook/api.Handler",
+			want:    "api.Handler",
+		},
+		{
+			handler: "main.main.func1",
+			want:    "main.func1",
+		},
+	}
+
+	for _, v := range testCases {
+		if got := handlerSpanName(v.handler); got != v.want {
+			t.Errorf("handlerSpanName(%q): wanted %q, got %q", v.handler, v.want, got)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Package tracing configures OpenTelemetry tracing for the server. Incoming
+// requests continue traces propagated with W3C trace context headers, and
+// spans are recorded for middleware, handlers and database calls.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName identifies the tracer used for all spans created by the server
+const InstrumentationName = "github.com/hrand1005/training-notebook"
+
+// Supported exporters
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+	ExporterOTLP   = "otlp"
+)
+
+// Options configures how spans are sampled and exported.
+type Options struct {
+	// Exporter is one of none, stdout, file or otlp. Tracing is disabled if it
+	// is empty or none.
+	Exporter string
+	// File is the path spans are appended to by the file exporter
+	File string
+	// Endpoint is the host:port of the OTLP/HTTP collector
+	Endpoint string
+	// Insecure disables TLS when connecting to the OTLP collector
+	Insecure bool
+	// SampleRatio is the fraction of new traces that are sampled. Traces
+	// continued from a sampled parent are always sampled.
+	SampleRatio float64
+	// ServiceName identifies the server in exported spans
+	ServiceName string
+}
+
+// Setup installs a global tracer provider exporting spans as configured, and
+// the W3C trace context and baggage propagators. The returned function flushes
+// and stops the exporter, and must be called before the process exits.
+func Setup(opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	exporter, closer, err := newExporter(opts)
+	if err != nil {
+		return nil, err
+	}
+	if exporter == nil {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	serviceName := opts.ServiceName
+	if serviceName == "" {
+		serviceName = "training-notebook"
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("building trace resource: %v", err)
+	}
+
+	ratio := opts.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			if cerr := closer.Close(); err == nil {
+				err = cerr
+			}
+		}
+		return err
+	}, nil
+}
+
+// newExporter returns the exporter configured by opts, and a closer for any
+// file it writes to. Returns a nil exporter if tracing is disabled.
+func newExporter(opts Options) (sdktrace.SpanExporter, io.Closer, error) {
+	switch strings.ToLower(opts.Exporter) {
+	case "", ExporterNone:
+		return nil, nil, nil
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		return exporter, nil, err
+	case ExporterFile:
+		if opts.File == "" {
+			return nil, nil, fmt.Errorf("file trace exporter requires a file")
+		}
+		f, err := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("opening trace file: %v", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return exporter, f, nil
+	case ExporterOTLP:
+		clientOpts := []otlptracehttp.Option{}
+		if opts.Endpoint != "" {
+			clientOpts = append(clientOpts, otlptracehttp.WithEndpoint(opts.Endpoint))
+		}
+		if opts.Insecure {
+			clientOpts = append(clientOpts, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(context.Background(), clientOpts...)
+		return exporter, nil, err
+	default:
+		return nil, nil, fmt.Errorf("invalid trace exporter %q, must be one of none, stdout, file or otlp", opts.Exporter)
+	}
+}
+
+// Tracer returns the tracer used for all spans created by the server.
+func Tracer() trace.Tracer {
+	return otel.Tracer(InstrumentationName)
+}
+
+// Start starts a span named name as a child of any span in ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on span, if it is not nil, and ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}